@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"eskalate-movie-api/internal/services"
+)
+
+const usage = `Usage: eskalate-movie-api [command]
+
+Without a command the API server is started. Commands:
+  cleanup [job...]  run the named cleanup jobs, or all of them, once
+  jobs              show the cleanup jobs and their last runs
+`
+
+// isCommand reports whether name is a command, so a mistyped one is
+// rejected before connecting to the database.
+func isCommand(name string) bool {
+	return name == "cleanup" || name == "jobs"
+}
+
+// runCommand runs a command given on the command line instead of the server
+// and returns the exit status.
+func runCommand(jobs services.JobScheduler, args []string) int {
+	switch args[0] {
+	case "cleanup":
+		return runCleanup(jobs, args[1:])
+	case "jobs":
+		return printJobs(jobs)
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return 2
+}
+
+// runCleanup runs the jobs named, or every job. A job another instance is
+// running is skipped rather than counted as failed.
+func runCleanup(jobs services.JobScheduler, names []string) int {
+	if len(names) == 0 {
+		statuses, err := jobs.Status()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot list jobs: %v\n", err)
+			return 1
+		}
+		for _, status := range statuses {
+			names = append(names, status.Name)
+		}
+	}
+	exit := 0
+	for _, name := range names {
+		run, err := jobs.Run(name)
+		switch {
+		case errors.Is(err, services.ErrJobRunning):
+			fmt.Printf("%s: skipped, already running on another instance\n", name)
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "%s: failed: %v\n", name, err)
+			exit = 1
+		default:
+			fmt.Printf("%s: %d rows in %s\n", name, run.Affected, run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond))
+		}
+	}
+	return exit
+}
+
+func printJobs(jobs services.JobScheduler) int {
+	statuses, err := jobs.Status()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot list jobs: %v\n", err)
+		return 1
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tEVERY\tLAST RUN\tSTATUS\tROWS\tINSTANCE")
+	for _, status := range statuses {
+		every := status.Interval
+		if !status.Scheduled {
+			every = "manual"
+		}
+		if status.LastRun == nil {
+			fmt.Fprintf(w, "%s\t%s\tnever\t\t\t\n", status.Name, every)
+			continue
+		}
+		run := status.LastRun
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", status.Name, every, run.StartedAt.Format(time.RFC3339), run.Status, run.Affected, run.Instance)
+	}
+	w.Flush()
+	return 0
+}
@@ -1,34 +1,415 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
 	DatabaseURL         string
-	JWTSecret           string
+	JWTIssuer           string
+	JWTSigningKeyFile   string
+	JWTSigningKeyID     string
+	JWTVerificationKeys string
+	RefreshTokenKey     string
+	MFAIssuer           string
+	MFAEncryptionKey    string
+	AdminEmails         []string
 	CloudinaryCloudName string
 	CloudinaryAPIKey    string
 	CloudinaryAPISecret string
 	Port                string
+	AppBaseURL          string
+	MailDriver          string
+	MailFrom            string
+	MailOutboxDir       string
+	SMTPHost            string
+	SMTPPort            string
+	SMTPUsername        string
+	SMTPPassword        string
+	OIDCProviders       []OIDCProvider
+	// AuthProviders lists, in order, where passwords are checked: database and ldap
+	AuthProviders []string
+	LDAP          LDAP
+	// TokenRevocationStore holds the access token denylist: postgres or memory
+	TokenRevocationStore string
+	// JWTAllowEphemeralKey lets a development server without
+	// JWTSigningKeyFile sign tokens with a key generated at startup
+	JWTAllowEphemeralKey bool
+	// AuthCookies lets browser clients receive their tokens in HttpOnly cookies
+	AuthCookies bool
+	// MagicLinkHourlyLimit caps the sign-in links emailed to one address per hour
+	MagicLinkHourlyLimit int
+	// RegistrationMode controls who may sign up: open, invite-only or closed
+	RegistrationMode string
+	// InvitationsPerUser caps the active invitations a user without
+	// users:manage can hold
+	InvitationsPerUser int
+	// Passkeys are scoped to WebAuthnRPID and may be used from WebAuthnOrigins
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
+
+	// Failed logins before an account or client IP is locked out, and for how long
+	LoginLockoutThreshold   int
+	LoginIPLockoutThreshold int
+	LoginLockoutDuration    time.Duration
+
+	// Argon2id cost parameters for password hashes; memory is in KiB
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+
+	PasswordPolicy PasswordPolicy
+
+	Cleanup Cleanup
+}
+
+// Cleanup is the background deletion of expired and revoked tokens and other
+// stale rows. Every job runs each Interval unless JobIntervals gives it its
+// own, where an interval of 0 leaves the job to be run by hand. Refresh
+// tokens are kept for RefreshTokenRetention after they expire or are
+// revoked, so reuse of a stolen token and returning devices are still
+// recognised for a while.
+type Cleanup struct {
+	Enabled               bool
+	Interval              time.Duration
+	JobIntervals          map[string]time.Duration
+	RefreshTokenRetention time.Duration
+}
+
+// PasswordPolicy is the rules new passwords must follow. History is the
+// number of recent passwords, the current one included, that cannot be
+// reused, and BreachFile a local copy of the Pwned Passwords list: a
+// directory of range files or one file sorted by hash.
+type PasswordPolicy struct {
+	MinLength        int
+	MaxLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	DisallowIdentity bool
+	History          int
+	BreachFile       string
 }
 
-func LoadConfig() *Config {
+// Registration modes.
+const (
+	RegistrationOpen       = "open"
+	RegistrationInviteOnly = "invite-only"
+	RegistrationClosed     = "closed"
+)
+
+// LDAP is the directory password logins can be checked against. Users are
+// found with UserFilter, in which {login} stands for the email they entered,
+// and GroupRoles maps directory groups to roles as role=groupDN pairs
+// separated by semicolons.
+type LDAP struct {
+	URL                  string
+	StartTLS             bool
+	BindDN               string
+	BindPassword         string
+	BaseDN               string
+	UserFilter           string
+	EmailAttribute       string
+	UsernameAttribute    string
+	DisplayNameAttribute string
+	GroupAttribute       string
+	GroupRoles           string
+	Timeout              time.Duration
+}
+
+// OIDCProvider is an OpenID Connect provider users can sign in with. Each
+// name listed in OIDC_PROVIDERS is read from OIDC_<NAME>_* variables.
+type OIDCProvider struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// minSecretKeyLength is the least number of bytes accepted for the keys
+// tokens are hashed and secrets encrypted with.
+const minSecretKeyLength = 32
+
+// LoadConfig reads the configuration from the environment and a .env file.
+// It fails when a required key is missing or too short to be safe.
+func LoadConfig() (*Config, error) {
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
 
-	return &Config{
-		DatabaseURL:         os.Getenv("DATABASE_URL"),
-		JWTSecret:           os.Getenv("JWT_SECRET"),
-		CloudinaryCloudName: os.Getenv("CLOUDINARY_CLOUD_NAME"),
-		CloudinaryAPIKey:    os.Getenv("CLOUDINARY_API_KEY"),
-		CloudinaryAPISecret: os.Getenv("CLOUDINARY_API_SECRET"),
-		Port:                os.Getenv("PORT"),
+	cfg := &Config{
+		DatabaseURL:          os.Getenv("DATABASE_URL"),
+		JWTIssuer:            os.Getenv("JWT_ISSUER"),
+		JWTSigningKeyFile:    os.Getenv("JWT_SIGNING_KEY_FILE"),
+		JWTSigningKeyID:      os.Getenv("JWT_SIGNING_KEY_ID"),
+		JWTVerificationKeys:  os.Getenv("JWT_VERIFICATION_KEYS"),
+		RefreshTokenKey:      os.Getenv("REFRESH_TOKEN_HASH_KEY"),
+		MFAIssuer:            os.Getenv("MFA_ISSUER"),
+		MFAEncryptionKey:     os.Getenv("MFA_ENCRYPTION_KEY"),
+		AdminEmails:          splitList(os.Getenv("ADMIN_EMAILS")),
+		CloudinaryCloudName:  os.Getenv("CLOUDINARY_CLOUD_NAME"),
+		CloudinaryAPIKey:     os.Getenv("CLOUDINARY_API_KEY"),
+		CloudinaryAPISecret:  os.Getenv("CLOUDINARY_API_SECRET"),
+		Port:                 os.Getenv("PORT"),
+		AppBaseURL:           os.Getenv("APP_BASE_URL"),
+		MailDriver:           os.Getenv("MAIL_DRIVER"),
+		MailFrom:             os.Getenv("MAIL_FROM"),
+		MailOutboxDir:        os.Getenv("MAIL_OUTBOX_DIR"),
+		SMTPHost:             os.Getenv("SMTP_HOST"),
+		SMTPPort:             os.Getenv("SMTP_PORT"),
+		SMTPUsername:         os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:         os.Getenv("SMTP_PASSWORD"),
+		TokenRevocationStore: os.Getenv("TOKEN_REVOCATION_STORE"),
+	}
+
+	if cfg.JWTIssuer == "" {
+		cfg.JWTIssuer = "eskalate-movie-api"
+	}
+
+	if cfg.AppBaseURL == "" {
+		cfg.AppBaseURL = "http://localhost:8080"
 	}
+	cfg.AppBaseURL = strings.TrimSuffix(cfg.AppBaseURL, "/")
+	if cfg.MailFrom == "" {
+		cfg.MailFrom = "Eskalate Movie API <no-reply@localhost>"
+	}
+	if cfg.MailOutboxDir == "" {
+		cfg.MailOutboxDir = "outbox"
+	}
+
+	// Stored tokens are hashed, and TOTP secrets encrypted, with keys of their own
+	if err := requireSecretKey("REFRESH_TOKEN_HASH_KEY", cfg.RefreshTokenKey); err != nil {
+		return nil, err
+	}
+	if err := requireSecretKey("MFA_ENCRYPTION_KEY", cfg.MFAEncryptionKey); err != nil {
+		return nil, err
+	}
+	if cfg.MFAIssuer == "" {
+		cfg.MFAIssuer = "Eskalate Movie API"
+	}
+
+	cfg.JWTAllowEphemeralKey = boolEnv("JWT_ALLOW_EPHEMERAL_KEY", false)
+	cfg.AuthCookies = boolEnv("AUTH_COOKIES", false)
+	cfg.MagicLinkHourlyLimit = intEnv("MAGIC_LINK_HOURLY_LIMIT", 5)
+
+	cfg.RegistrationMode = strings.ToLower(stringEnv("REGISTRATION_MODE", RegistrationOpen))
+	switch cfg.RegistrationMode {
+	case RegistrationOpen, RegistrationInviteOnly, RegistrationClosed:
+	default:
+		// Fail closed rather than open the deployment to everyone
+		log.Printf("Invalid REGISTRATION_MODE %q, using %s", cfg.RegistrationMode, RegistrationClosed)
+		cfg.RegistrationMode = RegistrationClosed
+	}
+	cfg.InvitationsPerUser = intEnv("INVITATIONS_PER_USER", 5)
+
+	cfg.WebAuthnRPID = os.Getenv("WEBAUTHN_RP_ID")
+	if cfg.WebAuthnRPID == "" {
+		if u, err := url.Parse(cfg.AppBaseURL); err == nil {
+			cfg.WebAuthnRPID = u.Hostname()
+		}
+	}
+	cfg.WebAuthnRPName = os.Getenv("WEBAUTHN_RP_NAME")
+	if cfg.WebAuthnRPName == "" {
+		cfg.WebAuthnRPName = cfg.MFAIssuer
+	}
+	cfg.WebAuthnOrigins = splitList(os.Getenv("WEBAUTHN_ORIGINS"))
+	if len(cfg.WebAuthnOrigins) == 0 {
+		cfg.WebAuthnOrigins = []string{cfg.AppBaseURL}
+	}
+
+	cfg.OIDCProviders = loadOIDCProviders(splitList(os.Getenv("OIDC_PROVIDERS")))
+
+	cfg.AuthProviders = splitList(strings.ToLower(os.Getenv("AUTH_PROVIDERS")))
+	if len(cfg.AuthProviders) == 0 {
+		cfg.AuthProviders = []string{"database"}
+	}
+	cfg.LDAP = LDAP{
+		URL:                  os.Getenv("LDAP_URL"),
+		StartTLS:             boolEnv("LDAP_START_TLS", false),
+		BindDN:               os.Getenv("LDAP_BIND_DN"),
+		BindPassword:         os.Getenv("LDAP_BIND_PASSWORD"),
+		BaseDN:               os.Getenv("LDAP_BASE_DN"),
+		UserFilter:           stringEnv("LDAP_USER_FILTER", "(mail={login})"),
+		EmailAttribute:       stringEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		UsernameAttribute:    stringEnv("LDAP_USERNAME_ATTRIBUTE", "uid"),
+		DisplayNameAttribute: stringEnv("LDAP_DISPLAY_NAME_ATTRIBUTE", "displayName"),
+		GroupAttribute:       stringEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		GroupRoles:           os.Getenv("LDAP_GROUP_ROLES"),
+		Timeout:              durationEnv("LDAP_TIMEOUT", 10*time.Second),
+	}
+
+	cfg.LoginLockoutThreshold = intEnv("LOGIN_LOCKOUT_THRESHOLD", 10)
+	cfg.LoginIPLockoutThreshold = intEnv("LOGIN_IP_LOCKOUT_THRESHOLD", 50)
+	cfg.LoginLockoutDuration = durationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+
+	cfg.Argon2Memory = uint32(intEnv("PASSWORD_ARGON2_MEMORY_KIB", 64*1024))
+	cfg.Argon2Iterations = uint32(intEnv("PASSWORD_ARGON2_ITERATIONS", 3))
+	cfg.Argon2Parallelism = uint8(min(intEnv("PASSWORD_ARGON2_PARALLELISM", 2), 255))
+
+	cfg.PasswordPolicy = PasswordPolicy{
+		MinLength:        intEnv("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:        intEnv("PASSWORD_MAX_LENGTH", 128),
+		RequireUppercase: boolEnv("PASSWORD_REQUIRE_UPPERCASE", true),
+		RequireLowercase: boolEnv("PASSWORD_REQUIRE_LOWERCASE", true),
+		RequireDigit:     boolEnv("PASSWORD_REQUIRE_DIGIT", false),
+		RequireSymbol:    boolEnv("PASSWORD_REQUIRE_SYMBOL", true),
+		DisallowIdentity: boolEnv("PASSWORD_DISALLOW_IDENTITY", true),
+		History:          countEnv("PASSWORD_HISTORY", 5),
+		BreachFile:       os.Getenv("PASSWORD_BREACH_FILE"),
+	}
+	if cfg.PasswordPolicy.MaxLength < cfg.PasswordPolicy.MinLength {
+		log.Printf("PASSWORD_MAX_LENGTH is below PASSWORD_MIN_LENGTH, using %d", cfg.PasswordPolicy.MinLength)
+		cfg.PasswordPolicy.MaxLength = cfg.PasswordPolicy.MinLength
+	}
+
+	cfg.Cleanup = Cleanup{
+		Enabled:               boolEnv("CLEANUP_ENABLED", true),
+		Interval:              durationEnv("CLEANUP_INTERVAL", time.Hour),
+		JobIntervals:          durationsEnv("CLEANUP_JOB_INTERVALS"),
+		RefreshTokenRetention: durationEnv("REFRESH_TOKEN_RETENTION", 30*24*time.Hour),
+	}
+
+	return cfg, nil
 }
 
+// requireSecretKey checks that the key read from the named variable is set
+// and at least minSecretKeyLength bytes long.
+func requireSecretKey(name, key string) error {
+	if len(key) < minSecretKeyLength {
+		return fmt.Errorf("%s must be set to at least %d bytes", name, minSecretKeyLength)
+	}
+	return nil
+}
+
+// stringEnv reads a string, falling back to def when unset.
+func stringEnv(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// intEnv reads a positive integer, falling back to def when unset or invalid.
+func intEnv(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid %s %q, using %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// countEnv reads a non-negative integer, falling back to def when unset or
+// invalid.
+func countEnv(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s %q, using %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// boolEnv reads a boolean such as "true" or "0", falling back to def when
+// unset or invalid.
+func boolEnv(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %t", key, value, def)
+		return def
+	}
+	return b
+}
+
+// durationEnv reads a positive duration such as "15m", falling back to def
+// when unset or invalid.
+func durationEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s", key, value, def)
+		return def
+	}
+	return d
+}
+
+// durationsEnv reads comma separated name=duration pairs such as
+// "refresh_tokens=6h,action_tokens=0". A duration of 0 is allowed; invalid
+// pairs are skipped with a warning.
+func durationsEnv(key string) map[string]time.Duration {
+	out := map[string]time.Duration{}
+	for _, pair := range splitList(os.Getenv(key)) {
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || name == "" || err != nil || d < 0 {
+			log.Printf("Invalid %s entry %q, skipping", key, pair)
+			continue
+		}
+		out[name] = d
+	}
+	return out
+}
+
+// loadOIDCProviders reads the settings of each named provider. Providers
+// without an issuer or client ID are skipped with a warning.
+func loadOIDCProviders(names []string) []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range names {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		p := OIDCProvider{
+			Name:         name,
+			DisplayName:  os.Getenv(prefix + "DISPLAY_NAME"),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if p.Issuer == "" || p.ClientID == "" {
+			log.Printf("OIDC provider %q is missing %sISSUER or %sCLIENT_ID, skipping", name, prefix, prefix)
+			continue
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
+
+// splitList parses a comma separated environment value, dropping empty entries.
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
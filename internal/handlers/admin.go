@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAdminRoutes registers administration endpoints on a group that
+// already requires the users:manage permission
+func RegisterAdminRoutes(rg *gin.RouterGroup, throttle services.LoginThrottle, adminUsers services.AdminUserService, invitations services.InvitationService, auditLog services.AuditLogService, jobs services.JobScheduler) {
+	rg.GET("/lockouts", ListLockouts(throttle))
+	rg.DELETE("/lockouts/:kind/:subject", ClearLockout(throttle))
+
+	rg.GET("/users", ListUsers(adminUsers))
+	rg.GET("/users/:id", GetUser(adminUsers))
+	rg.POST("/users/:id/disable", SetUserDisabled(adminUsers, true))
+	rg.POST("/users/:id/enable", SetUserDisabled(adminUsers, false))
+	rg.POST("/users/:id/logout", ForceLogout(adminUsers))
+	rg.DELETE("/users/:id/mfa", ResetUserMFA(adminUsers))
+	rg.PUT("/users/:id/role", SetUserRole(adminUsers))
+	rg.GET("/users/:id/audit", UserAuditTrail(adminUsers))
+
+	rg.GET("/invitations", ListAllInvitations(invitations))
+
+	rg.GET("/audit", ListAuditLog(auditLog))
+	rg.GET("/audit/export", ExportAuditLog(auditLog))
+
+	rg.GET("/jobs", ListJobs(jobs))
+	rg.POST("/jobs/:name/run", RunJob(jobs))
+}
+
+// ListLockouts godoc
+// @Summary      List failed-login lockouts
+// @Description  List accounts (by email) and client IP addresses with recent failed logins. Requires users:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        kind query string false "account or ip"
+// @Param        locked query bool false "Only subjects currently locked out or backed off"
+// @Success      200 {object} BaseResponse{object=[]models.LoginThrottle}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/lockouts [get]
+func ListLockouts(throttle services.LoginThrottle) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind := c.Query("kind")
+		if kind != "" && kind != models.ThrottleAccount && kind != models.ThrottleIP {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid kind",
+				Errors:  []string{"kind must be account or ip"},
+			})
+			return
+		}
+		lockouts, err := throttle.List(kind, c.Query("locked") == "true")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{
+				Success: false,
+				Message: "Failed to retrieve lockouts",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Lockouts retrieved successfully",
+			Object:  lockouts,
+		})
+	}
+}
+
+// ClearLockout godoc
+// @Summary      Clear a lockout
+// @Description  Forget the failed logins of an account (by email) or client IP address, lifting any lockout. Requires users:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        kind path string true "account or ip"
+// @Param        subject path string true "Email address or IP address"
+// @Success      200 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/lockouts/{kind}/{subject} [delete]
+func ClearLockout(throttle services.LoginThrottle) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := throttle.Clear(c.Param("kind"), c.Param("subject")); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrThrottleNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, BaseResponse{
+				Success: false,
+				Message: "Failed to clear lockout",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Lockout cleared successfully",
+		})
+	}
+}
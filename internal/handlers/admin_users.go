@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/repository"
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// ListUsers godoc
+// @Summary      List users
+// @Description  Get a paginated list of users, newest first. Requires users:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        q query string false "Substring of the email, username or display name"
+// @Param        role query string false "member, curator or admin"
+// @Param        disabled query bool false "Only disabled (true) or enabled (false) accounts"
+// @Param        pageNumber query int false "Page number"
+// @Param        pageSize query int false "Page size"
+// @Success      200 {object} PaginatedResponse{object=[]models.User}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/users [get]
+func ListUsers(adminUsers services.AdminUserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := repository.UserFilter{Query: c.Query("q"), Role: c.Query("role")}
+		if filter.Role != "" && !models.ValidRole(filter.Role) {
+			c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid role", Errors: []string{services.ErrInvalidRole.Error()}})
+			return
+		}
+		if d := c.Query("disabled"); d != "" {
+			disabled, err := strconv.ParseBool(d)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid disabled filter", Errors: []string{err.Error()}})
+				return
+			}
+			filter.Disabled = &disabled
+		}
+		pageNumber, pageSize := pagination(c)
+		users, total, err := adminUsers.List(filter, pageNumber, pageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, PaginatedResponse{Success: false, Message: "Failed to fetch users", Errors: []string{err.Error()}})
+			return
+		}
+		c.JSON(http.StatusOK, PaginatedResponse{
+			Success:    true,
+			Message:    "Users fetched",
+			Object:     users,
+			PageNumber: pageNumber,
+			PageSize:   pageSize,
+			TotalSize:  total,
+		})
+	}
+}
+
+// GetUser godoc
+// @Summary      Get a user
+// @Description  Get an account with its permissions, session and token counts and linked identities. Requires users:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Success      200 {object} BaseResponse{object=services.UserDetails}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/users/{id} [get]
+func GetUser(adminUsers services.AdminUserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		details, err := adminUsers.Get(userID)
+		if err != nil {
+			respondAdminError(c, "Failed to retrieve user", err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{Success: true, Message: "User retrieved successfully", Object: details})
+	}
+}
+
+// SetUserDisabled godoc
+// @Summary      Disable or enable a user
+// @Description  Disabling an account blocks sign-in, ends every session and revokes its personal access tokens. Enabling lets the user sign in again. Requires users:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Success      200 {object} BaseResponse{object=models.User}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/users/{id}/disable [post]
+// @Router       /api/admin/users/{id}/enable [post]
+func SetUserDisabled(adminUsers services.AdminUserService, disabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		user, err := adminUsers.SetDisabled(actor(principal), clientInfo(c), userID, disabled)
+		if err != nil {
+			respondAdminError(c, "Failed to update user", err)
+			return
+		}
+		message := "User enabled successfully"
+		if disabled {
+			message = "User disabled successfully"
+		}
+		c.JSON(http.StatusOK, BaseResponse{Success: true, Message: message, Object: user})
+	}
+}
+
+// ForceLogout godoc
+// @Summary      Sign a user out everywhere
+// @Description  End every session of the user. Requires users:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/users/{id}/logout [post]
+func ForceLogout(adminUsers services.AdminUserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		ended, err := adminUsers.ForceLogout(actor(principal), clientInfo(c), userID)
+		if err != nil {
+			respondAdminError(c, "Failed to sign the user out", err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{Success: true, Message: fmt.Sprintf("%d sessions ended", ended)})
+	}
+}
+
+// ResetUserMFA godoc
+// @Summary      Reset a user's two-factor authentication
+// @Description  Turn off TOTP and delete the recovery codes of a user who lost access to them. Requires users:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/users/{id}/mfa [delete]
+func ResetUserMFA(adminUsers services.AdminUserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		if err := adminUsers.ResetMFA(actor(principal), clientInfo(c), userID); err != nil {
+			respondAdminError(c, "Failed to reset two-factor authentication", err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{Success: true, Message: "Two-factor authentication reset successfully"})
+	}
+}
+
+// SetUserRole godoc
+// @Summary      Change a user's role
+// @Description  Set the role of a user. A promotion applies from the user's next token refresh; a demotion signs the user out of every session at once. Requires users:manage.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        setRoleRequest body SetRoleRequest true "member, curator or admin"
+// @Success      200 {object} BaseResponse{object=models.User}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/users/{id}/role [put]
+func SetUserRole(adminUsers services.AdminUserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		var req SetRoleRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		user, err := adminUsers.SetRole(actor(principal), clientInfo(c), userID, req.Role)
+		if err != nil {
+			respondAdminError(c, "Failed to change role", err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{Success: true, Message: "Role changed successfully", Object: user})
+	}
+}
+
+// UserAuditTrail godoc
+// @Summary      Get a user's audit trail
+// @Description  Get the events recorded on an account, newest first: its signup, logins, refreshes, logouts and password changes, and the administrative actions taken on it. Requires users:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        pageNumber query int false "Page number"
+// @Param        pageSize query int false "Page size"
+// @Success      200 {object} PaginatedResponse{object=[]models.AuditEvent}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/users/{id}/audit [get]
+func UserAuditTrail(adminUsers services.AdminUserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		pageNumber, pageSize := pagination(c)
+		events, total, err := adminUsers.AuditTrail(userID, pageNumber, pageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, PaginatedResponse{Success: false, Message: "Failed to fetch audit trail", Errors: []string{err.Error()}})
+			return
+		}
+		c.JSON(http.StatusOK, PaginatedResponse{
+			Success:    true,
+			Message:    "Audit trail fetched",
+			Object:     events,
+			PageNumber: pageNumber,
+			PageSize:   pageSize,
+			TotalSize:  total,
+		})
+	}
+}
+
+// pagination reads the pageNumber and pageSize query parameters, keeping
+// them within sensible bounds.
+func pagination(c *gin.Context) (int, int) {
+	pageNumber, err := strconv.Atoi(c.Query("pageNumber"))
+	if err != nil || pageNumber < 1 {
+		pageNumber = 1
+	}
+	pageSize, err := strconv.Atoi(c.Query("pageSize"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+	return pageNumber, min(pageSize, 100)
+}
+
+// userIDParam parses the :id path parameter, writing a 400 when it is not a UUID.
+func userIDParam(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid user ID", Errors: []string{err.Error()}})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// respondAdminError maps user management errors to status codes.
+func respondAdminError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrCannotModifySelf):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrMFANotEnabled):
+		status = http.StatusConflict
+	}
+	c.JSON(status, BaseResponse{Success: false, Message: message, Errors: []string{err.Error()}})
+}
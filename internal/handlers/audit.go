@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Audit log export formats.
+const (
+	auditFormatCSV    = "csv"
+	auditFormatNDJSON = "ndjson"
+)
+
+var auditCSVHeader = []string{"id", "createdAt", "action", "outcome", "actorId", "targetId", "ipAddress", "userAgent", "details"}
+
+// ListAuditLog godoc
+// @Summary      Query the audit log
+// @Description  Get a paginated list of audit events, newest first: signups, logins (successful or not), token refreshes, logouts, password changes and resets, and administrative actions such as role changes. Requires users:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        actorId query string false "User who acted"
+// @Param        targetId query string false "Account acted on"
+// @Param        action query string false "Action, e.g. auth.login or user.role_change"
+// @Param        outcome query string false "success or failure"
+// @Param        ip query string false "Client IP address"
+// @Param        since query string false "Only events at or after this RFC 3339 time"
+// @Param        until query string false "Only events before this RFC 3339 time"
+// @Param        pageNumber query int false "Page number"
+// @Param        pageSize query int false "Page size"
+// @Success      200 {object} PaginatedResponse{object=[]models.AuditEvent}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/audit [get]
+func ListAuditLog(auditLog services.AuditLogService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, ok := auditFilter(c)
+		if !ok {
+			return
+		}
+		pageNumber, pageSize := pagination(c)
+		events, total, err := auditLog.Query(filter, pageNumber, pageSize)
+		if err != nil {
+			respondAuditError(c, "Failed to fetch audit log", err)
+			return
+		}
+		c.JSON(http.StatusOK, PaginatedResponse{
+			Success:    true,
+			Message:    "Audit log fetched",
+			Object:     events,
+			PageNumber: pageNumber,
+			PageSize:   pageSize,
+			TotalSize:  total,
+		})
+	}
+}
+
+// ExportAuditLog godoc
+// @Summary      Export the audit log
+// @Description  Download every audit event matching the filters, oldest first, as CSV or newline-delimited JSON. The export is itself recorded in the audit log. Requires users:manage.
+// @Tags         admin
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        format query string false "csv (default) or ndjson"
+// @Param        actorId query string false "User who acted"
+// @Param        targetId query string false "Account acted on"
+// @Param        action query string false "Action, e.g. auth.login or user.role_change"
+// @Param        outcome query string false "success or failure"
+// @Param        ip query string false "Client IP address"
+// @Param        since query string false "Only events at or after this RFC 3339 time"
+// @Param        until query string false "Only events before this RFC 3339 time"
+// @Success      200 {file} file
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/audit/export [get]
+func ExportAuditLog(auditLog services.AuditLogService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		format := c.DefaultQuery("format", auditFormatCSV)
+		if format != auditFormatCSV && format != auditFormatNDJSON {
+			c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid format", Errors: []string{"format must be csv or ndjson"}})
+			return
+		}
+		filter, ok := auditFilter(c)
+		if !ok {
+			return
+		}
+
+		// The response starts with the first event, so a query that fails
+		// straight away can still be answered with an error
+		started := false
+		var csvWriter *csv.Writer
+		begin := func() error {
+			started = true
+			contentType := "text/csv; charset=utf-8"
+			if format == auditFormatNDJSON {
+				contentType = "application/x-ndjson"
+			}
+			c.Header("Content-Type", contentType)
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-log-%s.%s"`, time.Now().UTC().Format("20060102T150405Z"), format))
+			c.Status(http.StatusOK)
+			if format == auditFormatCSV {
+				csvWriter = csv.NewWriter(c.Writer)
+				return csvWriter.Write(auditCSVHeader)
+			}
+			return nil
+		}
+		encoder := json.NewEncoder(c.Writer)
+		write := func(event *models.AuditEvent) error {
+			if !started {
+				if err := begin(); err != nil {
+					return err
+				}
+			}
+			if format == auditFormatNDJSON {
+				return encoder.Encode(event)
+			}
+			return csvWriter.Write(auditCSVRecord(event))
+		}
+
+		err := auditLog.Export(actor(principal), clientInfo(c), filter, format, write)
+		if err != nil && !started {
+			respondAuditError(c, "Failed to export audit log", err)
+			return
+		}
+		if err == nil && !started {
+			err = begin()
+		}
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if err == nil {
+				err = csvWriter.Error()
+			}
+		}
+		if err != nil {
+			// Too late for an error response; the download is cut short
+			logrus.WithFields(logrus.Fields{
+				"event": "audit_export_error",
+				"error": err.Error(),
+			}).Error("audit log export failed")
+		}
+	}
+}
+
+func auditCSVRecord(event *models.AuditEvent) []string {
+	optionalID := func(id *uuid.UUID) string {
+		if id == nil {
+			return ""
+		}
+		return id.String()
+	}
+	return []string{
+		event.ID.String(),
+		event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		event.Action,
+		event.Outcome,
+		optionalID(event.ActorID),
+		optionalID(event.TargetID),
+		event.IPAddress,
+		csvSafe(event.UserAgent),
+		csvSafe(event.Details),
+	}
+}
+
+// csvSafe stops client-supplied text from being run as a formula when the
+// export is opened in a spreadsheet.
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// auditFilter reads the audit log filters from the query string, writing a
+// 400 when one is malformed.
+func auditFilter(c *gin.Context) (services.AuditFilter, bool) {
+	filter := services.AuditFilter{
+		Action:    c.Query("action"),
+		Outcome:   c.Query("outcome"),
+		IPAddress: c.Query("ip"),
+	}
+	ids := map[string]**uuid.UUID{"actorId": &filter.ActorID, "targetId": &filter.TargetID}
+	for name, dst := range ids {
+		if v := c.Query(name); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid " + name, Errors: []string{err.Error()}})
+				return filter, false
+			}
+			*dst = &id
+		}
+	}
+	times := map[string]**time.Time{"since": &filter.Since, "until": &filter.Until}
+	for name, dst := range times {
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid " + name, Errors: []string{err.Error()}})
+				return filter, false
+			}
+			*dst = &t
+		}
+	}
+	return filter, true
+}
+
+// respondAuditError maps audit log errors to status codes.
+func respondAuditError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, services.ErrInvalidAuditFilter) {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, BaseResponse{Success: false, Message: message, Errors: []string{err.Error()}})
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 
 	"eskalate-movie-api/internal/config"
 	"eskalate-movie-api/internal/models"
@@ -14,7 +18,9 @@ import (
 type SignupRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Username string `json:"username" binding:"required,alphanum,min=3,max=20"`
-	Password string `json:"password" binding:"required,min=8,password"`
+	Password string `json:"password" binding:"required"`
+	// InvitationCode is required unless registration is open
+	InvitationCode string `json:"invitationCode,omitempty"`
 }
 
 type LoginRequest struct {
@@ -27,58 +33,94 @@ type TokenResponse struct {
 	RefreshToken string `json:"refreshToken"`
 }
 
+// MFAChallengeResponse is returned by login instead of tokens when the user
+// has two-factor authentication enabled. Methods lists the second factors
+// the challenge can be completed with: totp and passkey.
+type MFAChallengeResponse struct {
+	MFARequired bool     `json:"mfaRequired"`
+	MFAToken    string   `json:"mfaToken"`
+	Methods     []string `json:"methods"`
+}
+
+// RefreshRequest carries the refresh token. Browser sessions using cookies
+// leave it empty and send the refresh token cookie and CSRF header instead.
 type RefreshRequest struct {
-	RefreshToken string `json:"refreshToken" binding:"required"`
+	RefreshToken string `json:"refreshToken"`
 }
 
 type LogoutRequest struct {
-	RefreshToken string `json:"refreshToken" binding:"required"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
 }
 
-// RegisterAuthRoutes registers auth endpoints
-func RegisterAuthRoutes(rg *gin.RouterGroup, authService services.AuthService, cfg *config.Config) {
+// RegisterAuthRoutes registers auth endpoints: credential exchanges on the
+// public group and account security settings on the authenticated group
+func RegisterAuthRoutes(rg, authenticated *gin.RouterGroup, authService services.AuthService, cfg *config.Config) {
 	rg.POST("/signup", Signup(authService, cfg))
 	rg.POST("/login", Login(authService, cfg))
+	rg.POST("/login/mfa", LoginMFA(authService, cfg))
+	rg.POST("/login/mfa/passkey/begin", BeginPasskeyMFA(authService))
+	rg.POST("/login/mfa/passkey", LoginMFAPasskey(authService, cfg))
 	rg.POST("/refresh", RefreshToken(authService, cfg))
-	rg.POST("/logout", Logout(authService))
+	rg.POST("/logout", Logout(authService, cfg))
+	rg.POST("/verify-email", VerifyEmail(authService))
+	rg.POST("/verify-email/resend", ResendVerification(authService))
+	rg.POST("/confirm-email", ConfirmEmailChange(authService))
+	rg.POST("/forgot-password", ForgotPassword(authService))
+	rg.POST("/reset-password", ResetPassword(authService))
+	rg.GET("/password-policy", GetPasswordPolicy(authService))
+	rg.POST("/magic-link", RequestMagicLink(authService, cfg))
+	rg.POST("/magic-link/exchange", MagicLinkLogin(authService, cfg))
 
-	// Debug endpoint - REMOVE IN PRODUCTION
-	rg.GET("/debug/:email", func(c *gin.Context) {
-		email := c.Param("email")
-		user, err := authService.GetUserByEmail(email)
-		if err != nil {
-			c.JSON(http.StatusNotFound, BaseResponse{
-				Success: false,
-				Message: "User not found",
-				Errors:  []string{err.Error()},
-			})
-			return
-		}
-		c.JSON(http.StatusOK, BaseResponse{
-			Success: true,
-			Message: "User found",
-			Object: gin.H{
-				"id":            user.ID,
-				"email":         user.Email,
-				"username":      user.Username,
-				"password_hash": user.Password,
-				"password_len":  len(user.Password),
-				"created_at":    user.CreatedAt,
-				"updated_at":    user.UpdatedAt,
-			},
-		})
-	})
+	authenticated.GET("/sessions", ListSessions(authService))
+	authenticated.DELETE("/sessions", RevokeAllSessions(authService))
+	authenticated.DELETE("/sessions/:id", RevokeSession(authService))
+
+	authenticated.POST("/mfa/totp/setup", SetupTOTP(authService))
+	authenticated.POST("/mfa/totp/enable", EnableTOTP(authService))
+	authenticated.POST("/mfa/totp/disable", DisableTOTP(authService))
+
+	rg.POST("/passkeys/login/begin", BeginPasskeyLogin(authService))
+	rg.POST("/passkeys/login/finish", FinishPasskeyLogin(authService, cfg))
+	authenticated.POST("/passkeys/register/begin", BeginPasskeyRegistration(authService))
+	authenticated.POST("/passkeys/register/finish", FinishPasskeyRegistration(authService))
+	authenticated.GET("/passkeys", ListPasskeys(authService))
+	authenticated.DELETE("/passkeys/:id", DeletePasskey(authService))
+
+	rg.GET("/oidc/providers", ListOIDCProviders(authService))
+	rg.GET("/oidc/:provider/login", OIDCLogin(authService, cfg))
+	rg.GET("/oidc/:provider/callback", OIDCCallback(authService, cfg))
+	authenticated.POST("/oidc/:provider/link", LinkOIDCIdentity(authService, cfg))
+	authenticated.GET("/identities", ListIdentities(authService))
+	authenticated.DELETE("/identities/:id", UnlinkIdentity(authService))
 }
 
 // Signup godoc
 // @Summary      Register a new user
-// @Description  Register a new user with email, username, and password
+// @Description  Register a new user with email, username, and password. While registration is invite-only an invitation code is required, and the invitation's role is granted; while it is closed signups are refused. The password must follow the password policy at /api/auth/password-policy; each broken rule is listed in errors.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        signupRequest body SignupRequest true "Signup request"
 // @Success      201 {object} BaseResponse
 // @Failure      400 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
 // @Router       /api/auth/signup [post]
 func Signup(authService services.AuthService, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -121,24 +163,36 @@ func Signup(authService services.AuthService, cfg *config.Config) gin.HandlerFun
 			Username: req.Username,
 			Password: req.Password,
 		}
-		if err := authService.Signup(user); err != nil {
-			c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: err.Error(), Errors: []string{err.Error()}})
+		if err := authService.Signup(user, req.InvitationCode, clientInfo(c)); err != nil {
+			if respondPasswordRejected(c, err) {
+				return
+			}
+			status := http.StatusBadRequest
+			if errors.Is(err, services.ErrRegistrationClosed) || errors.Is(err, services.ErrInvitationRequired) {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, BaseResponse{Success: false, Message: err.Error(), Errors: []string{err.Error()}})
 			return
 		}
 		user.Password = ""
-		c.JSON(http.StatusCreated, BaseResponse{Success: true, Message: "Signup successful", Object: user})
+		c.JSON(http.StatusCreated, BaseResponse{Success: true, Message: "Signup successful, check your email to verify your address", Object: user})
 	}
 }
 
 // Login godoc
 // @Summary      Login a user
-// @Description  Login with email and password
+// @Description  Login with email and password, checked against the database and, when configured, the LDAP directory. Repeated failures for an email or from an IP address are answered with 429 and a Retry-After header, with waits doubling up to a temporary lockout. Users with two-factor authentication get an MFA challenge token instead of tokens, to be completed at /api/auth/login/mfa.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        loginRequest body LoginRequest true "Login request"
-// @Success      200 {object} BaseResponse
+// @Param        X-Auth-Mode header string false "Set to cookie to receive the tokens in HttpOnly cookies (when AUTH_COOKIES is enabled)"
+// @Success      200 {object} BaseResponse{object=TokenResponse}
+// @Success      202 {object} BaseResponse{object=MFAChallengeResponse}
 // @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      429 {object} BaseResponse
+// @Failure      503 {object} BaseResponse
 // @Router       /api/auth/login [post]
 func Login(authService services.AuthService, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -188,39 +242,55 @@ func Login(authService services.AuthService, cfg *config.Config) gin.HandlerFunc
 			return
 		}
 
-		accessToken, refreshToken, err := authService.LoginWithRefresh(req.Email, req.Password, cfg.JWTSecret)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, BaseResponse{
+		result, err := authService.LoginWithRefresh(req.Email, req.Password, clientInfo(c))
+		if respondThrottled(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrAccountDisabled) {
+			respondForbidden(c, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrAuthProviderUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, BaseResponse{
 				Success: false,
-				Message: "Login failed",
-				Errors:  []string{"Invalid email or password"},
+				Message: err.Error(),
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		if err != nil {
+			respondUnauthorized(c, "Invalid email or password")
+			return
+		}
+		if result.MFARequired() {
+			c.JSON(http.StatusAccepted, BaseResponse{
+				Success: true,
+				Message: "Two-factor authentication required",
+				Object:  MFAChallengeResponse{MFARequired: true, MFAToken: result.MFAToken, Methods: result.MFAMethods},
 			})
 			return
 		}
 
-		c.JSON(http.StatusOK, BaseResponse{
-			Success: true,
-			Message: "Login successful",
-			Object:  TokenResponse{AccessToken: accessToken, RefreshToken: refreshToken},
-		})
+		respondTokens(c, cfg, wantsCookies(c, cfg), "Login successful", result.AccessToken, result.RefreshToken)
 	}
 }
 
 // RefreshToken godoc
 // @Summary      Refresh access token
-// @Description  Get a new access token using a valid refresh token
+// @Description  Exchange a refresh token for a new access/refresh pair. The presented refresh token is consumed; presenting it again revokes every token from the same login. Browser sessions using cookies send an empty body with the X-CSRF-Token header and get new cookies back.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
-// @Security     BearerAuth
-// @Param        refreshRequest body RefreshRequest true "Refresh token request"
-// @Success      200 {object} BaseResponse
+// @Param        refreshRequest body RefreshRequest false "Refresh token request"
+// @Param        X-CSRF-Token header string false "CSRF token, required when the refresh token cookie is used"
+// @Success      200 {object} BaseResponse{object=TokenResponse}
 // @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
 // @Router       /api/auth/refresh [post]
 func RefreshToken(authService services.AuthService, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req RefreshRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
 			c.JSON(http.StatusBadRequest, BaseResponse{
 				Success: false,
 				Message: "Invalid input format",
@@ -228,37 +298,80 @@ func RefreshToken(authService services.AuthService, cfg *config.Config) gin.Hand
 			})
 			return
 		}
-		accessToken, err := authService.RefreshAccessToken(req.RefreshToken, cfg.JWTSecret)
+		fromCookie := req.RefreshToken == ""
+		if fromCookie {
+			var ok bool
+			if req.RefreshToken, ok = cookieRefreshToken(c); !ok {
+				return
+			}
+		}
+		accessToken, refreshToken, err := authService.RefreshAccessToken(req.RefreshToken, clientInfo(c))
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, BaseResponse{
+			respondUnauthorized(c, err.Error())
+			return
+		}
+		respondTokens(c, cfg, fromCookie, "Token refreshed successfully", accessToken, refreshToken)
+	}
+}
+
+// Logout godoc
+// @Summary      Logout (revoke refresh token)
+// @Description  Revoke a refresh token, ending the session it belongs to. Access tokens issued to the session are revoked too. Browser sessions using cookies send an empty body with the X-CSRF-Token header; their cookies are cleared.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        logoutRequest body LogoutRequest false "Logout request"
+// @Param        X-CSRF-Token header string false "CSRF token, required when the refresh token cookie is used"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Router       /api/auth/logout [post]
+func Logout(authService services.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LogoutRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, BaseResponse{
 				Success: false,
-				Message: "Invalid or expired refresh token",
+				Message: "Invalid input format",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		if req.RefreshToken == "" {
+			var ok bool
+			if req.RefreshToken, ok = cookieRefreshToken(c); !ok {
+				return
+			}
+			clearSessionCookies(c, cfg)
+		}
+		if err := authService.RevokeRefreshToken(req.RefreshToken, clientInfo(c)); err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Failed to revoke refresh token",
 				Errors:  []string{err.Error()},
 			})
 			return
 		}
 		c.JSON(http.StatusOK, BaseResponse{
 			Success: true,
-			Message: "Token refreshed successfully",
-			Object:  gin.H{"accessToken": accessToken},
+			Message: "Logged out successfully",
 		})
 	}
 }
 
-// Logout godoc
-// @Summary      Logout (revoke refresh token)
-// @Description  Revoke a refresh token (logout)
+// VerifyEmail godoc
+// @Summary      Verify email address
+// @Description  Confirm an email address with the token from the verification email. Tokens are single-use and expire after 24 hours.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
-// @Security     BearerAuth
-// @Param        logoutRequest body LogoutRequest true "Logout request"
+// @Param        verifyEmailRequest body VerifyEmailRequest true "Verify email request"
 // @Success      200 {object} BaseResponse
 // @Failure      400 {object} BaseResponse
-// @Router       /api/auth/logout [post]
-func Logout(authService services.AuthService) gin.HandlerFunc {
+// @Router       /api/auth/verify-email [post]
+func VerifyEmail(authService services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req LogoutRequest
+		var req VerifyEmailRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, BaseResponse{
 				Success: false,
@@ -267,17 +380,214 @@ func Logout(authService services.AuthService) gin.HandlerFunc {
 			})
 			return
 		}
-		if err := authService.RevokeRefreshToken(req.RefreshToken); err != nil {
+		if err := authService.VerifyEmail(req.Token); err != nil {
 			c.JSON(http.StatusBadRequest, BaseResponse{
 				Success: false,
-				Message: "Failed to revoke refresh token",
+				Message: "Email verification failed",
 				Errors:  []string{err.Error()},
 			})
 			return
 		}
 		c.JSON(http.StatusOK, BaseResponse{
 			Success: true,
-			Message: "Logged out successfully",
+			Message: "Email verified successfully",
+		})
+	}
+}
+
+// ConfirmEmailChange godoc
+// @Summary      Confirm an email change
+// @Description  Switch the account to its new email address with the token sent there by /api/users/me/email. Tokens are single-use and expire after 24 hours.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        verifyEmailRequest body VerifyEmailRequest true "Token from the confirmation email"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Router       /api/auth/confirm-email [post]
+func ConfirmEmailChange(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req VerifyEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid input format",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		if err := authService.ConfirmEmailChange(req.Token); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, services.ErrEmailTaken) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, BaseResponse{
+				Success: false,
+				Message: "Email change failed",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Email address changed successfully",
+		})
+	}
+}
+
+// ResendVerification godoc
+// @Summary      Resend verification email
+// @Description  Send a new verification link. Earlier links stop working. The response is the same whether or not the address belongs to an unverified account.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        resendVerificationRequest body ResendVerificationRequest true "Resend verification request"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Router       /api/auth/verify-email/resend [post]
+func ResendVerification(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ResendVerificationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid input format",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		if err := authService.ResendVerificationEmail(req.Email); err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{
+				Success: false,
+				Message: "Failed to send verification email",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "If the address belongs to an unverified account, a new verification email has been sent",
+		})
+	}
+}
+
+// respondThrottled writes a 429 with a Retry-After header when err is a
+// *services.ThrottledError, and reports whether it did.
+func respondThrottled(c *gin.Context, err error) bool {
+	var throttled *services.ThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, BaseResponse{
+		Success: false,
+		Message: "Too many failed login attempts",
+		Errors:  []string{throttled.Error()},
+	})
+	return true
+}
+
+// ForgotPassword godoc
+// @Summary      Request a password reset
+// @Description  Email a single-use password reset link valid for 1 hour. The response is the same whether or not the address belongs to an account.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        forgotPasswordRequest body ForgotPasswordRequest true "Forgot password request"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Router       /api/auth/forgot-password [post]
+func ForgotPassword(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ForgotPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid input format",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		if err := authService.RequestPasswordReset(req.Email); err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{
+				Success: false,
+				Message: "Failed to process the password reset request",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "If the address belongs to an account, a password reset email has been sent",
+		})
+	}
+}
+
+// ResetPassword godoc
+// @Summary      Reset password
+// @Description  Set a new password with the token from a password reset email. The password must follow the password policy, and a rejected password leaves the token usable. Every session of the user is signed out.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        resetPasswordRequest body ResetPasswordRequest true "Reset password request"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Router       /api/auth/reset-password [post]
+func ResetPassword(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ResetPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				errs := make([]string, len(validationErrors))
+				for i, err := range validationErrors {
+					errs[i] = getValidationErrorMsg(err)
+				}
+				c.JSON(http.StatusBadRequest, BaseResponse{
+					Success: false,
+					Message: "Validation failed",
+					Errors:  errs,
+				})
+				return
+			}
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid input format",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		if err := authService.ResetPassword(req.Token, req.Password, clientInfo(c)); err != nil {
+			if respondPasswordRejected(c, err) {
+				return
+			}
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Password reset failed",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Password has been reset, please log in again",
+		})
+	}
+}
+
+// GetPasswordPolicy godoc
+// @Summary      Get the password policy
+// @Description  Get the rules new passwords must follow, so clients can explain them before submitting. Passwords are also rejected when they contain the username or email address (disallowIdentity), were used recently (history counts the current password), or appear in the breached password list (breachCheck).
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=passwordpolicy.Policy}
+// @Router       /api/auth/password-policy [get]
+func GetPasswordPolicy(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Password policy retrieved successfully",
+			Object:  authService.PasswordPolicy(),
 		})
 	}
 }
@@ -307,20 +617,10 @@ func getValidationErrorMsg(fieldError validator.FieldError) string {
 		default:
 			return "Invalid username format"
 		}
-	case "Password":
+	case "Password", "NewPassword":
 		switch fieldError.Tag() {
 		case "required":
 			return "Password is required"
-		case "min":
-			return "Password must be at least 8 characters long"
-		case "containsany":
-			if fieldError.Param() == "!@#$%^&*()" {
-				return "Password must contain at least one special character (!@#$%^&*())"
-			} else if fieldError.Param() == "ABCDEFGHIJKLMNOPQRSTUVWXYZ" {
-				return "Password must contain at least one uppercase letter"
-			} else if fieldError.Param() == "abcdefghijklmnopqrstuvwxyz" {
-				return "Password must contain at least one lowercase letter"
-			}
 		}
 		return "Invalid password format"
 	}
@@ -9,13 +9,21 @@ import (
 )
 
 func UploadPosterToCloudinary(file multipart.File, fileHeader *multipart.FileHeader, cfgCloudName, cfgAPIKey, cfgAPISecret string) (string, error) {
+	return uploadToCloudinary(file, fileHeader, "movie_posters", cfgCloudName, cfgAPIKey, cfgAPISecret)
+}
+
+func UploadAvatarToCloudinary(file multipart.File, fileHeader *multipart.FileHeader, cfgCloudName, cfgAPIKey, cfgAPISecret string) (string, error) {
+	return uploadToCloudinary(file, fileHeader, "user_avatars", cfgCloudName, cfgAPIKey, cfgAPISecret)
+}
+
+func uploadToCloudinary(file multipart.File, fileHeader *multipart.FileHeader, folder, cfgCloudName, cfgAPIKey, cfgAPISecret string) (string, error) {
 	cld, err := cloudinary.NewFromParams(cfgCloudName, cfgAPIKey, cfgAPISecret)
 	if err != nil {
 		return "", err
 	}
 	uploadResult, err := cld.Upload.Upload(context.Background(), file, uploader.UploadParams{
 		PublicID:       fileHeader.Filename,
-		Folder:         "movie_posters",
+		Folder:         folder,
 		UniqueFilename: func(b bool) *bool { return &b }(true),
 	})
 	if err != nil {
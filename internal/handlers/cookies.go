@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/middleware"
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Browser clients can ask for their session in cookies instead of response
+// bodies by sending "X-Auth-Mode: cookie" when logging in, once AUTH_COOKIES
+// is enabled. The refresh token cookie is only sent to the auth endpoints.
+const (
+	authModeHeader     = "X-Auth-Mode"
+	authModeCookie     = "cookie"
+	refreshTokenCookie = "refresh_token"
+	refreshCookiePath  = "/api/auth"
+)
+
+// CookieSessionResponse is returned instead of the tokens when they are set
+// as cookies. The CSRF token must be sent in the X-CSRF-Token header of
+// every state-changing request; it is also readable from the csrf_token cookie.
+type CookieSessionResponse struct {
+	CSRFToken string `json:"csrfToken"`
+}
+
+// wantsCookies reports whether the tokens issued by this request should be
+// delivered as cookies.
+func wantsCookies(c *gin.Context, cfg *config.Config) bool {
+	return cfg.AuthCookies && strings.EqualFold(c.GetHeader(authModeHeader), authModeCookie)
+}
+
+// respondTokens writes a freshly issued token pair, in cookies when asCookies
+// is set and in the response body otherwise.
+func respondTokens(c *gin.Context, cfg *config.Config, asCookies bool, message, accessToken, refreshToken string) {
+	if !asCookies {
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: message,
+			Object:  TokenResponse{AccessToken: accessToken, RefreshToken: refreshToken},
+		})
+		return
+	}
+	csrfToken, err := newCSRFToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, BaseResponse{Success: false, Message: "Failed to start session", Errors: []string{err.Error()}})
+		return
+	}
+	secure := strings.HasPrefix(cfg.AppBaseURL, "https://")
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(middleware.AccessTokenCookie, accessToken, int(services.AccessTokenTTL.Seconds()), "/api", "", secure, true)
+	c.SetCookie(refreshTokenCookie, refreshToken, int(services.RefreshTokenTTL.Seconds()), refreshCookiePath, "", secure, true)
+	c.SetCookie(middleware.CSRFCookie, csrfToken, int(services.RefreshTokenTTL.Seconds()), "/", "", secure, false)
+	c.JSON(http.StatusOK, BaseResponse{
+		Success: true,
+		Message: message,
+		Object:  CookieSessionResponse{CSRFToken: csrfToken},
+	})
+}
+
+// clearSessionCookies removes the cookies set by respondTokens.
+func clearSessionCookies(c *gin.Context, cfg *config.Config) {
+	secure := strings.HasPrefix(cfg.AppBaseURL, "https://")
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(middleware.AccessTokenCookie, "", -1, "/api", "", secure, true)
+	c.SetCookie(refreshTokenCookie, "", -1, refreshCookiePath, "", secure, true)
+	c.SetCookie(middleware.CSRFCookie, "", -1, "/", "", secure, false)
+}
+
+// cookieRefreshToken returns the refresh token cookie of a browser session.
+// Using it changes state, so the request must pass the CSRF check; ok is
+// false, with the response written, when it does not.
+func cookieRefreshToken(c *gin.Context) (token string, ok bool) {
+	token, err := c.Cookie(refreshTokenCookie)
+	if err != nil || token == "" {
+		c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid input format", Errors: []string{"refreshToken is required"}})
+		return "", false
+	}
+	if !middleware.ValidCSRF(c) {
+		respondForbidden(c, "Missing or invalid CSRF token")
+		return "", false
+	}
+	return token, true
+}
+
+func newCSRFToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
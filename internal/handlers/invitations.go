@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"eskalate-movie-api/internal/middleware"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateInvitationRequest struct {
+	// Role defaults to member; only administrators can choose another
+	Role          string `json:"role"`
+	Note          string `json:"note" binding:"max=200"`
+	MaxUses       int    `json:"maxUses" binding:"required,min=1"`
+	ExpiresInDays int    `json:"expiresInDays" binding:"required,min=1"`
+}
+
+type InvitationResponse struct {
+	Code       string            `json:"code"`
+	Invitation models.Invitation `json:"invitation"`
+}
+
+type RegistrationResponse struct {
+	Mode string `json:"mode"`
+}
+
+// RegisterInvitationRoutes registers the public registration mode endpoint
+// on rg and the invitation endpoints on account, an authenticated group
+func RegisterInvitationRoutes(rg *gin.RouterGroup, account *gin.RouterGroup, invitations services.InvitationService) {
+	rg.GET("/registration", GetRegistrationMode(invitations))
+
+	account.POST("/invitations", middleware.RequireVerifiedEmail(), CreateInvitation(invitations))
+	account.GET("/invitations", ListInvitations(invitations))
+	account.DELETE("/invitations/:id", RevokeInvitation(invitations))
+}
+
+// GetRegistrationMode godoc
+// @Summary      Get the registration mode
+// @Description  Tell clients whether anyone can sign up (open), only people with an invitation code (invite-only) or nobody (closed).
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=RegistrationResponse}
+// @Router       /api/auth/registration [get]
+func GetRegistrationMode(invitations services.InvitationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Registration mode retrieved successfully",
+			Object:  RegistrationResponse{Mode: invitations.RegistrationMode()},
+		})
+	}
+}
+
+// CreateInvitation godoc
+// @Summary      Create an invitation
+// @Description  Create an invitation code that lets people sign up while registration is invite-only. The code is only shown in this response. Users can invite members while registration is invite-only, with up to 10 uses and 30 days, within a limit of active invitations. Users with users:manage can invite at any time, to any role, with up to 1000 uses and 365 days.
+// @Tags         invitations
+// @Accept       json
+// @Produce      json
+// @Param        createInvitationRequest body CreateInvitationRequest true "Invitation settings"
+// @Success      201 {object} BaseResponse{object=InvitationResponse}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/invitations [post]
+func CreateInvitation(invitations services.InvitationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req CreateInvitationRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		invitation, code, err := invitations.Create(actor(principal), req.Role, req.Note, req.MaxUses,
+			time.Duration(req.ExpiresInDays)*24*time.Hour)
+		if err != nil {
+			respondInvitationError(c, "Failed to create invitation", err)
+			return
+		}
+		c.JSON(http.StatusCreated, BaseResponse{
+			Success: true,
+			Message: "Invitation created, copy the code now as it will not be shown again",
+			Object:  InvitationResponse{Code: code, Invitation: *invitation},
+		})
+	}
+}
+
+// ListInvitations godoc
+// @Summary      List my invitations
+// @Description  List the invitations the current user created, newest first, with their uses. Codes are never returned.
+// @Tags         invitations
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=[]models.Invitation}
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/invitations [get]
+func ListInvitations(invitations services.InvitationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		list, err := invitations.ListOwn(principal.UserID)
+		if err != nil {
+			respondInvitationError(c, "Failed to retrieve invitations", err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{Success: true, Message: "Invitations retrieved successfully", Object: list})
+	}
+}
+
+// RevokeInvitation godoc
+// @Summary      Revoke an invitation
+// @Description  Stop an invitation from being used. Users can revoke their own invitations; users:manage allows revoking anyone's.
+// @Tags         invitations
+// @Produce      json
+// @Param        id path string true "Invitation ID"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/invitations/{id} [delete]
+func RevokeInvitation(invitations services.InvitationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		invitationID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid invitation ID", Errors: []string{err.Error()}})
+			return
+		}
+		if err := invitations.Revoke(actor(principal), invitationID); err != nil {
+			respondInvitationError(c, "Failed to revoke invitation", err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{Success: true, Message: "Invitation revoked successfully"})
+	}
+}
+
+// ListAllInvitations godoc
+// @Summary      List all invitations
+// @Description  Get a paginated list of every user's invitations, newest first. Requires users:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        active query bool false "Only invitations that can still be used"
+// @Param        pageNumber query int false "Page number"
+// @Param        pageSize query int false "Page size"
+// @Success      200 {object} PaginatedResponse{object=[]models.Invitation}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/invitations [get]
+func ListAllInvitations(invitations services.InvitationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		activeOnly := false
+		if a := c.Query("active"); a != "" {
+			var err error
+			if activeOnly, err = strconv.ParseBool(a); err != nil {
+				c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid active filter", Errors: []string{err.Error()}})
+				return
+			}
+		}
+		pageNumber, pageSize := pagination(c)
+		list, total, err := invitations.ListAll(activeOnly, pageNumber, pageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, PaginatedResponse{Success: false, Message: "Failed to fetch invitations", Errors: []string{err.Error()}})
+			return
+		}
+		c.JSON(http.StatusOK, PaginatedResponse{
+			Success:    true,
+			Message:    "Invitations fetched",
+			Object:     list,
+			PageNumber: pageNumber,
+			PageSize:   pageSize,
+			TotalSize:  total,
+		})
+	}
+}
+
+// respondInvitationError maps invitation errors to status codes.
+func respondInvitationError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrInvitationNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrInvitationSettings):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrInvitationRole), errors.Is(err, services.ErrInvitationsDisabled):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrInvitationLimit):
+		status = http.StatusConflict
+	}
+	c.JSON(status, BaseResponse{Success: false, Message: message, Errors: []string{err.Error()}})
+}
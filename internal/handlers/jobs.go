@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListJobs godoc
+// @Summary      List background jobs
+// @Description  List the cleanup jobs that delete expired and revoked tokens and other stale rows, with how often each runs and its last run on any server instance. Requires users:manage.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=[]services.JobStatus}
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/admin/jobs [get]
+func ListJobs(jobs services.JobScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		statuses, err := jobs.Status()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{
+				Success: false,
+				Message: "Failed to retrieve jobs",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Jobs retrieved successfully",
+			Object:  statuses,
+		})
+	}
+}
+
+// RunJob godoc
+// @Summary      Run a background job
+// @Description  Run a cleanup job now, however recently it last ran. Refused while another instance is running it. Requires users:manage.
+// @Tags         admin
+// @Produce      json
+// @Param        name path string true "Job name, e.g. refresh_tokens"
+// @Success      200 {object} BaseResponse{object=models.JobRun}
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Failure      500 {object} BaseResponse{object=models.JobRun}
+// @Security     BearerAuth
+// @Router       /api/admin/jobs/{name}/run [post]
+func RunJob(jobs services.JobScheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		run, err := jobs.Run(c.Param("name"))
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, services.ErrJobNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, services.ErrJobRunning):
+				status = http.StatusConflict
+			}
+			resp := BaseResponse{Success: false, Message: "Failed to run job", Errors: []string{err.Error()}}
+			// A failed run is recorded like a successful one and returned with the error
+			if run != nil {
+				resp.Object = run
+			}
+			c.JSON(status, resp)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Job ran successfully",
+			Object:  run,
+		})
+	}
+}
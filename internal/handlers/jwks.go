@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"eskalate-movie-api/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS godoc
+// @Summary      JSON Web Key Set
+// @Description  Public keys that access tokens are verified with, including keys retired by a rotation whose tokens may still be valid
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} tokens.JWKSet
+// @Router       /.well-known/jwks.json [get]
+func JWKS(keys *tokens.KeyRing) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, keys.JWKS())
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// magicLinkBindingCookie ties a sign-in link to the browser that asked for it.
+const (
+	magicLinkBindingCookie = "magic_link_binding"
+	magicLinkCookiePath    = "/api/auth/magic-link"
+)
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkLoginRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RequestMagicLink godoc
+// @Summary      Request a sign-in link
+// @Description  Email a single-use sign-in link valid for 15 minutes. The link only works in the browser that made this request, which receives a binding cookie. The response is the same whether or not the address belongs to an account, and no more than MAGIC_LINK_HOURLY_LIMIT links are sent to an address per hour.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        magicLinkRequest body MagicLinkRequest true "Email address"
+// @Success      202 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Router       /api/auth/magic-link [post]
+func RequestMagicLink(authService services.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MagicLinkRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid input format",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		binding, err := authService.RequestMagicLink(req.Email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{
+				Success: false,
+				Message: "Failed to send sign-in link",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(magicLinkBindingCookie, binding, 900, magicLinkCookiePath, "", strings.HasPrefix(cfg.AppBaseURL, "https://"), true)
+		c.JSON(http.StatusAccepted, BaseResponse{
+			Success: true,
+			Message: "If the address belongs to an account, a sign-in link has been sent",
+		})
+	}
+}
+
+// MagicLinkLogin godoc
+// @Summary      Sign in with a link
+// @Description  Exchange the token from a sign-in link for tokens, from the browser that asked for the link. Users with two-factor authentication get an MFA challenge token, to be completed at /api/auth/login/mfa.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        magicLinkLoginRequest body MagicLinkLoginRequest true "Token from the sign-in link"
+// @Param        X-Auth-Mode header string false "Set to cookie to receive the tokens in HttpOnly cookies (when AUTH_COOKIES is enabled)"
+// @Success      200 {object} BaseResponse{object=TokenResponse}
+// @Success      202 {object} BaseResponse{object=MFAChallengeResponse}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Router       /api/auth/magic-link/exchange [post]
+func MagicLinkLogin(authService services.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MagicLinkLoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid input format",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		binding, _ := c.Cookie(magicLinkBindingCookie)
+		result, err := authService.LoginWithMagicLink(req.Token, binding, clientInfo(c))
+		if errors.Is(err, services.ErrAccountDisabled) {
+			respondForbidden(c, err.Error())
+			return
+		}
+		if err != nil {
+			respondUnauthorized(c, "Invalid or expired sign-in link")
+			return
+		}
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(magicLinkBindingCookie, "", -1, magicLinkCookiePath, "", strings.HasPrefix(cfg.AppBaseURL, "https://"), true)
+		if result.MFARequired() {
+			c.JSON(http.StatusAccepted, BaseResponse{
+				Success: true,
+				Message: "Two-factor authentication required",
+				Object:  MFAChallengeResponse{MFARequired: true, MFAToken: result.MFAToken, Methods: result.MFAMethods},
+			})
+			return
+		}
+		respondTokens(c, cfg, wantsCookies(c, cfg), "Login successful", result.AccessToken, result.RefreshToken)
+	}
+}
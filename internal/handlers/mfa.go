@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// LoginMFA godoc
+// @Summary      Complete a two-factor login
+// @Description  Exchange the MFA challenge token returned by login plus a TOTP or recovery code for tokens. A challenge expires after 5 minutes or 5 wrong codes. Wrong codes count as failed logins of the account, so repeated failures are answered with 429 and a Retry-After header like password logins.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        mfaLoginRequest body MFALoginRequest true "MFA login request"
+// @Param        X-Auth-Mode header string false "Set to cookie to receive the tokens in HttpOnly cookies (when AUTH_COOKIES is enabled)"
+// @Success      200 {object} BaseResponse{object=TokenResponse}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      429 {object} BaseResponse
+// @Router       /api/auth/login/mfa [post]
+func LoginMFA(authService services.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MFALoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid input format",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		accessToken, refreshToken, err := authService.CompleteMFALogin(req.MFAToken, req.Code, clientInfo(c))
+		if respondThrottled(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrAccountDisabled) {
+			respondForbidden(c, err.Error())
+			return
+		}
+		if err != nil {
+			respondUnauthorized(c, err.Error())
+			return
+		}
+		respondTokens(c, cfg, wantsCookies(c, cfg), "Login successful", accessToken, refreshToken)
+	}
+}
+
+// SetupTOTP godoc
+// @Summary      Start TOTP enrollment
+// @Description  Generate a TOTP secret and the otpauth:// provisioning URI to show as a QR code. Two-factor authentication is not enforced until it is enabled with a valid code.
+// @Tags         mfa
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=services.TOTPSetup}
+// @Failure      401 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/mfa/totp/setup [post]
+func SetupTOTP(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		setup, err := authService.SetupTOTP(principal.UserID)
+		if err != nil {
+			respondMFAError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Scan the provisioning URI with your authenticator app, then enable two-factor authentication with a code",
+			Object:  setup,
+		})
+	}
+}
+
+// EnableTOTP godoc
+// @Summary      Enable TOTP
+// @Description  Confirm enrollment with a code from the authenticator app. Returns single-use recovery codes, which are only shown once.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Param        mfaCodeRequest body MFACodeRequest true "TOTP code"
+// @Success      200 {object} BaseResponse{object=RecoveryCodesResponse}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/mfa/totp/enable [post]
+func EnableTOTP(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req MFACodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid input format",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		codes, err := authService.EnableTOTP(principal.UserID, req.Code)
+		if err != nil {
+			respondMFAError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Two-factor authentication enabled, store your recovery codes somewhere safe",
+			Object:  RecoveryCodesResponse{RecoveryCodes: codes},
+		})
+	}
+}
+
+// DisableTOTP godoc
+// @Summary      Disable TOTP
+// @Description  Turn off two-factor authentication with a current TOTP or recovery code. Remaining recovery codes are deleted. Wrong codes count as failed logins of the account and are answered with 429 once it is throttled.
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Param        mfaCodeRequest body MFACodeRequest true "TOTP or recovery code"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Failure      429 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/mfa/totp/disable [post]
+func DisableTOTP(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req MFACodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid input format",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		if err := authService.DisableTOTP(principal.UserID, req.Code, clientInfo(c)); err != nil {
+			if respondThrottled(c, err) {
+				return
+			}
+			respondMFAError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Two-factor authentication disabled",
+		})
+	}
+}
+
+// respondMFAError maps MFA enrollment errors to status codes.
+func respondMFAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode):
+		c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid code", Errors: []string{err.Error()}})
+	case errors.Is(err, services.ErrMFAAlreadyEnabled), errors.Is(err, services.ErrMFANotEnabled), errors.Is(err, services.ErrMFANotEnrolled):
+		c.JSON(http.StatusConflict, BaseResponse{Success: false, Message: err.Error(), Errors: []string{err.Error()}})
+	default:
+		c.JSON(http.StatusInternalServerError, BaseResponse{Success: false, Message: "Two-factor authentication request failed", Errors: []string{err.Error()}})
+	}
+}
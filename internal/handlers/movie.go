@@ -1,17 +1,20 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/middleware"
 	"eskalate-movie-api/internal/models"
 	"eskalate-movie-api/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type MovieRequest struct {
@@ -22,19 +25,23 @@ type MovieRequest struct {
 	Trailer     string   `form:"trailerUrl" binding:"required"`
 }
 
-// RegisterMovieRoutes registers movie endpoints
-func RegisterMovieRoutes(rg *gin.RouterGroup, movieService services.MovieService, cfg *config.Config) {
-	rg.POST("/", CreateMovie(movieService, cfg))
-	rg.PUT("/:id", UpdateMovie(movieService, cfg))
-	rg.GET("/", GetMovies(movieService, cfg))
-	rg.GET("/search", SearchMovies(movieService, cfg))
-	rg.GET("/:id", MovieDetails(movieService, cfg))
-	rg.DELETE("/:id", DeleteMovie(movieService, cfg))
+// RegisterMovieRoutes registers movie endpoints: read endpoints
+// on the public group and write endpoints on the authenticated group
+func RegisterMovieRoutes(public, authenticated *gin.RouterGroup, movieService services.MovieService, cfg *config.Config) {
+	public.GET("/", GetMovies(movieService, cfg))
+	public.GET("/search", SearchMovies(movieService, cfg))
+	public.GET("/:id", MovieDetails(movieService, cfg))
+
+	// Personal access tokens need the movies:write scope for every write
+	authenticated.Use(middleware.RequireScope(models.ScopeMoviesWrite))
+	authenticated.POST("/", middleware.RequireVerifiedEmail(), middleware.RequirePermission(models.PermMoviesCreate), CreateMovie(movieService, cfg))
+	authenticated.PUT("/:id", UpdateMovie(movieService, cfg))
+	authenticated.DELETE("/:id", DeleteMovie(movieService, cfg))
 }
 
 // CreateMovie godoc
 // @Summary      Create a new movie
-// @Description  Create a new movie (auth required)
+// @Description  Create a new movie (auth and a verified email address required)
 // @Tags         movies
 // @Accept       multipart/form-data
 // @Produce      json
@@ -47,10 +54,15 @@ func RegisterMovieRoutes(rg *gin.RouterGroup, movieService services.MovieService
 // @Success      201 {object} BaseResponse
 // @Failure      400 {object} BaseResponse
 // @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
 // @Security     BearerAuth
 // @Router       /api/movies [post]
 func CreateMovie(movieService services.MovieService, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
 		var req MovieRequest
 		if err := c.ShouldBind(&req); err != nil {
 			c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid input", Errors: []string{err.Error()}})
@@ -77,8 +89,6 @@ func CreateMovie(movieService services.MovieService, cfg *config.Config) gin.Han
 			c.JSON(http.StatusInternalServerError, BaseResponse{Success: false, Message: "Failed to upload poster", Errors: []string{err.Error()}})
 			return
 		}
-		userID, _ := c.Get("userID")
-		uuidUser, _ := uuid.Parse(userID.(string))
 		movie := &models.Movie{
 			Title:       req.Title,
 			Description: req.Description,
@@ -86,7 +96,7 @@ func CreateMovie(movieService services.MovieService, cfg *config.Config) gin.Han
 			Actors:      req.Actors,
 			Trailer:     req.Trailer,
 			Poster:      posterURL,
-			UserID:      uuidUser,
+			UserID:      principal.UserID,
 		}
 		if err := movieService.Create(movie); err != nil {
 			c.JSON(http.StatusInternalServerError, BaseResponse{Success: false, Message: "Failed to create movie", Errors: []string{err.Error()}})
@@ -98,7 +108,7 @@ func CreateMovie(movieService services.MovieService, cfg *config.Config) gin.Han
 
 // UpdateMovie godoc
 // @Summary      Update a movie
-// @Description  Update a movie (auth required, must own movie)
+// @Description  Update a movie (auth required, must own movie unless admin or curator)
 // @Tags         movies
 // @Accept       multipart/form-data
 // @Produce      json
@@ -124,8 +134,10 @@ func UpdateMovie(movieService services.MovieService, cfg *config.Config) gin.Han
 			c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid movie ID", Errors: []string{err.Error()}})
 			return
 		}
-		userID, _ := c.Get("userID")
-		uuidUser, _ := uuid.Parse(userID.(string))
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
 		var req MovieRequest
 		if err := c.ShouldBind(&req); err != nil {
 			c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid input", Errors: []string{err.Error()}})
@@ -146,8 +158,8 @@ func UpdateMovie(movieService services.MovieService, cfg *config.Config) gin.Han
 			c.JSON(http.StatusNotFound, BaseResponse{Success: false, Message: "Movie not found", Errors: []string{"Movie not found"}})
 			return
 		}
-		if movie.UserID != uuidUser {
-			c.JSON(http.StatusForbidden, BaseResponse{Success: false, Message: "Forbidden", Errors: []string{"You do not own this movie"}})
+		if !actor(principal).CanModify(movie.UserID, models.PermMoviesUpdateAny) {
+			respondForbidden(c, "You do not own this movie")
 			return
 		}
 		posterFile, posterHeader, err := c.Request.FormFile("poster")
@@ -165,7 +177,11 @@ func UpdateMovie(movieService services.MovieService, cfg *config.Config) gin.Han
 		movie.Genres = req.Genres
 		movie.Actors = req.Actors
 		movie.Trailer = req.Trailer
-		if err := movieService.Update(movie, uuidUser); err != nil {
+		if err := movieService.Update(movie, actor(principal)); err != nil {
+			if errors.Is(err, services.ErrForbidden) {
+				respondForbidden(c, "You do not own this movie")
+				return
+			}
 			c.JSON(http.StatusInternalServerError, BaseResponse{Success: false, Message: "Failed to update movie", Errors: []string{err.Error()}})
 			return
 		}
@@ -276,7 +292,7 @@ func MovieDetails(movieService services.MovieService, cfg *config.Config) gin.Ha
 
 // DeleteMovie godoc
 // @Summary      Delete a movie
-// @Description  Delete a movie (auth required, must own movie)
+// @Description  Delete a movie (auth required, must own movie unless admin or curator)
 // @Tags         movies
 // @Accept       json
 // @Produce      json
@@ -295,11 +311,17 @@ func DeleteMovie(movieService services.MovieService, cfg *config.Config) gin.Han
 			c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid movie ID", Errors: []string{err.Error()}})
 			return
 		}
-		userID, _ := c.Get("userID")
-		uuidUser, _ := uuid.Parse(userID.(string))
-		if err := movieService.Delete(movieID, uuidUser); err != nil {
-			if err.Error() == "forbidden" {
-				c.JSON(http.StatusForbidden, BaseResponse{Success: false, Message: "Forbidden", Errors: []string{"You do not own this movie"}})
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		if err := movieService.Delete(movieID, actor(principal)); err != nil {
+			if errors.Is(err, services.ErrForbidden) {
+				respondForbidden(c, "You do not own this movie")
+				return
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, BaseResponse{Success: false, Message: "Movie not found", Errors: []string{"Movie not found"}})
 				return
 			}
 			c.JSON(http.StatusInternalServerError, BaseResponse{Success: false, Message: "Failed to delete movie", Errors: []string{err.Error()}})
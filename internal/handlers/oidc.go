@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/oidc"
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// oidcBindingCookie ties a provider callback to the browser that started the login.
+const (
+	oidcBindingCookie = "oidc_binding"
+	oidcCookiePath    = "/api/auth/oidc"
+)
+
+type OIDCLinkResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+}
+
+// ListOIDCProviders godoc
+// @Summary      List identity providers
+// @Description  List the OpenID Connect providers users can sign in with
+// @Tags         oidc
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=[]services.OIDCProviderInfo}
+// @Router       /api/auth/oidc/providers [get]
+func ListOIDCProviders(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Identity providers retrieved successfully",
+			Object:  authService.OIDCProviders(),
+		})
+	}
+}
+
+// OIDCLogin godoc
+// @Summary      Sign in with an identity provider
+// @Description  Redirect to the provider's authorization endpoint (authorization code flow with PKCE). The provider sends the user back to /api/auth/oidc/{provider}/callback.
+// @Tags         oidc
+// @Param        provider path string true "Provider name"
+// @Success      302
+// @Failure      404 {object} BaseResponse
+// @Failure      502 {object} BaseResponse
+// @Router       /api/auth/oidc/{provider}/login [get]
+func OIDCLogin(authService services.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authz, err := authService.BeginOIDCLogin(c.Request.Context(), c.Param("provider"), nil)
+		if err != nil {
+			respondOIDCError(c, err)
+			return
+		}
+		setOIDCBinding(c, cfg, authz.Binding)
+		c.Redirect(http.StatusFound, authz.URL)
+	}
+}
+
+// OIDCCallback godoc
+// @Summary      Identity provider callback
+// @Description  Complete a sign-in or identity link started in this browser. An existing account with the same email is linked when the provider has verified the address; otherwise a new account is created. Users with two-factor authentication get an MFA challenge token. When AUTH_COOKIES is enabled the tokens are set as HttpOnly cookies.
+// @Tags         oidc
+// @Produce      json
+// @Param        provider path string true "Provider name"
+// @Param        code query string false "Authorization code"
+// @Param        state query string true "State from the authorization request"
+// @Param        error query string false "Error reported by the provider"
+// @Success      200 {object} BaseResponse{object=TokenResponse}
+// @Success      202 {object} BaseResponse{object=MFAChallengeResponse}
+// @Failure      401 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Router       /api/auth/oidc/{provider}/callback [get]
+func OIDCCallback(authService services.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		binding, _ := c.Cookie(oidcBindingCookie)
+		clearOIDCBinding(c, cfg)
+		if reason := c.Query("error"); reason != "" {
+			if description := c.Query("error_description"); description != "" {
+				reason += ": " + description
+			}
+			respondUnauthorized(c, reason)
+			return
+		}
+
+		result, err := authService.CompleteOIDCLogin(c.Request.Context(), c.Param("provider"), c.Query("state"), c.Query("code"), binding, clientInfo(c))
+		if err != nil {
+			respondOIDCError(c, err)
+			return
+		}
+		if result.Identity != nil {
+			c.JSON(http.StatusOK, BaseResponse{
+				Success: true,
+				Message: "Identity linked successfully",
+				Object:  result.Identity,
+			})
+			return
+		}
+		if result.Login.MFARequired() {
+			c.JSON(http.StatusAccepted, BaseResponse{
+				Success: true,
+				Message: "Two-factor authentication required",
+				Object:  MFAChallengeResponse{MFARequired: true, MFAToken: result.Login.MFAToken, Methods: result.Login.MFAMethods},
+			})
+			return
+		}
+		// The callback is a browser redirect, so it cannot ask for cookies
+		// with a header: it uses them whenever they are enabled
+		respondTokens(c, cfg, cfg.AuthCookies, "Login successful", result.Login.AccessToken, result.Login.RefreshToken)
+	}
+}
+
+// LinkOIDCIdentity godoc
+// @Summary      Link an identity provider
+// @Description  Start linking another provider account to the current user. Open the returned URL in the browser that made this request; the provider redirects back to the callback, which links the identity.
+// @Tags         oidc
+// @Produce      json
+// @Param        provider path string true "Provider name"
+// @Success      200 {object} BaseResponse{object=OIDCLinkResponse}
+// @Failure      401 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/oidc/{provider}/link [post]
+func LinkOIDCIdentity(authService services.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		authz, err := authService.BeginOIDCLogin(c.Request.Context(), c.Param("provider"), &principal.UserID)
+		if err != nil {
+			respondOIDCError(c, err)
+			return
+		}
+		setOIDCBinding(c, cfg, authz.Binding)
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Continue at the identity provider",
+			Object:  OIDCLinkResponse{AuthorizationURL: authz.URL},
+		})
+	}
+}
+
+// ListIdentities godoc
+// @Summary      List linked identities
+// @Description  List the identity provider accounts linked to the current user
+// @Tags         oidc
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=[]models.UserIdentity}
+// @Failure      401 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/identities [get]
+func ListIdentities(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		identities, err := authService.ListIdentities(principal.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{
+				Success: false,
+				Message: "Failed to retrieve identities",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Identities retrieved successfully",
+			Object:  identities,
+		})
+	}
+}
+
+// UnlinkIdentity godoc
+// @Summary      Unlink an identity
+// @Description  Remove a linked identity provider account. The last identity of an account without a password cannot be removed.
+// @Tags         oidc
+// @Produce      json
+// @Param        id path string true "Identity ID"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/identities/{id} [delete]
+func UnlinkIdentity(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		identityID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid identity ID",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		if err := authService.UnlinkIdentity(principal.UserID, identityID); err != nil {
+			respondOIDCError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Identity unlinked successfully",
+		})
+	}
+}
+
+func setOIDCBinding(c *gin.Context, cfg *config.Config, value string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, value, 600, oidcCookiePath, "", strings.HasPrefix(cfg.AppBaseURL, "https://"), true)
+}
+
+func clearOIDCBinding(c *gin.Context, cfg *config.Config) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, "", -1, oidcCookiePath, "", strings.HasPrefix(cfg.AppBaseURL, "https://"), true)
+}
+
+// respondOIDCError maps identity provider errors to status codes.
+func respondOIDCError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownProvider), errors.Is(err, services.ErrIdentityNotFound):
+		c.JSON(http.StatusNotFound, BaseResponse{Success: false, Message: err.Error(), Errors: []string{err.Error()}})
+	case errors.Is(err, services.ErrInvalidOIDCState), errors.Is(err, oidc.ErrInvalidIDToken), errors.Is(err, oidc.ErrNonceMismatch),
+		errors.Is(err, services.ErrOIDCEmailRequired):
+		respondUnauthorized(c, err.Error())
+	case errors.Is(err, services.ErrAccountDisabled), errors.Is(err, services.ErrRegistrationClosed),
+		errors.Is(err, services.ErrInvitationRequired):
+		respondForbidden(c, err.Error())
+	case errors.Is(err, services.ErrOIDCEmailUnverified), errors.Is(err, services.ErrIdentityAlreadyLinked), errors.Is(err, services.ErrLastSignInMethod):
+		c.JSON(http.StatusConflict, BaseResponse{Success: false, Message: err.Error(), Errors: []string{err.Error()}})
+	default:
+		c.JSON(http.StatusBadGateway, BaseResponse{Success: false, Message: "Sign-in with the identity provider failed", Errors: []string{err.Error()}})
+	}
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/webauthn"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PasskeyRegistrationRequest struct {
+	CeremonyID uuid.UUID                    `json:"ceremonyId" binding:"required"`
+	Name       string                       `json:"name"`
+	Credential webauthn.AttestationResponse `json:"credential"`
+}
+
+type PasskeyLoginRequest struct {
+	CeremonyID uuid.UUID                  `json:"ceremonyId" binding:"required"`
+	Credential webauthn.AssertionResponse `json:"credential"`
+}
+
+type PasskeyMFABeginRequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+}
+
+type PasskeyMFALoginRequest struct {
+	MFAToken   string                     `json:"mfaToken" binding:"required"`
+	CeremonyID uuid.UUID                  `json:"ceremonyId" binding:"required"`
+	Credential webauthn.AssertionResponse `json:"credential"`
+}
+
+// BeginPasskeyRegistration godoc
+// @Summary      Start adding a passkey
+// @Description  Returns the options to pass to navigator.credentials.create as publicKey. Send the resulting credential to /api/auth/passkeys/register/finish with the ceremony ID within 5 minutes.
+// @Tags         passkeys
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=services.PasskeyRegistration}
+// @Failure      401 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/passkeys/register/begin [post]
+func BeginPasskeyRegistration(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		registration, err := authService.BeginPasskeyRegistration(principal.UserID)
+		if err != nil {
+			respondPasskeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Create a passkey with these options",
+			Object:  registration,
+		})
+	}
+}
+
+// FinishPasskeyRegistration godoc
+// @Summary      Add a passkey
+// @Description  Verify the credential created by the authenticator and store it. Once a user has a passkey, password logins ask for a second factor.
+// @Tags         passkeys
+// @Accept       json
+// @Produce      json
+// @Param        passkeyRegistrationRequest body PasskeyRegistrationRequest true "Ceremony ID and created credential"
+// @Success      201 {object} BaseResponse{object=models.Passkey}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/passkeys/register/finish [post]
+func FinishPasskeyRegistration(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req PasskeyRegistrationRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		passkey, err := authService.FinishPasskeyRegistration(principal.UserID, req.CeremonyID, req.Name, &req.Credential)
+		if err != nil {
+			respondPasskeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, BaseResponse{
+			Success: true,
+			Message: "Passkey added successfully",
+			Object:  passkey,
+		})
+	}
+}
+
+// ListPasskeys godoc
+// @Summary      List passkeys
+// @Description  List the passkeys registered by the current user
+// @Tags         passkeys
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=[]models.Passkey}
+// @Failure      401 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/passkeys [get]
+func ListPasskeys(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		passkeys, err := authService.ListPasskeys(principal.UserID)
+		if err != nil {
+			respondPasskeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Passkeys retrieved successfully",
+			Object:  passkeys,
+		})
+	}
+}
+
+// DeletePasskey godoc
+// @Summary      Remove a passkey
+// @Description  Remove one of the current user's passkeys. The last way to sign in to an account without a password cannot be removed.
+// @Tags         passkeys
+// @Produce      json
+// @Param        id path string true "Passkey ID"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/passkeys/{id} [delete]
+func DeletePasskey(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		passkeyID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid passkey ID",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		if err := authService.DeletePasskey(principal.UserID, passkeyID); err != nil {
+			respondPasskeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Passkey removed successfully",
+		})
+	}
+}
+
+// BeginPasskeyLogin godoc
+// @Summary      Start a passkey login
+// @Description  Returns the options to pass to navigator.credentials.get as publicKey. The browser offers the passkeys it holds for this site; send the resulting assertion to /api/auth/passkeys/login/finish with the ceremony ID within 5 minutes.
+// @Tags         passkeys
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=services.PasskeyAssertion}
+// @Router       /api/auth/passkeys/login/begin [post]
+func BeginPasskeyLogin(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assertion, err := authService.BeginPasskeyLogin()
+		if err != nil {
+			respondPasskeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Sign in with a passkey using these options",
+			Object:  assertion,
+		})
+	}
+}
+
+// FinishPasskeyLogin godoc
+// @Summary      Sign in with a passkey
+// @Description  Exchange a passkey assertion for tokens. The authenticator must verify the user, so no second factor is asked for.
+// @Tags         passkeys
+// @Accept       json
+// @Produce      json
+// @Param        passkeyLoginRequest body PasskeyLoginRequest true "Ceremony ID and assertion"
+// @Param        X-Auth-Mode header string false "Set to cookie to receive the tokens in HttpOnly cookies (when AUTH_COOKIES is enabled)"
+// @Success      200 {object} BaseResponse{object=TokenResponse}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Router       /api/auth/passkeys/login/finish [post]
+func FinishPasskeyLogin(authService services.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PasskeyLoginRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		accessToken, refreshToken, err := authService.FinishPasskeyLogin(req.CeremonyID, &req.Credential, clientInfo(c))
+		if err != nil {
+			respondPasskeyError(c, err)
+			return
+		}
+		respondTokens(c, cfg, wantsCookies(c, cfg), "Login successful", accessToken, refreshToken)
+	}
+}
+
+// BeginPasskeyMFA godoc
+// @Summary      Start a passkey second factor
+// @Description  Returns the options to complete the MFA challenge returned by login with one of the user's passkeys. Send the resulting assertion to /api/auth/login/mfa/passkey with the ceremony ID.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        passkeyMFABeginRequest body PasskeyMFABeginRequest true "MFA challenge token"
+// @Success      200 {object} BaseResponse{object=services.PasskeyAssertion}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Router       /api/auth/login/mfa/passkey/begin [post]
+func BeginPasskeyMFA(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PasskeyMFABeginRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		assertion, err := authService.BeginPasskeyMFA(req.MFAToken)
+		if err != nil {
+			respondPasskeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Confirm the login with a passkey using these options",
+			Object:  assertion,
+		})
+	}
+}
+
+// LoginMFAPasskey godoc
+// @Summary      Complete a two-factor login with a passkey
+// @Description  Exchange the MFA challenge token returned by login plus a passkey assertion for tokens. Failed assertions count towards the 5 attempts a challenge allows.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        passkeyMFALoginRequest body PasskeyMFALoginRequest true "MFA challenge token, ceremony ID and assertion"
+// @Param        X-Auth-Mode header string false "Set to cookie to receive the tokens in HttpOnly cookies (when AUTH_COOKIES is enabled)"
+// @Success      200 {object} BaseResponse{object=TokenResponse}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Router       /api/auth/login/mfa/passkey [post]
+func LoginMFAPasskey(authService services.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PasskeyMFALoginRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		accessToken, refreshToken, err := authService.CompletePasskeyMFALogin(req.MFAToken, req.CeremonyID, &req.Credential, clientInfo(c))
+		if err != nil {
+			respondPasskeyError(c, err)
+			return
+		}
+		respondTokens(c, cfg, wantsCookies(c, cfg), "Login successful", accessToken, refreshToken)
+	}
+}
+
+// respondPasskeyError maps passkey errors to status codes.
+func respondPasskeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPasskeyNotFound):
+		c.JSON(http.StatusNotFound, BaseResponse{Success: false, Message: err.Error(), Errors: []string{err.Error()}})
+	case errors.Is(err, services.ErrInvalidPasskey), errors.Is(err, services.ErrInvalidCeremony), errors.Is(err, services.ErrInvalidActionToken):
+		respondUnauthorized(c, err.Error())
+	case errors.Is(err, services.ErrAccountDisabled):
+		respondForbidden(c, err.Error())
+	case errors.Is(err, services.ErrPasskeyExists), errors.Is(err, services.ErrPasskeyNotRegistered), errors.Is(err, services.ErrLastSignInMethod):
+		c.JSON(http.StatusConflict, BaseResponse{Success: false, Message: err.Error(), Errors: []string{err.Error()}})
+	default:
+		c.JSON(http.StatusInternalServerError, BaseResponse{Success: false, Message: "Passkey request failed", Errors: []string{err.Error()}})
+	}
+}
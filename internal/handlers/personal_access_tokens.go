@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreatePersonalAccessTokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expiresInDays" binding:"required,min=1,max=365"`
+}
+
+type PersonalAccessTokenResponse struct {
+	Token               string                     `json:"token"`
+	PersonalAccessToken models.PersonalAccessToken `json:"personalAccessToken"`
+}
+
+// RegisterPersonalAccessTokenRoutes registers personal access token endpoints
+// on an authenticated group
+func RegisterPersonalAccessTokenRoutes(rg *gin.RouterGroup, patService services.PersonalAccessTokenService) {
+	rg.POST("", CreatePersonalAccessToken(patService))
+	rg.GET("", ListPersonalAccessTokens(patService))
+	rg.DELETE("/:id", RevokePersonalAccessToken(patService))
+}
+
+// CreatePersonalAccessToken godoc
+// @Summary      Create a personal access token
+// @Description  Create a named, scoped and expiring token for scripts, sent as "Authorization: Bearer pat_...". The token is only shown in this response. Available scopes: movies:write, scim.
+// @Tags         tokens
+// @Accept       json
+// @Produce      json
+// @Param        createPersonalAccessTokenRequest body CreatePersonalAccessTokenRequest true "Token settings"
+// @Success      201 {object} BaseResponse{object=PersonalAccessTokenResponse}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/tokens [post]
+func CreatePersonalAccessToken(patService services.PersonalAccessTokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req CreatePersonalAccessTokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid input format",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		record, token, err := patService.Create(principal.UserID, req.Name, req.Scopes, time.Duration(req.ExpiresInDays)*24*time.Hour)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrInvalidScope) || errors.Is(err, services.ErrInvalidTokenLifetime) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, BaseResponse{
+				Success: false,
+				Message: "Failed to create personal access token",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusCreated, BaseResponse{
+			Success: true,
+			Message: "Personal access token created, copy it now as it will not be shown again",
+			Object:  PersonalAccessTokenResponse{Token: token, PersonalAccessToken: *record},
+		})
+	}
+}
+
+// ListPersonalAccessTokens godoc
+// @Summary      List personal access tokens
+// @Description  List the current user's personal access tokens with their scopes, expiry and last use. Token values are never returned.
+// @Tags         tokens
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=[]models.PersonalAccessToken}
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/tokens [get]
+func ListPersonalAccessTokens(patService services.PersonalAccessTokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		tokens, err := patService.List(principal.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{
+				Success: false,
+				Message: "Failed to retrieve personal access tokens",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Personal access tokens retrieved successfully",
+			Object:  tokens,
+		})
+	}
+}
+
+// RevokePersonalAccessToken godoc
+// @Summary      Revoke a personal access token
+// @Description  Revoke one of the current user's personal access tokens
+// @Tags         tokens
+// @Produce      json
+// @Param        id path string true "Token ID"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/tokens/{id} [delete]
+func RevokePersonalAccessToken(patService services.PersonalAccessTokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		tokenID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid token ID",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		if err := patService.Revoke(principal.UserID, tokenID); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrPersonalAccessTokenNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, BaseResponse{
+				Success: false,
+				Message: "Failed to revoke personal access token",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Personal access token revoked successfully",
+		})
+	}
+}
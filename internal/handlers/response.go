@@ -1,5 +1,14 @@
 package handlers
 
+import (
+	"net/http"
+
+	"eskalate-movie-api/internal/middleware"
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
 type BaseResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
@@ -16,3 +25,30 @@ type PaginatedResponse struct {
 	TotalSize  int64       `json:"totalSize"`
 	Errors     []string    `json:"errors,omitempty"`
 }
+
+// respondUnauthorized writes a 401: the caller is not authenticated.
+func respondUnauthorized(c *gin.Context, reason string) {
+	c.JSON(http.StatusUnauthorized, BaseResponse{Success: false, Message: "Unauthorized", Errors: []string{reason}})
+}
+
+// respondForbidden writes a 403: the caller is authenticated but not allowed.
+func respondForbidden(c *gin.Context, reason string) {
+	c.JSON(http.StatusForbidden, BaseResponse{Success: false, Message: "Forbidden", Errors: []string{reason}})
+}
+
+// currentPrincipal returns the caller attached by middleware.AuthMiddleware.
+// It writes a 401 and returns false when the request is anonymous, so handlers
+// can simply return.
+func currentPrincipal(c *gin.Context) (*middleware.Principal, bool) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		respondUnauthorized(c, "Authentication required")
+		return nil, false
+	}
+	return principal, true
+}
+
+// actor describes the principal to the service layer.
+func actor(p *middleware.Principal) services.Actor {
+	return services.Actor{UserID: p.UserID, Permissions: p.Permissions}
+}
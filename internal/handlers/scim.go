@@ -0,0 +1,477 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/scim"
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page sizes of SCIM queries.
+const (
+	scimDefaultCount = 100
+	scimMaxCount     = 200
+)
+
+// RegisterSCIMRoutes mounts the SCIM API on rg, which must require the
+// users:manage permission and the scim scope.
+func RegisterSCIMRoutes(rg *gin.RouterGroup, provisioning services.ProvisioningService, cfg *config.Config) {
+	rg.GET("/ServiceProviderConfig", SCIMServiceProviderConfig())
+
+	rg.GET("/Users", ListSCIMUsers(provisioning, cfg))
+	rg.POST("/Users", CreateSCIMUser(provisioning, cfg))
+	rg.GET("/Users/:id", GetSCIMUser(provisioning, cfg))
+	rg.PUT("/Users/:id", ReplaceSCIMUser(provisioning, cfg))
+	rg.PATCH("/Users/:id", PatchSCIMUser(provisioning, cfg))
+	rg.DELETE("/Users/:id", DeleteSCIMUser(provisioning))
+
+	rg.GET("/Groups", ListSCIMGroups(provisioning, cfg))
+	rg.GET("/Groups/:id", GetSCIMGroup(provisioning, cfg))
+	rg.PUT("/Groups/:id", ReplaceSCIMGroup(provisioning, cfg))
+	rg.PATCH("/Groups/:id", PatchSCIMGroup(provisioning))
+}
+
+// SCIMServiceProviderConfig godoc
+// @Summary      SCIM service provider configuration
+// @Description  Describe the SCIM features supported: PATCH and filtering, but not bulk operations, sorting, ETags or password changes.
+// @Tags         scim
+// @Produce      json
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /scim/v2/ServiceProviderConfig [get]
+func SCIMServiceProviderConfig() gin.HandlerFunc {
+	unsupported := gin.H{"supported": false}
+	return func(c *gin.Context) {
+		respondSCIM(c, http.StatusOK, gin.H{
+			"schemas":        []string{scim.SchemaServiceProviderConfig},
+			"patch":          gin.H{"supported": true},
+			"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+			"filter":         gin.H{"supported": true, "maxResults": scimMaxCount},
+			"changePassword": unsupported,
+			"sort":           unsupported,
+			"etag":           unsupported,
+			"authenticationSchemes": []gin.H{{
+				"type":        "oauthbearertoken",
+				"name":        "Personal access token",
+				"description": "A personal access token with the scim scope, owned by a user with the users:manage permission",
+			}},
+		})
+	}
+}
+
+// ListSCIMUsers godoc
+// @Summary      List or find users (SCIM)
+// @Description  List users, oldest first, optionally filtered, e.g. userName eq "jane@example.com". Filters can use id, userName, emails.value, externalId, displayName, active, meta.created and meta.lastModified with and, or and not. Requires users:manage and, for personal access tokens, the scim scope.
+// @Tags         scim
+// @Produce      json
+// @Param        filter query string false "SCIM filter expression"
+// @Param        startIndex query int false "1-based index of the first result"
+// @Param        count query int false "Maximum number of results"
+// @Success      200 {object} scim.ListResponse{Resources=[]scim.User}
+// @Failure      400 {object} scim.Error
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /scim/v2/Users [get]
+func ListSCIMUsers(provisioning services.ProvisioningService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, ok := scimFilter(c)
+		if !ok {
+			return
+		}
+		startIndex, count := scimPage(c)
+		users, total, err := provisioning.ListUsers(filter, startIndex-1, count)
+		if err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		resources := make([]*scim.User, len(users))
+		for i := range users {
+			resources[i] = scimUser(cfg, &users[i])
+		}
+		respondSCIM(c, http.StatusOK, scim.NewListResponse(resources, len(resources), total, startIndex))
+	}
+}
+
+// GetSCIMUser godoc
+// @Summary      Get a user (SCIM)
+// @Description  Requires users:manage and, for personal access tokens, the scim scope.
+// @Tags         scim
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Success      200 {object} scim.User
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} scim.Error
+// @Security     BearerAuth
+// @Router       /scim/v2/Users/{id} [get]
+func GetSCIMUser(provisioning services.ProvisioningService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := provisioning.GetUser(c.Param("id"))
+		if err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		respondSCIM(c, http.StatusOK, scimUser(cfg, user))
+	}
+}
+
+// CreateSCIMUser godoc
+// @Summary      Provision a user (SCIM)
+// @Description  Create an account. userName must be the user's email address, which is marked verified; the account has no password, so the user signs in with a magic link, an identity provider or a password reset. Requires users:manage and, for personal access tokens, the scim scope.
+// @Tags         scim
+// @Accept       json
+// @Produce      json
+// @Param        request body scim.User true "User"
+// @Success      201 {object} scim.User
+// @Failure      400 {object} scim.Error
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      409 {object} scim.Error
+// @Security     BearerAuth
+// @Router       /scim/v2/Users [post]
+func CreateSCIMUser(provisioning services.ProvisioningService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req scim.User
+		if !bindSCIM(c, &req) {
+			return
+		}
+		user, err := provisioning.CreateUser(actor(principal), clientInfo(c), &req)
+		if err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		resource := scimUser(cfg, user)
+		c.Header("Location", resource.Meta.Location)
+		respondSCIM(c, http.StatusCreated, resource)
+	}
+}
+
+// ReplaceSCIMUser godoc
+// @Summary      Replace a user (SCIM)
+// @Description  Set the user's email (userName), display name and externalId. active false deprovisions the account and true reactivates it. Requires users:manage and, for personal access tokens, the scim scope.
+// @Tags         scim
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        request body scim.User true "User"
+// @Success      200 {object} scim.User
+// @Failure      400 {object} scim.Error
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} scim.Error
+// @Failure      409 {object} scim.Error
+// @Security     BearerAuth
+// @Router       /scim/v2/Users/{id} [put]
+func ReplaceSCIMUser(provisioning services.ProvisioningService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req scim.User
+		if !bindSCIM(c, &req) {
+			return
+		}
+		user, err := provisioning.ReplaceUser(actor(principal), clientInfo(c), c.Param("id"), &req)
+		if err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		respondSCIM(c, http.StatusOK, scimUser(cfg, user))
+	}
+}
+
+// PatchSCIMUser godoc
+// @Summary      Update a user (SCIM)
+// @Description  Apply add, replace and remove operations to userName, displayName, name, externalId and active. Setting active to false deprovisions the account. Other attributes are ignored. Requires users:manage and, for personal access tokens, the scim scope.
+// @Tags         scim
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        request body scim.PatchRequest true "Operations"
+// @Success      200 {object} scim.User
+// @Failure      400 {object} scim.Error
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} scim.Error
+// @Failure      409 {object} scim.Error
+// @Security     BearerAuth
+// @Router       /scim/v2/Users/{id} [patch]
+func PatchSCIMUser(provisioning services.ProvisioningService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req scim.PatchRequest
+		if !bindSCIM(c, &req) {
+			return
+		}
+		user, err := provisioning.PatchUser(actor(principal), clientInfo(c), c.Param("id"), req.Operations)
+		if err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		respondSCIM(c, http.StatusOK, scimUser(cfg, user))
+	}
+}
+
+// DeleteSCIMUser godoc
+// @Summary      Deprovision a user (SCIM)
+// @Description  Disable the account and revoke its sessions and personal access tokens. The account is kept and still returned with active false, so it can be reactivated. Requires users:manage and, for personal access tokens, the scim scope.
+// @Tags         scim
+// @Param        id path string true "User ID"
+// @Success      204
+// @Failure      400 {object} scim.Error
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} scim.Error
+// @Security     BearerAuth
+// @Router       /scim/v2/Users/{id} [delete]
+func DeleteSCIMUser(provisioning services.ProvisioningService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		if err := provisioning.DeprovisionUser(actor(principal), clientInfo(c), c.Param("id")); err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ListSCIMGroups godoc
+// @Summary      List groups (SCIM)
+// @Description  The groups are the roles admin, curator and member; their members are the users holding the role. Filters can use id and displayName. Requires users:manage and, for personal access tokens, the scim scope.
+// @Tags         scim
+// @Produce      json
+// @Param        filter query string false "SCIM filter expression"
+// @Param        excludedAttributes query string false "members to leave out the member lists"
+// @Success      200 {object} scim.ListResponse{Resources=[]scim.Group}
+// @Failure      400 {object} scim.Error
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /scim/v2/Groups [get]
+func ListSCIMGroups(provisioning services.ProvisioningService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, ok := scimFilter(c)
+		if !ok {
+			return
+		}
+		groups, err := provisioning.ListGroups(filter, wantsMembers(c))
+		if err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		resources := make([]*scim.Group, len(groups))
+		for i := range groups {
+			resources[i] = scimGroup(cfg, &groups[i])
+		}
+		respondSCIM(c, http.StatusOK, scim.NewListResponse(resources, len(resources), int64(len(resources)), 1))
+	}
+}
+
+// GetSCIMGroup godoc
+// @Summary      Get a group (SCIM)
+// @Description  Get a role and the users holding it. Requires users:manage and, for personal access tokens, the scim scope.
+// @Tags         scim
+// @Produce      json
+// @Param        id path string true "admin, curator or member"
+// @Param        excludedAttributes query string false "members to leave out the member list"
+// @Success      200 {object} scim.Group
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} scim.Error
+// @Security     BearerAuth
+// @Router       /scim/v2/Groups/{id} [get]
+func GetSCIMGroup(provisioning services.ProvisioningService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, err := provisioning.GetGroup(c.Param("id"), wantsMembers(c))
+		if err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		respondSCIM(c, http.StatusOK, scimGroup(cfg, group))
+	}
+}
+
+// ReplaceSCIMGroup godoc
+// @Summary      Replace a group's members (SCIM)
+// @Description  Give the role to exactly the listed users. Users who held it and are not listed become members. Groups cannot be renamed. Requires users:manage and, for personal access tokens, the scim scope.
+// @Tags         scim
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "admin, curator or member"
+// @Param        request body scim.Group true "Group"
+// @Success      200 {object} scim.Group
+// @Failure      400 {object} scim.Error
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} scim.Error
+// @Security     BearerAuth
+// @Router       /scim/v2/Groups/{id} [put]
+func ReplaceSCIMGroup(provisioning services.ProvisioningService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req scim.Group
+		if !bindSCIM(c, &req) {
+			return
+		}
+		if req.DisplayName != "" && req.DisplayName != c.Param("id") {
+			respondSCIMError(c, scim.BadRequest(scim.ErrorMutability, "groups are roles and cannot be renamed"))
+			return
+		}
+		members := make([]string, len(req.Members))
+		for i, m := range req.Members {
+			members[i] = m.Value
+		}
+		if err := provisioning.ReplaceGroup(actor(principal), clientInfo(c), c.Param("id"), members); err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		group, err := provisioning.GetGroup(c.Param("id"), true)
+		if err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		respondSCIM(c, http.StatusOK, scimGroup(cfg, group))
+	}
+}
+
+// PatchSCIMGroup godoc
+// @Summary      Change a group's members (SCIM)
+// @Description  Add members to give users the role, remove them to make them members again. Requires users:manage and, for personal access tokens, the scim scope.
+// @Tags         scim
+// @Accept       json
+// @Param        id path string true "admin, curator or member"
+// @Param        request body scim.PatchRequest true "Operations"
+// @Success      204
+// @Failure      400 {object} scim.Error
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      404 {object} scim.Error
+// @Security     BearerAuth
+// @Router       /scim/v2/Groups/{id} [patch]
+func PatchSCIMGroup(provisioning services.ProvisioningService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req scim.PatchRequest
+		if !bindSCIM(c, &req) {
+			return
+		}
+		if err := provisioning.PatchGroup(actor(principal), clientInfo(c), c.Param("id"), req.Operations); err != nil {
+			respondSCIMError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func scimUser(cfg *config.Config, user *models.User) *scim.User {
+	resource := scim.NewUser(user)
+	resource.Meta.Location = cfg.AppBaseURL + "/scim/v2/Users/" + resource.ID
+	for i := range resource.Groups {
+		resource.Groups[i].Ref = cfg.AppBaseURL + "/scim/v2/Groups/" + resource.Groups[i].Value
+	}
+	return resource
+}
+
+func scimGroup(cfg *config.Config, group *services.RoleGroup) *scim.Group {
+	resource := scim.NewGroup(group.Role, group.Members)
+	resource.Meta.Location = cfg.AppBaseURL + "/scim/v2/Groups/" + resource.ID
+	for i := range resource.Members {
+		resource.Members[i].Ref = cfg.AppBaseURL + "/scim/v2/Users/" + resource.Members[i].Value
+	}
+	return resource
+}
+
+// scimFilter parses the filter query parameter, writing a 400 when it is
+// invalid. A request without a filter gets a nil filter.
+func scimFilter(c *gin.Context) (scim.Filter, bool) {
+	raw := c.Query("filter")
+	if raw == "" {
+		return nil, true
+	}
+	filter, err := scim.ParseFilter(raw)
+	if err != nil {
+		respondSCIMError(c, err)
+		return nil, false
+	}
+	return filter, true
+}
+
+// scimPage returns the 1-based startIndex and the count of a query.
+func scimPage(c *gin.Context) (int, int) {
+	startIndex, err := strconv.Atoi(c.Query("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.Query("count"))
+	if err != nil || count < 0 {
+		count = scimDefaultCount
+	}
+	return startIndex, min(count, scimMaxCount)
+}
+
+// wantsMembers reports whether group members should be listed, which
+// clients can turn off with excludedAttributes=members.
+func wantsMembers(c *gin.Context) bool {
+	for _, attr := range strings.Split(c.Query("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attr), "members") {
+			return false
+		}
+	}
+	return true
+}
+
+// bindSCIM decodes a SCIM request body, writing a 400 when it is malformed.
+func bindSCIM(c *gin.Context, req interface{}) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		respondSCIMError(c, scim.BadRequest(scim.ErrorInvalidSyntax, "invalid JSON body: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+func respondSCIM(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", scim.ContentType)
+	c.JSON(status, body)
+}
+
+func respondSCIMError(c *gin.Context, err error) {
+	var scimErr *scim.Error
+	switch {
+	case errors.As(err, &scimErr):
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrGroupNotFound):
+		scimErr = scim.NewError(http.StatusNotFound, "", err.Error())
+	case errors.Is(err, services.ErrCannotModifySelf):
+		scimErr = scim.BadRequest(scim.ErrorMutability, err.Error())
+	default:
+		scimErr = scim.NewError(http.StatusInternalServerError, "", err.Error())
+	}
+	respondSCIM(c, scimErr.StatusCode(), scimErr)
+}
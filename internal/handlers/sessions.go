@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type RevokeAllSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+// clientInfo describes the client of the request for session tracking.
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{UserAgent: c.Request.UserAgent(), IPAddress: c.ClientIP()}
+}
+
+// ListSessions godoc
+// @Summary      List active sessions
+// @Description  List the devices the current user is signed in on, with user agent, IP address and last use. The session of this request is marked current.
+// @Tags         sessions
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=[]services.Session}
+// @Failure      401 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/sessions [get]
+func ListSessions(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		sessions, err := authService.ListSessions(principal.UserID, principal.SessionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{
+				Success: false,
+				Message: "Failed to retrieve sessions",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Sessions retrieved successfully",
+			Object:  sessions,
+		})
+	}
+}
+
+// RevokeSession godoc
+// @Summary      Revoke a session
+// @Description  Sign the current user out of one session. Its refresh token stops working immediately; access tokens already issued to it expire within 15 minutes.
+// @Tags         sessions
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      404 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/sessions/{id} [delete]
+func RevokeSession(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		sessionID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{
+				Success: false,
+				Message: "Invalid session ID",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		if err := authService.RevokeSession(principal.UserID, sessionID); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrSessionNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, BaseResponse{
+				Success: false,
+				Message: "Failed to revoke session",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Session revoked successfully",
+		})
+	}
+}
+
+// RevokeAllSessions godoc
+// @Summary      Log out everywhere
+// @Description  Sign the current user out of every session. With keepCurrent=true the session of this request stays signed in.
+// @Tags         sessions
+// @Produce      json
+// @Param        keepCurrent query bool false "Keep the current session"
+// @Success      200 {object} BaseResponse{object=RevokeAllSessionsResponse}
+// @Failure      401 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/auth/sessions [delete]
+func RevokeAllSessions(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		except := uuid.Nil
+		if c.Query("keepCurrent") == "true" {
+			except = principal.SessionID
+		}
+		revoked, err := authService.RevokeAllSessions(principal.UserID, except)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{
+				Success: false,
+				Message: "Failed to revoke sessions",
+				Errors:  []string{err.Error()},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{
+			Success: true,
+			Message: "Signed out of all sessions",
+			Object:  RevokeAllSessionsResponse{Revoked: revoked},
+		})
+	}
+}
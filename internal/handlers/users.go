@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"displayName" binding:"omitempty,max=50"`
+	Bio         *string `json:"bio" binding:"omitempty,max=500"`
+	AvatarURL   *string `json:"avatarUrl" binding:"omitempty,url"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail        string `json:"newEmail" binding:"required,email"`
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+type DeleteAccountRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+}
+
+// RegisterUserRoutes registers the current user's account endpoints on an
+// authenticated group
+func RegisterUserRoutes(rg *gin.RouterGroup, userService services.UserService, authService services.AuthService, cfg *config.Config) {
+	rg.GET("/me", GetMe(userService))
+	rg.PATCH("/me", UpdateMe(userService))
+	rg.PUT("/me/avatar", UploadAvatar(userService, cfg))
+	rg.POST("/me/email", ChangeEmail(authService))
+	rg.POST("/me/password", ChangePassword(authService))
+	rg.DELETE("/me", DeleteMe(authService))
+}
+
+// GetMe godoc
+// @Summary      Get my profile
+// @Description  Get the current user's account and profile
+// @Tags         users
+// @Produce      json
+// @Success      200 {object} BaseResponse{object=models.User}
+// @Failure      401 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/users/me [get]
+func GetMe(userService services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		user, err := userService.GetProfile(principal.UserID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, BaseResponse{Success: false, Message: "User not found", Errors: []string{err.Error()}})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{Success: true, Message: "Profile retrieved successfully", Object: user})
+	}
+}
+
+// UpdateMe godoc
+// @Summary      Update my profile
+// @Description  Change the display name, bio or avatar URL. Omitted fields are left unchanged.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        updateProfileRequest body UpdateProfileRequest true "Profile fields"
+// @Success      200 {object} BaseResponse{object=models.User}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/users/me [patch]
+func UpdateMe(userService services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req UpdateProfileRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		user, err := userService.UpdateProfile(principal.UserID, services.ProfileUpdate{
+			DisplayName: req.DisplayName,
+			Bio:         req.Bio,
+			AvatarURL:   req.AvatarURL,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{Success: false, Message: "Failed to update profile", Errors: []string{err.Error()}})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{Success: true, Message: "Profile updated successfully", Object: user})
+	}
+}
+
+// UploadAvatar godoc
+// @Summary      Upload my avatar
+// @Description  Upload an avatar image and set it on the profile
+// @Tags         users
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        avatar formData file true "Avatar image"
+// @Success      200 {object} BaseResponse{object=models.User}
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/users/me/avatar [put]
+func UploadAvatar(userService services.UserService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		avatarFile, avatarHeader, err := c.Request.FormFile("avatar")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Avatar image is required", Errors: []string{err.Error()}})
+			return
+		}
+		defer avatarFile.Close()
+		avatarURL, err := UploadAvatarToCloudinary(avatarFile, avatarHeader, cfg.CloudinaryCloudName, cfg.CloudinaryAPIKey, cfg.CloudinaryAPISecret)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{Success: false, Message: "Failed to upload avatar", Errors: []string{err.Error()}})
+			return
+		}
+		user, err := userService.UpdateProfile(principal.UserID, services.ProfileUpdate{AvatarURL: &avatarURL})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{Success: false, Message: "Failed to update profile", Errors: []string{err.Error()}})
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{Success: true, Message: "Avatar updated successfully", Object: user})
+	}
+}
+
+// ChangeEmail godoc
+// @Summary      Change my email
+// @Description  Start changing the account's email address. A confirmation link is sent to the new address, to be used at /api/auth/confirm-email; the old address is notified. The email changes only once confirmed.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        changeEmailRequest body ChangeEmailRequest true "New email and current password"
+// @Success      202 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      409 {object} BaseResponse
+// @Failure      429 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/users/me/email [post]
+func ChangeEmail(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req ChangeEmailRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		if err := authService.ChangeEmail(principal.UserID, req.CurrentPassword, req.NewEmail, clientInfo(c)); err != nil {
+			respondAccountError(c, "Failed to change email", err)
+			return
+		}
+		c.JSON(http.StatusAccepted, BaseResponse{Success: true, Message: "Check your new email address to confirm the change"})
+	}
+}
+
+// ChangePassword godoc
+// @Summary      Change my password
+// @Description  Set a new password after checking the current one. The new password must follow the password policy and must not be a recent one. Every other session is signed out.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        changePasswordRequest body ChangePasswordRequest true "Current and new password"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      429 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/users/me/password [post]
+func ChangePassword(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req ChangePasswordRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		if err := authService.ChangePassword(principal.UserID, req.CurrentPassword, req.NewPassword, principal.SessionID, clientInfo(c)); err != nil {
+			if respondPasswordRejected(c, err) {
+				return
+			}
+			respondAccountError(c, "Failed to change password", err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{Success: true, Message: "Password changed successfully"})
+	}
+}
+
+// DeleteMe godoc
+// @Summary      Delete my account
+// @Description  Permanently delete the account after checking the current password. The user's movies are deleted with it, and every session, personal access token, linked identity and recovery code is removed.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        deleteAccountRequest body DeleteAccountRequest true "Current password"
+// @Success      200 {object} BaseResponse
+// @Failure      400 {object} BaseResponse
+// @Failure      401 {object} BaseResponse
+// @Failure      403 {object} BaseResponse
+// @Failure      429 {object} BaseResponse
+// @Security     BearerAuth
+// @Router       /api/users/me [delete]
+func DeleteMe(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			return
+		}
+		var req DeleteAccountRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		if err := authService.DeleteAccount(principal.UserID, req.CurrentPassword, clientInfo(c)); err != nil {
+			respondAccountError(c, "Failed to delete account", err)
+			return
+		}
+		c.JSON(http.StatusOK, BaseResponse{Success: true, Message: "Account deleted successfully"})
+	}
+}
+
+// bindJSON binds the request body into req, writing a 400 with readable
+// validation messages when it is invalid.
+func bindJSON(c *gin.Context, req interface{}) bool {
+	err := c.ShouldBindJSON(req)
+	if err == nil {
+		return true
+	}
+	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		errs := make([]string, len(validationErrors))
+		for i, err := range validationErrors {
+			errs[i] = getValidationErrorMsg(err)
+		}
+		c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Validation failed", Errors: errs})
+		return false
+	}
+	c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Invalid input format", Errors: []string{err.Error()}})
+	return false
+}
+
+// respondAccountError maps account change errors to status codes.
+func respondAccountError(c *gin.Context, message string, err error) {
+	if respondThrottled(c, err) {
+		return
+	}
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrIncorrectPassword):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrNoPassword), errors.Is(err, services.ErrSameEmail):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrEmailTaken):
+		status = http.StatusConflict
+	}
+	c.JSON(status, BaseResponse{Success: false, Message: message, Errors: []string{err.Error()}})
+}
@@ -1,47 +1,22 @@
 package handlers
 
 import (
+	"errors"
+	"net/http"
 	"regexp"
 	"strings"
 
+	"eskalate-movie-api/internal/passwordpolicy"
+
+	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 )
 
 func RegisterCustomValidators(v *validator.Validate) {
-	v.RegisterValidation("password", validatePassword)
 	v.RegisterValidation("youtubeurl", validateYouTubeURL)
 	v.RegisterValidation("customemail", validateEmail)
 }
 
-func validatePassword(fl validator.FieldLevel) bool {
-	password := fl.Field().String()
-
-	// Check minimum length
-	if len(password) < 8 {
-		return false
-	}
-
-	// Check for at least one uppercase letter
-	hasUpper := regexp.MustCompile(`[A-Z]`).MatchString(password)
-	if !hasUpper {
-		return false
-	}
-
-	// Check for at least one lowercase letter
-	hasLower := regexp.MustCompile(`[a-z]`).MatchString(password)
-	if !hasLower {
-		return false
-	}
-
-	// Check for at least one special character
-	hasSpecial := regexp.MustCompile(`[!@#$%^&*()]`).MatchString(password)
-	if !hasSpecial {
-		return false
-	}
-
-	return true
-}
-
 func validateYouTubeURL(fl validator.FieldLevel) bool {
 	url := fl.Field().String()
 	pattern := `^https?://(www\.)?(youtube\.com/watch\?v=|youtu\.be/)[\w-]{11}$`
@@ -109,3 +84,18 @@ func validateEmail(fl validator.FieldLevel) bool {
 
 	return true
 }
+
+// respondPasswordRejected writes a 400 listing each password policy rule err
+// reports as broken, and reports whether err was a policy violation.
+func respondPasswordRejected(c *gin.Context, err error) bool {
+	var violations passwordpolicy.Violations
+	if !errors.As(err, &violations) {
+		return false
+	}
+	errs := make([]string, len(violations))
+	for i, violation := range violations {
+		errs[i] = violation.Error()
+	}
+	c.JSON(http.StatusBadRequest, BaseResponse{Success: false, Message: "Password does not meet the password policy", Errors: errs})
+	return true
+}
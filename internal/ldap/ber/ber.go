@@ -0,0 +1,215 @@
+// Package ber encodes and decodes the subset of ASN.1 BER used by LDAP:
+// definite lengths and single byte tags.
+package ber
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Tag classes.
+const (
+	ClassUniversal   = 0x00
+	ClassApplication = 0x40
+	ClassContext     = 0x80
+)
+
+// Universal tags.
+const (
+	TagBoolean     = 0x01
+	TagInteger     = 0x02
+	TagOctetString = 0x04
+	TagNull        = 0x05
+	TagEnumerated  = 0x0a
+	TagSequence    = 0x10
+	TagSet         = 0x11
+)
+
+// maxLength bounds a single element, so a peer cannot make us allocate
+// arbitrary amounts of memory.
+const maxLength = 1 << 20
+
+var ErrMalformed = errors.New("ber: malformed element")
+
+// Packet is one BER element: either primitive, with its content in Value, or
+// constructed from Children.
+type Packet struct {
+	Class       byte
+	Constructed bool
+	Tag         byte
+	Value       []byte
+	Children    []*Packet
+}
+
+// Is reports whether p has the given class and tag.
+func (p *Packet) Is(class, tag byte) bool {
+	return p.Class == class && p.Tag == tag
+}
+
+// Child returns the i-th child, or nil if there is none.
+func (p *Packet) Child(i int) *Packet {
+	if i < 0 || i >= len(p.Children) {
+		return nil
+	}
+	return p.Children[i]
+}
+
+// Int decodes a primitive two's complement integer.
+func (p *Packet) Int() (int64, error) {
+	if p.Constructed || len(p.Value) == 0 || len(p.Value) > 8 {
+		return 0, ErrMalformed
+	}
+	n := int64(int8(p.Value[0]))
+	for _, b := range p.Value[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+// Str returns the content of a primitive element as a string.
+func (p *Packet) Str() string {
+	return string(p.Value)
+}
+
+// Bool decodes a primitive boolean.
+func (p *Packet) Bool() bool {
+	return len(p.Value) == 1 && p.Value[0] != 0
+}
+
+// Bytes encodes p.
+func (p *Packet) Bytes() []byte {
+	content := p.Value
+	if p.Constructed {
+		content = nil
+		for _, child := range p.Children {
+			content = append(content, child.Bytes()...)
+		}
+	}
+	identifier := p.Class | p.Tag
+	if p.Constructed {
+		identifier |= 0x20
+	}
+	out := []byte{identifier}
+	switch n := len(content); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	case n <= 0xffff:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
+
+// Read decodes the next element from r.
+func Read(r *bufio.Reader) (*Packet, error) {
+	identifier, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if identifier&0x1f == 0x1f {
+		return nil, fmt.Errorf("%w: multi-byte tag", ErrMalformed)
+	}
+	first, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		n := int(first & 0x7f)
+		if n == 0 || n > 3 {
+			return nil, fmt.Errorf("%w: unsupported length", ErrMalformed)
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxLength {
+		return nil, fmt.Errorf("%w: element too large", ErrMalformed)
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	p := &Packet{Class: identifier & 0xc0, Constructed: identifier&0x20 != 0, Tag: identifier & 0x1f}
+	if !p.Constructed {
+		p.Value = content
+		return p, nil
+	}
+	children := bufio.NewReader(bytes.NewReader(content))
+	for {
+		child, err := Read(children)
+		if err == io.EOF {
+			return p, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.Children = append(p.Children, child)
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Constructed returns a constructed element of the given class and tag.
+func Constructed(class, tag byte, children ...*Packet) *Packet {
+	return &Packet{Class: class, Constructed: true, Tag: tag, Children: children}
+}
+
+// Primitive returns a primitive element of the given class and tag.
+func Primitive(class, tag byte, value []byte) *Packet {
+	return &Packet{Class: class, Tag: tag, Value: value}
+}
+
+func Sequence(children ...*Packet) *Packet {
+	return Constructed(ClassUniversal, TagSequence, children...)
+}
+
+func Set(children ...*Packet) *Packet {
+	return Constructed(ClassUniversal, TagSet, children...)
+}
+
+func OctetString(s string) *Packet {
+	return Primitive(ClassUniversal, TagOctetString, []byte(s))
+}
+
+func Boolean(b bool) *Packet {
+	v := byte(0)
+	if b {
+		v = 0xff
+	}
+	return Primitive(ClassUniversal, TagBoolean, []byte{v})
+}
+
+func Integer(n int64) *Packet {
+	return Primitive(ClassUniversal, TagInteger, encodeInt(n))
+}
+
+func Enumerated(n int64) *Packet {
+	return Primitive(ClassUniversal, TagEnumerated, encodeInt(n))
+}
+
+// encodeInt returns the shortest two's complement encoding of n.
+func encodeInt(n int64) []byte {
+	out := []byte{byte(n)}
+	for n > 0x7f || n < -0x80 {
+		n >>= 8
+		out = append([]byte{byte(n)}, out...)
+	}
+	return out
+}
@@ -0,0 +1,322 @@
+// Package ldap is a minimal LDAPv3 client: simple binds and searches over
+// ldap://, ldaps:// or StartTLS, enough to check directory passwords.
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"eskalate-movie-api/internal/ldap/ber"
+)
+
+// Protocol operations, application tags (RFC 4511 section 4.2).
+const (
+	OpBindRequest       = 0
+	OpBindResponse      = 1
+	OpUnbindRequest     = 2
+	OpSearchRequest     = 3
+	OpSearchEntry       = 4
+	OpSearchDone        = 5
+	OpSearchReference   = 19
+	OpExtendedRequest   = 23
+	OpExtendedResponse  = 24
+	startTLSOID         = "1.3.6.1.4.1.1466.20037"
+	authSimple          = 0
+	extendedRequestName = 0
+)
+
+// Result codes.
+const (
+	ResultSuccess            = 0
+	ResultSizeLimitExceeded  = 4
+	ResultNoSuchObject       = 32
+	ResultInvalidCredentials = 49
+)
+
+// Search scopes.
+const (
+	ScopeBaseObject   = 0
+	ScopeSingleLevel  = 1
+	ScopeWholeSubtree = 2
+)
+
+var (
+	ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+	ErrUnexpectedResponse = errors.New("ldap: unexpected response")
+)
+
+// ResultError is a non-success result returned by the server.
+type ResultError struct {
+	Code    int64
+	Message string
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("ldap: result code %d: %s", e.Code, e.Message)
+}
+
+// Conn is a connection to a directory server. It is not safe for concurrent use.
+type Conn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	nextID  int64
+}
+
+// Dial connects to an ldap:// or ldaps:// URL. With startTLS, an ldap://
+// connection is upgraded to TLS before anything else is sent. tlsConfig may
+// be nil to use the system roots.
+func Dial(rawURL string, tlsConfig *tls.Config, startTLS bool, timeout time.Duration) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = u.Hostname()
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		if u.Port() == "" {
+			host = net.JoinHostPort(host, "389")
+		}
+		conn, err = dialer.Dial("tcp", host)
+	case "ldaps":
+		if u.Port() == "" {
+			host = net.JoinHostPort(host, "636")
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, tlsConfig)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported URL scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{conn: conn, r: bufio.NewReader(conn), timeout: timeout}
+	if startTLS && u.Scheme == "ldap" {
+		if err := c.startTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Bind authenticates the connection with a simple bind. An empty password is
+// refused rather than sent, since servers treat it as an anonymous bind
+// that always succeeds.
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" {
+		return ErrInvalidCredentials
+	}
+	resp, err := c.roundTrip(ber.Constructed(ber.ClassApplication, OpBindRequest,
+		ber.Integer(3),
+		ber.OctetString(dn),
+		ber.Primitive(ber.ClassContext, authSimple, []byte(password)),
+	))
+	if err != nil {
+		return err
+	}
+	if !resp.Is(ber.ClassApplication, OpBindResponse) {
+		return ErrUnexpectedResponse
+	}
+	err = resultError(resp)
+	var result *ResultError
+	if errors.As(err, &result) && result.Code == ResultInvalidCredentials {
+		return ErrInvalidCredentials
+	}
+	return err
+}
+
+// SearchRequest describes a search; Filter uses the RFC 4515 string form.
+type SearchRequest struct {
+	BaseDN     string
+	Scope      int
+	Filter     string
+	Attributes []string
+	SizeLimit  int
+}
+
+// Entry is a search result.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Values returns the values of an attribute, matching its name case-insensitively.
+func (e *Entry) Values(name string) []string {
+	for attr, values := range e.Attributes {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// Value returns the first value of an attribute, or "".
+func (e *Entry) Value(name string) string {
+	if values := e.Values(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Search runs a search and returns the entries found. Referrals are ignored,
+// and hitting the size limit returns the entries up to it.
+func (c *Conn) Search(req SearchRequest) ([]Entry, error) {
+	filter, err := CompileFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	attrs := ber.Sequence()
+	for _, attr := range req.Attributes {
+		attrs.Children = append(attrs.Children, ber.OctetString(attr))
+	}
+	id, err := c.send(ber.Constructed(ber.ClassApplication, OpSearchRequest,
+		ber.OctetString(req.BaseDN),
+		ber.Enumerated(int64(req.Scope)),
+		ber.Enumerated(0), // never dereference aliases
+		ber.Integer(int64(req.SizeLimit)),
+		ber.Integer(int64(c.timeout/time.Second)),
+		ber.Boolean(false),
+		filter,
+		attrs,
+	))
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case op.Is(ber.ClassApplication, OpSearchEntry):
+			entry, err := parseEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case op.Is(ber.ClassApplication, OpSearchReference):
+		case op.Is(ber.ClassApplication, OpSearchDone):
+			if err := resultError(op); err != nil {
+				var result *ResultError
+				if errors.As(err, &result) && result.Code == ResultNoSuchObject {
+					return nil, nil
+				}
+				if errors.As(err, &result) && result.Code == ResultSizeLimitExceeded {
+					return entries, nil
+				}
+				return nil, err
+			}
+			return entries, nil
+		default:
+			return nil, ErrUnexpectedResponse
+		}
+	}
+}
+
+// Close sends an unbind request and closes the connection.
+func (c *Conn) Close() error {
+	c.send(ber.Primitive(ber.ClassApplication, OpUnbindRequest, nil))
+	return c.conn.Close()
+}
+
+func (c *Conn) startTLS(tlsConfig *tls.Config) error {
+	resp, err := c.roundTrip(ber.Constructed(ber.ClassApplication, OpExtendedRequest,
+		ber.Primitive(ber.ClassContext, extendedRequestName, []byte(startTLSOID)),
+	))
+	if err != nil {
+		return err
+	}
+	if !resp.Is(ber.ClassApplication, OpExtendedResponse) {
+		return ErrUnexpectedResponse
+	}
+	if err := resultError(resp); err != nil {
+		return err
+	}
+	tlsConn := tls.Client(c.conn, tlsConfig)
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	c.conn = tlsConn
+	c.r = bufio.NewReader(tlsConn)
+	return nil
+}
+
+func (c *Conn) roundTrip(op *ber.Packet) (*ber.Packet, error) {
+	id, err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+	return c.receive(id)
+}
+
+func (c *Conn) send(op *ber.Packet) (int64, error) {
+	c.nextID++
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(ber.Sequence(ber.Integer(c.nextID), op).Bytes())
+	return c.nextID, err
+}
+
+// receive reads the next message, which must answer message id.
+func (c *Conn) receive(id int64) (*ber.Packet, error) {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	msg, err := ber.Read(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Children) < 2 {
+		return nil, ErrUnexpectedResponse
+	}
+	if got, err := msg.Children[0].Int(); err != nil || got != id {
+		return nil, ErrUnexpectedResponse
+	}
+	return msg.Children[1], nil
+}
+
+// resultError returns the LDAPResult at the start of op as an error, or nil on success.
+func resultError(op *ber.Packet) error {
+	if len(op.Children) < 3 {
+		return ErrUnexpectedResponse
+	}
+	code, err := op.Children[0].Int()
+	if err != nil {
+		return ErrUnexpectedResponse
+	}
+	if code == ResultSuccess {
+		return nil
+	}
+	return &ResultError{Code: code, Message: op.Children[2].Str()}
+}
+
+func parseEntry(op *ber.Packet) (Entry, error) {
+	if len(op.Children) < 2 {
+		return Entry{}, ErrUnexpectedResponse
+	}
+	entry := Entry{DN: op.Children[0].Str(), Attributes: map[string][]string{}}
+	for _, attr := range op.Children[1].Children {
+		if len(attr.Children) < 2 {
+			return Entry{}, ErrUnexpectedResponse
+		}
+		name := attr.Children[0].Str()
+		for _, value := range attr.Children[1].Children {
+			entry.Attributes[name] = append(entry.Attributes[name], value.Str())
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,152 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LoginPlaceholder is replaced by the escaped login in a user filter.
+const LoginPlaceholder = "{login}"
+
+var ErrAmbiguousLogin = errors.New("ldap: login matches more than one entry")
+
+// Config describes how users are looked up in a directory. When BindDN is
+// set the search runs as that service account, otherwise anonymously.
+type Config struct {
+	URL                  string
+	StartTLS             bool
+	TLSConfig            *tls.Config
+	Timeout              time.Duration
+	BindDN               string
+	BindPassword         string
+	BaseDN               string
+	UserFilter           string
+	EmailAttribute       string
+	UsernameAttribute    string
+	DisplayNameAttribute string
+	GroupAttribute       string
+}
+
+// Identity is a directory user whose password has been checked.
+type Identity struct {
+	DN          string
+	Email       string
+	Username    string
+	DisplayName string
+	Groups      []string
+}
+
+// Directory checks passwords against an LDAP server.
+type Directory struct {
+	cfg Config
+}
+
+func NewDirectory(cfg Config) *Directory {
+	return &Directory{cfg: cfg}
+}
+
+// Authenticate finds the single entry matching login and binds as it with
+// password. Unknown logins and wrong passwords both return
+// ErrInvalidCredentials.
+func (d *Directory) Authenticate(login, password string) (*Identity, error) {
+	if login == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	conn, err := Dial(d.cfg.URL, d.cfg.TLSConfig, d.cfg.StartTLS, d.cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if d.cfg.BindDN != "" {
+		// Not wrapped: a rejected service account is not a wrong user password
+		if err := conn.Bind(d.cfg.BindDN, d.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap: service account bind failed: %v", err)
+		}
+	}
+	entries, err := conn.Search(SearchRequest{
+		BaseDN:     d.cfg.BaseDN,
+		Scope:      ScopeWholeSubtree,
+		Filter:     strings.ReplaceAll(d.cfg.UserFilter, LoginPlaceholder, EscapeFilter(login)),
+		Attributes: []string{d.cfg.EmailAttribute, d.cfg.UsernameAttribute, d.cfg.DisplayNameAttribute, d.cfg.GroupAttribute},
+		SizeLimit:  2,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+	if len(entries) > 1 {
+		return nil, ErrAmbiguousLogin
+	}
+	entry := entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		return nil, err
+	}
+	return &Identity{
+		DN:          entry.DN,
+		Email:       entry.Value(d.cfg.EmailAttribute),
+		Username:    entry.Value(d.cfg.UsernameAttribute),
+		DisplayName: entry.Value(d.cfg.DisplayNameAttribute),
+		Groups:      entry.Values(d.cfg.GroupAttribute),
+	}, nil
+}
+
+// InGroup reports whether the identity is a member of the group with the
+// given DN. DNs are compared ignoring case and spaces around separators.
+func (i *Identity) InGroup(dn string) bool {
+	want := NormalizeDN(dn)
+	for _, group := range i.Groups {
+		if NormalizeDN(group) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeDN lower-cases a DN and trims the spaces around its RDNs.
+func NormalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, part := range parts {
+		attr, value, _ := strings.Cut(part, "=")
+		parts[i] = strings.TrimSpace(attr) + "=" + strings.TrimSpace(value)
+	}
+	return strings.ToLower(strings.Join(parts, ","))
+}
+
+// GroupRole grants Role to the members of the group with DN Group.
+type GroupRole struct {
+	Group string
+	Role  string
+}
+
+// ParseGroupRoles parses a semicolon separated list of role=groupDN pairs,
+// such as "admin=cn=admins,ou=groups,dc=example,dc=org". Pairs without a
+// role or group are skipped.
+func ParseGroupRoles(value string) []GroupRole {
+	var mapping []GroupRole
+	for _, pair := range strings.Split(value, ";") {
+		role, group, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		role, group = strings.TrimSpace(role), strings.TrimSpace(group)
+		if ok && role != "" && group != "" {
+			mapping = append(mapping, GroupRole{Group: group, Role: role})
+		}
+	}
+	return mapping
+}
+
+// Role returns the role of the first mapping whose group the identity is a
+// member of, or fallback when there is none. Mappings are therefore listed
+// from the most to the least privileged.
+func (i *Identity) Role(mapping []GroupRole, fallback string) string {
+	for _, m := range mapping {
+		if i.InGroup(m.Group) {
+			return m.Role
+		}
+	}
+	return fallback
+}
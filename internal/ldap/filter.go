@@ -0,0 +1,181 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"eskalate-movie-api/internal/ldap/ber"
+)
+
+// Filter choices (RFC 4511 section 4.5.1), context specific tags.
+const (
+	FilterAnd        = 0
+	FilterOr         = 1
+	FilterNot        = 2
+	FilterEquality   = 3
+	FilterSubstrings = 4
+	FilterPresent    = 7
+)
+
+// Substring filter parts.
+const (
+	SubstringInitial = 0
+	SubstringAny     = 1
+	SubstringFinal   = 2
+)
+
+var ErrInvalidFilter = errors.New("ldap: invalid search filter")
+
+// EscapeFilter escapes a value for use inside a search filter, so user input
+// cannot change the filter's structure.
+func EscapeFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// CompileFilter parses a string filter such as (&(objectClass=person)(mail=a@b))
+// into its BER form. Equality, presence, substring, and, or and not filters
+// are supported.
+func CompileFilter(filter string) (*ber.Packet, error) {
+	p := &filterParser{s: filter}
+	packet, err := p.filter()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.s) {
+		return nil, ErrInvalidFilter
+	}
+	return packet, nil
+}
+
+type filterParser struct {
+	s   string
+	pos int
+}
+
+func (p *filterParser) filter() (*ber.Packet, error) {
+	if !p.consume('(') {
+		return nil, ErrInvalidFilter
+	}
+	var packet *ber.Packet
+	var err error
+	switch {
+	case p.consume('&'):
+		packet, err = p.set(FilterAnd)
+	case p.consume('|'):
+		packet, err = p.set(FilterOr)
+	case p.consume('!'):
+		var inner *ber.Packet
+		if inner, err = p.filter(); err == nil {
+			packet = ber.Constructed(ber.ClassContext, FilterNot, inner)
+		}
+	default:
+		packet, err = p.item()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume(')') {
+		return nil, ErrInvalidFilter
+	}
+	return packet, nil
+}
+
+func (p *filterParser) set(tag byte) (*ber.Packet, error) {
+	packet := ber.Constructed(ber.ClassContext, tag)
+	for p.pos < len(p.s) && p.s[p.pos] == '(' {
+		child, err := p.filter()
+		if err != nil {
+			return nil, err
+		}
+		packet.Children = append(packet.Children, child)
+	}
+	if len(packet.Children) == 0 {
+		return nil, ErrInvalidFilter
+	}
+	return packet, nil
+}
+
+// item parses attr=value, attr=* or attr=with*wildcards.
+func (p *filterParser) item() (*ber.Packet, error) {
+	end := strings.IndexByte(p.s[p.pos:], ')')
+	if end < 0 {
+		return nil, ErrInvalidFilter
+	}
+	attr, raw, ok := strings.Cut(p.s[p.pos:p.pos+end], "=")
+	if !ok || attr == "" || strings.ContainsAny(attr, "<>~:*") {
+		return nil, ErrInvalidFilter
+	}
+	p.pos += end
+	if raw == "*" {
+		return ber.Primitive(ber.ClassContext, FilterPresent, []byte(attr)), nil
+	}
+	parts := strings.Split(raw, "*")
+	values := make([]string, len(parts))
+	for i, part := range parts {
+		value, err := unescapeFilter(part)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	if len(values) == 1 {
+		return ber.Constructed(ber.ClassContext, FilterEquality, ber.OctetString(attr), ber.OctetString(values[0])), nil
+	}
+	substrings := ber.Sequence()
+	for i, value := range values {
+		if value == "" {
+			continue
+		}
+		kind := byte(SubstringAny)
+		if i == 0 {
+			kind = SubstringInitial
+		} else if i == len(values)-1 {
+			kind = SubstringFinal
+		}
+		substrings.Children = append(substrings.Children, ber.Primitive(ber.ClassContext, kind, []byte(value)))
+	}
+	return ber.Constructed(ber.ClassContext, FilterSubstrings, ber.OctetString(attr), substrings), nil
+}
+
+func (p *filterParser) consume(c byte) bool {
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// unescapeFilter decodes the \xx escapes of a filter value.
+func unescapeFilter(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+3 > len(s) {
+			return "", ErrInvalidFilter
+		}
+		n, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", ErrInvalidFilter
+		}
+		b.WriteByte(byte(n))
+		i += 2
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,283 @@
+// Package ldaptest provides an in-process LDAP server for tests, in the
+// spirit of net/http/httptest. It supports simple binds and subtree searches
+// over a fixed set of entries.
+package ldaptest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+
+	"eskalate-movie-api/internal/ldap"
+	"eskalate-movie-api/internal/ldap/ber"
+)
+
+const (
+	resultProtocolError      = 2
+	resultInsufficientAccess = 50
+)
+
+// Entry is a directory entry. Password, when set, lets clients bind as DN.
+type Entry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// Server serves Entries on a local port until closed.
+type Server struct {
+	// URL is the ldap:// URL of the server
+	URL string
+	// AllowAnonymousSearch lets clients search without binding first
+	AllowAnonymousSearch bool
+
+	listener net.Listener
+	mu       sync.Mutex
+	entries  []Entry
+	conns    map[net.Conn]bool
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server holding entries.
+func NewServer(entries ...Entry) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{URL: "ldap://" + listener.Addr().String(), listener: listener, entries: entries, conns: map[net.Conn]bool{}}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server, closing any open connections.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// SetPassword changes the password of the entry with the given DN.
+func (s *Server) SetPassword(dn, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if ldap.NormalizeDN(s.entries[i].DN) == ldap.NormalizeDN(dn) {
+			s.entries[i].Password = password
+		}
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	bound := false
+	for {
+		msg, err := ber.Read(r)
+		if err != nil || len(msg.Children) < 2 {
+			return
+		}
+		id, _ := msg.Children[0].Int()
+		op := msg.Children[1]
+		reply := func(ops ...*ber.Packet) {
+			for _, op := range ops {
+				conn.Write(ber.Sequence(ber.Integer(id), op).Bytes())
+			}
+		}
+		switch {
+		case op.Is(ber.ClassApplication, ldap.OpBindRequest):
+			code := s.bind(op)
+			bound = code == ldap.ResultSuccess
+			reply(result(ldap.OpBindResponse, code))
+		case op.Is(ber.ClassApplication, ldap.OpSearchRequest):
+			if !bound && !s.AllowAnonymousSearch {
+				reply(result(ldap.OpSearchDone, resultInsufficientAccess))
+				continue
+			}
+			reply(s.search(op)...)
+		case op.Is(ber.ClassApplication, ldap.OpUnbindRequest):
+			return
+		default:
+			reply(result(ldap.OpExtendedResponse, resultProtocolError))
+		}
+	}
+}
+
+func result(tag byte, code int64) *ber.Packet {
+	return ber.Constructed(ber.ClassApplication, tag, ber.Enumerated(code), ber.OctetString(""), ber.OctetString(""))
+}
+
+func (s *Server) bind(op *ber.Packet) int64 {
+	if len(op.Children) < 3 || !op.Children[2].Is(ber.ClassContext, 0) {
+		return resultProtocolError
+	}
+	dn, password := ldap.NormalizeDN(op.Children[1].Str()), op.Children[2].Str()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if ldap.NormalizeDN(e.DN) == dn && e.Password != "" && e.Password == password {
+			return ldap.ResultSuccess
+		}
+	}
+	return ldap.ResultInvalidCredentials
+}
+
+func (s *Server) search(op *ber.Packet) []*ber.Packet {
+	if len(op.Children) < 8 {
+		return []*ber.Packet{result(ldap.OpSearchDone, resultProtocolError)}
+	}
+	base := ldap.NormalizeDN(op.Children[0].Str())
+	sizeLimit, _ := op.Children[3].Int()
+	filter := op.Children[6]
+	var wanted []string
+	for _, attr := range op.Children[7].Children {
+		wanted = append(wanted, attr.Str())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*ber.Packet
+	for _, e := range s.entries {
+		dn := ldap.NormalizeDN(e.DN)
+		if dn != base && !strings.HasSuffix(dn, ","+base) {
+			continue
+		}
+		if !matches(filter, e) {
+			continue
+		}
+		if sizeLimit > 0 && int64(len(out)) == sizeLimit {
+			return append(out, result(ldap.OpSearchDone, ldap.ResultSizeLimitExceeded))
+		}
+		out = append(out, entryPacket(e, wanted))
+	}
+	return append(out, result(ldap.OpSearchDone, ldap.ResultSuccess))
+}
+
+func entryPacket(e Entry, wanted []string) *ber.Packet {
+	attrs := ber.Sequence()
+	for name, values := range e.Attributes {
+		if len(wanted) > 0 && !containsFold(wanted, name) {
+			continue
+		}
+		set := ber.Set()
+		for _, v := range values {
+			set.Children = append(set.Children, ber.OctetString(v))
+		}
+		attrs.Children = append(attrs.Children, ber.Sequence(ber.OctetString(name), set))
+	}
+	return ber.Constructed(ber.ClassApplication, ldap.OpSearchEntry, ber.OctetString(e.DN), attrs)
+}
+
+// matches evaluates a BER encoded filter against an entry, comparing values
+// case-insensitively.
+func matches(f *ber.Packet, e Entry) bool {
+	switch f.Tag {
+	case ldap.FilterAnd:
+		for _, child := range f.Children {
+			if !matches(child, e) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range f.Children {
+			if matches(child, e) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return len(f.Children) == 1 && !matches(f.Children[0], e)
+	case ldap.FilterPresent:
+		return len(values(e, f.Str())) > 0
+	case ldap.FilterEquality:
+		if len(f.Children) != 2 {
+			return false
+		}
+		for _, v := range values(e, f.Children[0].Str()) {
+			if strings.EqualFold(v, f.Children[1].Str()) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterSubstrings:
+		if len(f.Children) != 2 {
+			return false
+		}
+		for _, v := range values(e, f.Children[0].Str()) {
+			if matchSubstrings(strings.ToLower(v), f.Children[1].Children) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func matchSubstrings(v string, parts []*ber.Packet) bool {
+	for _, part := range parts {
+		s := strings.ToLower(part.Str())
+		switch part.Tag {
+		case ldap.SubstringInitial:
+			if !strings.HasPrefix(v, s) {
+				return false
+			}
+			v = v[len(s):]
+		case ldap.SubstringAny:
+			i := strings.Index(v, s)
+			if i < 0 {
+				return false
+			}
+			v = v[i+len(s):]
+		case ldap.SubstringFinal:
+			if !strings.HasSuffix(v, s) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func values(e Entry, name string) []string {
+	for attr, vals := range e.Attributes {
+		if strings.EqualFold(attr, name) {
+			return vals
+		}
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+// Package mailer delivers transactional email such as verification links.
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New returns the mailer selected by driver: "smtp" delivers through an SMTP
+// relay, "outbox" (the default) writes messages to files in outboxDir.
+func New(driver, from, smtpHost, smtpPort, smtpUsername, smtpPassword, outboxDir string) (Mailer, error) {
+	switch driver {
+	case "smtp":
+		return NewSMTPMailer(smtpHost, smtpPort, smtpUsername, smtpPassword, from), nil
+	case "", "outbox":
+		return NewOutboxMailer(outboxDir, from)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", driver)
+	}
+}
+
+// render formats msg as an RFC 5322 message.
+func render(from string, msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return b.Bytes()
+}
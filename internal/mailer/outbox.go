@@ -0,0 +1,55 @@
+package mailer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// OutboxMailer writes every message to an .eml file instead of sending it,
+// and keeps a copy in memory. It is meant for local development and tests.
+type OutboxMailer struct {
+	dir  string
+	from string
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+// NewOutboxMailer creates the outbox directory if needed. An empty dir keeps
+// messages in memory only.
+func NewOutboxMailer(dir, from string) (*OutboxMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &OutboxMailer{dir: dir, from: from}, nil
+}
+
+func (m *OutboxMailer) Send(msg Message) error {
+	m.mu.Lock()
+	m.sent = append(m.sent, msg)
+	m.mu.Unlock()
+
+	if m.dir == "" {
+		return nil
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(m.dir, name), render(m.from, msg), 0o600)
+}
+
+// Sent returns the messages sent so far, oldest first.
+func (m *OutboxMailer) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.sent...)
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"errors"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPMailer delivers mail through an SMTP relay, authenticating with PLAIN
+// when a username is configured.
+type SMTPMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	if port == "" {
+		port = "587"
+	}
+	return &SMTPMailer{
+		addr:     net.JoinHostPort(host, port),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") {
+		return errors.New("invalid recipient")
+	}
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	return smtp.SendMail(m.addr, auth, m.from, []string{msg.To}, render(m.from, msg))
+}
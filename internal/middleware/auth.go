@@ -4,32 +4,97 @@ import (
 	"net/http"
 	"strings"
 
+	"eskalate-movie-api/internal/revocation"
+	"eskalate-movie-api/internal/tokens"
+
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-// AuthMiddleware validates JWT and sets userID in context
-func AuthMiddleware(secret string) gin.HandlerFunc {
+// TokenAuthenticator resolves personal access tokens to the caller they were
+// issued to.
+type TokenAuthenticator interface {
+	Authenticate(token string) (*tokens.Identity, error)
+}
+
+// AuthMiddleware authenticates the bearer token and attaches the caller's
+// Principal to the context. Personal access tokens are checked with pats;
+// anything else must be a JWT access token signed by the key ring that
+// denylist does not report as revoked. Without an Authorization header the
+// access token cookie of a browser session is used, and state-changing
+// requests must then pass the CSRF check
+func AuthMiddleware(keys *tokens.KeyRing, pats TokenAuthenticator, denylist revocation.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		header := c.GetHeader("Authorization")
-		if header == "" || !strings.HasPrefix(header, "Bearer ") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Missing or invalid Authorization header"})
+		var tokenStr string
+		if header := c.GetHeader("Authorization"); header != "" {
+			if !strings.HasPrefix(header, "Bearer ") {
+				AbortUnauthorized(c, "Missing or invalid Authorization header")
+				return
+			}
+			tokenStr = strings.TrimPrefix(header, "Bearer ")
+		} else if cookie, err := c.Cookie(AccessTokenCookie); err == nil && cookie != "" {
+			if !SafeMethod(c.Request.Method) && !ValidCSRF(c) {
+				AbortForbidden(c, "Missing or invalid CSRF token")
+				return
+			}
+			tokenStr = cookie
+		} else {
+			AbortUnauthorized(c, "Missing or invalid Authorization header")
 			return
 		}
-		tokenStr := strings.TrimPrefix(header, "Bearer ")
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
-		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Invalid or expired token"})
+
+		if strings.HasPrefix(tokenStr, tokens.PersonalAccessTokenScheme) {
+			identity, err := pats.Authenticate(tokenStr)
+			if err != nil {
+				AbortUnauthorized(c, "Invalid, expired or revoked token")
+				return
+			}
+			SetPrincipal(c, &Principal{
+				UserID:        identity.UserID,
+				Roles:         identity.Roles,
+				Permissions:   identity.Permissions,
+				Scopes:        identity.Scopes,
+				EmailVerified: identity.EmailVerified,
+				TokenID:       identity.TokenID,
+			})
+			c.Next()
 			return
 		}
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || claims["user_id"] == nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Invalid token claims"})
+
+		var claims tokens.AccessClaims
+		token, err := keys.Parse(tokenStr, &claims)
+		if err != nil || !token.Valid || claims.Use != tokens.UseAccess {
+			AbortUnauthorized(c, "Invalid or expired token")
 			return
 		}
-		c.Set("userID", claims["user_id"])
+		userID, err := uuid.Parse(claims.UserID)
+		if err != nil {
+			AbortUnauthorized(c, "Invalid token claims")
+			return
+		}
+		// Tokens issued before revocation existed carry no jti
+		if claims.ID != "" {
+			revoked, err := denylist.IsRevoked(claims.ID)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"success": false, "message": "Service Unavailable", "errors": []string{"Could not check token revocation"}})
+				return
+			}
+			if revoked {
+				AbortUnauthorized(c, "Token has been revoked")
+				return
+			}
+		}
+		// Tokens issued before sessions were tracked carry no sid
+		sessionID, _ := uuid.Parse(claims.SessionID)
+		SetPrincipal(c, &Principal{
+			UserID:      userID,
+			Roles:       claims.Roles,
+			Permissions: claims.Permissions,
+			Scopes:      claims.Scopes,
+
+			EmailVerified: claims.EmailVerified,
+			SessionID:     sessionID,
+		})
 		c.Next()
 	}
 }
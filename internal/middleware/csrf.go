@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Browser sessions keep their access token in an HttpOnly cookie. Requests
+// authenticated by that cookie are protected against cross-site request
+// forgery with a double-submit token: the CSRF cookie, readable by the page,
+// must be echoed in the CSRF header of every state-changing request.
+const (
+	AccessTokenCookie = "access_token"
+	CSRFCookie        = "csrf_token"
+	CSRFHeader        = "X-CSRF-Token"
+)
+
+// SafeMethod reports whether method cannot change state and so needs no
+// CSRF token.
+func SafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// ValidCSRF reports whether the request carries a CSRF header matching its
+// CSRF cookie.
+func ValidCSRF(c *gin.Context) bool {
+	cookie, err := c.Cookie(CSRFCookie)
+	header := c.GetHeader(CSRFHeader)
+	if err != nil || cookie == "" || header == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// RequirePermission allows the request through only when the authenticated
+// principal holds every listed permission. It must run after AuthMiddleware.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			AbortUnauthorized(c, "Authentication required")
+			return
+		}
+		for _, permission := range permissions {
+			if !principal.Can(permission) {
+				AbortForbidden(c, "Missing permission: "+permission)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail allows the request through only for principals who
+// have confirmed their email address. It must run after AuthMiddleware.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			AbortUnauthorized(c, "Authentication required")
+			return
+		}
+		if !principal.EmailVerified {
+			AbortForbidden(c, "Email address not verified")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope limits personal access tokens to routes within their scopes.
+// Session access tokens are not scoped and always pass. It must run after
+// AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			AbortUnauthorized(c, "Authentication required")
+			return
+		}
+		if principal.ViaPersonalAccessToken() && !principal.HasScope(scope) {
+			AbortForbidden(c, "Token missing scope: "+scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession rejects personal access tokens, keeping account security
+// settings behind an interactive login. It must run after AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			AbortUnauthorized(c, "Authentication required")
+			return
+		}
+		if principal.ViaPersonalAccessToken() {
+			AbortForbidden(c, "Personal access tokens cannot be used here; sign in instead")
+			return
+		}
+		c.Next()
+	}
+}
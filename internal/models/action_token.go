@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ActionToken records a single-use token sent to a user out of band, such as
+// an email verification link. The token itself is a signed JWT whose jti is
+// the row ID; the row only tracks expiry and whether it has been used.
+// BindingHash, when set, ties the token to the browser that asked for it.
+type ActionToken struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	Purpose     string     `gorm:"not null;index" json:"purpose"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expiresAt"`
+	UsedAt      *time.Time `json:"usedAt,omitempty"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	BindingHash string     `gorm:"size:64" json:"-"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
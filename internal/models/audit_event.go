@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audited actions.
+const (
+	AuditUserDisable     = "user.disable"
+	AuditUserEnable      = "user.enable"
+	AuditUserLogout      = "user.force_logout"
+	AuditUserMFAReset    = "user.mfa_reset"
+	AuditUserRoleChange  = "user.role_change"
+	AuditUserProvision   = "user.provision"
+	AuditUserUpdate      = "user.update"
+	AuditUserDeprovision = "user.deprovision"
+
+	AuditSignup         = "auth.signup"
+	AuditLogin          = "auth.login"
+	AuditRefresh        = "auth.refresh"
+	AuditLogout         = "auth.logout"
+	AuditPasswordChange = "auth.password_change"
+	AuditPasswordReset  = "auth.password_reset"
+
+	AuditLogExport = "audit.export"
+)
+
+// Outcomes of audited actions.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent records an action taken on or by an account, and whether it
+// succeeded. Rows are only ever inserted; the database refuses updates and
+// deletes.
+type AuditEvent struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	ActorID   *uuid.UUID `gorm:"type:uuid;index" json:"actorId,omitempty"`
+	Action    string     `gorm:"not null;index" json:"action"`
+	TargetID  *uuid.UUID `gorm:"type:uuid;index" json:"targetId,omitempty"`
+	Outcome   string     `gorm:"size:16;not null;default:success;index" json:"outcome"`
+	Details   string     `json:"details,omitempty"`
+	IPAddress string     `json:"ipAddress"`
+	UserAgent string     `json:"userAgent"`
+	CreatedAt time.Time  `gorm:"not null;index" json:"createdAt"`
+}
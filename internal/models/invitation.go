@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Invitation lets people sign up while registration is invite-only, and can
+// give them a role other than member. Like personal access tokens, its code
+// is an opaque "inv_<prefix>.<secret>" string of which only the prefix and
+// a keyed hash are stored.
+type Invitation struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	CreatedByID uuid.UUID  `gorm:"type:uuid;not null;index" json:"createdById"`
+	CodePrefix  string     `gorm:"size:32;not null;uniqueIndex" json:"prefix"`
+	CodeHash    string     `gorm:"size:64;not null" json:"-"`
+	Role        string     `gorm:"not null;default:member" json:"role"`
+	Note        string     `gorm:"size:200" json:"note,omitempty"`
+	MaxUses     int        `gorm:"not null" json:"maxUses"`
+	Uses        int        `gorm:"not null;default:0" json:"uses"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expiresAt"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// Active reports whether the invitation can still be used at now.
+func (i *Invitation) Active(now time.Time) bool {
+	return i.RevokedAt == nil && now.Before(i.ExpiresAt) && i.Uses < i.MaxUses
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Job run outcomes.
+const (
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// What started a job run.
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+// JobRun is the last run of a background job, shared by every server
+// instance so each can tell when the job last ran anywhere. Affected is the
+// number of rows the job deleted or changed, and Instance the host that ran it.
+type JobRun struct {
+	Name       string    `gorm:"primaryKey" json:"name"`
+	Status     string    `gorm:"size:16;not null" json:"status"`
+	Trigger    string    `gorm:"size:16;not null" json:"trigger"`
+	Error      string    `gorm:"size:1000" json:"error,omitempty"`
+	Affected   int64     `gorm:"not null;default:0" json:"affected"`
+	Instance   string    `gorm:"size:255" json:"instance"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
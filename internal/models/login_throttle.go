@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Kinds of login throttle.
+const (
+	ThrottleAccount = "account"
+	ThrottleIP      = "ip"
+)
+
+// LoginThrottle counts recent failed logins for an account, identified by
+// the email address tried, or for a client IP address. While LockedUntil is
+// in the future, logins for that email or from that address are refused.
+type LoginThrottle struct {
+	Kind          string     `gorm:"primaryKey" json:"kind"`
+	Subject       string     `gorm:"primaryKey" json:"subject"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"lastFailureAt"`
+	LockedUntil   *time.Time `gorm:"index" json:"lockedUntil,omitempty"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
@@ -0,0 +1,6 @@
+package models
+
+// All returns every model stored in the database, for migrations.
+func All() []interface{} {
+	return []interface{}{&User{}, &UserPermission{}, &Movie{}, &RefreshToken{}, &ActionToken{}, &RecoveryCode{}, &UserIdentity{}, &OIDCLoginState{}, &PersonalAccessToken{}, &LoginThrottle{}, &AuditEvent{}, &RevokedAccessToken{}, &Passkey{}, &WebAuthnCeremony{}, &Invitation{}, &PasswordHistory{}, &JobRun{}}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Passkey is a WebAuthn credential registered by a user. PublicKey is the
+// COSE encoded key from registration and SignCount the authenticator's
+// signature counter as of the last login, used to detect cloned
+// authenticators.
+type Passkey struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	Name           string     `gorm:"size:100;not null" json:"name"`
+	CredentialID   []byte     `gorm:"not null;uniqueIndex" json:"-"`
+	PublicKey      []byte     `gorm:"not null" json:"-"`
+	Algorithm      int        `gorm:"not null" json:"algorithm"`
+	SignCount      uint32     `gorm:"not null;default:0" json:"-"`
+	Transports     ScopeList  `gorm:"type:text" json:"transports" swaggertype:"array,string"`
+	BackupEligible bool       `gorm:"not null;default:false" json:"backupEligible"`
+	LastUsedAt     *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// Purposes of a WebAuthn ceremony.
+const (
+	CeremonyRegistration = "registration"
+	CeremonyLogin        = "login"
+	CeremonyMFA          = "mfa"
+)
+
+// WebAuthnCeremony is the server side of a registration or login in flight:
+// the challenge handed to the browser, which must be signed back before
+// ExpiresAt. UserID is unset for passkey logins that do not name a user.
+type WebAuthnCeremony struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"-"`
+	UserID    *uuid.UUID `gorm:"type:uuid" json:"-"`
+	Purpose   string     `gorm:"not null" json:"-"`
+	Challenge []byte     `gorm:"not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"-"`
+	CreatedAt time.Time  `json:"-"`
+}
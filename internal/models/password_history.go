@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordHistory is a password hash a user has replaced, kept so the
+// password cannot be chosen again while it is among their recent ones.
+type PasswordHistory struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"userId"`
+	Hash      string    `gorm:"not null" json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes a personal access token can be granted. Session tokens from login
+// are not limited by scopes.
+const (
+	ScopeMoviesWrite = "movies:write"
+	// ScopeSCIM lets a provisioning client use the SCIM API
+	ScopeSCIM = "scim"
+)
+
+// ValidScopes lists every scope a personal access token may request.
+var ValidScopes = []string{ScopeMoviesWrite, ScopeSCIM}
+
+// ValidScope reports whether scope is one of the known scopes.
+func ValidScope(scope string) bool {
+	for _, s := range ValidScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeList is stored as a space separated string.
+type ScopeList []string
+
+func (l ScopeList) Value() (driver.Value, error) {
+	return strings.Join(l, " "), nil
+}
+
+func (l *ScopeList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		*l = strings.Fields(v)
+	case []byte:
+		*l = strings.Fields(string(v))
+	case nil:
+		*l = nil
+	default:
+		return fmt.Errorf("cannot scan %T into ScopeList", value)
+	}
+	return nil
+}
+
+// PersonalAccessToken is a long-lived, scoped credential a user creates for
+// scripts. Like refresh tokens it is an opaque "pat_<prefix>.<secret>" string
+// of which only the prefix and a keyed hash are stored.
+type PersonalAccessToken struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	Name        string     `gorm:"not null" json:"name"`
+	TokenPrefix string     `gorm:"size:32;not null;uniqueIndex" json:"prefix"`
+	TokenHash   string     `gorm:"size:64;not null" json:"-"`
+	Scopes      ScopeList  `gorm:"type:text;not null" json:"scopes" swaggertype:"array,string"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expiresAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
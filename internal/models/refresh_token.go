@@ -6,11 +6,29 @@ import (
 	"github.com/google/uuid"
 )
 
+// RefreshToken is a single-use refresh token. Every refresh consumes the
+// presented token and issues its successor in the same family, so all tokens
+// descended from one login share a FamilyID. The token itself is never
+// stored: TokenPrefix locates the row and TokenHash is a keyed hash of the
+// full value.
+//
+// A family is what users see as a session: StartedAt is when its login
+// happened, and each token records the client that last used the session.
+// AccessTokenID is the jti of the access token issued with the refresh
+// token, denylisted when the session is revoked.
 type RefreshToken struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"userId"`
-	Token     string    `gorm:"not null;uniqueIndex" json:"token"`
-	ExpiresAt time.Time `gorm:"not null" json:"expiresAt"`
-	Revoked   bool      `gorm:"not null;default:false" json:"revoked"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID            uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	TokenPrefix   string     `gorm:"size:32;uniqueIndex" json:"-"`
+	TokenHash     string     `gorm:"size:64" json:"-"`
+	FamilyID      uuid.UUID  `gorm:"type:uuid;index" json:"familyId"`
+	AccessTokenID string     `gorm:"size:36" json:"-"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expiresAt"`
+	Revoked       bool       `gorm:"not null;default:false" json:"revoked"`
+	ConsumedAt    *time.Time `json:"consumedAt,omitempty"`
+	UserAgent     string     `gorm:"size:512" json:"userAgent"`
+	IPAddress     string     `gorm:"size:64" json:"ipAddress"`
+	StartedAt     time.Time  `json:"startedAt"`
+	LastUsedAt    time.Time  `json:"lastUsedAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
 }
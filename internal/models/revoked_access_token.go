@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// RevokedAccessToken denylists an access token, by its jti, until it expires.
+type RevokedAccessToken struct {
+	JTI       string    `gorm:"size:36;primaryKey"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Roles a user can hold.
+const (
+	RoleAdmin   = "admin"
+	RoleCurator = "curator"
+	RoleMember  = "member"
+)
+
+// Permissions checked by RequirePermission and the services.
+const (
+	PermMoviesCreate    = "movies:create"
+	PermMoviesUpdateAny = "movies:update:any"
+	PermMoviesDeleteAny = "movies:delete:any"
+	PermUsersManage     = "users:manage"
+)
+
+// RolePermissions lists what each role may do. Members may always edit and
+// delete their own movies; the ":any" permissions extend that to everyone's.
+var RolePermissions = map[string][]string{
+	RoleMember:  {PermMoviesCreate},
+	RoleCurator: {PermMoviesCreate, PermMoviesUpdateAny, PermMoviesDeleteAny},
+	RoleAdmin:   {PermMoviesCreate, PermMoviesUpdateAny, PermMoviesDeleteAny, PermUsersManage},
+}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// IsDemotion reports whether changing a user's role from one to the other
+// takes away any permission.
+func IsDemotion(from, to string) bool {
+	for _, p := range RolePermissions[from] {
+		found := false
+		for _, q := range RolePermissions[to] {
+			if p == q {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return true
+		}
+	}
+	return false
+}
+
+// UserPermission grants a single permission to a user on top of their role.
+type UserPermission struct {
+	UserID     uuid.UUID `gorm:"type:uuid;primaryKey" json:"userId"`
+	Permission string    `gorm:"primaryKey" json:"permission"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// EffectivePermissions returns the permissions of role plus any per-user grants.
+func EffectivePermissions(role string, grants []string) []string {
+	perms := append([]string{}, RolePermissions[role]...)
+	for _, grant := range grants {
+		found := false
+		for _, p := range perms {
+			if p == grant {
+				found = true
+				break
+			}
+		}
+		if !found {
+			perms = append(perms, grant)
+		}
+	}
+	return perms
+}
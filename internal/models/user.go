@@ -10,7 +10,34 @@ type User struct {
 	ID       uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
 	Username string    `gorm:"unique;not null" json:"username" validate:"required,alphanum,min=3,max=20"`
 	Email    string    `gorm:"unique;not null" json:"email" validate:"required,email"`
-	Password string    `json:"password" validate:"required,min=8,password"`
+	Password string    `json:"-"`
+	Role     string    `gorm:"not null;default:member" json:"role"`
+
+	EmailVerified   bool       `gorm:"not null;default:false" json:"emailVerified"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	// PendingEmail is the address the user is changing to, until they
+	// confirm it from the link sent there
+	PendingEmail string `json:"pendingEmail,omitempty"`
+
+	DisplayName string `gorm:"size:50" json:"displayName"`
+	Bio         string `gorm:"size:500" json:"bio"`
+	AvatarURL   string `json:"avatarUrl"`
+
+	// TOTPSecret is encrypted at rest. It is set during enrollment and only
+	// enforced at login once TOTPEnabled is true.
+	TOTPSecret   string `json:"-"`
+	TOTPEnabled  bool   `gorm:"not null;default:false" json:"mfaEnabled"`
+	TOTPLastStep int64  `gorm:"not null;default:0" json:"-"`
+
+	// Disabled accounts cannot sign in or refresh their sessions
+	Disabled   bool       `gorm:"not null;default:false" json:"disabled"`
+	DisabledAt *time.Time `json:"disabledAt,omitempty"`
+
+	// ExternalID is the identifier of the account in the directory that
+	// provisions it through SCIM
+	ExternalID string `gorm:"index" json:"externalId,omitempty"`
+	// InvitationID is the invitation the user signed up with
+	InvitationID *uuid.UUID `gorm:"type:uuid;index" json:"invitationId,omitempty"`
 
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider. A user may have several identities, one per provider account.
+type UserIdentity struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	Provider    string     `gorm:"not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject     string     `gorm:"not null;uniqueIndex:idx_identity_provider_subject" json:"subject"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// OIDCLoginState is the server side of an authorization request in flight,
+// keyed by the state parameter. It holds the PKCE verifier and nonce, and a
+// hash of the browser binding cookie so the callback only completes in the
+// browser that started the login. LinkUserID is set when a signed-in user is
+// linking another identity rather than logging in.
+type OIDCLoginState struct {
+	State        string     `gorm:"primaryKey" json:"-"`
+	Provider     string     `gorm:"not null" json:"-"`
+	Nonce        string     `gorm:"not null" json:"-"`
+	CodeVerifier string     `gorm:"not null" json:"-"`
+	BindingHash  string     `gorm:"size:64;not null" json:"-"`
+	LinkUserID   *uuid.UUID `gorm:"type:uuid" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null;index" json:"-"`
+	CreatedAt    time.Time  `json:"-"`
+}
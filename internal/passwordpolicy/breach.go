@@ -0,0 +1,148 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachList reports whether a password is known from a data breach.
+type BreachList interface {
+	Contains(password string) (bool, error)
+}
+
+// rangePrefixLength is the number of hex digits of the SHA-1 hash that name
+// a range, as in the k-anonymity model of the Pwned Passwords range API.
+const rangePrefixLength = 5
+
+// maxLineLength bounds the lines of a breach list; HASH:COUNT lines are
+// well under it.
+const maxLineLength = 256
+
+// OpenBreachList opens a local copy of a breached password list in the
+// Pwned Passwords format, where passwords are identified by their upper
+// case hex SHA-1 hash. path is either
+//
+//   - a directory of range files named after the first five digits of the
+//     hash (ABCDE or ABCDE.txt), each holding SUFFIX:COUNT lines as served
+//     by the range API, or
+//   - a single file of HASH:COUNT lines sorted by hash, which is searched
+//     without being loaded into memory.
+func OpenBreachList(path string) (BreachList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return rangeDir(path), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &sortedFile{f: f, size: info.Size()}, nil
+}
+
+func passwordHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// rangeDir is a directory of range files. A missing range file means no
+// breached password has a hash starting with its prefix.
+type rangeDir string
+
+func (d rangeDir) Contains(password string) (bool, error) {
+	hash := passwordHash(password)
+	prefix, suffix := hash[:rangePrefixLength], hash[rangePrefixLength:]
+	f, err := os.Open(filepath.Join(string(d), prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		f, err = os.Open(filepath.Join(string(d), prefix))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		candidate, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		// Padded responses list made-up suffixes with a count of 0
+		if strings.EqualFold(candidate, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// sortedFile is a file of HASH:COUNT lines sorted by hash, binary searched
+// by byte offset.
+type sortedFile struct {
+	f    *os.File
+	size int64
+}
+
+func (s *sortedFile) Contains(password string) (bool, error) {
+	hash := passwordHash(password)
+	// Find the first line whose hash is not below the password's
+	lo, hi := int64(0), s.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, next, ok, err := s.lineFrom(mid)
+		if err != nil {
+			return false, err
+		}
+		if !ok || lineHash(line) >= hash {
+			hi = mid
+		} else {
+			lo = next
+		}
+	}
+	line, _, ok, err := s.lineFrom(lo)
+	return ok && lineHash(line) == hash, err
+}
+
+// lineFrom returns the first line starting at or after off and the offset
+// just past it; ok is false when no line starts there.
+func (s *sortedFile) lineFrom(off int64) (line string, next int64, ok bool, err error) {
+	// Reading from the byte before off tells whether a line starts at off
+	start := max(off-1, 0)
+	buf := make([]byte, 2*maxLineLength)
+	n, err := s.f.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return "", 0, false, err
+	}
+	buf = buf[:n]
+	i := 0
+	if off > 0 {
+		newline := bytes.IndexByte(buf, '\n')
+		if newline < 0 {
+			return "", 0, false, nil
+		}
+		i = newline + 1
+	}
+	rest := buf[i:]
+	if len(rest) == 0 {
+		return "", 0, false, nil
+	}
+	end := bytes.IndexByte(rest, '\n')
+	if end < 0 {
+		end = len(rest)
+	}
+	return strings.TrimSpace(string(rest[:end])), start + int64(i+end+1), true, nil
+}
+
+func lineHash(line string) string {
+	hash, _, _ := strings.Cut(line, ":")
+	return strings.ToUpper(hash)
+}
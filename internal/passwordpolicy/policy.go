@@ -0,0 +1,138 @@
+// Package passwordpolicy checks new passwords against configurable rules:
+// length, character classes, the account's own username and email, and a
+// list of passwords known from data breaches.
+package passwordpolicy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Each rule a password can break has its own error. Violations wrap them
+// with the details of the rule, so errors.Is reports which rules failed.
+var (
+	ErrTooShort         = errors.New("password is too short")
+	ErrTooLong          = errors.New("password is too long")
+	ErrNoUppercase      = errors.New("password must contain an uppercase letter")
+	ErrNoLowercase      = errors.New("password must contain a lowercase letter")
+	ErrNoDigit          = errors.New("password must contain a digit")
+	ErrNoSymbol         = errors.New("password must contain a symbol")
+	ErrContainsUsername = errors.New("password must not contain your username")
+	ErrContainsEmail    = errors.New("password must not contain your email address")
+	ErrBreached         = errors.New("password has appeared in a data breach, choose another one")
+	ErrReused           = errors.New("password has been used recently, choose another one")
+)
+
+// minIdentityLength is the shortest username or email local part looked for
+// in passwords; shorter ones would reject too many good passwords.
+const minIdentityLength = 3
+
+// Policy lists the rules new passwords must follow. Lengths are counted in
+// characters, not bytes. A symbol is any character other than a letter or
+// digit.
+type Policy struct {
+	MinLength        int  `json:"minLength"`
+	MaxLength        int  `json:"maxLength"`
+	RequireUppercase bool `json:"requireUppercase"`
+	RequireLowercase bool `json:"requireLowercase"`
+	RequireDigit     bool `json:"requireDigit"`
+	RequireSymbol    bool `json:"requireSymbol"`
+	// DisallowIdentity rejects passwords containing the username or the
+	// local part of the email address
+	DisallowIdentity bool `json:"disallowIdentity"`
+	// History is the number of recent passwords, the current one included,
+	// that cannot be reused; 0 allows any
+	History int `json:"history"`
+	// BreachCheck tells clients that breached passwords are rejected
+	BreachCheck bool `json:"breachCheck"`
+}
+
+// Identity is the account a password is chosen for.
+type Identity struct {
+	Username string
+	Email    string
+}
+
+// Violations is the list of rules a password broke, each as a distinct error.
+type Violations []error
+
+func (v Violations) Error() string {
+	messages := make([]string, len(v))
+	for i, err := range v {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap lets errors.Is and errors.As look at each violation.
+func (v Violations) Unwrap() []error {
+	return v
+}
+
+// Err returns v as an error, or nil when no rule was broken.
+func (v Violations) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// Check returns the rules password breaks, in the order they are listed in
+// Policy. History and breaches need stored data and are checked by callers.
+func (p Policy) Check(password string, id Identity) Violations {
+	var v Violations
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		v = append(v, fmt.Errorf("%w: use at least %d characters", ErrTooShort, p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		v = append(v, fmt.Errorf("%w: use at most %d characters", ErrTooLong, p.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	if p.RequireUppercase && !upper {
+		v = append(v, ErrNoUppercase)
+	}
+	if p.RequireLowercase && !lower {
+		v = append(v, ErrNoLowercase)
+	}
+	if p.RequireDigit && !digit {
+		v = append(v, ErrNoDigit)
+	}
+	if p.RequireSymbol && !symbol {
+		v = append(v, ErrNoSymbol)
+	}
+
+	if p.DisallowIdentity {
+		lowered := strings.ToLower(password)
+		if containsFold(lowered, id.Username) {
+			v = append(v, ErrContainsUsername)
+		}
+		local, _, _ := strings.Cut(id.Email, "@")
+		if containsFold(lowered, local) {
+			v = append(v, ErrContainsEmail)
+		}
+	}
+	return v
+}
+
+// containsFold reports whether the lowered password contains s, ignoring
+// case, when s is long enough to be meaningful.
+func containsFold(lowered, s string) bool {
+	return utf8.RuneCountInString(s) >= minIdentityLength && strings.Contains(lowered, strings.ToLower(s))
+}
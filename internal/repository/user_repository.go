@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"strings"
+
 	"eskalate-movie-api/internal/models"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -10,6 +13,19 @@ type UserRepository interface {
 	Create(user *models.User) error
 	FindByEmail(email string) (*models.User, error)
 	FindByUsername(username string) (*models.User, error)
+	FindByID(id uuid.UUID) (*models.User, error)
+	UpdateRole(id uuid.UUID, role string) error
+	UpdateProfile(id uuid.UUID, displayName, bio, avatarURL string) error
+	FindPermissionGrants(id uuid.UUID) ([]string, error)
+	Search(filter UserFilter, offset, limit int) ([]models.User, int64, error)
+}
+
+// UserFilter narrows a user search; zero fields match every user.
+type UserFilter struct {
+	// Query matches a substring of the email, username or display name
+	Query    string
+	Role     string
+	Disabled *bool
 }
 
 type userRepository struct {
@@ -39,3 +55,48 @@ func (r *userRepository) FindByUsername(username string) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+func (r *userRepository) FindByID(id uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) UpdateRole(id uuid.UUID, role string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Update("role", role).Error
+}
+
+func (r *userRepository) UpdateProfile(id uuid.UUID, displayName, bio, avatarURL string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"display_name": displayName,
+		"bio":          bio,
+		"avatar_url":   avatarURL,
+	}).Error
+}
+
+func (r *userRepository) Search(filter UserFilter, offset, limit int) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+	q := r.db.Model(&models.User{})
+	if filter.Query != "" {
+		like := "%" + strings.ToLower(filter.Query) + "%"
+		q = q.Where("LOWER(email) LIKE ? OR LOWER(username) LIKE ? OR LOWER(display_name) LIKE ?", like, like, like)
+	}
+	if filter.Role != "" {
+		q = q.Where("role = ?", filter.Role)
+	}
+	if filter.Disabled != nil {
+		q = q.Where("disabled = ?", *filter.Disabled)
+	}
+	q.Count(&total)
+	err := q.Order("created_at DESC").Offset(offset).Limit(limit).Find(&users).Error
+	return users, total, err
+}
+
+func (r *userRepository) FindPermissionGrants(id uuid.UUID) ([]string, error) {
+	var perms []string
+	err := r.db.Model(&models.UserPermission{}).Where("user_id = ?", id).Pluck("permission", &perms).Error
+	return perms, err
+}
@@ -0,0 +1,45 @@
+package revocation
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired entries are dropped from a MemoryStore.
+const sweepInterval = time.Minute
+
+// MemoryStore keeps revoked token IDs in memory until they expire.
+type MemoryStore struct {
+	mu        sync.Mutex
+	revoked   map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{revoked: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+func (s *MemoryStore) Revoke(jti string, expiresAt time.Time) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if expiresAt.After(s.revoked[jti]) {
+		s.revoked[jti] = expiresAt
+	}
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for id, exp := range s.revoked {
+			if !now.Before(exp) {
+				delete(s.revoked, id)
+			}
+		}
+		s.lastSweep = now
+	}
+	return nil
+}
+
+func (s *MemoryStore) IsRevoked(jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exp, ok := s.revoked[jti]
+	return ok && time.Now().Before(exp), nil
+}
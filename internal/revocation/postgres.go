@@ -0,0 +1,34 @@
+package revocation
+
+import (
+	"time"
+
+	"eskalate-movie-api/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresStore keeps the denylist in the revoked_access_tokens table.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Revoke(jti string, expiresAt time.Time) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "jti"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"expires_at": gorm.Expr("GREATEST(revoked_access_tokens.expires_at, EXCLUDED.expires_at)")}),
+	}).Create(&models.RevokedAccessToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+func (s *PostgresStore) IsRevoked(jti string) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.RevokedAccessToken{}).
+		Where("jti = ? AND expires_at > ?", jti, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
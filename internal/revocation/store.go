@@ -0,0 +1,32 @@
+// Package revocation keeps the denylist of access tokens revoked before
+// they expire, such as those of a session that logged out.
+package revocation
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Store records revoked access tokens by jti. An entry only needs to be kept
+// until expiresAt, after which the token is rejected as expired anyway.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+}
+
+// New returns the store selected by driver: "postgres" (the default) keeps
+// the denylist in db, shared by every server instance; "memory" keeps it in
+// this process only, which suits single-instance deployments and tests.
+func New(driver string, db *gorm.DB) (Store, error) {
+	switch driver {
+	case "", "postgres":
+		return NewPostgresStore(db), nil
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown token revocation store %q", driver)
+	}
+}
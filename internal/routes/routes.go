@@ -3,8 +3,13 @@ package routes
 import (
 	"eskalate-movie-api/internal/config"
 	"eskalate-movie-api/internal/handlers"
+	"eskalate-movie-api/internal/mailer"
+	"eskalate-movie-api/internal/middleware"
+	"eskalate-movie-api/internal/models"
 	"eskalate-movie-api/internal/repository"
+	"eskalate-movie-api/internal/revocation"
 	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/tokens"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -13,14 +18,44 @@ import (
 )
 
 // RegisterRoutes sets up all API routes
-func RegisterRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
+func RegisterRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config, keys *tokens.KeyRing, mail mailer.Mailer, denylist revocation.Store, jobs services.JobScheduler) {
+	r.GET("/.well-known/jwks.json", handlers.JWKS(keys))
+
 	userRepo := repository.NewUserRepository(db)
-	authService := services.NewAuthService(userRepo, db)
-	handlers.RegisterAuthRoutes(r.Group("/api/auth"), authService, cfg)
+	patService := services.NewPersonalAccessTokenService(userRepo, db, cfg)
+
+	api := r.Group("/api")
+	authenticated := api.Group("", middleware.AuthMiddleware(keys, patService, denylist))
+
+	// Account security settings need an interactive login, not a personal access token
+	account := authenticated.Group("/auth", middleware.RequireSession())
+	throttle := services.NewLoginThrottle(db, cfg)
+	hasher := services.NewArgon2idHasher(services.Argon2Params{
+		Memory:      cfg.Argon2Memory,
+		Iterations:  cfg.Argon2Iterations,
+		Parallelism: cfg.Argon2Parallelism,
+	})
+	authService := services.NewAuthService(userRepo, db, keys, mail, throttle, hasher, denylist, cfg)
+	handlers.RegisterAuthRoutes(api.Group("/auth"), account, authService, cfg)
+	handlers.RegisterPersonalAccessTokenRoutes(account.Group("/tokens"), patService)
+	invitations := services.NewInvitationService(db, cfg)
+	handlers.RegisterInvitationRoutes(api.Group("/auth"), account, invitations)
+
+	userService := services.NewUserService(userRepo)
+	handlers.RegisterUserRoutes(authenticated.Group("/users", middleware.RequireSession()), userService, authService, cfg)
+
+	admin := authenticated.Group("/admin", middleware.RequireSession(), middleware.RequirePermission(models.PermUsersManage))
+	adminUsers := services.NewAdminUserService(userRepo, db, denylist)
+	handlers.RegisterAdminRoutes(admin, throttle, adminUsers, invitations, services.NewAuditLogService(db), jobs)
+
+	// Provisioning clients use a personal access token with the scim scope
+	scim := r.Group("/scim/v2", middleware.AuthMiddleware(keys, patService, denylist),
+		middleware.RequireScope(models.ScopeSCIM), middleware.RequirePermission(models.PermUsersManage))
+	handlers.RegisterSCIMRoutes(scim, services.NewProvisioningService(db, denylist), cfg)
 
 	movieRepo := repository.NewMovieRepository(db)
 	movieService := services.NewMovieService(movieRepo)
-	handlers.RegisterMovieRoutes(r.Group("/api/movies"), movieService, cfg)
+	handlers.RegisterMovieRoutes(api.Group("/movies"), authenticated.Group("/movies"), movieService, cfg)
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }
@@ -0,0 +1,414 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter is a parsed filter expression (RFC 7644 section 3.4.2.2): a
+// *Comparison, *Logical, *Not or *ValuePath.
+type Filter interface {
+	filter()
+}
+
+// Comparison compares an attribute with a value. Operator is lower case:
+// eq, ne, co, sw, ew, gt, ge, lt, le or pr. Value is a string, bool,
+// float64 or nil.
+type Comparison struct {
+	Attribute string
+	Operator  string
+	Value     interface{}
+}
+
+// Logical combines two filters with "and" or "or".
+type Logical struct {
+	Operator    string
+	Left, Right Filter
+}
+
+// Not negates a filter.
+type Not struct {
+	Filter Filter
+}
+
+// ValuePath filters the elements of a multi-valued attribute, as in
+// emails[type eq "work"].
+type ValuePath struct {
+	Attribute string
+	Filter    Filter
+}
+
+func (*Comparison) filter() {}
+func (*Logical) filter()    {}
+func (*Not) filter()        {}
+func (*ValuePath) filter()  {}
+
+var comparisonOperators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true,
+}
+
+// ParseFilter parses a filter expression. Attribute names keep their case,
+// except that the core User and Group schema prefixes are removed.
+func ParseFilter(s string) (Filter, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, BadRequest(ErrorInvalidFilter, err.Error())
+	}
+	p := &parser{tokens: tokens}
+	f, err := p.or()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, BadRequest(ErrorInvalidFilter, err.Error())
+	}
+	return f, nil
+}
+
+// Path is the target of a PATCH operation: an attribute, optionally
+// narrowed by a filter and followed by a sub-attribute, as in
+// emails[type eq "work"].value or name.givenName.
+type Path struct {
+	Attribute    string
+	Filter       Filter
+	SubAttribute string
+}
+
+// ParsePath parses the path of a PATCH operation.
+func ParsePath(s string) (Path, error) {
+	s = strings.TrimSpace(s)
+	if open := strings.IndexByte(s, '['); open >= 0 {
+		closing := strings.LastIndexByte(s, ']')
+		if closing < open {
+			return Path{}, BadRequest(ErrorInvalidPath, "unterminated filter in path "+s)
+		}
+		f, err := ParseFilter(s[open+1 : closing])
+		if err != nil {
+			return Path{}, BadRequest(ErrorInvalidPath, "invalid filter in path "+s)
+		}
+		path := Path{Attribute: stripSchema(s[:open]), Filter: f}
+		if rest := s[closing+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ".") || !validName(rest[1:]) {
+				return Path{}, BadRequest(ErrorInvalidPath, "invalid path "+s)
+			}
+			path.SubAttribute = rest[1:]
+		}
+		if !validName(path.Attribute) {
+			return Path{}, BadRequest(ErrorInvalidPath, "invalid path "+s)
+		}
+		return path, nil
+	}
+	attr := stripSchema(s)
+	if strings.HasPrefix(strings.ToLower(attr), "urn:") {
+		// An attribute of an extension schema, which are not supported
+		return Path{Attribute: attr}, nil
+	}
+	if !validAttributePath(attr) {
+		return Path{}, BadRequest(ErrorInvalidPath, "invalid path "+s)
+	}
+	name, sub, _ := strings.Cut(attr, ".")
+	return Path{Attribute: name, SubAttribute: sub}, nil
+}
+
+// Matches evaluates f against a resource given as attribute values. Names
+// and string values are compared case-insensitively.
+func Matches(f Filter, attrs map[string]interface{}) bool {
+	switch f := f.(type) {
+	case *Logical:
+		if f.Operator == "and" {
+			return Matches(f.Left, attrs) && Matches(f.Right, attrs)
+		}
+		return Matches(f.Left, attrs) || Matches(f.Right, attrs)
+	case *Not:
+		return !Matches(f.Filter, attrs)
+	case *Comparison:
+		var value interface{}
+		found := false
+		for name, v := range attrs {
+			if strings.EqualFold(name, f.Attribute) {
+				value, found = v, true
+			}
+		}
+		if f.Operator == "pr" {
+			return found && value != nil && value != ""
+		}
+		if !found {
+			return false
+		}
+		return compare(value, f.Operator, f.Value)
+	}
+	return false
+}
+
+func compare(value interface{}, operator string, operand interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		b, ok := operand.(bool)
+		if !ok {
+			return false
+		}
+		switch operator {
+		case "eq":
+			return v == b
+		case "ne":
+			return v != b
+		}
+	case string:
+		s, ok := operand.(string)
+		if !ok {
+			return false
+		}
+		v, s = strings.ToLower(v), strings.ToLower(s)
+		switch operator {
+		case "eq":
+			return v == s
+		case "ne":
+			return v != s
+		case "co":
+			return strings.Contains(v, s)
+		case "sw":
+			return strings.HasPrefix(v, s)
+		case "ew":
+			return strings.HasSuffix(v, s)
+		case "gt":
+			return v > s
+		case "ge":
+			return v >= s
+		case "lt":
+			return v < s
+		case "le":
+			return v <= s
+		}
+	}
+	return false
+}
+
+// stripSchema removes a core schema URI from an attribute name, so that
+// urn:ietf:params:scim:schemas:core:2.0:User:userName is userName.
+func stripSchema(attr string) string {
+	for _, schema := range []string{SchemaUser, SchemaGroup} {
+		if len(attr) > len(schema) && strings.EqualFold(attr[:len(schema)+1], schema+":") {
+			return attr[len(schema)+1:]
+		}
+	}
+	return attr
+}
+
+func validName(s string) bool {
+	if s == "" || !isLetter(rune(s[0])) {
+		return false
+	}
+	for _, r := range s {
+		if !isLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '$' {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(r rune) bool {
+	return r < unicode.MaxASCII && unicode.IsLetter(r)
+}
+
+// validAttributePath reports whether s is an attribute name, optionally
+// followed by one sub-attribute.
+func validAttributePath(s string) bool {
+	name, sub, found := strings.Cut(s, ".")
+	return validName(name) && (!found || validName(sub))
+}
+
+const (
+	tokenEOF = iota
+	tokenWord
+	tokenString
+	tokenPunct
+)
+
+type token struct {
+	kind int
+	text string
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			tokens = append(tokens, token{kind: tokenPunct, text: string(c)})
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			var str string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &str); err != nil {
+				return nil, fmt.Errorf("invalid string %s", s[i:end+1])
+			}
+			tokens = append(tokens, token{kind: tokenString, text: str})
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t\n\r()[]\"", rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: s[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// parser is a recursive descent parser over the grammar
+//
+//	or     = and *("or" and)
+//	and    = factor *("and" factor)
+//	factor = "(" or ")" / "not" "(" or ")" / attrPath "[" or "]" /
+//	         attrPath "pr" / attrPath compareOp compValue
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{kind: tokenEOF}
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokenWord && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) expect(punct string) error {
+	if t := p.next(); t.kind != tokenPunct || t.text != punct {
+		return fmt.Errorf("expected %q", punct)
+	}
+	return nil
+}
+
+func (p *parser) or() (Filter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Operator: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (Filter, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		p.next()
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Operator: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) factor() (Filter, error) {
+	t := p.peek()
+	if t.kind == tokenPunct && t.text == "(" {
+		p.next()
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect(")")
+	}
+	if p.keyword("not") {
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Filter: f}, p.expect(")")
+	}
+
+	t = p.next()
+	attr := stripSchema(t.text)
+	if t.kind != tokenWord || !validAttributePath(attr) {
+		return nil, fmt.Errorf("expected an attribute, got %q", t.text)
+	}
+	if next := p.peek(); next.kind == tokenPunct && next.text == "[" {
+		p.next()
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return &ValuePath{Attribute: attr, Filter: f}, p.expect("]")
+	}
+
+	op := p.next()
+	operator := strings.ToLower(op.text)
+	if op.kind != tokenWord || (operator != "pr" && !comparisonOperators[operator]) {
+		return nil, fmt.Errorf("expected an operator after %s", attr)
+	}
+	if operator == "pr" {
+		return &Comparison{Attribute: attr, Operator: operator}, nil
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	return &Comparison{Attribute: attr, Operator: operator, Value: value}, nil
+}
+
+func (p *parser) value() (interface{}, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenString:
+		return t.text, nil
+	case t.kind != tokenWord:
+		return nil, fmt.Errorf("expected a value")
+	case t.text == "true":
+		return true, nil
+	case t.text == "false":
+		return false, nil
+	case t.text == "null":
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", t.text)
+	}
+	return n, nil
+}
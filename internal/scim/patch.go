@@ -0,0 +1,159 @@
+package scim
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Patch operations.
+const (
+	OpAdd     = "add"
+	OpReplace = "replace"
+	OpRemove  = "remove"
+)
+
+// Operation returns the lower-cased op, checking it is one of the three
+// operations.
+func (o PatchOperation) Operation() (string, error) {
+	op := strings.ToLower(o.Op)
+	if op != OpAdd && op != OpReplace && op != OpRemove {
+		return "", BadRequest(ErrorInvalidSyntax, "unknown patch operation "+strconv.Quote(o.Op))
+	}
+	return op, nil
+}
+
+// Targets returns the attributes an operation changes. An operation without
+// a path carries an object whose keys are the paths to set.
+func (o PatchOperation) Targets() (map[Path]json.RawMessage, error) {
+	if o.Path != "" {
+		path, err := ParsePath(o.Path)
+		if err != nil {
+			return nil, err
+		}
+		return map[Path]json.RawMessage{path: o.Value}, nil
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(o.Value, &values); err != nil {
+		return nil, BadRequest(ErrorNoTarget, "an operation without a path needs an object value")
+	}
+	targets := make(map[Path]json.RawMessage, len(values))
+	for key, value := range values {
+		path, err := ParsePath(key)
+		if err != nil {
+			return nil, err
+		}
+		targets[path] = value
+	}
+	return targets, nil
+}
+
+// ApplyUserPatch applies PATCH operations to a user. Attributes a User does
+// not hold, such as extension schemas, are ignored.
+func ApplyUserPatch(user *User, ops []PatchOperation) error {
+	for _, o := range ops {
+		op, err := o.Operation()
+		if err != nil {
+			return err
+		}
+		if op == OpRemove && o.Path == "" {
+			return BadRequest(ErrorNoTarget, "remove needs a path")
+		}
+		targets, err := o.Targets()
+		if err != nil {
+			return err
+		}
+		for path, value := range targets {
+			if op == OpRemove {
+				err = removeUserAttribute(user, path)
+			} else {
+				err = setUserAttribute(user, path, value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func setUserAttribute(user *User, path Path, value json.RawMessage) error {
+	attr, sub := strings.ToLower(path.Attribute), strings.ToLower(path.SubAttribute)
+	switch {
+	case attr == "username" && sub == "":
+		return decodeString(value, path, &user.UserName)
+	case attr == "displayname" && sub == "":
+		return decodeString(value, path, &user.DisplayName)
+	case attr == "externalid" && sub == "":
+		return decodeString(value, path, &user.ExternalID)
+	case attr == "active" && sub == "":
+		active, err := decodeBool(value)
+		if err != nil {
+			return err
+		}
+		user.Active = &active
+	case attr == "name" && sub == "":
+		var name Name
+		if err := json.Unmarshal(value, &name); err != nil {
+			return BadRequest(ErrorInvalidValue, "name must be an object")
+		}
+		user.Name = &name
+	case attr == "name":
+		if user.Name == nil {
+			user.Name = &Name{}
+		}
+		switch sub {
+		case "formatted":
+			return decodeString(value, path, &user.Name.Formatted)
+		case "givenname":
+			return decodeString(value, path, &user.Name.GivenName)
+		case "familyname":
+			return decodeString(value, path, &user.Name.FamilyName)
+		}
+	}
+	return nil
+}
+
+func removeUserAttribute(user *User, path Path) error {
+	attr, sub := strings.ToLower(path.Attribute), strings.ToLower(path.SubAttribute)
+	switch {
+	case attr == "username" || attr == "active":
+		return BadRequest(ErrorMutability, path.Attribute+" cannot be removed")
+	case attr == "displayname":
+		user.DisplayName = ""
+	case attr == "externalid":
+		user.ExternalID = ""
+	case attr == "name" && (sub == "" || user.Name == nil):
+		user.Name = nil
+	case attr == "name" && sub == "formatted":
+		user.Name.Formatted = ""
+	case attr == "name" && sub == "givenname":
+		user.Name.GivenName = ""
+	case attr == "name" && sub == "familyname":
+		user.Name.FamilyName = ""
+	}
+	return nil
+}
+
+func decodeString(value json.RawMessage, path Path, dst *string) error {
+	if err := json.Unmarshal(value, dst); err != nil {
+		return BadRequest(ErrorInvalidValue, path.Attribute+" must be a string")
+	}
+	return nil
+}
+
+// decodeBool accepts a JSON boolean, or the strings "true" and "false" that
+// some clients send instead.
+func decodeBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	}
+	return false, BadRequest(ErrorInvalidValue, "active must be a boolean")
+}
@@ -0,0 +1,228 @@
+// Package scim implements the parts of SCIM 2.0 (RFC 7643 and RFC 7644) an
+// identity management system needs to provision accounts: the User and
+// Group resources, list responses, filters and PATCH operations.
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+)
+
+// ContentType is the media type of SCIM requests and responses.
+const ContentType = "application/scim+json"
+
+// Schema URIs.
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// Error types (RFC 7644 section 3.12).
+const (
+	ErrorInvalidFilter = "invalidFilter"
+	ErrorInvalidPath   = "invalidPath"
+	ErrorInvalidSyntax = "invalidSyntax"
+	ErrorInvalidValue  = "invalidValue"
+	ErrorMutability    = "mutability"
+	ErrorNoTarget      = "noTarget"
+	ErrorUniqueness    = "uniqueness"
+)
+
+// Error is a SCIM error response. The parsers in this package return it for
+// malformed input, and handlers render it as is.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// NewError returns an error response with the given HTTP status.
+func NewError(status int, scimType, detail string) *Error {
+	return &Error{Schemas: []string{SchemaError}, Status: strconv.Itoa(status), ScimType: scimType, Detail: detail}
+}
+
+// BadRequest returns a 400 error of the given type.
+func BadRequest(scimType, detail string) *Error {
+	return NewError(http.StatusBadRequest, scimType, detail)
+}
+
+func (e *Error) Error() string {
+	return "scim: " + e.Detail
+}
+
+// StatusCode returns the HTTP status of the error.
+func (e *Error) StatusCode() int {
+	status, err := strconv.Atoi(e.Status)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// Meta describes a resource.
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+}
+
+// Name is the components of a user's name.
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// MultiValue is an element of a multi-valued attribute such as emails,
+// groups or members.
+type MultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// User is the User resource. Only the attributes an account stores are
+// represented; others are ignored when decoding.
+type User struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	UserName    string       `json:"userName"`
+	Name        *Name        `json:"name,omitempty"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Emails      []MultiValue `json:"emails,omitempty"`
+	Active      *bool        `json:"active,omitempty"`
+	Groups      []MultiValue `json:"groups,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+// Group is the Group resource.
+type Group struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id"`
+	DisplayName string       `json:"displayName"`
+	Members     []MultiValue `json:"members,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+// ListResponse is the result of a query.
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// NewListResponse wraps one page of resources.
+func NewListResponse(resources interface{}, items int, total int64, startIndex int) *ListResponse {
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: items,
+		Resources:    resources,
+	}
+}
+
+// PatchRequest is the body of a PATCH request.
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is one change of a PATCH request. Op is add, replace or
+// remove; some clients capitalise it.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty" swaggertype:"object"`
+}
+
+// NewUser returns the User resource of an account. Users sign in with their
+// email, so it is their userName as well as their only email. Their role is
+// their only group.
+func NewUser(user *models.User) *User {
+	active := !user.Disabled
+	created, modified := user.CreatedAt, user.UpdatedAt
+	resource := &User{
+		Schemas:     []string{SchemaUser},
+		ID:          user.ID.String(),
+		ExternalID:  user.ExternalID,
+		UserName:    user.Email,
+		DisplayName: user.DisplayName,
+		Emails:      []MultiValue{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Groups:      []MultiValue{{Value: user.Role, Display: user.Role}},
+		Meta:        &Meta{ResourceType: "User", Created: &created, LastModified: &modified},
+	}
+	if user.DisplayName != "" {
+		resource.Name = &Name{Formatted: user.DisplayName}
+	}
+	return resource
+}
+
+// FullName returns the name to display for a user: displayName, or else
+// their formatted or given and family names.
+func (u *User) FullName() string {
+	if u.DisplayName != "" {
+		return strings.TrimSpace(u.DisplayName)
+	}
+	if u.Name == nil {
+		return ""
+	}
+	if u.Name.Formatted != "" {
+		return strings.TrimSpace(u.Name.Formatted)
+	}
+	return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+}
+
+// NewGroup returns the Group resource of a role, listing members when
+// they were loaded.
+func NewGroup(role string, members []models.User) *Group {
+	group := &Group{
+		Schemas:     []string{SchemaGroup},
+		ID:          role,
+		DisplayName: role,
+		Meta:        &Meta{ResourceType: "Group"},
+	}
+	for _, member := range members {
+		group.Members = append(group.Members, MultiValue{Value: member.ID.String(), Display: member.Email})
+	}
+	return group
+}
+
+// MemberValues decodes a list of members, as in the value of a PATCH
+// operation on members, and returns their values.
+func MemberValues(raw json.RawMessage) ([]string, error) {
+	var members []MultiValue
+	if err := json.Unmarshal(raw, &members); err != nil {
+		// Some clients send a single member rather than a list
+		var member MultiValue
+		if json.Unmarshal(raw, &member) != nil {
+			return nil, BadRequest(ErrorInvalidValue, "members must be a list of objects with a value")
+		}
+		members = []MultiValue{member}
+	}
+	values := make([]string, 0, len(members))
+	for _, m := range members {
+		if m.Value == "" {
+			return nil, BadRequest(ErrorInvalidValue, "every member needs a value")
+		}
+		values = append(values, m.Value)
+	}
+	return values, nil
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/tokens"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrNoPassword        = errors.New("this account has no password; set one with forgot-password first")
+	ErrEmailTaken        = errors.New("email already exists")
+	ErrSameEmail         = errors.New("new email is the current email")
+)
+
+// checkPassword verifies the user's current password before a sensitive
+// change. Wrong passwords count as failed logins of the account, so a stolen
+// session cannot be used to guess the password past the login throttle.
+func (s *authService) checkPassword(user *models.User, password string, client ClientInfo) error {
+	if user.Password == "" {
+		return ErrNoPassword
+	}
+	if err := s.throttle.Check(user.Email, client.IPAddress); err != nil {
+		return err
+	}
+	if !s.hasher.Verify(user.Password, password) {
+		if err := s.throttle.RecordFailure(user.Email, client.IPAddress); err != nil {
+			return err
+		}
+		return ErrIncorrectPassword
+	}
+	return s.throttle.RecordSuccess(user.Email)
+}
+
+// ChangeEmail starts changing the user's address: a confirmation link is sent
+// to the new address, and the old one is told about the request. The email
+// only changes once the link is used.
+func (s *authService) ChangeEmail(userID uuid.UUID, currentPassword, newEmail string, client ClientInfo) error {
+	newEmail = strings.TrimSpace(newEmail)
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkPassword(user, currentPassword, client); err != nil {
+		return err
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		return ErrSameEmail
+	}
+	if _, err := s.userRepo.FindByEmail(newEmail); err == nil {
+		return ErrEmailTaken
+	}
+
+	var token string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("pending_email", newEmail).Error; err != nil {
+			return err
+		}
+		user.PendingEmail = newEmail
+		if err := invalidateActionTokens(tx, user.ID, tokens.UseEmailChange); err != nil {
+			return err
+		}
+		token, err = s.issueActionToken(tx, tokens.UseEmailChange, user, emailChangeTTL)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.mailer.Send(s.emailChangeEmail(user, token)); err != nil {
+		return err
+	}
+	if err := s.mailer.Send(s.emailChangeNoticeEmail(user)); err != nil {
+		log.Printf("Error sending email change notice for user %s: %v", user.ID, err)
+	}
+	return nil
+}
+
+// ConfirmEmailChange switches the user to the pending address using the token
+// sent there. The new address counts as verified.
+func (s *authService) ConfirmEmailChange(token string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := s.consumeActionToken(tx, tokens.UseEmailChange, token)
+		if err != nil {
+			return err
+		}
+		var taken int64
+		if err := tx.Model(&models.User{}).Where("email = ? AND id <> ?", user.PendingEmail, user.ID).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrEmailTaken
+		}
+		return tx.Model(user).Updates(map[string]interface{}{
+			"email":             user.PendingEmail,
+			"pending_email":     "",
+			"email_verified":    true,
+			"email_verified_at": time.Now(),
+		}).Error
+	})
+}
+
+// ChangePassword replaces the password after checking the current one. The
+// new password must follow the password policy. Every other session is
+// signed out; the session making the change stays.
+func (s *authService) ChangePassword(userID uuid.UUID, currentPassword, newPassword string, currentSessionID uuid.UUID, client ClientInfo) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkPassword(user, currentPassword, client); err != nil {
+		if errors.Is(err, ErrIncorrectPassword) {
+			s.auditAuth(models.AuditPasswordChange, models.AuditFailure, &user.ID, client, err.Error())
+		}
+		return err
+	}
+	if err := s.validateNewPassword(s.db, user, newPassword); err != nil {
+		return err
+	}
+	hash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.setPassword(tx, user, hash); err != nil {
+			return err
+		}
+		if err := invalidateActionTokens(tx, user.ID, tokens.UsePasswordReset); err != nil {
+			return err
+		}
+		if err := revokeRefreshTokens(tx, s.denylist, func(q *gorm.DB) *gorm.DB {
+			return q.Where("user_id = ? AND family_id <> ? AND revoked = false", user.ID, currentSessionID)
+		}); err != nil {
+			return err
+		}
+		return recordAudit(tx, authAuditEvent(models.AuditPasswordChange, models.AuditSuccess, &user.ID, client, ""))
+	})
+}
+
+// DeleteAccount permanently deletes the user after checking their password.
+//
+// Cascade policy: the user's movies are deleted with the account, since a
+// collection is personal. Every credential is deleted too: refresh tokens
+// (ending all sessions), personal access tokens, pending email links,
+// recovery codes, linked identities, permission grants, previous password
+// hashes and the invitations the user created.
+func (s *authService) DeleteAccount(userID uuid.UUID, currentPassword string, client ClientInfo) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkPassword(user, currentPassword, client); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := revokeUserRefreshTokens(tx, s.denylist, user.ID); err != nil {
+			return err
+		}
+		for _, owned := range []interface{}{
+			&models.Movie{},
+			&models.RefreshToken{},
+			&models.PersonalAccessToken{},
+			&models.ActionToken{},
+			&models.RecoveryCode{},
+			&models.UserIdentity{},
+			&models.UserPermission{},
+			&models.Passkey{},
+			&models.WebAuthnCeremony{},
+			&models.PasswordHistory{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(owned).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("created_by_id = ?", user.ID).Delete(&models.Invitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("kind = ? AND subject = ?", models.ThrottleAccount, normalizeEmail(user.Email)).
+			Delete(&models.LoginThrottle{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/tokens"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	passwordResetTTL     = time.Hour
+	emailChangeTTL       = 24 * time.Hour
+	magicLinkTTL         = 15 * time.Minute
+)
+
+var ErrInvalidActionToken = errors.New("invalid or expired token")
+
+// issueActionToken records a new single-use token for user and returns it signed.
+func (s *authService) issueActionToken(db *gorm.DB, use string, user *models.User, ttl time.Duration) (string, error) {
+	return s.issueBoundActionToken(db, use, user, ttl, "")
+}
+
+// issueBoundActionToken is issueActionToken for a token that may only be
+// used by the browser holding the binding whose keyed hash is bindingHash.
+func (s *authService) issueBoundActionToken(db *gorm.DB, use string, user *models.User, ttl time.Duration, bindingHash string) (string, error) {
+	row := &models.ActionToken{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		Purpose:     use,
+		ExpiresAt:   time.Now().Add(ttl),
+		BindingHash: bindingHash,
+	}
+	if err := db.Create(row).Error; err != nil {
+		return "", err
+	}
+	email := user.Email
+	if use == tokens.UseEmailChange {
+		email = user.PendingEmail
+	}
+	return s.keys.Sign(s.keys.NewActionClaims(use, row.ID, user.ID, email, ttl))
+}
+
+// loadActionToken verifies token and locks its row, returning it with its
+// user. A token is rejected if it was issued for another use, has already
+// been used, or was sent to an address the user no longer has. Email change
+// tokens are sent to, and checked against, the pending address.
+func (s *authService) loadActionToken(tx *gorm.DB, use, token string) (*models.ActionToken, *models.User, error) {
+	var claims tokens.ActionClaims
+	parsed, err := s.keys.Parse(token, &claims)
+	if err != nil || !parsed.Valid || claims.Use != use {
+		return nil, nil, ErrInvalidActionToken
+	}
+	var row models.ActionToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND purpose = ?", claims.ID, use).First(&row).Error; err != nil {
+		return nil, nil, ErrInvalidActionToken
+	}
+	if row.UsedAt != nil || time.Now().After(row.ExpiresAt) || row.UserID.String() != claims.Subject {
+		return nil, nil, ErrInvalidActionToken
+	}
+	var user models.User
+	if err := tx.First(&user, "id = ?", row.UserID).Error; err != nil {
+		return nil, nil, ErrInvalidActionToken
+	}
+	expected := user.Email
+	if use == tokens.UseEmailChange {
+		expected = user.PendingEmail
+	}
+	if claims.Email != "" && claims.Email != expected {
+		return nil, nil, ErrInvalidActionToken
+	}
+	return &row, &user, nil
+}
+
+// consumeActionToken verifies token, marks it used and returns its user.
+func (s *authService) consumeActionToken(tx *gorm.DB, use, token string) (*models.User, error) {
+	row, user, err := s.loadActionToken(tx, use, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := markActionTokenUsed(tx, row); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func markActionTokenUsed(tx *gorm.DB, row *models.ActionToken) error {
+	return tx.Model(row).Update("used_at", time.Now()).Error
+}
+
+// invalidateActionTokens marks every outstanding token of the given use as used.
+func invalidateActionTokens(db *gorm.DB, userID uuid.UUID, use string) error {
+	return db.Model(&models.ActionToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, use).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,25 @@
+package services
+
+import "github.com/google/uuid"
+
+// Actor is the user on whose behalf a service call is made.
+type Actor struct {
+	UserID      uuid.UUID
+	Permissions []string
+}
+
+// Can reports whether the actor holds the given permission.
+func (a Actor) Can(permission string) bool {
+	for _, p := range a.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanModify reports whether the actor may change a resource owned by ownerID:
+// owners always may, anyone else needs anyPermission.
+func (a Actor) CanModify(ownerID uuid.UUID, anyPermission string) bool {
+	return ownerID == a.UserID || a.Can(anyPermission)
+}
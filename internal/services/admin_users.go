@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/repository"
+	"eskalate-movie-api/internal/revocation"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrInvalidRole      = errors.New("unknown role")
+	ErrCannotModifySelf = errors.New("administrators cannot disable their own account or change their own role")
+)
+
+// UserDetails is an account as administrators see it. Secrets such as the
+// password hash and TOTP secret are never included.
+type UserDetails struct {
+	models.User
+	Permissions          []string              `json:"permissions"`
+	ActiveSessions       int64                 `json:"activeSessions"`
+	PersonalAccessTokens int64                 `json:"personalAccessTokens"`
+	Identities           []models.UserIdentity `json:"identities"`
+}
+
+// AdminUserService manages other users' accounts. Every change is recorded
+// in the audit trail with the administrator and client that made it.
+type AdminUserService interface {
+	List(filter repository.UserFilter, pageNumber, pageSize int) ([]models.User, int64, error)
+	Get(userID uuid.UUID) (*UserDetails, error)
+	SetDisabled(actor Actor, client ClientInfo, userID uuid.UUID, disabled bool) (*models.User, error)
+	ForceLogout(actor Actor, client ClientInfo, userID uuid.UUID) (int64, error)
+	ResetMFA(actor Actor, client ClientInfo, userID uuid.UUID) error
+	SetRole(actor Actor, client ClientInfo, userID uuid.UUID, role string) (*models.User, error)
+	AuditTrail(userID uuid.UUID, pageNumber, pageSize int) ([]models.AuditEvent, int64, error)
+}
+
+type adminUserService struct {
+	userRepo repository.UserRepository
+	db       *gorm.DB
+	denylist revocation.Store
+}
+
+func NewAdminUserService(userRepo repository.UserRepository, db *gorm.DB, denylist revocation.Store) AdminUserService {
+	return &adminUserService{userRepo: userRepo, db: db, denylist: denylist}
+}
+
+func (s *adminUserService) List(filter repository.UserFilter, pageNumber, pageSize int) ([]models.User, int64, error) {
+	offset := (pageNumber - 1) * pageSize
+	return s.userRepo.Search(filter, offset, pageSize)
+}
+
+func (s *adminUserService) Get(userID uuid.UUID) (*UserDetails, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	grants, err := s.userRepo.FindPermissionGrants(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	details := &UserDetails{User: *user, Permissions: models.EffectivePermissions(user.Role, grants)}
+	now := time.Now()
+	if err := s.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked = false AND consumed_at IS NULL AND expires_at > ?", user.ID, now).
+		Count(&details.ActiveSessions).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, now).
+		Count(&details.PersonalAccessTokens).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("user_id = ?", user.ID).Order("created_at").Find(&details.Identities).Error; err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+// SetDisabled disables or re-enables an account. Disabling signs the user
+// out of every session, including their access tokens, and revokes their
+// personal access tokens.
+func (s *adminUserService) SetDisabled(actor Actor, client ClientInfo, userID uuid.UUID, disabled bool) (*models.User, error) {
+	if userID == actor.UserID {
+		return nil, ErrCannotModifySelf
+	}
+	var user *models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = lockAccount(tx, userID); err != nil {
+			return err
+		}
+		if user.Disabled == disabled {
+			return nil
+		}
+		if err := setAccountDisabled(tx, s.denylist, user, disabled); err != nil {
+			return err
+		}
+		action := models.AuditUserEnable
+		if disabled {
+			action = models.AuditUserDisable
+		}
+		return recordAudit(tx, adminAuditEvent(actor, client, action, user.ID, ""))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// setAccountDisabled disables or re-enables a locked account. Disabling
+// revokes the user's sessions and personal access tokens.
+func setAccountDisabled(tx *gorm.DB, denylist revocation.Store, user *models.User, disabled bool) error {
+	var disabledAt *time.Time
+	if disabled {
+		now := time.Now()
+		disabledAt = &now
+		if err := revokeUserRefreshTokens(tx, denylist, user.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PersonalAccessToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"disabled":    disabled,
+		"disabled_at": disabledAt,
+	}).Error; err != nil {
+		return err
+	}
+	user.Disabled, user.DisabledAt = disabled, disabledAt
+	return nil
+}
+
+// ForceLogout ends every session of the user, denylisting their access
+// tokens. It returns the number of sessions ended.
+func (s *adminUserService) ForceLogout(actor Actor, client ClientInfo, userID uuid.UUID) (int64, error) {
+	var ended int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := lockAccount(tx, userID)
+		if err != nil {
+			return err
+		}
+		var families []uuid.UUID
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked = false AND consumed_at IS NULL AND expires_at > ?", user.ID, time.Now()).
+			Distinct().Pluck("family_id", &families).Error; err != nil {
+			return err
+		}
+		if err := revokeUserRefreshTokens(tx, s.denylist, user.ID); err != nil {
+			return err
+		}
+		ended = int64(len(families))
+		return recordAudit(tx, adminAuditEvent(actor, client, models.AuditUserLogout, user.ID,
+			fmt.Sprintf("%d sessions ended", ended)))
+	})
+	return ended, err
+}
+
+// ResetMFA turns off two-factor authentication for a user who lost their
+// authenticator and recovery codes. Their passkeys are removed as well.
+func (s *adminUserService) ResetMFA(actor Actor, client ClientInfo, userID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := lockAccount(tx, userID)
+		if err != nil {
+			return err
+		}
+		passkeys := tx.Where("user_id = ?", user.ID).Delete(&models.Passkey{})
+		if passkeys.Error != nil {
+			return passkeys.Error
+		}
+		if !user.TOTPEnabled && user.TOTPSecret == "" && passkeys.RowsAffected == 0 {
+			return ErrMFANotEnabled
+		}
+		if err := clearTOTP(tx, user.ID); err != nil {
+			return err
+		}
+		return recordAudit(tx, adminAuditEvent(actor, client, models.AuditUserMFAReset, user.ID, ""))
+	})
+}
+
+// SetRole changes the user's role. A promotion applies to access tokens
+// issued from the next refresh on; a demotion signs the user out of every
+// session, including their access tokens, so the permissions they lost end
+// at once.
+func (s *adminUserService) SetRole(actor Actor, client ClientInfo, userID uuid.UUID, role string) (*models.User, error) {
+	if !models.ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if userID == actor.UserID {
+		return nil, ErrCannotModifySelf
+	}
+	var user *models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = lockAccount(tx, userID); err != nil {
+			return err
+		}
+		if user.Role == role {
+			return nil
+		}
+		previous := user.Role
+		if err := setUserRole(tx, s.denylist, user, role); err != nil {
+			return err
+		}
+		return recordAudit(tx, adminAuditEvent(actor, client, models.AuditUserRoleChange, user.ID,
+			fmt.Sprintf("%s -> %s", previous, role)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// setUserRole changes the role of a locked user. A demotion revokes the
+// user's sessions and denylists their access tokens, which still carry the
+// old role.
+func setUserRole(tx *gorm.DB, denylist revocation.Store, user *models.User, role string) error {
+	if models.IsDemotion(user.Role, role) {
+		if err := revokeUserRefreshTokens(tx, denylist, user.ID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(user).Update("role", role).Error; err != nil {
+		return err
+	}
+	user.Role = role
+	return nil
+}
+
+// AuditTrail returns the events recorded on the user's account, newest first.
+func (s *adminUserService) AuditTrail(userID uuid.UUID, pageNumber, pageSize int) ([]models.AuditEvent, int64, error) {
+	var events []models.AuditEvent
+	var total int64
+	q := s.db.Model(&models.AuditEvent{}).Where("target_id = ?", userID)
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.Order("created_at DESC").Offset((pageNumber - 1) * pageSize).Limit(pageSize).Find(&events).Error
+	return events, total, err
+}
+
+// lockAccount is lockUser reporting a missing user as ErrUserNotFound.
+func lockAccount(tx *gorm.DB, userID uuid.UUID) (*models.User, error) {
+	user, err := lockUser(tx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+func adminAuditEvent(actor Actor, client ClientInfo, action string, targetID uuid.UUID, details string) *models.AuditEvent {
+	return &models.AuditEvent{
+		ActorID:   &actor.UserID,
+		Action:    action,
+		TargetID:  &targetID,
+		Details:   details,
+		UserAgent: truncate(client.UserAgent, 512),
+		IPAddress: truncate(client.IPAddress, 64),
+	}
+}
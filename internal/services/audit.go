@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidAuditFilter = errors.New("invalid audit filter")
+
+// recordAudit appends event to the audit trail. It is written with the
+// caller's transaction, so an event is kept exactly when its action is.
+// Events without an outcome succeeded.
+func recordAudit(tx *gorm.DB, event *models.AuditEvent) error {
+	event.ID = uuid.New()
+	event.CreatedAt = time.Now()
+	if event.Outcome == "" {
+		event.Outcome = models.AuditSuccess
+	}
+	return tx.Create(event).Error
+}
+
+// authAuditEvent is an event of a user acting on their own account.
+// userID is nil when the account is unknown, such as a failed login for an
+// email nobody uses.
+func authAuditEvent(action, outcome string, userID *uuid.UUID, client ClientInfo, details string) *models.AuditEvent {
+	return &models.AuditEvent{
+		ActorID:   userID,
+		Action:    action,
+		TargetID:  userID,
+		Outcome:   outcome,
+		Details:   details,
+		UserAgent: truncate(client.UserAgent, 512),
+		IPAddress: truncate(client.IPAddress, 64),
+	}
+}
+
+// auditAuth records an authentication event on its own, for actions that
+// failed or completed outside a transaction. Failing to record it is
+// logged rather than failing the request.
+func (s *authService) auditAuth(action, outcome string, userID *uuid.UUID, client ClientInfo, details string) {
+	if err := recordAudit(s.db, authAuditEvent(action, outcome, userID, client, details)); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"event":  "audit_write_error",
+			"action": action,
+			"error":  err.Error(),
+		}).Error("audit event could not be recorded")
+	}
+}
+
+// auditLoginFailure records a failed login. The account is looked up by
+// email so failures against a real account show in its trail.
+func (s *authService) auditLoginFailure(email string, client ClientInfo, reason string) {
+	var userID *uuid.UUID
+	if user, err := s.userRepo.FindByEmail(email); err == nil {
+		userID = &user.ID
+	}
+	s.auditAuth(models.AuditLogin, models.AuditFailure, userID, client, reason)
+}
+
+// AuditFilter narrows an audit log query. Zero fields match everything.
+type AuditFilter struct {
+	ActorID   *uuid.UUID
+	TargetID  *uuid.UUID
+	Action    string
+	Outcome   string
+	IPAddress string
+	Since     *time.Time
+	Until     *time.Time
+}
+
+// AuditLogService reads the audit log: authentication events of every user
+// and the administrative actions taken on their accounts.
+type AuditLogService interface {
+	Query(filter AuditFilter, pageNumber, pageSize int) ([]models.AuditEvent, int64, error)
+	// Export calls fn with every matching event, oldest first, without
+	// loading them all into memory. The export itself is audited.
+	Export(actor Actor, client ClientInfo, filter AuditFilter, format string, fn func(*models.AuditEvent) error) error
+}
+
+type auditLogService struct {
+	db *gorm.DB
+}
+
+func NewAuditLogService(db *gorm.DB) AuditLogService {
+	return &auditLogService{db: db}
+}
+
+// Query returns the matching events, newest first.
+func (s *auditLogService) Query(filter AuditFilter, pageNumber, pageSize int) ([]models.AuditEvent, int64, error) {
+	q, err := auditQuery(s.db, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var events []models.AuditEvent
+	err = q.Order("created_at DESC, id").Offset((pageNumber - 1) * pageSize).Limit(pageSize).Find(&events).Error
+	return events, total, err
+}
+
+func (s *auditLogService) Export(actor Actor, client ClientInfo, filter AuditFilter, format string, fn func(*models.AuditEvent) error) error {
+	q, err := auditQuery(s.db, filter)
+	if err != nil {
+		return err
+	}
+	if err := recordAudit(s.db, &models.AuditEvent{
+		ActorID:   &actor.UserID,
+		Action:    models.AuditLogExport,
+		Details:   format,
+		UserAgent: truncate(client.UserAgent, 512),
+		IPAddress: truncate(client.IPAddress, 64),
+	}); err != nil {
+		return err
+	}
+	rows, err := q.Order("created_at, id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := s.db.ScanRows(rows, &event); err != nil {
+			return err
+		}
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func auditQuery(db *gorm.DB, filter AuditFilter) (*gorm.DB, error) {
+	if filter.Outcome != "" && filter.Outcome != models.AuditSuccess && filter.Outcome != models.AuditFailure {
+		return nil, ErrInvalidAuditFilter
+	}
+	if filter.Since != nil && filter.Until != nil && filter.Until.Before(*filter.Since) {
+		return nil, ErrInvalidAuditFilter
+	}
+	q := db.Model(&models.AuditEvent{})
+	if filter.ActorID != nil {
+		q = q.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.TargetID != nil {
+		q = q.Where("target_id = ?", *filter.TargetID)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if filter.Outcome != "" {
+		q = q.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.IPAddress != "" {
+		q = q.Where("ip_address = ?", filter.IPAddress)
+	}
+	if filter.Since != nil {
+		q = q.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		q = q.Where("created_at < ?", *filter.Until)
+	}
+	return q, nil
+}
+
+// EnforceAppendOnlyAudit installs a trigger that makes the database refuse
+// to update or delete audit events, whoever asks.
+func EnforceAppendOnlyAudit(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range []string{
+			`CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+			BEGIN
+				RAISE EXCEPTION 'audit_events is append-only';
+			END;
+			$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events`,
+			`CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+			FOR EACH ROW EXECUTE FUNCTION audit_events_append_only()`,
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
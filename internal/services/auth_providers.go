@@ -0,0 +1,141 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"log"
+	"sync"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/repository"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Names of the providers AUTH_PROVIDERS can list.
+const (
+	ProviderDatabase = "database"
+	ProviderLDAP     = "ldap"
+)
+
+var ErrAuthProviderUnavailable = errors.New("the sign-in service is temporarily unavailable")
+
+// AuthProvider checks the email and password of a login against one source
+// of accounts and returns the matching local user, creating it if needed.
+// It returns ErrInvalidCredentials when it does not recognise them, so the
+// next provider gets a chance, and ErrAuthProviderUnavailable when it could
+// not check them.
+type AuthProvider interface {
+	Name() string
+	Authenticate(email, password string) (*models.User, error)
+}
+
+// newAuthProviders builds the providers listed in cfg.AuthProviders. Unknown
+// and unconfigured providers are skipped with a warning.
+func newAuthProviders(cfg *config.Config, s *authService) []AuthProvider {
+	var providers []AuthProvider
+	for _, name := range cfg.AuthProviders {
+		switch name {
+		case ProviderDatabase:
+			providers = append(providers, &databaseProvider{userRepo: s.userRepo, db: s.db, hasher: s.hasher})
+		case ProviderLDAP:
+			if cfg.LDAP.URL == "" || cfg.LDAP.BaseDN == "" {
+				log.Printf("Auth provider ldap needs LDAP_URL and LDAP_BASE_DN, skipping")
+				continue
+			}
+			providers = append(providers, newLDAPProvider(cfg.LDAP, s.db, s.denylist))
+		default:
+			log.Printf("Unknown auth provider %q, skipping", name)
+		}
+	}
+	return providers
+}
+
+// authenticatePassword asks each provider in turn to check the login. If
+// none accepts it and one of them failed, the login could not be checked
+// and ErrAuthProviderUnavailable is returned.
+func (s *authService) authenticatePassword(email, password string) (*models.User, error) {
+	unavailable := false
+	for _, provider := range s.providers {
+		user, err := provider.Authenticate(email, password)
+		if err == nil {
+			return user, nil
+		}
+		if errors.Is(err, ErrInvalidCredentials) {
+			continue
+		}
+		unavailable = true
+		logrus.WithFields(logrus.Fields{
+			"event":    "auth_provider_error",
+			"provider": provider.Name(),
+			"error":    err.Error(),
+		}).Error("auth provider could not check a login")
+	}
+	if unavailable {
+		return nil, ErrAuthProviderUnavailable
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// databaseProvider checks passwords against the hashes stored with users.
+// Unknown emails cost the same password hash as known ones so response
+// times do not reveal which accounts exist. Hashes in an outdated format,
+// such as bcrypt, are upgraded after a successful login.
+type databaseProvider struct {
+	userRepo repository.UserRepository
+	db       *gorm.DB
+	hasher   PasswordHasher
+
+	dummyHashOnce sync.Once
+	dummyHash     string
+}
+
+func (p *databaseProvider) Name() string {
+	return ProviderDatabase
+}
+
+func (p *databaseProvider) Authenticate(email, password string) (*models.User, error) {
+	// Accounts without a password (signed up through an identity provider)
+	// are compared against the dummy hash too, and can never match
+	user, findErr := p.userRepo.FindByEmail(email)
+	hash := p.dummyPasswordHash()
+	if findErr == nil && user.Password != "" {
+		hash = user.Password
+	}
+	if !p.hasher.Verify(hash, password) || findErr != nil || user.Password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	if p.hasher.NeedsRehash(user.Password) {
+		p.rehashPassword(user, password)
+	}
+	return user, nil
+}
+
+// rehashPassword stores a fresh hash of the user's password, checked just
+// before. A failure is only logged: the old hash keeps working.
+func (p *databaseProvider) rehashPassword(user *models.User, password string) {
+	hash, err := p.hasher.Hash(password)
+	if err == nil {
+		// Only replace the hash that was verified, in case the password changed meanwhile
+		err = p.db.Model(&models.User{}).Where("id = ? AND password = ?", user.ID, user.Password).
+			Update("password", hash).Error
+	}
+	if err != nil {
+		log.Printf("Error upgrading password hash for user %s: %v", user.ID, err)
+		return
+	}
+	user.Password = hash
+}
+
+// dummyPasswordHash returns a hash of a random password, verified against
+// when an email is unknown so the login takes as long as for a real account.
+func (p *databaseProvider) dummyPasswordHash() string {
+	p.dummyHashOnce.Do(func() {
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		p.dummyHash, _ = p.hasher.Hash(string(secret))
+	})
+	return p.dummyHash
+}
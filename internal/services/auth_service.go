@@ -1,186 +1,478 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"log"
 	"time"
 
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/mailer"
 	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/oidc"
+	"eskalate-movie-api/internal/passwordpolicy"
 	"eskalate-movie-api/internal/repository"
+	"eskalate-movie-api/internal/revocation"
+	"eskalate-movie-api/internal/tokens"
+	"eskalate-movie-api/internal/totp"
+	"eskalate-movie-api/internal/webauthn"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrAccountDisabled     = errors.New("this account has been disabled")
+)
+
 type AuthService interface {
-	Signup(user *models.User) error
-	Login(email, password, jwtSecret string) (string, error)
-	LoginWithRefresh(email, password, jwtSecret string) (string, string, error)
-	RefreshAccessToken(refreshToken, jwtSecret string) (string, error)
-	RevokeRefreshToken(refreshToken string) error
-	GetUserByEmail(email string) (*models.User, error)
+	Signup(user *models.User, invitationCode string, client ClientInfo) error
+	LoginWithRefresh(email, password string, client ClientInfo) (*LoginResult, error)
+	CompleteMFALogin(mfaToken, code string, client ClientInfo) (string, string, error)
+	RefreshAccessToken(refreshToken string, client ClientInfo) (string, string, error)
+	RevokeRefreshToken(refreshToken string, client ClientInfo) error
+	VerifyEmail(token string) error
+	ResendVerificationEmail(email string) error
+	RequestPasswordReset(email string) error
+	RequestMagicLink(email string) (string, error)
+	LoginWithMagicLink(token, binding string, client ClientInfo) (*LoginResult, error)
+	ResetPassword(token, newPassword string, client ClientInfo) error
+	PasswordPolicy() passwordpolicy.Policy
+	SetupTOTP(userID uuid.UUID) (*TOTPSetup, error)
+	EnableTOTP(userID uuid.UUID, code string) ([]string, error)
+	DisableTOTP(userID uuid.UUID, code string, client ClientInfo) error
+	BeginPasskeyRegistration(userID uuid.UUID) (*PasskeyRegistration, error)
+	FinishPasskeyRegistration(userID, ceremonyID uuid.UUID, name string, resp *webauthn.AttestationResponse) (*models.Passkey, error)
+	ListPasskeys(userID uuid.UUID) ([]models.Passkey, error)
+	DeletePasskey(userID, passkeyID uuid.UUID) error
+	BeginPasskeyLogin() (*PasskeyAssertion, error)
+	FinishPasskeyLogin(ceremonyID uuid.UUID, resp *webauthn.AssertionResponse, client ClientInfo) (string, string, error)
+	BeginPasskeyMFA(mfaToken string) (*PasskeyAssertion, error)
+	CompletePasskeyMFALogin(mfaToken string, ceremonyID uuid.UUID, resp *webauthn.AssertionResponse, client ClientInfo) (string, string, error)
+	OIDCProviders() []OIDCProviderInfo
+	BeginOIDCLogin(ctx context.Context, provider string, linkUserID *uuid.UUID) (*OIDCAuthorization, error)
+	CompleteOIDCLogin(ctx context.Context, provider, state, code, binding string, client ClientInfo) (*OIDCResult, error)
+	ListIdentities(userID uuid.UUID) ([]models.UserIdentity, error)
+	UnlinkIdentity(userID, identityID uuid.UUID) error
+	ChangeEmail(userID uuid.UUID, currentPassword, newEmail string, client ClientInfo) error
+	ConfirmEmailChange(token string) error
+	ChangePassword(userID uuid.UUID, currentPassword, newPassword string, currentSessionID uuid.UUID, client ClientInfo) error
+	DeleteAccount(userID uuid.UUID, currentPassword string, client ClientInfo) error
+	ListSessions(userID, currentSessionID uuid.UUID) ([]Session, error)
+	RevokeSession(userID, sessionID uuid.UUID) error
+	RevokeAllSessions(userID, exceptSessionID uuid.UUID) (int64, error)
+}
+
+// LoginResult is the outcome of a successful password check: either a token
+// pair, or, for users with two-factor authentication, an MFA challenge token
+// to be completed through CompleteMFALogin or CompletePasskeyMFALogin, as
+// listed in MFAMethods.
+type LoginResult struct {
+	AccessToken  string
+	RefreshToken string
+	MFAToken     string
+	MFAMethods   []string
+}
+
+// Login methods, as recorded in the audit log.
+const (
+	loginPassword  = "password"
+	loginMagicLink = "magic_link"
+	loginPasskey   = "passkey"
+	loginMFA       = "mfa:"  // followed by the second factor
+	loginOIDC      = "oidc:" // followed by the provider name
+)
 
+// MFARequired reports whether the login must be completed with a second factor.
+func (r *LoginResult) MFARequired() bool {
+	return r.MFAToken != ""
 }
 
 type authService struct {
-	userRepo repository.UserRepository
-	db       *gorm.DB
+	userRepo        repository.UserRepository
+	db              *gorm.DB
+	keys            *tokens.KeyRing
+	mailer          mailer.Mailer
+	throttle        LoginThrottle
+	hasher          PasswordHasher
+	denylist        revocation.Store
+	cfg             *config.Config
+	refreshTokenKey []byte
+	mfaKey          []byte
+	oidc            map[string]*oidc.Provider
+	rp              *webauthn.RelyingParty
+	providers       []AuthProvider
+	policy          passwordpolicy.Policy
+	breaches        passwordpolicy.BreachList
 }
 
-func NewAuthService(userRepo repository.UserRepository, db *gorm.DB) AuthService {
-	return &authService{userRepo, db}
+// NewAuthService creates the auth service. Tokens are signed with keys,
+// account emails are delivered through m, password logins are limited by
+// throttle and checked by the providers listed in cfg.AuthProviders,
+// passwords are stored with hasher and the access tokens of revoked sessions
+// are added to denylist. New passwords must follow cfg.PasswordPolicy.
+func NewAuthService(userRepo repository.UserRepository, db *gorm.DB, keys *tokens.KeyRing, m mailer.Mailer, throttle LoginThrottle, hasher PasswordHasher, denylist revocation.Store, cfg *config.Config) AuthService {
+	s := &authService{
+		userRepo:        userRepo,
+		db:              db,
+		keys:            keys,
+		mailer:          m,
+		throttle:        throttle,
+		hasher:          hasher,
+		denylist:        denylist,
+		cfg:             cfg,
+		refreshTokenKey: []byte(cfg.RefreshTokenKey),
+		mfaKey:          totp.SealKey(cfg.MFAEncryptionKey),
+		oidc:            newOIDCProviders(cfg),
+		rp:              newRelyingParty(cfg),
+	}
+	s.providers = newAuthProviders(cfg, s)
+	s.policy, s.breaches = newPasswordPolicy(cfg.PasswordPolicy)
+	return s
 }
 
-func (s *authService) Signup(user *models.User) error {
-	log.Printf("Starting signup process for email: %s", user.Email)
+// Signup registers a user with a password. Unless registration is open, an
+// invitation code is required; one given while registration is open is
+// checked all the same. The invitation's role is granted, and it is
+// redeemed in the same transaction as the account is created.
+func (s *authService) Signup(user *models.User, invitationCode string, client ClientInfo) error {
+	switch {
+	case s.cfg.RegistrationMode == config.RegistrationClosed:
+		return ErrRegistrationClosed
+	case s.cfg.RegistrationMode == config.RegistrationInviteOnly && invitationCode == "":
+		return ErrInvitationRequired
+	}
 
 	if _, err := s.userRepo.FindByEmail(user.Email); err == nil {
-		log.Printf("Email already exists: %s", user.Email)
 		return errors.New("email already exists")
 	}
 	if _, err := s.userRepo.FindByUsername(user.Username); err == nil {
-		log.Printf("Username already exists: %s", user.Username)
 		return errors.New("username already exists")
 	}
+	if err := s.validateNewPassword(s.db, user, user.Password); err != nil {
+		return err
+	}
 
-	log.Printf("Original password length: %d", len(user.Password))
-	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hash, err := s.hasher.Hash(user.Password)
 	if err != nil {
-		log.Printf("Error hashing password: %v", err)
 		return err
 	}
-	log.Printf("Generated hash length: %d", len(hash))
 
-	user.Password = string(hash)
-	log.Printf("Final stored password hash length: %d", len(user.Password))
+	user.Password = hash
+	user.Role = models.RoleMember
+	user.EmailVerified = false
 
-	if err := s.userRepo.Create(user); err != nil {
-		log.Printf("Error creating user: %v", err)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		details := ""
+		if invitationCode != "" {
+			invitation, err := redeemInvitation(tx, s.refreshTokenKey, invitationCode)
+			if err != nil {
+				return err
+			}
+			user.Role = invitation.Role
+			user.InvitationID = &invitation.ID
+			details = "invitation " + invitation.ID.String()
+		}
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, authAuditEvent(models.AuditSignup, models.AuditSuccess, &user.ID, client, details))
+	})
+	if err != nil {
 		return err
 	}
 
-	log.Printf("User created successfully with ID: %s", user.ID)
+	// A failed delivery does not fail the signup; the user can ask for a new link
+	if err := s.sendVerificationEmail(user); err != nil {
+		log.Printf("Error sending verification email for user %s: %v", user.ID, err)
+	}
 	return nil
 }
 
-func (s *authService) Login(email, password, jwtSecret string) (string, error) {
-	user, err := s.userRepo.FindByEmail(email)
+func (s *authService) sendVerificationEmail(user *models.User) error {
+	token, err := s.issueActionToken(s.db, tokens.UseEmailVerification, user, emailVerificationTTL)
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		return err
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return "", errors.New("invalid credentials")
+	return s.mailer.Send(s.verificationEmail(user, token))
+}
+
+// VerifyEmail marks the user's address as verified using a token from a verification email.
+func (s *authService) VerifyEmail(token string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := s.consumeActionToken(tx, tokens.UseEmailVerification, token)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(user).Updates(map[string]interface{}{
+			"email_verified":    true,
+			"email_verified_at": now,
+		}).Error
+	})
+}
+
+// RequestPasswordReset emails a single-use password reset link, invalidating
+// earlier ones. Unknown addresses are silently ignored so the endpoint cannot
+// be used to discover accounts: the link is issued and sent in the
+// background, so a known address is answered as quickly as an unknown one
+// and a failed delivery is only logged.
+func (s *authService) RequestPasswordReset(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
 	}
-	claims := jwt.MapClaims{
-		"user_id": user.ID.String(),
-		"exp":     time.Now().Add(15 * time.Minute).Unix(),
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.sendPasswordResetEmail(user); err != nil {
+			log.Printf("Error sending password reset email for user %s: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+func (s *authService) sendPasswordResetEmail(user *models.User) error {
+	if err := invalidateActionTokens(s.db, user.ID, tokens.UsePasswordReset); err != nil {
+		return err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString([]byte(jwtSecret))
+	token, err := s.issueActionToken(s.db, tokens.UsePasswordReset, user, passwordResetTTL)
 	if err != nil {
-		return "", err
+		return err
 	}
-	return tokenStr, nil
+	return s.mailer.Send(s.passwordResetEmail(user, token))
 }
 
-func (s *authService) LoginWithRefresh(email, password, jwtSecret string) (string, string, error) {
-	log.Printf("Login attempt for email: %s", email)
+// ResetPassword sets a new password using a token from a reset email and
+// revokes every refresh token of the user, signing out all their sessions.
+// The password must follow the password policy.
+func (s *authService) ResetPassword(token, newPassword string, client ClientInfo) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := s.consumeActionToken(tx, tokens.UsePasswordReset, token)
+		if err != nil {
+			return err
+		}
+		// A rejected password rolls back, so the token can be used again
+		if err := s.validateNewPassword(tx, user, newPassword); err != nil {
+			return err
+		}
+		hash, err := s.hasher.Hash(newPassword)
+		if err != nil {
+			return err
+		}
+		if err := s.setPassword(tx, user, hash); err != nil {
+			return err
+		}
+		if err := invalidateActionTokens(tx, user.ID, tokens.UsePasswordReset); err != nil {
+			return err
+		}
+		if err := revokeUserRefreshTokens(tx, s.denylist, user.ID); err != nil {
+			return err
+		}
+		return recordAudit(tx, authAuditEvent(models.AuditPasswordReset, models.AuditSuccess, &user.ID, client, ""))
+	})
+}
 
+// ResendVerificationEmail sends a new verification link, invalidating earlier
+// ones. Unknown and already verified addresses are silently ignored so the
+// endpoint cannot be used to discover accounts.
+func (s *authService) ResendVerificationEmail(email string) error {
 	user, err := s.userRepo.FindByEmail(email)
-	if err != nil {
-		log.Printf("User not found with email: %s", email)
-		return "", "", errors.New("invalid credentials")
+	if err != nil || user.EmailVerified {
+		return nil
 	}
+	if err := invalidateActionTokens(s.db, user.ID, tokens.UseEmailVerification); err != nil {
+		return err
+	}
+	return s.sendVerificationEmail(user)
+}
 
-	log.Printf("Found user with ID: %s", user.ID)
-	log.Printf("Stored password hash length: %d", len(user.Password))
-	log.Printf("Provided password length: %d", len(password))
+// LoginWithRefresh checks a password login against each configured
+// AuthProvider in turn. Failed attempts, including those a provider could
+// not check, are throttled per email and per client IP.
+func (s *authService) LoginWithRefresh(email, password string, client ClientInfo) (*LoginResult, error) {
+	if err := s.throttle.Check(email, client.IPAddress); err != nil {
+		var throttled *ThrottledError
+		if errors.As(err, &throttled) {
+			s.auditLoginFailure(email, client, loginPassword+": throttled")
+		}
+		return nil, err
+	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	user, err := s.authenticatePassword(email, password)
+	if errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrAuthProviderUnavailable) {
+		s.auditLoginFailure(email, client, loginPassword+": "+err.Error())
+		if err := s.throttle.RecordFailure(email, client.IPAddress); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
 	if err != nil {
-		log.Printf("Password comparison failed: %v", err)
-		return "", "", errors.New("invalid credentials")
+		return nil, err
 	}
 
-	log.Printf("Password comparison successful")
+	result, err := s.completeLogin(user, client, loginPassword)
+	if err != nil {
+		return nil, err
+	}
+	// A correct password alone does not clear the failures: they also limit
+	// guesses at the second factor, and are cleared when that succeeds
+	if !result.MFARequired() {
+		if err := s.throttle.RecordSuccess(email); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
 
-	// Access token (short-lived)
-	accessClaims := jwt.MapClaims{
-		"user_id": user.ID.String(),
-		"exp":     time.Now().Add(15 * time.Minute).Unix(),
+// completeLogin signs in a user whose first factor, the login method, has
+// been checked: users with TOTP or a passkey get an MFA challenge, others a
+// session.
+func (s *authService) completeLogin(user *models.User, client ClientInfo, method string) (*LoginResult, error) {
+	if user.Disabled {
+		s.auditAuth(models.AuditLogin, models.AuditFailure, &user.ID, client, method+": "+ErrAccountDisabled.Error())
+		return nil, ErrAccountDisabled
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenStr, err := accessToken.SignedString([]byte(jwtSecret))
+	methods, err := s.mfaMethods(user)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
-	// Refresh token (longer-lived)
-	refreshClaims := jwt.MapClaims{
-		"user_id": user.ID.String(),
-		"exp":     time.Now().Add(7 * 24 * time.Hour).Unix(),
-		"type":    "refresh",
+	if len(methods) > 0 {
+		mfaToken, err := s.issueActionToken(s.db, tokens.UseMFAChallenge, user, mfaChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFAToken: mfaToken, MFAMethods: methods}, nil
 	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenStr, err := refreshToken.SignedString([]byte(jwtSecret))
+
+	accessTokenStr, refreshTokenStr, err := s.issueSession(user, client, method)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{AccessToken: accessTokenStr, RefreshToken: refreshTokenStr}, nil
+}
+
+// issueSession completes a login: it issues an access token and a refresh
+// token that starts a new token family, records the login and warns the
+// user by email when it comes from a device they have not used before.
+func (s *authService) issueSession(user *models.User, client ClientInfo, method string) (string, string, error) {
+	newDevice, err := s.isNewDevice(user.ID, client)
 	if err != nil {
 		return "", "", err
 	}
-	// Store refresh token in DB
-	refreshTokenModel := &models.RefreshToken{
-		ID:        uuid.New(),
-		UserID:    user.ID,
-		Token:     refreshTokenStr,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
-		Revoked:   false,
-		CreatedAt: time.Now(),
+	sessionID := uuid.New()
+	accessTokenStr, jti, err := s.signAccessToken(user, sessionID)
+	if err != nil {
+		return "", "", err
 	}
-	err = s.db.Create(refreshTokenModel).Error
+	refreshTokenStr, err := issueRefreshToken(s.db, s.refreshTokenKey, user.ID, sessionID, jti, time.Now(), client)
 	if err != nil {
 		return "", "", err
 	}
+	s.auditAuth(models.AuditLogin, models.AuditSuccess, &user.ID, client, method)
+	if newDevice {
+		if err := s.mailer.Send(s.newDeviceEmail(user, client, time.Now())); err != nil {
+			log.Printf("Error sending new device notification for user %s: %v", user.ID, err)
+		}
+	}
 	return accessTokenStr, refreshTokenStr, nil
 }
 
-func (s *authService) RefreshAccessToken(refreshToken, jwtSecret string) (string, error) {
-	// Parse and validate refresh token
-	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
+// RefreshAccessToken rotates a refresh token: the presented token is marked
+// consumed and a new access/refresh pair is issued in the same family.
+// Presenting an already consumed token is treated as theft and revokes the
+// whole family.
+func (s *authService) RefreshAccessToken(refreshToken string, client ClientInfo) (string, string, error) {
+	var accessTokenStr, newRefreshTokenStr string
+	var reused *models.RefreshToken
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		dbToken, err := findRefreshToken(tx, s.refreshTokenKey, refreshToken)
+		if err != nil {
+			return err
+		}
+		if dbToken.ConsumedAt != nil {
+			if err := revokeFamily(tx, s.denylist, dbToken.FamilyID); err != nil {
+				return err
+			}
+			reused = dbToken
+			return recordAudit(tx, authAuditEvent(models.AuditRefresh, models.AuditFailure, &dbToken.UserID, client,
+				"reused token; session revoked"))
+		}
+		if dbToken.Revoked || time.Now().After(dbToken.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		now := time.Now()
+		if err := tx.Model(dbToken).Update("consumed_at", now).Error; err != nil {
+			return err
+		}
+		user, err := s.userRepo.FindByID(dbToken.UserID)
+		if err != nil || user.Disabled {
+			return ErrInvalidRefreshToken
+		}
+		var jti string
+		accessTokenStr, jti, err = s.signAccessToken(user, dbToken.FamilyID)
+		if err != nil {
+			return err
+		}
+		newRefreshTokenStr, err = issueRefreshToken(tx, s.refreshTokenKey, dbToken.UserID, dbToken.FamilyID, jti, dbToken.StartedAt, client)
+		if err != nil {
+			return err
+		}
+		return recordAudit(tx, authAuditEvent(models.AuditRefresh, models.AuditSuccess, &dbToken.UserID, client, ""))
 	})
-	if err != nil || !token.Valid {
-		return "", errors.New("invalid refresh token")
-	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || claims["user_id"] == nil || claims["type"] != "refresh" {
-		return "", errors.New("invalid refresh token claims")
-	}
-	userID := claims["user_id"].(string)
-	// Check if refresh token is in DB and not revoked/expired
-	var dbToken models.RefreshToken
-	err = s.db.Where("token = ? AND revoked = false AND expires_at > ?", refreshToken, time.Now()).First(&dbToken).Error
 	if err != nil {
-		return "", errors.New("refresh token not recognized or expired")
-	}
-	if dbToken.UserID.String() != userID {
-		return "", errors.New("refresh token does not belong to user")
+		return "", "", err
 	}
-	// Issue new access token
-	accessClaims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(15 * time.Minute).Unix(),
+	if reused != nil {
+		logrus.WithFields(logrus.Fields{
+			"event":     "refresh_token_reuse",
+			"user_id":   reused.UserID,
+			"family_id": reused.FamilyID,
+			"token_id":  reused.ID,
+		}).Warn("consumed refresh token presented again; token family revoked")
+		return "", "", ErrRefreshTokenReused
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenStr, err := accessToken.SignedString([]byte(jwtSecret))
+	return accessTokenStr, newRefreshTokenStr, nil
+}
+
+// signAccessToken issues an access token for the session carrying the
+// user's current role and permissions. It also returns the token's jti.
+func (s *authService) signAccessToken(user *models.User, sessionID uuid.UUID) (string, string, error) {
+	grants, err := s.userRepo.FindPermissionGrants(user.ID)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	return accessTokenStr, nil
+	claims := s.keys.NewAccessClaims(user.ID, AccessTokenTTL)
+	claims.Roles = []string{user.Role}
+	claims.Permissions = models.EffectivePermissions(user.Role, grants)
+	claims.EmailVerified = user.EmailVerified
+	claims.SessionID = sessionID.String()
+	token, err := s.keys.Sign(claims)
+	return token, claims.ID, err
 }
 
-func (s *authService) RevokeRefreshToken(refreshToken string) error {
-	return s.db.Model(&models.RefreshToken{}).Where("token = ?", refreshToken).Update("revoked", true).Error
+// RevokeRefreshToken logs out: it ends the session the refresh token belongs
+// to, including its access token.
+func (s *authService) RevokeRefreshToken(refreshToken string, client ClientInfo) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		dbToken, err := findRefreshToken(tx, s.refreshTokenKey, refreshToken)
+		if err != nil {
+			return err
+		}
+		if err := revokeFamily(tx, s.denylist, dbToken.FamilyID); err != nil {
+			return err
+		}
+		return recordAudit(tx, authAuditEvent(models.AuditLogout, models.AuditSuccess, &dbToken.UserID, client, ""))
+	})
 }
-func (s *authService) GetUserByEmail(email string) (*models.User, error) {
-	return s.userRepo.FindByEmail(email)
-}
\ No newline at end of file
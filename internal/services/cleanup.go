@@ -0,0 +1,81 @@
+package services
+
+import (
+	"time"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Cleanup jobs.
+const (
+	JobRefreshTokens       = "refresh_tokens"
+	JobRevokedAccessTokens = "revoked_access_tokens"
+	JobActionTokens        = "action_tokens"
+	JobLoginCeremonies     = "login_ceremonies"
+	JobLoginThrottles      = "login_throttles"
+)
+
+// cleanupJobs deletes rows that no longer do anything. Each keeps what the
+// rest of the service still reads: refresh tokens for the retention period,
+// action tokens while they count toward the magic link limit, and failed
+// logins while they count toward a lockout.
+func cleanupJobs(cfg *config.Config) []Job {
+	retention := cfg.Cleanup.RefreshTokenRetention
+	lockoutDuration := cfg.LoginLockoutDuration
+	return []Job{
+		{
+			Name:        JobRefreshTokens,
+			Description: "Delete refresh tokens that expired or were revoked longer ago than the retention period",
+			Run: func(tx *gorm.DB) (int64, error) {
+				// Revoked tokens have no revocation time; their session was
+				// last used no later than that
+				cutoff := time.Now().Add(-retention)
+				return deleteWhere(tx, &models.RefreshToken{}, "expires_at < ? OR (revoked AND last_used_at < ?)", cutoff, cutoff)
+			},
+		},
+		{
+			Name:        JobRevokedAccessTokens,
+			Description: "Delete denylisted access tokens that have expired",
+			Run: func(tx *gorm.DB) (int64, error) {
+				return deleteWhere(tx, &models.RevokedAccessToken{}, "expires_at < ?", time.Now())
+			},
+		},
+		{
+			Name:        JobActionTokens,
+			Description: "Delete expired email verification, password reset, email change, magic link and MFA challenge tokens",
+			Run: func(tx *gorm.DB) (int64, error) {
+				now := time.Now()
+				return deleteWhere(tx, &models.ActionToken{}, "expires_at < ? AND created_at < ?", now, now.Add(-magicLinkWindow))
+			},
+		},
+		{
+			Name:        JobLoginCeremonies,
+			Description: "Delete expired OpenID Connect login states and passkey ceremonies",
+			Run: func(tx *gorm.DB) (int64, error) {
+				now := time.Now()
+				states, err := deleteWhere(tx, &models.OIDCLoginState{}, "expires_at < ?", now)
+				if err != nil {
+					return 0, err
+				}
+				ceremonies, err := deleteWhere(tx, &models.WebAuthnCeremony{}, "expires_at < ?", now)
+				return states + ceremonies, err
+			},
+		},
+		{
+			Name:        JobLoginThrottles,
+			Description: "Forget failed logins too old to count toward a lockout",
+			Run: func(tx *gorm.DB) (int64, error) {
+				now := time.Now()
+				return deleteWhere(tx, &models.LoginThrottle{}, "last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-lockoutDuration), now)
+			},
+		},
+	}
+}
+
+func deleteWhere(tx *gorm.DB, model interface{}, query string, args ...interface{}) (int64, error) {
+	result := tx.Where(query, args...).Delete(model)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"eskalate-movie-api/internal/mailer"
+	"eskalate-movie-api/internal/models"
+)
+
+// link builds an absolute link to a page of the client application carrying token.
+func (s *authService) link(path, token string) string {
+	return s.cfg.AppBaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func (s *authService) verificationEmail(user *models.User, token string) mailer.Message {
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(`Hi %s,
+
+Please confirm your email address by opening the link below:
+
+%s
+
+The link expires in 24 hours. If you did not create an account, you can ignore this email.
+`, user.Username, s.link("/verify-email", token)),
+	}
+}
+
+func (s *authService) passwordResetEmail(user *models.User, token string) mailer.Message {
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password of your account. To choose a new password, open the link below:
+
+%s
+
+The link expires in 1 hour and can only be used once. Resetting your password signs you out of every device.
+If you did not ask for a reset, you can ignore this email; your password has not been changed.
+`, user.Username, s.link("/reset-password", token)),
+	}
+}
+
+func (s *authService) magicLinkEmail(user *models.User, token string) mailer.Message {
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf(`Hi %s,
+
+Open the link below to sign in to your account:
+
+%s
+
+The link expires in 15 minutes, can only be used once and only works in the browser where you asked for it.
+If you did not ask to sign in, you can ignore this email.
+`, user.Username, s.link("/magic-link", token)),
+	}
+}
+
+func (s *authService) newDeviceEmail(user *models.User, client ClientInfo, at time.Time) mailer.Message {
+	device := client.UserAgent
+	if device == "" {
+		device = "unknown device"
+	}
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "New sign-in to your account",
+		Body: fmt.Sprintf(`Hi %s,
+
+Your account was just signed in to from a new device:
+
+Device:     %s
+IP address: %s
+Time:       %s
+
+If this was you, there is nothing to do. If not, sign out of that session from your active sessions
+and change your password.
+`, user.Username, device, client.IPAddress, at.UTC().Format(time.RFC1123)),
+	}
+}
+
+func (s *authService) emailChangeEmail(user *models.User, token string) mailer.Message {
+	return mailer.Message{
+		To:      user.PendingEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(`Hi %s,
+
+You asked to change the email address of your account to this one. To confirm, open the link below:
+
+%s
+
+The link expires in 24 hours. Until then your account keeps using %s.
+If you did not ask for this change, you can ignore this email.
+`, user.Username, s.link("/confirm-email", token), user.Email),
+	}
+}
+
+func (s *authService) emailChangeNoticeEmail(user *models.User) mailer.Message {
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf(`Hi %s,
+
+Someone signed in to your account asked to change its email address to %s.
+The change only happens once it is confirmed from that address.
+
+If this was not you, change your password and sign out of your other sessions.
+`, user.Username, user.PendingEmail),
+	}
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InvitationScheme starts every invitation code.
+const InvitationScheme = "inv_"
+
+// Limits on invitations. Users without users:manage get shorter, smaller
+// invitations to the member role only.
+const (
+	maxInvitationTTL      = 365 * 24 * time.Hour
+	maxInvitationUses     = 1000
+	maxUserInvitationTTL  = 30 * 24 * time.Hour
+	maxUserInvitationUses = 10
+)
+
+var (
+	ErrRegistrationClosed  = errors.New("registration is closed")
+	ErrInvitationRequired  = errors.New("an invitation code is required to sign up")
+	ErrInvalidInvitation   = errors.New("invalid, expired or used up invitation code")
+	ErrInvitationNotFound  = errors.New("invitation not found")
+	ErrInvitationsDisabled = errors.New("invitations can only be created while registration is invite-only")
+	ErrInvitationLimit     = errors.New("you have reached your limit of active invitations")
+	ErrInvitationRole      = errors.New("only administrators can invite people to a role other than member")
+	ErrInvitationSettings  = errors.New("invalid invitation usage limit or lifetime")
+)
+
+// InvitationService manages invitation codes. Anyone can invite people as
+// members while registration is invite-only, within a limit of active
+// invitations; users with users:manage can invite at any time, to any role.
+type InvitationService interface {
+	RegistrationMode() string
+	Create(actor Actor, role, note string, maxUses int, ttl time.Duration) (*models.Invitation, string, error)
+	ListOwn(userID uuid.UUID) ([]models.Invitation, error)
+	ListAll(activeOnly bool, pageNumber, pageSize int) ([]models.Invitation, int64, error)
+	Revoke(actor Actor, invitationID uuid.UUID) error
+}
+
+type invitationService struct {
+	db  *gorm.DB
+	cfg *config.Config
+	key []byte
+}
+
+// NewInvitationService creates the service managing invitations. Codes are
+// hashed with the same key as refresh tokens.
+func NewInvitationService(db *gorm.DB, cfg *config.Config) InvitationService {
+	return &invitationService{db: db, cfg: cfg, key: []byte(cfg.RefreshTokenKey)}
+}
+
+func (s *invitationService) RegistrationMode() string {
+	return s.cfg.RegistrationMode
+}
+
+// Create issues an invitation and returns it with its code, which is only
+// returned here. An empty role invites members.
+func (s *invitationService) Create(actor Actor, role, note string, maxUses int, ttl time.Duration) (*models.Invitation, string, error) {
+	if role == "" {
+		role = models.RoleMember
+	}
+	if !models.ValidRole(role) {
+		return nil, "", ErrInvalidRole
+	}
+	maxTTL, maxUsesAllowed := maxInvitationTTL, maxInvitationUses
+	if !actor.Can(models.PermUsersManage) {
+		if s.cfg.RegistrationMode != config.RegistrationInviteOnly {
+			return nil, "", ErrInvitationsDisabled
+		}
+		if role != models.RoleMember {
+			return nil, "", ErrInvitationRole
+		}
+		maxTTL, maxUsesAllowed = maxUserInvitationTTL, maxUserInvitationUses
+	}
+	if maxUses < 1 || maxUses > maxUsesAllowed || ttl <= 0 || ttl > maxTTL {
+		return nil, "", fmt.Errorf("%w: at most %d uses and %d days", ErrInvitationSettings, maxUsesAllowed, int(maxTTL.Hours()/24))
+	}
+
+	code, prefix, err := newOpaqueToken(InvitationScheme)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	invitation := &models.Invitation{
+		ID:          uuid.New(),
+		CreatedByID: actor.UserID,
+		CodePrefix:  prefix,
+		CodeHash:    keyedHash(s.key, code),
+		Role:        role,
+		Note:        truncate(strings.TrimSpace(note), 200),
+		MaxUses:     maxUses,
+		ExpiresAt:   now.Add(ttl),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if !actor.Can(models.PermUsersManage) {
+			// Locking the inviter serialises their concurrent requests
+			if _, err := lockAccount(tx, actor.UserID); err != nil {
+				return err
+			}
+			var active int64
+			if err := tx.Model(&models.Invitation{}).
+				Where("created_by_id = ? AND revoked_at IS NULL AND expires_at > ? AND uses < max_uses", actor.UserID, now).
+				Count(&active).Error; err != nil {
+				return err
+			}
+			if active >= int64(s.cfg.InvitationsPerUser) {
+				return ErrInvitationLimit
+			}
+		}
+		return tx.Create(invitation).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return invitation, code, nil
+}
+
+// ListOwn returns the invitations the user created, newest first.
+func (s *invitationService) ListOwn(userID uuid.UUID) ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	err := s.db.Where("created_by_id = ?", userID).Order("created_at DESC").Find(&invitations).Error
+	return invitations, err
+}
+
+// ListAll returns every invitation, newest first, or only those that can
+// still be used.
+func (s *invitationService) ListAll(activeOnly bool, pageNumber, pageSize int) ([]models.Invitation, int64, error) {
+	q := s.db.Model(&models.Invitation{})
+	if activeOnly {
+		q = q.Where("revoked_at IS NULL AND expires_at > ? AND uses < max_uses", time.Now())
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var invitations []models.Invitation
+	err := q.Order("created_at DESC").Offset((pageNumber - 1) * pageSize).Limit(pageSize).Find(&invitations).Error
+	return invitations, total, err
+}
+
+// Revoke stops an invitation from being used. Users can revoke their own
+// invitations; users:manage allows revoking anyone's.
+func (s *invitationService) Revoke(actor Actor, invitationID uuid.UUID) error {
+	q := s.db.Model(&models.Invitation{}).Where("id = ? AND revoked_at IS NULL", invitationID)
+	if !actor.Can(models.PermUsersManage) {
+		q = q.Where("created_by_id = ?", actor.UserID)
+	}
+	res := q.Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// redeemInvitation uses up one use of the invitation matching code, locking
+// it so concurrent signups cannot exceed its limit. Invitations from
+// accounts that have since been disabled no longer work.
+func redeemInvitation(tx *gorm.DB, key []byte, code string) (*models.Invitation, error) {
+	prefix, ok := opaqueTokenPrefix(strings.TrimSpace(code), InvitationScheme)
+	if !ok {
+		return nil, ErrInvalidInvitation
+	}
+	var invitation models.Invitation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code_prefix = ?", prefix).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+	if !hmac.Equal([]byte(invitation.CodeHash), []byte(keyedHash(key, strings.TrimSpace(code)))) ||
+		!invitation.Active(time.Now()) {
+		return nil, ErrInvalidInvitation
+	}
+	var inviter models.User
+	if err := tx.Select("id", "disabled").First(&inviter, "id = ?", invitation.CreatedByID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+	if inviter.Disabled {
+		return nil, ErrInvalidInvitation
+	}
+	if err := tx.Model(&invitation).Update("uses", gorm.Expr("uses + 1")).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"os"
+	"time"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+
+	// errJobNotDue skips a scheduled run when another instance ran the job
+	// recently.
+	errJobNotDue = errors.New("job is not due")
+)
+
+// Job is a background task run every Interval; an Interval of 0 means it
+// only runs when triggered. Run is called in a transaction that holds the
+// job's advisory lock and returns the number of rows it affected.
+type Job struct {
+	Name        string
+	Description string
+	Interval    time.Duration
+	Run         func(tx *gorm.DB) (int64, error)
+}
+
+// JobStatus describes a job and its last run on any instance. LastRun is
+// nil if the job never ran.
+type JobStatus struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Scheduled   bool           `json:"scheduled"`
+	Interval    string         `json:"interval,omitempty"`
+	LastRun     *models.JobRun `json:"lastRun,omitempty"`
+}
+
+// JobScheduler runs background jobs. Every server instance schedules them,
+// and a Postgres advisory lock makes sure only one runs a given job at a
+// time; an instance skips a job another one ran recently.
+type JobScheduler interface {
+	// Start runs each scheduled job on its interval until ctx is done. It
+	// returns straight away.
+	Start(ctx context.Context)
+	// Run runs the named job now, however recently it last ran.
+	Run(name string) (*models.JobRun, error)
+	Status() ([]JobStatus, error)
+}
+
+type jobScheduler struct {
+	db       *gorm.DB
+	jobs     []Job
+	enabled  bool
+	instance string
+}
+
+// NewJobScheduler creates the scheduler of the cleanup jobs, with their
+// intervals taken from cfg.
+func NewJobScheduler(db *gorm.DB, cfg *config.Config) JobScheduler {
+	jobs := cleanupJobs(cfg)
+	known := map[string]bool{}
+	for i := range jobs {
+		known[jobs[i].Name] = true
+		jobs[i].Interval = cfg.Cleanup.Interval
+		if d, ok := cfg.Cleanup.JobIntervals[jobs[i].Name]; ok {
+			jobs[i].Interval = d
+		}
+	}
+	for name := range cfg.Cleanup.JobIntervals {
+		if !known[name] {
+			logrus.WithFields(logrus.Fields{
+				"event": "job_unknown",
+				"job":   name,
+			}).Warn("unknown job in CLEANUP_JOB_INTERVALS, skipping")
+		}
+	}
+	instance, _ := os.Hostname()
+	return &jobScheduler{db: db, jobs: jobs, enabled: cfg.Cleanup.Enabled, instance: instance}
+}
+
+func (s *jobScheduler) Start(ctx context.Context) {
+	if !s.enabled {
+		return
+	}
+	for _, job := range s.jobs {
+		if job.Interval > 0 {
+			go s.schedule(ctx, job)
+		}
+	}
+}
+
+// schedule runs job at once and then every interval. Runs that fail are
+// logged and retried at the next tick.
+func (s *jobScheduler) schedule(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		s.run(job, models.JobTriggerSchedule)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *jobScheduler) Run(name string) (*models.JobRun, error) {
+	for _, job := range s.jobs {
+		if job.Name == name {
+			return s.run(job, models.JobTriggerManual)
+		}
+	}
+	return nil, ErrJobNotFound
+}
+
+func (s *jobScheduler) Status() ([]JobStatus, error) {
+	var runs []models.JobRun
+	if err := s.db.Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	lastRuns := make(map[string]*models.JobRun, len(runs))
+	for i := range runs {
+		lastRuns[runs[i].Name] = &runs[i]
+	}
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		status := JobStatus{
+			Name:        job.Name,
+			Description: job.Description,
+			Scheduled:   s.enabled && job.Interval > 0,
+			LastRun:     lastRuns[job.Name],
+		}
+		if job.Interval > 0 {
+			status.Interval = job.Interval.String()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// run runs job under its advisory lock and records the outcome. The lock is
+// taken without waiting: a job already running elsewhere is not run twice.
+// Scheduled runs are also skipped when the job ran within half its interval,
+// so instances whose tickers drift apart do not each run it.
+func (s *jobScheduler) run(job Job, trigger string) (*models.JobRun, error) {
+	run := &models.JobRun{Name: job.Name, Trigger: trigger, Instance: s.instance, StartedAt: time.Now()}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", jobLockKey(job.Name)).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return ErrJobRunning
+		}
+		if trigger == models.JobTriggerSchedule {
+			var last []models.JobRun
+			if err := tx.Where("name = ?", job.Name).Limit(1).Find(&last).Error; err != nil {
+				return err
+			}
+			if len(last) > 0 && run.StartedAt.Sub(last[0].StartedAt) < job.Interval/2 {
+				return errJobNotDue
+			}
+		}
+		affected, err := job.Run(tx)
+		if err != nil {
+			return err
+		}
+		run.Status = models.JobSucceeded
+		run.Affected = affected
+		run.FinishedAt = time.Now()
+		return saveJobRun(tx, run)
+	})
+	if errors.Is(err, ErrJobRunning) || errors.Is(err, errJobNotDue) {
+		return nil, err
+	}
+	if err != nil {
+		// The job's changes were rolled back; only the failure is recorded
+		run.Status = models.JobFailed
+		run.Error = truncate(err.Error(), 1000)
+		run.Affected = 0
+		run.FinishedAt = time.Now()
+		if saveErr := saveJobRun(s.db, run); saveErr != nil {
+			logrus.WithFields(logrus.Fields{
+				"event": "job_status_error",
+				"job":   job.Name,
+				"error": saveErr.Error(),
+			}).Error("job status could not be recorded")
+		}
+		logrus.WithFields(logrus.Fields{
+			"event":   "job_failed",
+			"job":     job.Name,
+			"trigger": trigger,
+			"error":   err.Error(),
+		}).Error("background job failed")
+		return run, err
+	}
+	logrus.WithFields(logrus.Fields{
+		"event":    "job_run",
+		"job":      job.Name,
+		"trigger":  trigger,
+		"affected": run.Affected,
+		"duration": run.FinishedAt.Sub(run.StartedAt).String(),
+	}).Info("background job finished")
+	return run, nil
+}
+
+func saveJobRun(db *gorm.DB, run *models.JobRun) error {
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(run).Error
+}
+
+// jobLockKey is the advisory lock key of a job, derived from its name so
+// every instance agrees on it.
+func jobLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("eskalate-movie-api/job/" + name))
+	return int64(h.Sum64())
+}
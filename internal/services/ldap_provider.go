@@ -0,0 +1,179 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/ldap"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/oidc"
+	"eskalate-movie-api/internal/revocation"
+
+	"gorm.io/gorm"
+)
+
+// ldapProvider checks passwords by binding to a directory. Directory users
+// get a local account on their first login, linked through a UserIdentity
+// whose subject is their DN, or are linked to the existing account with
+// their email. When group roles are configured, the user's role follows
+// their directory groups on every login.
+type ldapProvider struct {
+	directory  *ldap.Directory
+	db         *gorm.DB
+	denylist   revocation.Store
+	groupRoles []ldap.GroupRole
+}
+
+func newLDAPProvider(cfg config.LDAP, db *gorm.DB, denylist revocation.Store) *ldapProvider {
+	var groupRoles []ldap.GroupRole
+	for _, m := range ldap.ParseGroupRoles(cfg.GroupRoles) {
+		if !models.ValidRole(m.Role) {
+			log.Printf("LDAP_GROUP_ROLES maps %q to unknown role %q, skipping", m.Group, m.Role)
+			continue
+		}
+		groupRoles = append(groupRoles, m)
+	}
+	return &ldapProvider{
+		directory: ldap.NewDirectory(ldap.Config{
+			URL:                  cfg.URL,
+			StartTLS:             cfg.StartTLS,
+			Timeout:              cfg.Timeout,
+			BindDN:               cfg.BindDN,
+			BindPassword:         cfg.BindPassword,
+			BaseDN:               cfg.BaseDN,
+			UserFilter:           cfg.UserFilter,
+			EmailAttribute:       cfg.EmailAttribute,
+			UsernameAttribute:    cfg.UsernameAttribute,
+			DisplayNameAttribute: cfg.DisplayNameAttribute,
+			GroupAttribute:       cfg.GroupAttribute,
+		}),
+		db:         db,
+		denylist:   denylist,
+		groupRoles: groupRoles,
+	}
+}
+
+func (p *ldapProvider) Name() string {
+	return ProviderLDAP
+}
+
+func (p *ldapProvider) Authenticate(email, password string) (*models.User, error) {
+	identity, err := p.directory.Authenticate(email, password)
+	if errors.Is(err, ldap.ErrInvalidCredentials) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthProviderUnavailable, err)
+	}
+	if identity.Email == "" {
+		log.Printf("LDAP entry %s has no email address, refusing login", identity.DN)
+		return nil, ErrInvalidCredentials
+	}
+
+	var user *models.User
+	err = p.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = p.localUser(tx, identity); err != nil {
+			return err
+		}
+		if len(p.groupRoles) == 0 {
+			return nil
+		}
+		role, previous := identity.Role(p.groupRoles, models.RoleMember), user.Role
+		if role == previous {
+			return nil
+		}
+		if err := tx.Model(user).Update("role", role).Error; err != nil {
+			return err
+		}
+		user.Role = role
+		target := user.ID
+		return recordAudit(tx, &models.AuditEvent{
+			Action:   models.AuditUserRoleChange,
+			TargetID: &target,
+			Details:  fmt.Sprintf("%s -> %s (directory groups)", previous, role),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// localUser returns the account of a directory user, linking or creating it
+// on their first login. Directory accounts are trusted with their email, so
+// it is marked verified.
+func (p *ldapProvider) localUser(tx *gorm.DB, identity *ldap.Identity) (*models.User, error) {
+	now := time.Now()
+	subject := ldap.NormalizeDN(identity.DN)
+	email := strings.TrimSpace(identity.Email)
+
+	var link models.UserIdentity
+	err := tx.Where("provider = ? AND subject = ?", ProviderLDAP, subject).First(&link).Error
+	if err == nil {
+		if err := tx.Model(&link).Updates(map[string]interface{}{"email": email, "last_login_at": now}).Error; err != nil {
+			return nil, err
+		}
+		var user models.User
+		if err := tx.First(&user, "id = ?", link.UserID).Error; err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var user models.User
+	err = tx.Where("email = ?", email).First(&user).Error
+	switch {
+	case err == nil:
+		if !user.EmailVerified {
+			// As for identity providers: whoever registered the unconfirmed
+			// account loses its password and sessions to the directory user
+			if err := tx.Model(&user).Updates(map[string]interface{}{
+				"email_verified":    true,
+				"email_verified_at": now,
+				"password":          "",
+			}).Error; err != nil {
+				return nil, err
+			}
+			if err := revokeUserRefreshTokens(tx, p.denylist, user.ID); err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		username, err := uniqueUsername(tx, &oidc.Claims{PreferredUsername: identity.Username, Email: email})
+		if err != nil {
+			return nil, err
+		}
+		user = models.User{
+			Username:        username,
+			Email:           email,
+			DisplayName:     identity.DisplayName,
+			Role:            models.RoleMember,
+			EmailVerified:   true,
+			EmailVerifiedAt: &now,
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := tx.Create(&models.UserIdentity{
+		UserID:      user.ID,
+		Provider:    ProviderLDAP,
+		Subject:     subject,
+		Email:       email,
+		LastLoginAt: &now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Failures allowed before each further attempt has to wait: the wait starts
+// at a second and doubles with every failure until the lockout threshold.
+const (
+	accountBackoffAfter = 3
+	ipBackoffAfter      = 10
+)
+
+var ErrThrottleNotFound = errors.New("no failed logins recorded")
+
+// ThrottledError is returned when a login is refused because of earlier
+// failures. It never says whether the account exists.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginThrottle tracks failed logins per account and per client IP address.
+type LoginThrottle interface {
+	// Check refuses a login for email from ip while either is backed off or locked out.
+	Check(email, ip string) error
+	RecordFailure(email, ip string) error
+	// RecordSuccess clears the account's failures once a login, including
+	// any second factor, has succeeded.
+	RecordSuccess(email string) error
+	List(kind string, lockedOnly bool) ([]models.LoginThrottle, error)
+	Clear(kind, subject string) error
+}
+
+type loginThrottle struct {
+	db              *gorm.DB
+	threshold       int
+	ipThreshold     int
+	lockoutDuration time.Duration
+}
+
+// NewLoginThrottle creates a login throttle backed by the database, so limits
+// hold across instances. Failures older than the lockout duration are forgotten.
+func NewLoginThrottle(db *gorm.DB, cfg *config.Config) LoginThrottle {
+	return &loginThrottle{
+		db:              db,
+		threshold:       cfg.LoginLockoutThreshold,
+		ipThreshold:     cfg.LoginIPLockoutThreshold,
+		lockoutDuration: cfg.LoginLockoutDuration,
+	}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (t *loginThrottle) Check(email, ip string) error {
+	var rows []models.LoginThrottle
+	if err := t.db.Where("(kind = ? AND subject = ?) OR (kind = ? AND subject = ?)",
+		models.ThrottleAccount, normalizeEmail(email), models.ThrottleIP, ip).
+		Where("locked_until > ?", time.Now()).Find(&rows).Error; err != nil {
+		return err
+	}
+	var wait time.Duration
+	for _, row := range rows {
+		if d := time.Until(*row.LockedUntil); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (t *loginThrottle) RecordFailure(email, ip string) error {
+	if err := t.fail(models.ThrottleAccount, normalizeEmail(email), t.threshold, accountBackoffAfter); err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	return t.fail(models.ThrottleIP, ip, t.ipThreshold, ipBackoffAfter)
+}
+
+// fail counts a failure and, past backoffAfter failures, blocks the subject
+// for an exponentially growing delay, up to a full lockout at threshold.
+func (t *loginThrottle) fail(kind, subject string, threshold, backoffAfter int) error {
+	return t.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		row := models.LoginThrottle{Kind: kind, Subject: subject}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&row, "kind = ? AND subject = ?", kind, subject).Error; err != nil {
+			return err
+		}
+		if now.Sub(row.LastFailureAt) > t.lockoutDuration {
+			row.Failures = 0
+		}
+		row.Failures++
+		row.LastFailureAt = now
+		switch {
+		case row.Failures >= threshold:
+			until := now.Add(t.lockoutDuration)
+			row.LockedUntil = &until
+			logrus.WithFields(logrus.Fields{
+				"event":    "login_lockout",
+				"kind":     kind,
+				"failures": row.Failures,
+			}).Warn("too many failed logins; locked out")
+		case row.Failures > backoffAfter:
+			delay := time.Second << (row.Failures - backoffAfter - 1)
+			if delay > t.lockoutDuration {
+				delay = t.lockoutDuration
+			}
+			until := now.Add(delay)
+			row.LockedUntil = &until
+		}
+		return tx.Save(&row).Error
+	})
+}
+
+func (t *loginThrottle) RecordSuccess(email string) error {
+	return t.db.Where("kind = ? AND subject = ?", models.ThrottleAccount, normalizeEmail(email)).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// List returns subjects with recent failures, most recent first. kind may be
+// empty for both kinds; lockedOnly limits the list to current lockouts.
+func (t *loginThrottle) List(kind string, lockedOnly bool) ([]models.LoginThrottle, error) {
+	now := time.Now()
+	query := t.db.Model(&models.LoginThrottle{})
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if lockedOnly {
+		query = query.Where("locked_until > ?", now)
+	} else {
+		query = query.Where("last_failure_at > ? OR locked_until > ?", now.Add(-t.lockoutDuration), now)
+	}
+	var rows []models.LoginThrottle
+	err := query.Order("last_failure_at DESC").Find(&rows).Error
+	return rows, err
+}
+
+// Clear forgets the failures of a subject, lifting any lockout.
+func (t *loginThrottle) Clear(kind, subject string) error {
+	if kind == models.ThrottleAccount {
+		subject = normalizeEmail(subject)
+	}
+	res := t.db.Where("kind = ? AND subject = ?", kind, subject).Delete(&models.LoginThrottle{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrThrottleNotFound
+	}
+	return nil
+}
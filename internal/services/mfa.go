@@ -0,0 +1,277 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/tokens"
+	"eskalate-movie-api/internal/totp"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	mfaChallengeTTL   = 5 * time.Minute
+	maxMFAAttempts    = 5
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrInvalidMFACode    = errors.New("invalid authentication code")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication setup has not been started")
+)
+
+// TOTPSetup is returned when a user starts TOTP enrollment.
+type TOTPSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+// SetupTOTP generates a new TOTP secret for the user. It is not enforced until
+// EnableTOTP confirms the user's authenticator produces valid codes.
+func (s *authService) SetupTOTP(userID uuid.UUID) (*TOTPSetup, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := totp.Seal(s.mfaKey, secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(user).Update("totp_secret", sealed).Error; err != nil {
+		return nil, err
+	}
+	return &TOTPSetup{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.cfg.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+// EnableTOTP turns on TOTP once code proves the authenticator is set up, and
+// returns a fresh set of recovery codes. The codes are only shown this once.
+func (s *authService) EnableTOTP(userID uuid.UUID, code string) ([]string, error) {
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.TOTPEnabled {
+			return ErrMFAAlreadyEnabled
+		}
+		if user.TOTPSecret == "" {
+			return ErrMFANotEnrolled
+		}
+		secret, err := totp.Open(s.mfaKey, user.TOTPSecret)
+		if err != nil {
+			return err
+		}
+		step, ok := totp.Validate(secret, code, time.Now())
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = s.replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// DisableTOTP turns off TOTP after checking a current TOTP or recovery code.
+// Wrong codes count as failed logins of the account, so a stolen access token
+// cannot be used to guess codes without limit.
+func (s *authService) DisableTOTP(userID uuid.UUID, code string, client ClientInfo) error {
+	var email string
+	failed := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !user.TOTPEnabled {
+			return ErrMFANotEnabled
+		}
+		email = user.Email
+		if err := s.throttle.Check(email, client.IPAddress); err != nil {
+			return err
+		}
+		if err := s.verifySecondFactor(tx, user, code); err != nil {
+			failed = errors.Is(err, ErrInvalidMFACode)
+			return err
+		}
+		return clearTOTP(tx, user.ID)
+	})
+	if failed {
+		if err := s.throttle.RecordFailure(email, client.IPAddress); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// CompleteMFALogin finishes a login that LoginWithRefresh answered with an MFA
+// challenge. A challenge is single-use and is burnt after maxMFAAttempts wrong
+// codes. Wrong codes also count as failed logins of the account, so new
+// challenges cannot be used to keep guessing, and the account's failures are
+// only cleared once the login succeeds.
+func (s *authService) CompleteMFALogin(mfaToken, code string, client ClientInfo) (string, string, error) {
+	var user *models.User
+	failed := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		row, u, err := s.loadActionToken(tx, tokens.UseMFAChallenge, mfaToken)
+		if err != nil {
+			return err
+		}
+		user = u
+		if err := s.throttle.Check(u.Email, client.IPAddress); err != nil {
+			return err
+		}
+		if err := s.verifySecondFactor(tx, u, code); err != nil {
+			if !errors.Is(err, ErrInvalidMFACode) {
+				return err
+			}
+			// Record the failure rather than rolling it back
+			failed = true
+			updates := map[string]interface{}{"attempts": row.Attempts + 1}
+			if row.Attempts+1 >= maxMFAAttempts {
+				updates["used_at"] = time.Now()
+			}
+			return tx.Model(row).Updates(updates).Error
+		}
+		if u.Disabled {
+			return ErrAccountDisabled
+		}
+		return markActionTokenUsed(tx, row)
+	})
+	method := loginMFA + MFAMethodTOTP
+	var throttled *ThrottledError
+	switch {
+	case errors.Is(err, ErrAccountDisabled):
+		s.auditAuth(models.AuditLogin, models.AuditFailure, &user.ID, client, method+": "+err.Error())
+	case errors.As(err, &throttled):
+		s.auditAuth(models.AuditLogin, models.AuditFailure, &user.ID, client, method+": throttled")
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if failed {
+		s.auditAuth(models.AuditLogin, models.AuditFailure, &user.ID, client, method+": "+ErrInvalidMFACode.Error())
+		if err := s.throttle.RecordFailure(user.Email, client.IPAddress); err != nil {
+			return "", "", err
+		}
+		return "", "", ErrInvalidMFACode
+	}
+	if err := s.throttle.RecordSuccess(user.Email); err != nil {
+		return "", "", err
+	}
+	return s.issueSession(user, client, method)
+}
+
+// verifySecondFactor accepts either a TOTP code newer than the last one used
+// or an unused recovery code, which is then consumed.
+func (s *authService) verifySecondFactor(tx *gorm.DB, user *models.User, code string) error {
+	if !user.TOTPEnabled {
+		return ErrMFANotEnabled
+	}
+	secret, err := totp.Open(s.mfaKey, user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+	if step, ok := totp.Validate(secret, code, time.Now()); ok {
+		if step <= user.TOTPLastStep {
+			return ErrInvalidMFACode
+		}
+		return tx.Model(user).Update("totp_last_step", step).Error
+	}
+
+	res := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, keyedHash(s.refreshTokenKey, normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and returns a new set.
+func (s *authService) replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		rows[i] = models.RecoveryCode{
+			ID:       uuid.New(),
+			UserID:   userID,
+			CodeHash: keyedHash(s.refreshTokenKey, normalizeRecoveryCode(code)),
+		}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// clearTOTP removes the user's TOTP secret and recovery codes.
+func clearTOTP(tx *gorm.DB, userID uuid.UUID) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"totp_secret":    "",
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// newRecoveryCode returns a random code formatted as xxxx-xxxx-xxxx-xxxx.
+func newRecoveryCode() (string, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(base32.StdEncoding.EncodeToString(raw))
+	return encoded[0:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:16], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func lockUser(tx *gorm.DB, userID uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -10,10 +10,13 @@ import (
 	"github.com/google/uuid"
 )
 
+// ErrForbidden is returned when the actor may not modify the movie.
+var ErrForbidden = errors.New("forbidden")
+
 type MovieService interface {
 	Create(movie *models.Movie) error
-	Update(movie *models.Movie, userID uuid.UUID) error
-	Delete(movieID uuid.UUID, userID uuid.UUID) error
+	Update(movie *models.Movie, actor Actor) error
+	Delete(movieID uuid.UUID, actor Actor) error
 	GetByID(movieID uuid.UUID) (*models.Movie, error)
 	GetAll(pageNumber, pageSize int) ([]models.Movie, int64, error)
 	Search(title string, pageNumber, pageSize int) ([]models.Movie, int64, error)
@@ -31,25 +34,28 @@ func (s *movieService) Create(movie *models.Movie) error {
 	return s.repo.Create(movie)
 }
 
-func (s *movieService) Update(movie *models.Movie, userID uuid.UUID) error {
+// Update saves movie if the actor owns it or may update any movie.
+// Ownership never changes hands on update.
+func (s *movieService) Update(movie *models.Movie, actor Actor) error {
 	m, err := s.repo.FindByID(movie.ID)
 	if err != nil {
 		return err
 	}
-	if m.UserID != userID {
-		return errors.New("forbidden")
+	if !actor.CanModify(m.UserID, models.PermMoviesUpdateAny) {
+		return ErrForbidden
 	}
-	movie.UserID = userID
+	movie.UserID = m.UserID
 	return s.repo.Update(movie)
 }
 
-func (s *movieService) Delete(movieID uuid.UUID, userID uuid.UUID) error {
+// Delete removes the movie if the actor owns it or may delete any movie.
+func (s *movieService) Delete(movieID uuid.UUID, actor Actor) error {
 	m, err := s.repo.FindByID(movieID)
 	if err != nil {
 		return err
 	}
-	if m.UserID != userID {
-		return errors.New("forbidden")
+	if !actor.CanModify(m.UserID, models.PermMoviesDeleteAny) {
+		return ErrForbidden
 	}
 	return s.repo.Delete(m)
 }
@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/oidc"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	oidcLoginStateTTL  = 10 * time.Minute
+	oidcRequestTimeout = 10 * time.Second
+)
+
+var (
+	ErrUnknownProvider       = errors.New("unknown identity provider")
+	ErrInvalidOIDCState      = errors.New("invalid or expired login request")
+	ErrOIDCEmailRequired     = errors.New("the identity provider did not share an email address")
+	ErrOIDCEmailUnverified   = errors.New("the identity provider has not verified this email address, so it cannot be linked to an existing account")
+	ErrIdentityAlreadyLinked = errors.New("this identity is already linked to another account")
+	ErrIdentityNotFound      = errors.New("linked identity not found")
+	ErrLastSignInMethod      = errors.New("cannot remove the only way to sign in to this account")
+	errNoUniqueUsername      = errors.New("could not generate a unique username")
+)
+
+// OIDCProviderInfo describes a configured provider to clients.
+type OIDCProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// OIDCAuthorization starts an authorization request. The user is sent to
+// URL; Binding must be stored in the user's browser and presented again at
+// the callback.
+type OIDCAuthorization struct {
+	URL     string
+	Binding string
+}
+
+// OIDCResult is the outcome of a provider callback: a login, or, when a
+// signed-in user started the flow, the identity that was linked.
+type OIDCResult struct {
+	Login    *LoginResult
+	Identity *models.UserIdentity
+}
+
+// newOIDCProviders builds the configured providers. Their redirect URL is the
+// API's callback for that provider.
+func newOIDCProviders(cfg *config.Config) map[string]*oidc.Provider {
+	client := &http.Client{Timeout: oidcRequestTimeout}
+	providers := make(map[string]*oidc.Provider, len(cfg.OIDCProviders))
+	for _, p := range cfg.OIDCProviders {
+		providers[p.Name] = oidc.NewProvider(oidc.Config{
+			Name:         p.Name,
+			DisplayName:  p.DisplayName,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			Scopes:       p.Scopes,
+			RedirectURL:  cfg.AppBaseURL + "/api/auth/oidc/" + p.Name + "/callback",
+		}, client)
+	}
+	return providers
+}
+
+// OIDCProviders lists the providers users can sign in with, in configuration order.
+func (s *authService) OIDCProviders() []OIDCProviderInfo {
+	infos := make([]OIDCProviderInfo, 0, len(s.cfg.OIDCProviders))
+	for _, p := range s.cfg.OIDCProviders {
+		provider := s.oidc[p.Name]
+		infos = append(infos, OIDCProviderInfo{Name: provider.Name(), DisplayName: provider.DisplayName()})
+	}
+	return infos
+}
+
+// BeginOIDCLogin records a new authorization request and returns where to
+// send the user. linkUserID is set when a signed-in user links an identity.
+func (s *authService) BeginOIDCLogin(ctx context.Context, providerName string, linkUserID *uuid.UUID) (*OIDCAuthorization, error) {
+	provider, ok := s.oidc[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	binding, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return nil, err
+	}
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		return nil, err
+	}
+	// Abandoned requests are cleared out as new ones start
+	s.db.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+	if err := s.db.Create(&models.OIDCLoginState{
+		State:        state,
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		BindingHash:  keyedHash(s.refreshTokenKey, binding),
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oidcLoginStateTTL),
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &OIDCAuthorization{URL: authURL, Binding: binding}, nil
+}
+
+// CompleteOIDCLogin handles the provider's redirect back: it consumes the
+// login request, redeems the code and either signs the user in or links the
+// identity to the user who started the flow.
+func (s *authService) CompleteOIDCLogin(ctx context.Context, providerName, state, code, binding string, client ClientInfo) (*OIDCResult, error) {
+	provider, ok := s.oidc[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	var pending models.OIDCLoginState
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("state = ? AND provider = ?", state, providerName).First(&pending).Error; err != nil {
+			return ErrInvalidOIDCState
+		}
+		return tx.Delete(&pending).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(pending.ExpiresAt) || binding == "" || keyedHash(s.refreshTokenKey, binding) != pending.BindingHash {
+		return nil, ErrInvalidOIDCState
+	}
+
+	claims, err := provider.Exchange(ctx, code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if pending.LinkUserID != nil {
+		identity, err := s.linkIdentity(*pending.LinkUserID, providerName, claims)
+		if err != nil {
+			return nil, err
+		}
+		return &OIDCResult{Identity: identity}, nil
+	}
+
+	var user *models.User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = s.oidcUser(tx, providerName, claims, client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	login, err := s.completeLogin(user, client, loginOIDC+providerName)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCResult{Login: login}, nil
+}
+
+// oidcUser returns the local account for a provider identity. Known
+// identities sign in to the account they are linked to. Otherwise an account
+// with the same email is linked if the provider verified the address, and a
+// new account is created if there is none.
+func (s *authService) oidcUser(tx *gorm.DB, providerName string, claims *oidc.Claims, client ClientInfo) (*models.User, error) {
+	now := time.Now()
+	var identity models.UserIdentity
+	err := tx.Where("provider = ? AND subject = ?", providerName, claims.Subject).First(&identity).Error
+	if err == nil {
+		if err := tx.Model(&identity).Updates(map[string]interface{}{"email": claims.Email, "last_login_at": now}).Error; err != nil {
+			return nil, err
+		}
+		var user models.User
+		if err := tx.First(&user, "id = ?", identity.UserID).Error; err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	email := strings.TrimSpace(claims.Email)
+	if email == "" {
+		return nil, ErrOIDCEmailRequired
+	}
+	var user models.User
+	err = tx.Where("email = ?", email).First(&user).Error
+	switch {
+	case err == nil:
+		if !claims.EmailVerified {
+			return nil, ErrOIDCEmailUnverified
+		}
+		if !user.EmailVerified {
+			// Nobody has proven control of this address locally, so the
+			// account may have been registered by someone else in advance.
+			// The provider has proven it belongs to this user: drop the
+			// unconfirmed password and sessions before handing it over.
+			if err := tx.Model(&user).Updates(map[string]interface{}{
+				"email_verified":    true,
+				"email_verified_at": now,
+				"password":          "",
+			}).Error; err != nil {
+				return nil, err
+			}
+			if err := revokeUserRefreshTokens(tx, s.denylist, user.ID); err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// Invitations are redeemed through Signup; identity providers can
+		// only create accounts while registration is open
+		switch s.cfg.RegistrationMode {
+		case config.RegistrationClosed:
+			return nil, ErrRegistrationClosed
+		case config.RegistrationInviteOnly:
+			return nil, ErrInvitationRequired
+		}
+		username, err := uniqueUsername(tx, claims)
+		if err != nil {
+			return nil, err
+		}
+		user = models.User{
+			Username:      username,
+			Email:         email,
+			Role:          models.RoleMember,
+			EmailVerified: bool(claims.EmailVerified),
+		}
+		if user.EmailVerified {
+			user.EmailVerifiedAt = &now
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return nil, err
+		}
+		if err := recordAudit(tx, authAuditEvent(models.AuditSignup, models.AuditSuccess, &user.ID, client, loginOIDC+providerName)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := tx.Create(&models.UserIdentity{
+		UserID:      user.ID,
+		Provider:    providerName,
+		Subject:     claims.Subject,
+		Email:       email,
+		LastLoginAt: &now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{
+		"event":    "identity_linked",
+		"user_id":  user.ID,
+		"provider": providerName,
+	}).Info("external identity linked on sign-in")
+	return &user, nil
+}
+
+// linkIdentity links a provider identity to a signed-in user.
+func (s *authService) linkIdentity(userID uuid.UUID, providerName string, claims *oidc.Claims) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("provider = ? AND subject = ?", providerName, claims.Subject).First(&identity).Error
+		if err == nil {
+			if identity.UserID != userID {
+				return ErrIdentityAlreadyLinked
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		identity = models.UserIdentity{
+			UserID:   userID,
+			Provider: providerName,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}
+		return tx.Create(&identity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// ListIdentities returns the external identities linked to the user.
+func (s *authService) ListIdentities(userID uuid.UUID) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := s.db.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+// UnlinkIdentity removes a linked identity. An account without a password
+// keeps at least one identity so it can still be signed in to.
+func (s *authService) UnlinkIdentity(userID, identityID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		var identity models.UserIdentity
+		if err := tx.Where("id = ? AND user_id = ?", identityID, userID).First(&identity).Error; err != nil {
+			return ErrIdentityNotFound
+		}
+		if user.Password == "" {
+			methods, err := countSignInMethods(tx, userID)
+			if err != nil {
+				return err
+			}
+			if methods <= 1 {
+				return ErrLastSignInMethod
+			}
+		}
+		return tx.Delete(&identity).Error
+	})
+}
+
+// uniqueUsername derives an available username from the provider's claims,
+// within the 3 to 20 alphanumeric characters signup allows.
+func uniqueUsername(tx *gorm.DB, claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return -1
+	}, base)
+	if len(base) > 14 {
+		base = base[:14]
+	}
+	if len(base) < 3 {
+		base = "user" + base
+	}
+	candidate := base
+	for i := 0; i < 10; i++ {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s%06d", base, n)
+	}
+	return "", errNoUniqueUsername
+}
@@ -0,0 +1,401 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/tokens"
+	"eskalate-movie-api/internal/webauthn"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	webAuthnCeremonyTTL = 5 * time.Minute
+	maxPasskeyNameLen   = 100
+)
+
+// Second factors an MFA challenge can be completed with.
+const (
+	MFAMethodTOTP    = "totp"
+	MFAMethodPasskey = "passkey"
+)
+
+var (
+	ErrPasskeyNotFound      = errors.New("passkey not found")
+	ErrPasskeyExists        = errors.New("this passkey is already registered")
+	ErrInvalidPasskey       = errors.New("passkey verification failed")
+	ErrInvalidCeremony      = errors.New("invalid or expired passkey request")
+	ErrPasskeyNotRegistered = errors.New("no passkey is registered for this account")
+)
+
+// PasskeyRegistration starts adding a passkey: Options are passed to
+// navigator.credentials.create and the result sent back with CeremonyID.
+type PasskeyRegistration struct {
+	CeremonyID uuid.UUID                `json:"ceremonyId"`
+	Options    webauthn.CreationOptions `json:"publicKey"`
+}
+
+// PasskeyAssertion starts signing in with a passkey: Options are passed to
+// navigator.credentials.get and the result sent back with CeremonyID.
+type PasskeyAssertion struct {
+	CeremonyID uuid.UUID               `json:"ceremonyId"`
+	Options    webauthn.RequestOptions `json:"publicKey"`
+}
+
+func newRelyingParty(cfg *config.Config) *webauthn.RelyingParty {
+	return &webauthn.RelyingParty{ID: cfg.WebAuthnRPID, Name: cfg.WebAuthnRPName, Origins: cfg.WebAuthnOrigins}
+}
+
+// BeginPasskeyRegistration returns the options to create a passkey for the
+// user, excluding authenticators that already hold one.
+func (s *authService) BeginPasskeyRegistration(userID uuid.UUID) (*PasskeyRegistration, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := s.ListPasskeys(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	ceremonyID, challenge, err := s.startCeremony(models.CeremonyRegistration, &user.ID)
+	if err != nil {
+		return nil, err
+	}
+	entity := webauthn.UserEntity{ID: user.ID[:], Name: user.Email, DisplayName: user.Username}
+	return &PasskeyRegistration{
+		CeremonyID: ceremonyID,
+		Options:    s.rp.CreationOptions(challenge, entity, credentialDescriptors(passkeys)),
+	}, nil
+}
+
+// FinishPasskeyRegistration verifies the authenticator's response and stores
+// the new passkey under name, or a default name when empty.
+func (s *authService) FinishPasskeyRegistration(userID, ceremonyID uuid.UUID, name string, resp *webauthn.AttestationResponse) (*models.Passkey, error) {
+	var passkey *models.Passkey
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		challenge, err := takeCeremony(tx, ceremonyID, models.CeremonyRegistration, &userID)
+		if err != nil {
+			return err
+		}
+		cred, err := s.rp.VerifyRegistration(resp, challenge, false)
+		if err != nil {
+			s.logPasskeyFailure(userID, err)
+			return ErrInvalidPasskey
+		}
+		var existing int64
+		if err := tx.Model(&models.Passkey{}).Where("credential_id = ?", cred.ID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrPasskeyExists
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = "Passkey"
+		}
+		if len(name) > maxPasskeyNameLen {
+			name = name[:maxPasskeyNameLen]
+		}
+		passkey = &models.Passkey{
+			ID:             uuid.New(),
+			UserID:         userID,
+			Name:           name,
+			CredentialID:   cred.ID,
+			PublicKey:      cred.PublicKey,
+			Algorithm:      cred.Algorithm,
+			SignCount:      cred.SignCount,
+			Transports:     cred.Transports,
+			BackupEligible: cred.BackupEligible,
+		}
+		return tx.Create(passkey).Error
+	})
+	return passkey, err
+}
+
+// ListPasskeys returns the user's passkeys, oldest first.
+func (s *authService) ListPasskeys(userID uuid.UUID) ([]models.Passkey, error) {
+	var passkeys []models.Passkey
+	err := s.db.Where("user_id = ?", userID).Order("created_at").Find(&passkeys).Error
+	return passkeys, err
+}
+
+// DeletePasskey removes one of the user's passkeys, unless it is the only way
+// left to sign in to the account.
+func (s *authService) DeletePasskey(userID, passkeyID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		var passkey models.Passkey
+		if err := tx.Where("id = ? AND user_id = ?", passkeyID, userID).First(&passkey).Error; err != nil {
+			return ErrPasskeyNotFound
+		}
+		if user.Password == "" {
+			methods, err := countSignInMethods(tx, userID)
+			if err != nil {
+				return err
+			}
+			if methods <= 1 {
+				return ErrLastSignInMethod
+			}
+		}
+		return tx.Delete(&passkey).Error
+	})
+}
+
+// BeginPasskeyLogin returns the options to sign in with a passkey. No user
+// is named: the browser offers the passkeys it holds for this site, so the
+// response reveals nothing about which accounts exist.
+func (s *authService) BeginPasskeyLogin() (*PasskeyAssertion, error) {
+	ceremonyID, challenge, err := s.startCeremony(models.CeremonyLogin, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &PasskeyAssertion{
+		CeremonyID: ceremonyID,
+		Options:    s.rp.RequestOptions(challenge, nil, webauthn.VerificationRequired),
+	}, nil
+}
+
+// FinishPasskeyLogin signs in with a passkey. The authenticator must have
+// verified the user, by PIN or biometrics, so the passkey stands in for both
+// the password and the second factor.
+func (s *authService) FinishPasskeyLogin(ceremonyID uuid.UUID, resp *webauthn.AssertionResponse, client ClientInfo) (string, string, error) {
+	var user *models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		challenge, err := takeCeremony(tx, ceremonyID, models.CeremonyLogin, nil)
+		if err != nil {
+			return err
+		}
+		passkey, err := s.verifyPasskey(tx, nil, resp, challenge, true)
+		if err != nil {
+			return err
+		}
+		var u models.User
+		if err := tx.First(&u, "id = ?", passkey.UserID).Error; err != nil {
+			return ErrInvalidPasskey
+		}
+		user = &u
+		if u.Disabled {
+			return ErrAccountDisabled
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInvalidPasskey) || errors.Is(err, ErrAccountDisabled) {
+		var userID *uuid.UUID
+		if user != nil {
+			userID = &user.ID
+		}
+		s.auditAuth(models.AuditLogin, models.AuditFailure, userID, client, loginPasskey+": "+err.Error())
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return s.issueSession(user, client, loginPasskey)
+}
+
+// BeginPasskeyMFA returns the options to complete an MFA challenge from
+// LoginWithRefresh with one of the user's passkeys.
+func (s *authService) BeginPasskeyMFA(mfaToken string) (*PasskeyAssertion, error) {
+	var assertion *PasskeyAssertion
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		_, user, err := s.loadActionToken(tx, tokens.UseMFAChallenge, mfaToken)
+		if err != nil {
+			return err
+		}
+		var passkeys []models.Passkey
+		if err := tx.Where("user_id = ?", user.ID).Find(&passkeys).Error; err != nil {
+			return err
+		}
+		if len(passkeys) == 0 {
+			return ErrPasskeyNotRegistered
+		}
+		ceremonyID, challenge, err := s.startCeremony(models.CeremonyMFA, &user.ID)
+		if err != nil {
+			return err
+		}
+		assertion = &PasskeyAssertion{
+			CeremonyID: ceremonyID,
+			Options:    s.rp.RequestOptions(challenge, credentialDescriptors(passkeys), webauthn.VerificationPreferred),
+		}
+		return nil
+	})
+	return assertion, err
+}
+
+// CompletePasskeyMFALogin finishes an MFA challenge with a passkey. Failed
+// assertions count against the challenge like wrong codes do.
+func (s *authService) CompletePasskeyMFALogin(mfaToken string, ceremonyID uuid.UUID, resp *webauthn.AssertionResponse, client ClientInfo) (string, string, error) {
+	var user *models.User
+	failed := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		row, u, err := s.loadActionToken(tx, tokens.UseMFAChallenge, mfaToken)
+		if err != nil {
+			return err
+		}
+		user = u
+		challenge, err := takeCeremony(tx, ceremonyID, models.CeremonyMFA, &u.ID)
+		if err != nil {
+			return err
+		}
+		if _, err := s.verifyPasskey(tx, &u.ID, resp, challenge, false); err != nil {
+			if !errors.Is(err, ErrInvalidPasskey) {
+				return err
+			}
+			// Record the failure rather than rolling it back
+			failed = true
+			updates := map[string]interface{}{"attempts": row.Attempts + 1}
+			if row.Attempts+1 >= maxMFAAttempts {
+				updates["used_at"] = time.Now()
+			}
+			return tx.Model(row).Updates(updates).Error
+		}
+		if u.Disabled {
+			return ErrAccountDisabled
+		}
+		return markActionTokenUsed(tx, row)
+	})
+	method := loginMFA + MFAMethodPasskey
+	if errors.Is(err, ErrAccountDisabled) {
+		s.auditAuth(models.AuditLogin, models.AuditFailure, &user.ID, client, method+": "+err.Error())
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if failed {
+		s.auditAuth(models.AuditLogin, models.AuditFailure, &user.ID, client, method+": "+ErrInvalidPasskey.Error())
+		return "", "", ErrInvalidPasskey
+	}
+	if err := s.throttle.RecordSuccess(user.Email); err != nil {
+		return "", "", err
+	}
+	return s.issueSession(user, client, method)
+}
+
+// mfaMethods returns the second factors the user has set up.
+func (s *authService) mfaMethods(user *models.User) ([]string, error) {
+	var methods []string
+	if user.TOTPEnabled {
+		methods = append(methods, MFAMethodTOTP)
+	}
+	var passkeys int64
+	if err := s.db.Model(&models.Passkey{}).Where("user_id = ?", user.ID).Count(&passkeys).Error; err != nil {
+		return nil, err
+	}
+	if passkeys > 0 {
+		methods = append(methods, MFAMethodPasskey)
+	}
+	return methods, nil
+}
+
+// verifyPasskey checks an assertion signed by one of the user's passkeys,
+// or by any passkey when userID is nil, and records its new signature
+// counter. Every verification failure is reported as ErrInvalidPasskey.
+func (s *authService) verifyPasskey(tx *gorm.DB, userID *uuid.UUID, resp *webauthn.AssertionResponse, challenge []byte, requireUV bool) (*models.Passkey, error) {
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("credential_id = ?", []byte(resp.RawID))
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	var passkey models.Passkey
+	if err := query.First(&passkey).Error; err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	cred := &webauthn.Credential{ID: passkey.CredentialID, PublicKey: passkey.PublicKey, SignCount: passkey.SignCount}
+	signCount, err := s.rp.VerifyAssertion(resp, challenge, cred, passkey.UserID[:], requireUV)
+	if err != nil {
+		s.logPasskeyFailure(passkey.UserID, err)
+		return nil, ErrInvalidPasskey
+	}
+	now := time.Now()
+	if err := tx.Model(&passkey).Updates(map[string]interface{}{
+		"sign_count":   signCount,
+		"last_used_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &passkey, nil
+}
+
+// logPasskeyFailure records why a passkey was rejected. A counter that went
+// backwards suggests a cloned authenticator and is logged as a warning.
+func (s *authService) logPasskeyFailure(userID uuid.UUID, err error) {
+	entry := logrus.WithFields(logrus.Fields{
+		"event":   "passkey_rejected",
+		"user_id": userID,
+		"reason":  err.Error(),
+	})
+	if errors.Is(err, webauthn.ErrSignCountRegression) {
+		entry.Warn("passkey signature counter went backwards")
+		return
+	}
+	entry.Info("passkey rejected")
+}
+
+// startCeremony records a new challenge for purpose, clearing out abandoned ones.
+func (s *authService) startCeremony(purpose string, userID *uuid.UUID) (uuid.UUID, []byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	s.db.Where("expires_at < ?", time.Now()).Delete(&models.WebAuthnCeremony{})
+	ceremony := &models.WebAuthnCeremony{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		Challenge: challenge,
+		ExpiresAt: time.Now().Add(webAuthnCeremonyTTL),
+	}
+	if err := s.db.Create(ceremony).Error; err != nil {
+		return uuid.Nil, nil, err
+	}
+	return ceremony.ID, challenge, nil
+}
+
+// takeCeremony consumes a ceremony started for purpose and returns its
+// challenge. A ceremony started for a user can only be finished by them.
+func takeCeremony(tx *gorm.DB, id uuid.UUID, purpose string, userID *uuid.UUID) ([]byte, error) {
+	var ceremony models.WebAuthnCeremony
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND purpose = ?", id, purpose).First(&ceremony).Error; err != nil {
+		return nil, ErrInvalidCeremony
+	}
+	if err := tx.Delete(&ceremony).Error; err != nil {
+		return nil, err
+	}
+	if time.Now().After(ceremony.ExpiresAt) {
+		return nil, ErrInvalidCeremony
+	}
+	if userID != nil && (ceremony.UserID == nil || *ceremony.UserID != *userID) {
+		return nil, ErrInvalidCeremony
+	}
+	return ceremony.Challenge, nil
+}
+
+// countSignInMethods counts the linked identities and passkeys a user can
+// sign in with besides a password.
+func countSignInMethods(tx *gorm.DB, userID uuid.UUID) (int64, error) {
+	var identities, passkeys int64
+	if err := tx.Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&identities).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&models.Passkey{}).Where("user_id = ?", userID).Count(&passkeys).Error; err != nil {
+		return 0, err
+	}
+	return identities + passkeys, nil
+}
+
+func credentialDescriptors(passkeys []models.Passkey) []webauthn.CredentialDescriptor {
+	descriptors := make([]webauthn.CredentialDescriptor, len(passkeys))
+	for i, p := range passkeys {
+		descriptors[i] = webauthn.CredentialDescriptor{Type: "public-key", ID: p.CredentialID, Transports: p.Transports}
+	}
+	return descriptors
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"log"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/passwordpolicy"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// newPasswordPolicy builds the password policy from cfg and opens its
+// breach list. A list that cannot be opened is skipped.
+func newPasswordPolicy(cfg config.PasswordPolicy) (passwordpolicy.Policy, passwordpolicy.BreachList) {
+	policy := passwordpolicy.Policy{
+		MinLength:        cfg.MinLength,
+		MaxLength:        cfg.MaxLength,
+		RequireUppercase: cfg.RequireUppercase,
+		RequireLowercase: cfg.RequireLowercase,
+		RequireDigit:     cfg.RequireDigit,
+		RequireSymbol:    cfg.RequireSymbol,
+		DisallowIdentity: cfg.DisallowIdentity,
+		History:          cfg.History,
+	}
+	if cfg.BreachFile == "" {
+		return policy, nil
+	}
+	breaches, err := passwordpolicy.OpenBreachList(cfg.BreachFile)
+	if err != nil {
+		log.Printf("Cannot open PASSWORD_BREACH_FILE, breached passwords are not checked: %v", err)
+		return policy, nil
+	}
+	policy.BreachCheck = true
+	return policy, breaches
+}
+
+// PasswordPolicy returns the rules new passwords must follow.
+func (s *authService) PasswordPolicy() passwordpolicy.Policy {
+	return s.policy
+}
+
+// validateNewPassword checks a password chosen for user against the policy
+// and returns passwordpolicy.Violations listing every rule it breaks.
+func (s *authService) validateNewPassword(tx *gorm.DB, user *models.User, password string) error {
+	violations := s.policy.Check(password, passwordpolicy.Identity{Username: user.Username, Email: user.Email})
+	if s.breaches != nil {
+		breached, err := s.breaches.Contains(password)
+		switch {
+		case err != nil:
+			// An unreadable list must not stop everyone from setting a password
+			logrus.WithFields(logrus.Fields{
+				"event": "password_breach_check_error",
+				"error": err.Error(),
+			}).Warn("breached password list could not be read")
+		case breached:
+			violations = append(violations, passwordpolicy.ErrBreached)
+		}
+	}
+	reused, err := s.passwordReused(tx, user, password)
+	if err != nil {
+		return err
+	}
+	if reused {
+		violations = append(violations, passwordpolicy.ErrReused)
+	}
+	return violations.Err()
+}
+
+// passwordReused reports whether password is the user's current password or
+// one of the previous ones the policy's history still covers.
+func (s *authService) passwordReused(tx *gorm.DB, user *models.User, password string) (bool, error) {
+	if s.policy.History == 0 || user.ID == uuid.Nil {
+		return false, nil
+	}
+	var hashes []string
+	if s.policy.History > 1 {
+		if err := tx.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).
+			Order("created_at DESC").Limit(s.policy.History-1).Pluck("hash", &hashes).Error; err != nil {
+			return false, err
+		}
+	}
+	if user.Password != "" {
+		hashes = append(hashes, user.Password)
+	}
+	for _, hash := range hashes {
+		if s.hasher.Verify(hash, password) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setPassword replaces the user's password with hash, moving the old one
+// into their password history and dropping entries the history no longer
+// covers.
+func (s *authService) setPassword(tx *gorm.DB, user *models.User, hash string) error {
+	if user.Password != "" && s.policy.History > 1 {
+		if err := tx.Create(&models.PasswordHistory{ID: uuid.New(), UserID: user.ID, Hash: user.Password}).Error; err != nil {
+			return err
+		}
+		var stale []uuid.UUID
+		if err := tx.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).
+			Order("created_at DESC").Offset(s.policy.History-1).Pluck("id", &stale).Error; err != nil {
+			return err
+		}
+		if len(stale) > 0 {
+			if err := tx.Where("id IN ?", stale).Delete(&models.PasswordHistory{}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Model(user).Update("password", hash).Error
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/repository"
+	"eskalate-movie-api/internal/tokens"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	maxPersonalAccessTokenTTL = 365 * 24 * time.Hour
+	// lastUsedResolution bounds how often a token's last-used time is written
+	lastUsedResolution = time.Minute
+)
+
+var (
+	ErrInvalidPersonalAccessToken  = errors.New("invalid, expired or revoked personal access token")
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+	ErrInvalidScope                = errors.New("unknown scope")
+	ErrInvalidTokenLifetime        = errors.New("personal access tokens must expire within a year")
+)
+
+type PersonalAccessTokenService interface {
+	Create(userID uuid.UUID, name string, scopes []string, ttl time.Duration) (*models.PersonalAccessToken, string, error)
+	List(userID uuid.UUID) ([]models.PersonalAccessToken, error)
+	Revoke(userID, tokenID uuid.UUID) error
+	Authenticate(token string) (*tokens.Identity, error)
+}
+
+type personalAccessTokenService struct {
+	userRepo repository.UserRepository
+	db       *gorm.DB
+	key      []byte
+}
+
+// NewPersonalAccessTokenService creates the service managing personal access
+// tokens. They are hashed with the same key as refresh tokens.
+func NewPersonalAccessTokenService(userRepo repository.UserRepository, db *gorm.DB, cfg *config.Config) PersonalAccessTokenService {
+	return &personalAccessTokenService{userRepo: userRepo, db: db, key: []byte(cfg.RefreshTokenKey)}
+}
+
+// Create issues a new token and returns it with its record. The token itself
+// is only returned here; afterwards only its prefix is known.
+func (s *personalAccessTokenService) Create(userID uuid.UUID, name string, scopes []string, ttl time.Duration) (*models.PersonalAccessToken, string, error) {
+	if ttl <= 0 || ttl > maxPersonalAccessTokenTTL {
+		return nil, "", ErrInvalidTokenLifetime
+	}
+	var granted models.ScopeList
+	for _, scope := range scopes {
+		if !models.ValidScope(scope) {
+			return nil, "", fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+		if !contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	token, prefix, err := newOpaqueToken(tokens.PersonalAccessTokenScheme)
+	if err != nil {
+		return nil, "", err
+	}
+	record := &models.PersonalAccessToken{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Name:        strings.TrimSpace(name),
+		TokenPrefix: prefix,
+		TokenHash:   keyedHash(s.key, token),
+		Scopes:      granted,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, "", err
+	}
+	return record, token, nil
+}
+
+// List returns the user's tokens, newest first, including expired and revoked ones.
+func (s *personalAccessTokenService) List(userID uuid.UUID) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// Revoke stops a token of the user from being accepted.
+func (s *personalAccessTokenService) Revoke(userID, tokenID uuid.UUID) error {
+	res := s.db.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+// Authenticate resolves a presented token to its owner and records its use.
+func (s *personalAccessTokenService) Authenticate(token string) (*tokens.Identity, error) {
+	prefix, ok := opaqueTokenPrefix(token, tokens.PersonalAccessTokenScheme)
+	if !ok {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+	var record models.PersonalAccessToken
+	if err := s.db.Where("token_prefix = ?", prefix).First(&record).Error; err != nil {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+	now := time.Now()
+	if !hmac.Equal([]byte(record.TokenHash), []byte(keyedHash(s.key, token))) ||
+		record.RevokedAt != nil || now.After(record.ExpiresAt) {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+	user, err := s.userRepo.FindByID(record.UserID)
+	if err != nil || user.Disabled {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+	grants, err := s.userRepo.FindPermissionGrants(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= lastUsedResolution {
+		if err := s.db.Model(&record).Update("last_used_at", now).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &tokens.Identity{
+		TokenID:       record.ID,
+		UserID:        user.ID,
+		Roles:         []string{user.Role},
+		Permissions:   models.EffectivePermissions(user.Role, grants),
+		Scopes:        record.Scopes,
+		EmailVerified: user.EmailVerified,
+	}, nil
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,631 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/oidc"
+	"eskalate-movie-api/internal/revocation"
+	"eskalate-movie-api/internal/scim"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrGroupNotFound = errors.New("group not found")
+
+// scimRoles are the groups of the SCIM API, in the order they are listed.
+var scimRoles = []string{models.RoleAdmin, models.RoleCurator, models.RoleMember}
+
+// RoleGroup is a role as the SCIM API presents it: a group whose members
+// are the users holding the role.
+type RoleGroup struct {
+	Role    string
+	Members []models.User
+}
+
+// ProvisioningService backs the SCIM API that an identity management system
+// uses to create, update and deprovision accounts. A user's SCIM userName is
+// their email, which is how they sign in; groups are the roles. Changes are
+// recorded in the audit trail with the provisioning client's token owner as
+// actor, who cannot deprovision their own account or change their own role.
+type ProvisioningService interface {
+	ListUsers(filter scim.Filter, offset, limit int) ([]models.User, int64, error)
+	GetUser(id string) (*models.User, error)
+	CreateUser(actor Actor, client ClientInfo, resource *scim.User) (*models.User, error)
+	ReplaceUser(actor Actor, client ClientInfo, id string, resource *scim.User) (*models.User, error)
+	PatchUser(actor Actor, client ClientInfo, id string, ops []scim.PatchOperation) (*models.User, error)
+	DeprovisionUser(actor Actor, client ClientInfo, id string) error
+	ListGroups(filter scim.Filter, withMembers bool) ([]RoleGroup, error)
+	GetGroup(id string, withMembers bool) (*RoleGroup, error)
+	PatchGroup(actor Actor, client ClientInfo, id string, ops []scim.PatchOperation) error
+	ReplaceGroup(actor Actor, client ClientInfo, id string, members []string) error
+}
+
+type provisioningService struct {
+	db       *gorm.DB
+	denylist revocation.Store
+}
+
+func NewProvisioningService(db *gorm.DB, denylist revocation.Store) ProvisioningService {
+	return &provisioningService{db: db, denylist: denylist}
+}
+
+// ListUsers returns a page of the users matching filter, oldest first so
+// that pages stay stable while users are added. A nil filter matches all.
+func (s *provisioningService) ListUsers(filter scim.Filter, offset, limit int) ([]models.User, int64, error) {
+	q := s.db.Model(&models.User{})
+	if filter != nil {
+		where, args, err := scimUserCondition(filter, "")
+		if err != nil {
+			return nil, 0, err
+		}
+		q = q.Where(where, args...)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []models.User
+	if limit == 0 {
+		return users, total, nil
+	}
+	err := q.Order("created_at, id").Offset(offset).Limit(limit).Find(&users).Error
+	return users, total, err
+}
+
+func (s *provisioningService) GetUser(id string) (*models.User, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser creates an account for a directory user. The directory is
+// trusted with their email, so it is marked verified. The account has no
+// password: the user signs in through a magic link, an identity provider
+// or a password reset.
+func (s *provisioningService) CreateUser(actor Actor, client ClientInfo, resource *scim.User) (*models.User, error) {
+	email, err := scimEmail(resource)
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkEmailAvailable(tx, email, uuid.Nil); err != nil {
+			return err
+		}
+		username, err := uniqueUsername(tx, &oidc.Claims{Email: email})
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		user = models.User{
+			Username:        username,
+			Email:           email,
+			DisplayName:     truncate(resource.FullName(), 50),
+			ExternalID:      resource.ExternalID,
+			Role:            models.RoleMember,
+			EmailVerified:   true,
+			EmailVerifiedAt: &now,
+		}
+		if resource.Active != nil && !*resource.Active {
+			user.Disabled, user.DisabledAt = true, &now
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, adminAuditEvent(actor, client, models.AuditUserProvision, user.ID, "SCIM"))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ReplaceUser sets the user's attributes to those of resource. An absent
+// active attribute leaves the account enabled or disabled as it is.
+func (s *provisioningService) ReplaceUser(actor Actor, client ClientInfo, id string, resource *scim.User) (*models.User, error) {
+	return s.updateUser(actor, client, id, func(*models.User) (*scim.User, error) {
+		return resource, nil
+	})
+}
+
+// PatchUser applies PATCH operations to the user's SCIM representation and
+// saves the result.
+func (s *provisioningService) PatchUser(actor Actor, client ClientInfo, id string, ops []scim.PatchOperation) (*models.User, error) {
+	return s.updateUser(actor, client, id, func(user *models.User) (*scim.User, error) {
+		resource := scim.NewUser(user)
+		if err := scim.ApplyUserPatch(resource, ops); err != nil {
+			return nil, err
+		}
+		return resource, nil
+	})
+}
+
+// DeprovisionUser disables the account, revoking its sessions and personal
+// access tokens. The account and its data are kept, so it can be
+// reactivated by setting active again.
+func (s *provisioningService) DeprovisionUser(actor Actor, client ClientInfo, id string) error {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if userID == actor.UserID {
+		return ErrCannotModifySelf
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := lockAccount(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.Disabled {
+			return nil
+		}
+		if err := setAccountDisabled(tx, s.denylist, user, true); err != nil {
+			return err
+		}
+		return recordAudit(tx, adminAuditEvent(actor, client, models.AuditUserDeprovision, user.ID, "SCIM"))
+	})
+}
+
+// updateUser locks the user, builds the resource they should become and
+// applies the differences.
+func (s *provisioningService) updateUser(actor Actor, client ClientInfo, id string, target func(*models.User) (*scim.User, error)) (*models.User, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	var user *models.User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = lockAccount(tx, userID); err != nil {
+			return err
+		}
+		resource, err := target(user)
+		if err != nil {
+			return err
+		}
+		if err := s.applyUser(tx, actor, client, user, resource); err != nil {
+			return err
+		}
+		return tx.First(user, "id = ?", user.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *provisioningService) applyUser(tx *gorm.DB, actor Actor, client ClientInfo, user *models.User, resource *scim.User) error {
+	email, err := scimEmail(resource)
+	if err != nil {
+		return err
+	}
+	updates := map[string]interface{}{}
+	var changed []string
+	if email != user.Email {
+		if err := checkEmailAvailable(tx, email, user.ID); err != nil {
+			return err
+		}
+		updates["email"] = email
+		updates["email_verified"] = true
+		updates["email_verified_at"] = time.Now()
+		updates["pending_email"] = ""
+		changed = append(changed, "email")
+	}
+	if name := truncate(resource.FullName(), 50); name != user.DisplayName {
+		updates["display_name"] = name
+		changed = append(changed, "displayName")
+	}
+	if resource.ExternalID != user.ExternalID {
+		updates["external_id"] = resource.ExternalID
+		changed = append(changed, "externalId")
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(tx, adminAuditEvent(actor, client, models.AuditUserUpdate, user.ID,
+			"SCIM: "+strings.Join(changed, ", "))); err != nil {
+			return err
+		}
+	}
+
+	if resource.Active == nil || *resource.Active != user.Disabled {
+		return nil
+	}
+	if user.ID == actor.UserID {
+		return ErrCannotModifySelf
+	}
+	disabled := !*resource.Active
+	if err := setAccountDisabled(tx, s.denylist, user, disabled); err != nil {
+		return err
+	}
+	action := models.AuditUserEnable
+	if disabled {
+		action = models.AuditUserDeprovision
+	}
+	return recordAudit(tx, adminAuditEvent(actor, client, action, user.ID, "SCIM"))
+}
+
+// ListGroups returns the roles matching filter. Members are only loaded
+// when asked for, since every user belongs to one of the groups.
+func (s *provisioningService) ListGroups(filter scim.Filter, withMembers bool) ([]RoleGroup, error) {
+	var groups []RoleGroup
+	for _, role := range scimRoles {
+		if filter != nil && !scim.Matches(filter, map[string]interface{}{"id": role, "displayName": role}) {
+			continue
+		}
+		group, err := s.GetGroup(role, withMembers)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, *group)
+	}
+	return groups, nil
+}
+
+func (s *provisioningService) GetGroup(id string, withMembers bool) (*RoleGroup, error) {
+	if !models.ValidRole(id) {
+		return nil, ErrGroupNotFound
+	}
+	group := &RoleGroup{Role: id}
+	if withMembers {
+		if err := s.db.Where("role = ?", id).Order("created_at, id").Find(&group.Members).Error; err != nil {
+			return nil, err
+		}
+	}
+	return group, nil
+}
+
+// PatchGroup adds and removes members of a role. Adding a user gives them
+// the role; removing them makes them a member again. Removing users from
+// the member group has no effect, since there is no lesser role.
+func (s *provisioningService) PatchGroup(actor Actor, client ClientInfo, id string, ops []scim.PatchOperation) error {
+	if !models.ValidRole(id) {
+		return ErrGroupNotFound
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, o := range ops {
+			op, err := o.Operation()
+			if err != nil {
+				return err
+			}
+			if op == scim.OpRemove && o.Path == "" {
+				return scim.BadRequest(scim.ErrorNoTarget, "remove needs a path")
+			}
+			targets, err := o.Targets()
+			if err != nil {
+				return err
+			}
+			for path, value := range targets {
+				switch strings.ToLower(path.Attribute) {
+				case "members":
+					err = s.patchMembers(tx, actor, client, id, op, path, value)
+				case "displayname", "id":
+					var name string
+					if json.Unmarshal(value, &name) != nil || name != id {
+						err = scim.BadRequest(scim.ErrorMutability, "groups are roles and cannot be renamed")
+					}
+				case "externalid":
+				default:
+					err = scim.BadRequest(scim.ErrorInvalidPath, "unknown group attribute "+path.Attribute)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// ReplaceGroup makes members exactly the users holding the role.
+func (s *provisioningService) ReplaceGroup(actor Actor, client ClientInfo, id string, members []string) error {
+	if !models.ValidRole(id) {
+		return ErrGroupNotFound
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.replaceMembers(tx, actor, client, id, members)
+	})
+}
+
+func (s *provisioningService) patchMembers(tx *gorm.DB, actor Actor, client ClientInfo, role, op string, path scim.Path, value json.RawMessage) error {
+	if op == scim.OpRemove {
+		var ids []string
+		var err error
+		switch {
+		case path.Filter != nil:
+			current, err := memberIDs(tx, role)
+			if err != nil {
+				return err
+			}
+			for _, memberID := range current {
+				if scim.Matches(path.Filter, map[string]interface{}{"value": memberID}) {
+					ids = append(ids, memberID)
+				}
+			}
+		case len(value) > 0 && string(value) != "null":
+			if ids, err = scim.MemberValues(value); err != nil {
+				return err
+			}
+		default:
+			if ids, err = memberIDs(tx, role); err != nil {
+				return err
+			}
+		}
+		for _, memberID := range ids {
+			if err := s.revokeRole(tx, actor, client, memberID, role); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ids, err := scim.MemberValues(value)
+	if err != nil {
+		return err
+	}
+	if op == scim.OpReplace {
+		return s.replaceMembers(tx, actor, client, role, ids)
+	}
+	for _, memberID := range ids {
+		if err := s.grantRole(tx, actor, client, memberID, role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *provisioningService) replaceMembers(tx *gorm.DB, actor Actor, client ClientInfo, role string, members []string) error {
+	keep := make(map[string]bool, len(members))
+	for _, memberID := range members {
+		if err := s.grantRole(tx, actor, client, memberID, role); err != nil {
+			return err
+		}
+		keep[strings.ToLower(memberID)] = true
+	}
+	current, err := memberIDs(tx, role)
+	if err != nil {
+		return err
+	}
+	for _, memberID := range current {
+		if !keep[memberID] {
+			if err := s.revokeRole(tx, actor, client, memberID, role); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// grantRole gives the user the role, replacing the one they had.
+func (s *provisioningService) grantRole(tx *gorm.DB, actor Actor, client ClientInfo, memberID, role string) error {
+	user, err := lockMember(tx, memberID)
+	if err != nil || user.Role == role {
+		return err
+	}
+	return setRoleByProvisioning(tx, s.denylist, actor, client, user, role)
+}
+
+// revokeRole makes a user holding the role a member.
+func (s *provisioningService) revokeRole(tx *gorm.DB, actor Actor, client ClientInfo, memberID, role string) error {
+	if role == models.RoleMember {
+		return nil
+	}
+	user, err := lockMember(tx, memberID)
+	if err != nil || user.Role != role {
+		return err
+	}
+	return setRoleByProvisioning(tx, s.denylist, actor, client, user, models.RoleMember)
+}
+
+// setRoleByProvisioning changes the role of a locked user. As with SetRole,
+// a demotion signs the user out of every session.
+func setRoleByProvisioning(tx *gorm.DB, denylist revocation.Store, actor Actor, client ClientInfo, user *models.User, role string) error {
+	if user.ID == actor.UserID {
+		return ErrCannotModifySelf
+	}
+	previous := user.Role
+	if err := setUserRole(tx, denylist, user, role); err != nil {
+		return err
+	}
+	return recordAudit(tx, adminAuditEvent(actor, client, models.AuditUserRoleChange, user.ID,
+		fmt.Sprintf("%s -> %s (SCIM)", previous, role)))
+}
+
+// lockMember locks the user a group member value refers to. Unknown
+// members are a bad request rather than a missing group.
+func lockMember(tx *gorm.DB, memberID string) (*models.User, error) {
+	unknown := scim.BadRequest(scim.ErrorInvalidValue, "unknown member "+memberID)
+	userID, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, unknown
+	}
+	user, err := lockAccount(tx, userID)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, unknown
+	}
+	return user, err
+}
+
+func memberIDs(tx *gorm.DB, role string) ([]string, error) {
+	var ids []uuid.UUID
+	if err := tx.Model(&models.User{}).Where("role = ?", role).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = id.String()
+	}
+	return values, nil
+}
+
+// scimEmail returns the email of a User resource, which is its userName.
+func scimEmail(resource *scim.User) (string, error) {
+	email := strings.TrimSpace(resource.UserName)
+	if email == "" {
+		return "", scim.BadRequest(scim.ErrorInvalidValue, "userName is required")
+	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return "", scim.BadRequest(scim.ErrorInvalidValue, "userName must be the user's email address")
+	}
+	return email, nil
+}
+
+// checkEmailAvailable fails with a uniqueness error when another account
+// than exceptID uses email, compared case-insensitively.
+func checkEmailAvailable(tx *gorm.DB, email string, exceptID uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.User{}).
+		Where("(LOWER(email) = LOWER(?) OR LOWER(pending_email) = LOWER(?)) AND id <> ?", email, email, exceptID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return scim.NewError(http.StatusConflict, scim.ErrorUniqueness, ErrEmailTaken.Error())
+	}
+	return nil
+}
+
+// scimUserColumns maps User attributes, lower-cased, to the columns they
+// are stored in.
+var scimUserColumns = map[string]string{
+	"id":                "id",
+	"username":          "email",
+	"emails":            "email",
+	"emails.value":      "email",
+	"externalid":        "external_id",
+	"displayname":       "display_name",
+	"name.formatted":    "display_name",
+	"active":            "disabled",
+	"meta.created":      "created_at",
+	"meta.lastmodified": "updated_at",
+}
+
+// scimUserCondition translates a filter into an SQL condition on users.
+// Attributes inside a value path, such as emails[value eq "x"], are
+// prefixed with the path's attribute.
+func scimUserCondition(f scim.Filter, prefix string) (string, []interface{}, error) {
+	switch f := f.(type) {
+	case *scim.Logical:
+		left, leftArgs, err := scimUserCondition(f.Left, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rightArgs, err := scimUserCondition(f.Right, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + left + " " + strings.ToUpper(f.Operator) + " " + right + ")", append(leftArgs, rightArgs...), nil
+	case *scim.Not:
+		inner, args, err := scimUserCondition(f.Filter, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + inner + ")", args, nil
+	case *scim.ValuePath:
+		if prefix != "" {
+			return "", nil, scim.BadRequest(scim.ErrorInvalidFilter, "nested value filters are not supported")
+		}
+		return scimUserCondition(f.Filter, f.Attribute+".")
+	case *scim.Comparison:
+		return scimComparison(prefix+f.Attribute, f.Operator, f.Value)
+	}
+	return "", nil, scim.BadRequest(scim.ErrorInvalidFilter, "unsupported filter")
+}
+
+func scimComparison(attribute, operator string, value interface{}) (string, []interface{}, error) {
+	column, ok := scimUserColumns[strings.ToLower(attribute)]
+	if !ok {
+		return "", nil, scim.BadRequest(scim.ErrorInvalidFilter, "filtering on "+attribute+" is not supported")
+	}
+	invalid := scim.BadRequest(scim.ErrorInvalidFilter, fmt.Sprintf("%s cannot be compared with %s %v", attribute, operator, value))
+	if operator == "pr" {
+		switch column {
+		case "id", "disabled", "created_at", "updated_at":
+			return "TRUE", nil, nil
+		}
+		return column + " <> ''", nil, nil
+	}
+
+	switch column {
+	case "disabled":
+		active, ok := value.(bool)
+		if !ok || (operator != "eq" && operator != "ne") {
+			return "", nil, invalid
+		}
+		return "disabled = ?", []interface{}{(operator == "eq") != active}, nil
+	case "id":
+		s, ok := value.(string)
+		if !ok || (operator != "eq" && operator != "ne") {
+			return "", nil, invalid
+		}
+		id, err := uuid.Parse(s)
+		switch {
+		case err != nil && operator == "eq":
+			// No user has an ID that is not a UUID
+			return "FALSE", nil, nil
+		case err != nil:
+			return "TRUE", nil, nil
+		}
+		return "id " + scimOrderOperators[operator] + " ?", []interface{}{id}, nil
+	case "created_at", "updated_at":
+		s, ok := value.(string)
+		if !ok {
+			return "", nil, invalid
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return "", nil, invalid
+		}
+		sqlOp, ok := scimOrderOperators[operator]
+		if !ok {
+			return "", nil, invalid
+		}
+		return column + " " + sqlOp + " ?", []interface{}{t}, nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return "", nil, invalid
+	}
+	// externalId is case-exact; the other string attributes are not
+	expr, arg := "LOWER("+column+")", strings.ToLower(s)
+	if column == "external_id" {
+		expr, arg = column, s
+	}
+	like := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(arg)
+	switch operator {
+	case "co":
+		return expr + " LIKE ?", []interface{}{"%" + like + "%"}, nil
+	case "sw":
+		return expr + " LIKE ?", []interface{}{like + "%"}, nil
+	case "ew":
+		return expr + " LIKE ?", []interface{}{"%" + like}, nil
+	}
+	sqlOp, ok := scimOrderOperators[operator]
+	if !ok {
+		return "", nil, invalid
+	}
+	return expr + " " + sqlOp + " ?", []interface{}{arg}, nil
+}
+
+var scimOrderOperators = map[string]string{
+	"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<=",
+}
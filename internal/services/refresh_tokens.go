@@ -0,0 +1,194 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/revocation"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Refresh tokens are opaque strings of the form "rt_<prefix>.<secret>". The
+// prefix is stored in clear and used to find the row; the whole token is only
+// stored as an HMAC keyed with the server's refresh token key, so a copy of
+// the table cannot be replayed.
+const refreshTokenScheme = "rt_"
+
+// legacyRefreshTokenPrefix marks rows migrated from the signed-JWT scheme,
+// which have no random prefix of their own.
+const legacyRefreshTokenPrefix = "jwt_"
+
+// newOpaqueToken returns a fresh "<scheme><prefix>.<secret>" token and its
+// lookup prefix.
+func newOpaqueToken(scheme string) (token, prefix string, err error) {
+	prefixBytes := make([]byte, 8)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(prefixBytes)
+	token = scheme + prefix + "." + base64.RawURLEncoding.EncodeToString(secretBytes)
+	return token, prefix, nil
+}
+
+// opaqueTokenPrefix extracts the lookup prefix of a token issued by newOpaqueToken.
+func opaqueTokenPrefix(token, scheme string) (string, bool) {
+	if !strings.HasPrefix(token, scheme) {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(token, scheme), ".")
+	if !ok || prefix == "" || secret == "" {
+		return "", false
+	}
+	return prefix, true
+}
+
+// refreshTokenLookupPrefix returns the prefix a presented token is stored under.
+// Tokens issued before hashing was introduced are signed JWTs; they are looked
+// up by a digest so they keep working until their next rotation.
+func refreshTokenLookupPrefix(token string) (string, bool) {
+	if strings.HasPrefix(token, refreshTokenScheme) {
+		return opaqueTokenPrefix(token, refreshTokenScheme)
+	}
+	if strings.Count(token, ".") == 2 {
+		return legacyLookupPrefix(token), true
+	}
+	return "", false
+}
+
+func legacyLookupPrefix(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return legacyRefreshTokenPrefix + hex.EncodeToString(sum[:8])
+}
+
+// keyedHash returns the hex HMAC-SHA256 of value, used to store high-entropy
+// secrets such as refresh tokens, personal access tokens and recovery codes.
+func keyedHash(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// findRefreshToken locks and returns the row matching the presented token.
+func findRefreshToken(tx *gorm.DB, key []byte, token string) (*models.RefreshToken, error) {
+	prefix, ok := refreshTokenLookupPrefix(token)
+	if !ok {
+		return nil, ErrInvalidRefreshToken
+	}
+	var dbToken models.RefreshToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_prefix = ?", prefix).First(&dbToken).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if !hmac.Equal([]byte(dbToken.TokenHash), []byte(keyedHash(key, token))) {
+		return nil, ErrInvalidRefreshToken
+	}
+	return &dbToken, nil
+}
+
+// issueRefreshToken creates a new refresh token in the given family and stores
+// its hash using db, which may be a transaction. accessTokenID is the jti of
+// the access token issued with it, startedAt is when the family's login
+// happened and client is the client the token is issued to.
+func issueRefreshToken(db *gorm.DB, key []byte, userID, familyID uuid.UUID, accessTokenID string, startedAt time.Time, client ClientInfo) (string, error) {
+	token, prefix, err := newOpaqueToken(refreshTokenScheme)
+	if err != nil {
+		return "", err
+	}
+	refreshTokenModel := &models.RefreshToken{
+		ID:            uuid.New(),
+		UserID:        userID,
+		TokenPrefix:   prefix,
+		TokenHash:     keyedHash(key, token),
+		FamilyID:      familyID,
+		AccessTokenID: accessTokenID,
+		ExpiresAt:     time.Now().Add(RefreshTokenTTL),
+		Revoked:       false,
+		UserAgent:     truncate(client.UserAgent, 512),
+		IPAddress:     truncate(client.IPAddress, 64),
+		StartedAt:     startedAt,
+		LastUsedAt:    time.Now(),
+		CreatedAt:     time.Now(),
+	}
+	if err := db.Create(refreshTokenModel).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// revokeRefreshTokens revokes the refresh tokens selected by scope and
+// denylists the access tokens issued with them that have not expired yet, so
+// the sessions end at once instead of when their last access token expires.
+func revokeRefreshTokens(db *gorm.DB, denylist revocation.Store, scope func(*gorm.DB) *gorm.DB) error {
+	var issued []models.RefreshToken
+	if err := scope(db.Model(&models.RefreshToken{})).
+		Where("access_token_id <> '' AND created_at > ?", time.Now().Add(-AccessTokenTTL)).
+		Select("access_token_id", "created_at").Find(&issued).Error; err != nil {
+		return err
+	}
+	for _, token := range issued {
+		if err := denylist.Revoke(token.AccessTokenID, token.CreatedAt.Add(AccessTokenTTL)); err != nil {
+			return err
+		}
+	}
+	return scope(db.Model(&models.RefreshToken{})).Update("revoked", true).Error
+}
+
+func revokeFamily(db *gorm.DB, denylist revocation.Store, familyID uuid.UUID) error {
+	return revokeRefreshTokens(db, denylist, func(q *gorm.DB) *gorm.DB {
+		return q.Where("family_id = ?", familyID)
+	})
+}
+
+// revokeUserRefreshTokens revokes every refresh token of the user, ending all their sessions.
+func revokeUserRefreshTokens(db *gorm.DB, denylist revocation.Store, userID uuid.UUID) error {
+	return revokeRefreshTokens(db, denylist, func(q *gorm.DB) *gorm.DB {
+		return q.Where("user_id = ? AND revoked = false", userID)
+	})
+}
+
+// MigrateLegacyRefreshTokens converts rows created when refresh tokens were
+// stored as raw JWTs: each token is replaced by its keyed hash and the raw
+// column is dropped. It is safe to run on every start.
+func MigrateLegacyRefreshTokens(db *gorm.DB, key []byte) error {
+	migrator := db.Migrator()
+	if !migrator.HasColumn(&models.RefreshToken{}, "token") {
+		return nil
+	}
+
+	type legacyRow struct {
+		ID    uuid.UUID
+		Token string
+	}
+	var rows []legacyRow
+	if err := db.Table("refresh_tokens").Select("id, token").Where("token IS NOT NULL AND token_hash IS NULL").Scan(&rows).Error; err != nil {
+		return err
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			if err := tx.Model(&models.RefreshToken{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+				"token_prefix": legacyLookupPrefix(row.Token),
+				"token_hash":   keyedHash(key, row.Token),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return migrator.DropColumn(&models.RefreshToken{}, "token")
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// ClientInfo identifies the client a request came from.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Session is an active login: a refresh token family that has not been
+// revoked or expired, described by the client that last used it.
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	StartedAt  time.Time `json:"startedAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	// Current marks the session the request was made from
+	Current bool `json:"current"`
+}
+
+// ListSessions returns the user's active sessions, most recently used first.
+// A session is represented by its newest refresh token.
+func (s *authService) ListSessions(userID, currentSessionID uuid.UUID) ([]Session, error) {
+	var heads []models.RefreshToken
+	if err := s.db.Where("user_id = ? AND revoked = false AND consumed_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").Find(&heads).Error; err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, len(heads))
+	for i, head := range heads {
+		sessions[i] = Session{
+			ID:         head.FamilyID,
+			UserAgent:  head.UserAgent,
+			IPAddress:  head.IPAddress,
+			StartedAt:  head.StartedAt,
+			LastUsedAt: head.LastUsedAt,
+			ExpiresAt:  head.ExpiresAt,
+			Current:    head.FamilyID == currentSessionID,
+		}
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs one of the user's sessions out.
+func (s *authService) RevokeSession(userID, sessionID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var active int64
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND family_id = ? AND revoked = false", userID, sessionID).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active == 0 {
+			return ErrSessionNotFound
+		}
+		return revokeFamily(tx, s.denylist, sessionID)
+	})
+}
+
+// RevokeAllSessions signs the user out everywhere, except for the session
+// exceptSessionID when it is set. It returns the number of sessions ended.
+func (s *authService) RevokeAllSessions(userID, exceptSessionID uuid.UUID) (int64, error) {
+	query := s.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked = false AND consumed_at IS NULL AND expires_at > ?", userID, time.Now())
+	if exceptSessionID != uuid.Nil {
+		query = query.Where("family_id <> ?", exceptSessionID)
+	}
+	var families []uuid.UUID
+	if err := query.Distinct().Pluck("family_id", &families).Error; err != nil {
+		return 0, err
+	}
+	if len(families) == 0 {
+		return 0, nil
+	}
+	err := revokeRefreshTokens(s.db, s.denylist, func(q *gorm.DB) *gorm.DB {
+		return q.Where("user_id = ? AND family_id IN ?", userID, families)
+	})
+	return int64(len(families)), err
+}
+
+// isNewDevice reports whether a login from client should be notified: the
+// user has signed in before, but never with this user agent.
+func (s *authService) isNewDevice(userID uuid.UUID, client ClientInfo) (bool, error) {
+	var total, known int64
+	if err := s.db.Model(&models.RefreshToken{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+		return false, err
+	}
+	if total == 0 {
+		return false, nil
+	}
+	if err := s.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND user_agent = ?", userID, truncate(client.UserAgent, 512)).
+		Count(&known).Error; err != nil {
+		return false, err
+	}
+	return known == 0, nil
+}
+
+// truncate shortens s to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
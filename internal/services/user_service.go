@@ -0,0 +1,54 @@
+package services
+
+import (
+	"strings"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// ProfileUpdate holds the profile fields to change; nil fields are left as they are.
+type ProfileUpdate struct {
+	DisplayName *string
+	Bio         *string
+	AvatarURL   *string
+}
+
+type UserService interface {
+	GetProfile(userID uuid.UUID) (*models.User, error)
+	UpdateProfile(userID uuid.UUID, update ProfileUpdate) (*models.User, error)
+}
+
+type userService struct {
+	userRepo repository.UserRepository
+}
+
+func NewUserService(userRepo repository.UserRepository) UserService {
+	return &userService{userRepo}
+}
+
+func (s *userService) GetProfile(userID uuid.UUID) (*models.User, error) {
+	return s.userRepo.FindByID(userID)
+}
+
+func (s *userService) UpdateProfile(userID uuid.UUID, update ProfileUpdate) (*models.User, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if update.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*update.DisplayName)
+	}
+	if update.Bio != nil {
+		user.Bio = strings.TrimSpace(*update.Bio)
+	}
+	if update.AvatarURL != nil {
+		user.AvatarURL = strings.TrimSpace(*update.AvatarURL)
+	}
+	if err := s.userRepo.UpdateProfile(user.ID, user.DisplayName, user.Bio, user.AvatarURL); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,75 @@
+package tokens
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Token uses. Every token the key ring signs names its use so one kind can
+// never be accepted in place of another.
+const (
+	UseAccess            = "access"
+	UseEmailVerification = "email_verification"
+	UsePasswordReset     = "password_reset"
+	UseMFAChallenge      = "mfa_challenge"
+	UseEmailChange       = "email_change"
+	UseMagicLink         = "magic_link"
+)
+
+// AccessClaims are the claims carried by access tokens.
+type AccessClaims struct {
+	Use         string   `json:"token_use"`
+	UserID      string   `json:"user_id"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	Scopes      []string `json:"scopes,omitempty"`
+	// SessionID is the refresh token family the access token was issued for.
+	SessionID string `json:"sid,omitempty"`
+	// EmailVerified is false until the user confirms their address.
+	EmailVerified bool `json:"email_verified"`
+	jwt.RegisteredClaims
+}
+
+// ActionClaims are carried by single-use tokens delivered out of band, e.g.
+// in an email. The jti identifies the database row that records whether the
+// token has been used; Email binds the token to the address it was sent to.
+type ActionClaims struct {
+	Use   string `json:"token_use"`
+	Email string `json:"email,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// NewAccessClaims returns claims for an access token for userID valid for
+// ttl. Each token gets a unique jti so it can be revoked before it expires.
+func (k *KeyRing) NewAccessClaims(userID uuid.UUID, ttl time.Duration) *AccessClaims {
+	now := time.Now()
+	return &AccessClaims{
+		Use:    UseAccess,
+		UserID: userID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    k.issuer,
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
+
+// NewActionClaims returns claims for a single-use token with the given use.
+func (k *KeyRing) NewActionClaims(use string, tokenID, userID uuid.UUID, email string, ttl time.Duration) *ActionClaims {
+	now := time.Now()
+	return &ActionClaims{
+		Use:   use,
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID.String(),
+			Issuer:    k.issuer,
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
@@ -0,0 +1,234 @@
+// Package tokens signs and verifies the API's JWTs with asymmetric keys.
+package tokens
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// Supported signing algorithms. The algorithm is implied by the key type.
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnknownKey   = errors.New("token signed with an unknown key")
+	ErrNoSigningKey = errors.New("JWT_SIGNING_KEY_FILE is not set; set JWT_ALLOW_EPHEMERAL_KEY=true to use a temporary key in development")
+)
+
+// verificationKey is a public key tokens may be verified with.
+type verificationKey struct {
+	kid    string
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// KeyRing holds the key new tokens are signed with and every public key
+// tokens are still accepted from. During rotation the previous key stays in
+// the ring for verification until all tokens it signed have expired.
+type KeyRing struct {
+	issuer  string
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	verify  map[string]verificationKey
+	order   []string
+}
+
+// NewKeyRing creates a key ring signing with private under kid. The matching
+// public key is always accepted for verification. An empty kid is replaced by
+// the key's RFC 7638 thumbprint.
+func NewKeyRing(issuer, kid string, private crypto.Signer) (*KeyRing, error) {
+	method, err := methodFor(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	if kid == "" {
+		if kid, err = Thumbprint(private.Public()); err != nil {
+			return nil, err
+		}
+	}
+	k := &KeyRing{
+		issuer:  issuer,
+		kid:     kid,
+		method:  method,
+		private: private,
+		verify:  map[string]verificationKey{},
+	}
+	if err := k.AddVerificationKey(kid, private.Public()); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// AddVerificationKey accepts tokens signed by the private half of public
+// under kid, e.g. the key that was active before a rotation.
+func (k *KeyRing) AddVerificationKey(kid string, public crypto.PublicKey) error {
+	method, err := methodFor(public)
+	if err != nil {
+		return err
+	}
+	if kid == "" {
+		if kid, err = Thumbprint(public); err != nil {
+			return err
+		}
+	}
+	if _, exists := k.verify[kid]; exists {
+		return nil
+	}
+	k.verify[kid] = verificationKey{kid: kid, method: method, public: public}
+	k.order = append(k.order, kid)
+	return nil
+}
+
+// Issuer is the "iss" claim placed in and required of every token.
+func (k *KeyRing) Issuer() string {
+	return k.issuer
+}
+
+// Sign signs claims with the active key and sets the kid header.
+func (k *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.kid
+	return token.SignedString(k.private)
+}
+
+// Parse verifies tokenStr into claims. The key is selected by the kid header
+// and the token's alg must be the one that key is pinned to; HMAC and "none"
+// tokens are always rejected.
+func (k *KeyRing) Parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := k.verify[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %q for key %q", token.Method.Alg(), kid)
+		}
+		return key.public, nil
+	},
+		jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}),
+		jwt.WithIssuer(k.issuer),
+		jwt.WithExpirationRequired(),
+	)
+}
+
+func methodFor(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("RSA signing keys must be at least 2048 bits")
+		}
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+}
+
+// LoadKeyRing builds the key ring from configuration. signingKeyFile is a PEM
+// private key (PKCS#8, or PKCS#1 for RSA); verificationKeys is a comma
+// separated list of "kid=path" (or bare paths) to PEM public keys that are
+// still accepted. Without a signing key an ephemeral Ed25519 key is generated
+// if allowEphemeral is set; it is only suitable for local development, as
+// other instances reject the tokens it signs.
+func LoadKeyRing(issuer, signingKeyFile, signingKeyID, verificationKeys string, allowEphemeral bool) (*KeyRing, error) {
+	var private crypto.Signer
+	if signingKeyFile == "" {
+		if !allowEphemeral {
+			return nil, ErrNoSigningKey
+		}
+		logrus.Warn("JWT_SIGNING_KEY_FILE is not set; using an ephemeral Ed25519 key, tokens will not survive a restart")
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	} else {
+		key, err := readPrivateKey(signingKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("signing key: %w", err)
+		}
+		private = key
+	}
+
+	ring, err := NewKeyRing(issuer, signingKeyID, private)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range strings.Split(verificationKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, path, ok := strings.Cut(entry, "=")
+		if !ok {
+			kid, path = "", entry
+		}
+		public, err := readPublicKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("verification key %s: %w", path, err)
+		}
+		if err := ring.AddVerificationKey(kid, public); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	return block, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
@@ -0,0 +1,19 @@
+package tokens
+
+import "github.com/google/uuid"
+
+// PersonalAccessTokenScheme starts every personal access token, so they can
+// be told apart from JWT access tokens in the Authorization header.
+const PersonalAccessTokenScheme = "pat_"
+
+// Identity is the caller a personal access token authenticates: the token's
+// owner with their current role and permissions, limited to the token's
+// scopes.
+type Identity struct {
+	TokenID       uuid.UUID
+	UserID        uuid.UUID
+	Roles         []string
+	Permissions   []string
+	Scopes        []string
+	EmailVerified bool
+}
@@ -0,0 +1,138 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var errInvalidCBOR = errors.New("webauthn: invalid CBOR")
+
+// maxCBORDepth bounds nesting so hostile input cannot exhaust the stack.
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the first CBOR item of data and returns it with the
+// bytes that follow it. It supports the subset authenticators produce:
+// definite-length integers, byte and text strings, arrays, maps, tags and
+// simple values. Integers decode to int64, maps to map[interface{}]interface{}.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeItem(data, 0)
+}
+
+func decodeItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth || len(data) == 0 {
+		return nil, nil, errInvalidCBOR
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	data = data[1:]
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		case 25, 26, 27:
+			size := 1 << (info - 24)
+			if len(data) < size {
+				return nil, nil, errInvalidCBOR
+			}
+			var f float64
+			switch size {
+			case 4:
+				f = float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+			case 8:
+				f = math.Float64frombits(binary.BigEndian.Uint64(data))
+			}
+			// Half-precision floats never appear in WebAuthn data; they decode as 0
+			return f, data[size:], nil
+		}
+		return nil, nil, errInvalidCBOR
+	}
+
+	n, data, err := decodeLength(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch major {
+	case 0:
+		if n > math.MaxInt64 {
+			return nil, nil, errInvalidCBOR
+		}
+		return int64(n), data, nil
+	case 1:
+		if n > math.MaxInt64 {
+			return nil, nil, errInvalidCBOR
+		}
+		return -1 - int64(n), data, nil
+	case 2, 3:
+		if uint64(len(data)) < n {
+			return nil, nil, errInvalidCBOR
+		}
+		b := append([]byte(nil), data[:n]...)
+		if major == 3 {
+			return string(b), data[n:], nil
+		}
+		return b, data[n:], nil
+	case 4:
+		if n > uint64(len(data)) {
+			return nil, nil, errInvalidCBOR
+		}
+		items := make([]interface{}, 0, n)
+		for i := uint64(0); i < n; i++ {
+			var item interface{}
+			if item, data, err = decodeItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case 5:
+		if n > uint64(len(data)) {
+			return nil, nil, errInvalidCBOR
+		}
+		m := make(map[interface{}]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			var key, value interface{}
+			if key, data, err = decodeItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errInvalidCBOR
+			}
+			if value, data, err = decodeItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			m[key] = value
+		}
+		return m, data, nil
+	case 6:
+		// Tags add no meaning WebAuthn relies on: decode the tagged item
+		return decodeItem(data, depth+1)
+	}
+	return nil, nil, errInvalidCBOR
+}
+
+// decodeLength reads the argument of an item header. Indefinite lengths are
+// not supported.
+func decodeLength(info byte, data []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(data) < size {
+			return 0, nil, errInvalidCBOR
+		}
+		var n uint64
+		for _, b := range data[:size] {
+			n = n<<8 | uint64(b)
+		}
+		return n, data[size:], nil
+	}
+	return 0, nil, errInvalidCBOR
+}
@@ -0,0 +1,374 @@
+// Package webauthn implements the relying party side of the WebAuthn
+// registration and authentication ceremonies used for passkeys.
+//
+// Attestation statements are not verified: registration asks for no
+// attestation, so any authenticator the user's browser trusts is accepted.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagAttestedData   = 0x40
+	flagExtensions     = 0x80
+)
+
+// Client data types.
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+)
+
+// User verification requirements.
+const (
+	VerificationRequired    = "required"
+	VerificationPreferred   = "preferred"
+	VerificationDiscouraged = "discouraged"
+)
+
+var (
+	ErrInvalidResponse     = errors.New("webauthn: malformed authenticator response")
+	ErrChallengeMismatch   = errors.New("webauthn: challenge does not match")
+	ErrOriginMismatch      = errors.New("webauthn: origin is not allowed")
+	ErrRPIDMismatch        = errors.New("webauthn: relying party ID does not match")
+	ErrUserNotPresent      = errors.New("webauthn: user presence was not confirmed")
+	ErrUserNotVerified     = errors.New("webauthn: user verification is required")
+	ErrSignCountRegression = errors.New("webauthn: signature counter went backwards; the authenticator may have been cloned")
+	ErrUserHandleMismatch  = errors.New("webauthn: user handle does not match the credential owner")
+)
+
+// Bytes is binary data encoded as unpadded base64url in JSON, as WebAuthn
+// clients expect. Padded input is accepted too.
+type Bytes []byte
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// RelyingParty is this service as seen by authenticators: ID is the domain
+// credentials are scoped to and Origins the web origins allowed to use them.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          Bytes  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         Bytes    `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions are passed to navigator.credentials.create as publicKey.
+type CreationOptions struct {
+	Challenge              Bytes                  `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are passed to navigator.credentials.get as publicKey.
+type RequestOptions struct {
+	Challenge        Bytes                  `json:"challenge"`
+	Timeout          int                    `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// AttestationResponse is the PublicKeyCredential returned by
+// navigator.credentials.create, in its JSON form.
+type AttestationResponse struct {
+	ID       string `json:"id"`
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes    `json:"clientDataJSON"`
+		AttestationObject Bytes    `json:"attestationObject"`
+		Transports        []string `json:"transports,omitempty"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the PublicKeyCredential returned by
+// navigator.credentials.get, in its JSON form.
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes `json:"clientDataJSON"`
+		AuthenticatorData Bytes `json:"authenticatorData"`
+		Signature         Bytes `json:"signature"`
+		UserHandle        Bytes `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// Credential is a registered passkey.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte // COSE_Key
+	Algorithm int
+	SignCount uint32
+	// BackupEligible is set for passkeys synced between devices
+	BackupEligible bool
+	Transports     []string
+}
+
+// timeout is how long, in milliseconds, browsers should wait for the user.
+const timeout = 300000
+
+// NewChallenge returns a random challenge for a ceremony.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// CreationOptions builds the options to register a new passkey for user,
+// excluding credentials the user already has.
+func (rp *RelyingParty) CreationOptions(challenge []byte, user UserEntity, exclude []CredentialDescriptor) CreationOptions {
+	params := make([]CredentialParameter, len(SupportedAlgorithms))
+	for i, alg := range SupportedAlgorithms {
+		params[i] = CredentialParameter{Type: "public-key", Alg: alg}
+	}
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+	return CreationOptions{
+		Challenge:          challenge,
+		RP:                 RelyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User:               user,
+		PubKeyCredParams:   params,
+		Timeout:            timeout,
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: VerificationPreferred,
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions builds the options to sign in with a passkey. An empty
+// allow list lets the user pick any passkey for this relying party.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow []CredentialDescriptor, userVerification string) RequestOptions {
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          timeout,
+		RPID:             rp.ID,
+		AllowCredentials: allow,
+		UserVerification: userVerification,
+	}
+}
+
+// VerifyRegistration checks the response to CreationOptions issued with
+// challenge and returns the new credential.
+func (rp *RelyingParty) VerifyRegistration(resp *AttestationResponse, challenge []byte, requireUV bool) (*Credential, error) {
+	if resp.Type != "public-key" {
+		return nil, ErrInvalidResponse
+	}
+	if err := rp.verifyClientData(resp.Response.ClientDataJSON, typeCreate, challenge); err != nil {
+		return nil, err
+	}
+	item, _, err := decodeCBOR(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+	attestation, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+	authData, err := rp.parseAuthData(rawAuthData, requireUV)
+	if err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttestedData == 0 || authData.credentialID == nil {
+		return nil, ErrInvalidResponse
+	}
+	if len(resp.RawID) > 0 && !bytes.Equal(resp.RawID, authData.credentialID) {
+		return nil, ErrInvalidResponse
+	}
+	return &Credential{
+		ID:             authData.credentialID,
+		PublicKey:      authData.rawPublicKey,
+		Algorithm:      authData.publicKey.alg,
+		SignCount:      authData.signCount,
+		BackupEligible: authData.flags&flagBackupEligible != 0,
+		Transports:     resp.Response.Transports,
+	}, nil
+}
+
+// VerifyAssertion checks the response to RequestOptions issued with
+// challenge, signed with cred. userHandle, when set, is the credential
+// owner's user handle. It returns the authenticator's new signature counter,
+// to be stored in place of cred.SignCount.
+func (rp *RelyingParty) VerifyAssertion(resp *AssertionResponse, challenge []byte, cred *Credential, userHandle []byte, requireUV bool) (uint32, error) {
+	if resp.Type != "public-key" || !bytes.Equal(resp.RawID, cred.ID) {
+		return 0, ErrInvalidResponse
+	}
+	if len(resp.Response.UserHandle) > 0 && userHandle != nil && !bytes.Equal(resp.Response.UserHandle, userHandle) {
+		return 0, ErrUserHandleMismatch
+	}
+	if err := rp.verifyClientData(resp.Response.ClientDataJSON, typeGet, challenge); err != nil {
+		return 0, err
+	}
+	authData, err := rp.parseAuthData(resp.Response.AuthenticatorData, requireUV)
+	if err != nil {
+		return 0, err
+	}
+	key, _, err := parsePublicKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), resp.Response.AuthenticatorData...), clientDataHash[:]...)
+	if err := key.verify(signed, resp.Response.Signature); err != nil {
+		return 0, err
+	}
+	// Authenticators that do not count always report zero
+	if (authData.signCount != 0 || cred.SignCount != 0) && authData.signCount <= cred.SignCount {
+		return 0, ErrSignCountRegression
+	}
+	return authData.signCount, nil
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, wantType string, challenge []byte) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil || cd.Type != wantType {
+		return ErrInvalidResponse
+	}
+	got, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(cd.Challenge, "="))
+	if err != nil || len(challenge) == 0 || !bytes.Equal(got, challenge) {
+		return ErrChallengeMismatch
+	}
+	for _, origin := range rp.Origins {
+		if cd.Origin == origin {
+			return nil
+		}
+	}
+	return ErrOriginMismatch
+}
+
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	rawPublicKey []byte
+	publicKey    *publicKey
+}
+
+// parseAuthData decodes authenticator data and checks it was produced for
+// this relying party with the user present, and verified if requireUV.
+func (rp *RelyingParty) parseAuthData(data []byte, requireUV bool) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, ErrInvalidResponse
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data[:32], rpIDHash[:]) {
+		return nil, ErrRPIDMismatch
+	}
+	ad := &authenticatorData{flags: data[32], signCount: binary.BigEndian.Uint32(data[33:37])}
+	if ad.flags&flagUserPresent == 0 {
+		return nil, ErrUserNotPresent
+	}
+	if requireUV && ad.flags&flagUserVerified == 0 {
+		return nil, ErrUserNotVerified
+	}
+	rest := data[37:]
+	if ad.flags&flagAttestedData != 0 {
+		// AAGUID (16 bytes), credential ID length (2 bytes), credential ID, COSE key
+		if len(rest) < 18 {
+			return nil, ErrInvalidResponse
+		}
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+			return nil, ErrInvalidResponse
+		}
+		ad.credentialID = append([]byte(nil), rest[:idLen]...)
+		rest = rest[idLen:]
+		key, after, err := parsePublicKey(rest)
+		if err != nil {
+			return nil, err
+		}
+		ad.publicKey = key
+		ad.rawPublicKey = append([]byte(nil), rest[:len(rest)-len(after)]...)
+		rest = after
+	}
+	if ad.flags&flagExtensions != 0 {
+		if _, after, err := decodeCBOR(rest); err != nil {
+			return nil, err
+		} else {
+			rest = after
+		}
+	}
+	if len(rest) != 0 {
+		return nil, ErrInvalidResponse
+	}
+	return ad, nil
+}
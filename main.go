@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -13,7 +16,12 @@ import (
 	_ "eskalate-movie-api/docs"
 	"eskalate-movie-api/internal/config"
 	"eskalate-movie-api/internal/handlers"
+	"eskalate-movie-api/internal/mailer"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/revocation"
 	"eskalate-movie-api/internal/routes"
+	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/tokens"
 )
 
 // @title Eskalate Movie API
@@ -26,19 +34,43 @@ import (
 // @name Authorization
 // @description Enter the token with the `Bearer ` prefix, e.g. "Bearer abcde12345"
 func main() {
-	cfg := config.LoadConfig()
+	command := os.Args[1:]
+	if len(command) > 0 && !isCommand(command[0]) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logrus.Fatalf("invalid configuration: %v", err)
+	}
 
 	if cfg.Port == "" {
 		cfg.Port = ":8080"
 	}
 
-	logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
-
 	db, err := gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{})
 	if err != nil {
 		logrus.Fatalf("failed to connect to database: %v", err)
 	}
 
+	keyRing, err := tokens.LoadKeyRing(cfg.JWTIssuer, cfg.JWTSigningKeyFile, cfg.JWTSigningKeyID, cfg.JWTVerificationKeys, cfg.JWTAllowEphemeralKey)
+	if err != nil {
+		logrus.Fatalf("failed to load JWT keys: %v", err)
+	}
+
+	mail, err := mailer.New(cfg.MailDriver, cfg.MailFrom, cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailOutboxDir)
+	if err != nil {
+		logrus.Fatalf("failed to configure mailer: %v", err)
+	}
+
+	denylist, err := revocation.New(cfg.TokenRevocationStore, db)
+	if err != nil {
+		logrus.Fatalf("failed to configure token revocation: %v", err)
+	}
+
 	// Register custom validators globally for Gin
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		handlers.RegisterCustomValidators(v)
@@ -47,8 +79,38 @@ func main() {
 	// Enable uuid-ossp extension
 	db.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`)
 
+	// Accounts created before email verification existed are treated as verified
+	grandfatherVerified := db.Migrator().HasTable(&models.User{}) && !db.Migrator().HasColumn(&models.User{}, "email_verified")
+
 	// Auto-migrate models
-	db.AutoMigrate()
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		logrus.Fatalf("failed to migrate database: %v", err)
+	}
+	if grandfatherVerified {
+		db.Exec(`UPDATE users SET email_verified = true`)
+	}
+	// Refresh tokens issued before rotation existed each start their own family
+	db.Exec(`UPDATE refresh_tokens SET family_id = id WHERE family_id IS NULL`)
+	// Sessions started before their clients were tracked start at their token's creation
+	db.Exec(`UPDATE refresh_tokens SET started_at = created_at, last_used_at = created_at WHERE started_at IS NULL`)
+	// Users listed in ADMIN_EMAILS are promoted so a deployment always has an administrator
+	if len(cfg.AdminEmails) > 0 {
+		db.Model(&models.User{}).Where("email IN ?", cfg.AdminEmails).Update("role", models.RoleAdmin)
+	}
+	if err := services.EnforceAppendOnlyAudit(db); err != nil {
+		logrus.Fatalf("failed to protect the audit log: %v", err)
+	}
+	// Refresh tokens used to be stored as raw JWTs; keep only their hashes
+	if err := services.MigrateLegacyRefreshTokens(db, []byte(cfg.RefreshTokenKey)); err != nil {
+		logrus.Fatalf("failed to migrate refresh tokens: %v", err)
+	}
+
+	jobs := services.NewJobScheduler(db, cfg)
+	if len(command) > 0 {
+		os.Exit(runCommand(jobs, command))
+	}
+	// Each instance schedules the cleanup jobs; advisory locks keep them from running twice
+	jobs.Start(context.Background())
 
 	r := gin.Default()
 
@@ -60,7 +122,7 @@ func main() {
 		c.File("static/index.html")
 	})
 
-	routes.RegisterRoutes(r, db, cfg)
+	routes.RegisterRoutes(r, db, cfg, keyRing, mail, denylist, jobs)
 
 	log.Printf("Server running on %s", cfg.Port)
 	r.Run(cfg.Port)
@@ -0,0 +1,274 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/passwordpolicy"
+	"eskalate-movie-api/internal/repository"
+	"eskalate-movie-api/internal/services"
+
+	"gorm.io/gorm"
+)
+
+func TestUserJSONOmitsSecrets(t *testing.T) {
+	user := models.User{Email: "json@example.org", Password: "$argon2id$hash", TOTPSecret: "sealed-secret", TOTPLastStep: 42}
+	raw, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{user.Password, user.TOTPSecret, "password", "totp"} {
+		if strings.Contains(strings.ToLower(string(raw)), strings.ToLower(secret)) {
+			t.Errorf("user JSON contains %q: %s", secret, raw)
+		}
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "profile@example.org")
+	profiles := services.NewUserService(f.users)
+	str := func(s string) *string { return &s }
+
+	if _, err := profiles.UpdateProfile(user.ID, services.ProfileUpdate{
+		DisplayName: str("  Film Buff "),
+		Bio:         str("Mostly noir."),
+		AvatarURL:   str("https://cdn.example.org/avatar.png"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Fields left out keep their value
+	updated, err := profiles.UpdateProfile(user.ID, services.ProfileUpdate{Bio: str("")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.DisplayName != "Film Buff" || updated.Bio != "" || updated.AvatarURL != "https://cdn.example.org/avatar.png" {
+		t.Errorf("updated = %+v", updated)
+	}
+	stored, err := profiles.GetProfile(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.DisplayName != updated.DisplayName || stored.Bio != updated.Bio || stored.AvatarURL != updated.AvatarURL ||
+		stored.Email != user.Email || stored.Password != user.Password {
+		t.Errorf("stored = %+v", stored)
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "old@example.org")
+	taken := f.createUser(t, "taken@example.org")
+
+	cases := map[string]struct {
+		password, email string
+		want            error
+	}{
+		"wrong password": {"Wrong-Password-1", "new@example.org", services.ErrIncorrectPassword},
+		"same address":   {testPassword, " OLD@example.org ", services.ErrSameEmail},
+		"taken address":  {testPassword, taken.Email, services.ErrEmailTaken},
+	}
+	for name, c := range cases {
+		if err := f.auth.ChangeEmail(user.ID, c.password, c.email, testClient); !errors.Is(err, c.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, c.want)
+		}
+	}
+	if sent := f.mail.sentTo("new@example.org"); len(sent) != 0 {
+		t.Fatalf("refused changes sent %d messages", len(sent))
+	}
+
+	if err := f.auth.ChangeEmail(user.ID, testPassword, "first-try@example.org", testClient); err != nil {
+		t.Fatal(err)
+	}
+	replaced := f.lastTokenSentTo(t, "first-try@example.org")
+	if err := f.auth.ChangeEmail(user.ID, testPassword, "new@example.org", testClient); err != nil {
+		t.Fatal(err)
+	}
+	token := f.lastTokenSentTo(t, "new@example.org")
+	if notices := f.mail.sentTo(user.Email); len(notices) != 2 {
+		t.Errorf("%d notices sent to the old address, want 2", len(notices))
+	}
+
+	// Nothing changes until the new address is confirmed
+	f.login(t, user, testClient)
+	if err := f.auth.ConfirmEmailChange(replaced); !errors.Is(err, services.ErrInvalidActionToken) {
+		t.Errorf("replaced link: err = %v, want ErrInvalidActionToken", err)
+	}
+	if err := f.auth.ConfirmEmailChange(token); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := f.users.FindByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Email != "new@example.org" || stored.PendingEmail != "" || !stored.EmailVerified {
+		t.Errorf("after confirming: %+v", stored)
+	}
+	if _, err := f.auth.LoginWithRefresh("old@example.org", testPassword, testClient); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Errorf("login with the old address: err = %v, want ErrInvalidCredentials", err)
+	}
+	f.login(t, stored, testClient)
+	if err := f.auth.ConfirmEmailChange(token); !errors.Is(err, services.ErrInvalidActionToken) {
+		t.Errorf("link used twice: err = %v, want ErrInvalidActionToken", err)
+	}
+}
+
+func TestConfirmEmailChangeRejectsAddressTakenMeanwhile(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "slow@example.org")
+	if err := f.auth.ChangeEmail(user.ID, testPassword, "contested@example.org", testClient); err != nil {
+		t.Fatal(err)
+	}
+	token := f.lastTokenSentTo(t, "contested@example.org")
+	f.createUser(t, "contested@example.org")
+
+	if err := f.auth.ConfirmEmailChange(token); !errors.Is(err, services.ErrEmailTaken) {
+		t.Errorf("err = %v, want ErrEmailTaken", err)
+	}
+	if stored, err := f.users.FindByID(user.ID); err != nil || stored.Email != user.Email {
+		t.Errorf("email changed to %+v, %v", stored, err)
+	}
+}
+
+func TestChangePasswordRequiresCurrentPassword(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "careful@example.org")
+	current, _ := f.login(t, user, testClient)
+	session := f.sessionID(t, current)
+
+	if err := f.auth.ChangePassword(user.ID, "Wrong-Password-1", "Brand-New-Pass-9", session, testClient); !errors.Is(err, services.ErrIncorrectPassword) {
+		t.Errorf("wrong current password: err = %v, want ErrIncorrectPassword", err)
+	}
+	if err := f.auth.ChangePassword(user.ID, testPassword, "Short-1", session, testClient); !errors.Is(err, passwordpolicy.ErrTooShort) {
+		t.Errorf("short new password: err = %v, want ErrTooShort", err)
+	}
+	f.login(t, user, testClient)
+
+	if err := f.auth.ChangePassword(user.ID, testPassword, "Brand-New-Pass-9", session, testClient); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.auth.LoginWithRefresh(user.Email, testPassword, testClient); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Errorf("old password: err = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := f.auth.LoginWithRefresh(user.Email, "Brand-New-Pass-9", testClient); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+
+	var failures int64
+	if err := f.db.Model(&models.AuditEvent{}).Where("action = ? AND outcome = ? AND target_id = ?",
+		models.AuditPasswordChange, models.AuditFailure, user.ID).Count(&failures).Error; err != nil {
+		t.Fatal(err)
+	}
+	if failures != 1 {
+		t.Errorf("%d failed changes audited, want 1", failures)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "leaving@example.org")
+	stays := f.createUser(t, "staying@example.org")
+	access, refresh := f.login(t, user, testClient)
+	movies := services.NewMovieService(repository.NewMovieRepository(f.db))
+	addMovie := func(owner *models.User) *models.Movie {
+		movie := &models.Movie{
+			Title:       "Stalker",
+			Description: "A guide leads two men through the Zone.",
+			Trailer:     "https://www.youtube.com/watch?v=AAAAAAAAAAA",
+			Actors:      []string{"Alexander Kaidanovsky"},
+			Genres:      []string{"Science fiction"},
+			UserID:      owner.ID,
+		}
+		if err := movies.Create(movie); err != nil {
+			t.Fatal(err)
+		}
+		return movie
+	}
+	addMovie(user)
+	kept := addMovie(stays)
+	if _, _, err := services.NewPersonalAccessTokenService(f.users, f.db, f.cfg).Create(user.ID, "sync", nil, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.auth.DeleteAccount(user.ID, "Wrong-Password-1", testClient); !errors.Is(err, services.ErrIncorrectPassword) {
+		t.Fatalf("wrong password: err = %v, want ErrIncorrectPassword", err)
+	}
+	if _, err := f.users.FindByID(user.ID); err != nil {
+		t.Fatalf("account deleted despite the wrong password: %v", err)
+	}
+
+	if err := f.auth.DeleteAccount(user.ID, testPassword, testClient); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.users.FindByID(user.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("account still there: %v", err)
+	}
+	for name, model := range map[string]interface{}{
+		"movies":                &models.Movie{},
+		"refresh tokens":        &models.RefreshToken{},
+		"personal access token": &models.PersonalAccessToken{},
+	} {
+		if f.exists(t, model, "user_id = ?", user.ID) {
+			t.Errorf("%s of the deleted account kept", name)
+		}
+	}
+	if _, err := movies.GetByID(kept.ID); err != nil {
+		t.Errorf("another user's movie: %v", err)
+	}
+	if f.authorized(t, access) {
+		t.Error("access token accepted after the account was deleted")
+	}
+	if _, _, err := f.auth.RefreshAccessToken(refresh, testClient); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("refresh token after deletion: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := f.auth.LoginWithRefresh(user.Email, testPassword, testClient); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Errorf("login after deletion: err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestCurrentPasswordChecksAreThrottled(t *testing.T) {
+	f := newAuthFixture(t, throttleLimits(4, 100))
+	user := f.createUser(t, "guessed@example.org")
+	current, _ := f.login(t, user, testClient)
+	session := f.sessionID(t, current)
+
+	// A right password clears earlier failures, as a login does
+	if err := f.auth.DeleteAccount(user.ID, "Wrong-Password-1", testClient); !errors.Is(err, services.ErrIncorrectPassword) {
+		t.Fatalf("wrong password: err = %v", err)
+	}
+	if err := f.auth.ChangeEmail(user.ID, testPassword, "guessed-new@example.org", testClient); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.accountFailures(t, user.Email); got != 0 {
+		t.Errorf("failures after the right password = %d, want 0", got)
+	}
+
+	wrong := []func() error{
+		func() error { return f.auth.ChangeEmail(user.ID, "Wrong-Password-1", "other@example.org", testClient) },
+		func() error {
+			return f.auth.ChangePassword(user.ID, "Wrong-Password-2", "Brand-New-Pass-9", session, testClient)
+		},
+		func() error { return f.auth.DeleteAccount(user.ID, "Wrong-Password-3", testClient) },
+		func() error { return f.auth.ChangeEmail(user.ID, "Wrong-Password-4", "other@example.org", testClient) },
+	}
+	for i, attempt := range wrong {
+		if err := attempt(); !errors.Is(err, services.ErrIncorrectPassword) {
+			t.Fatalf("attempt %d: err = %v, want ErrIncorrectPassword", i+1, err)
+		}
+	}
+
+	var throttled *services.ThrottledError
+	if err := f.auth.DeleteAccount(user.ID, testPassword, testClient); !errors.As(err, &throttled) {
+		t.Fatalf("right password while locked out: err = %v, want ThrottledError", err)
+	}
+	if _, err := f.users.FindByID(user.ID); err != nil {
+		t.Errorf("account deleted while locked out: %v", err)
+	}
+	if _, err := f.auth.LoginWithRefresh(user.Email, testPassword, testClient); !errors.As(err, &throttled) {
+		t.Errorf("login while locked out: err = %v, want ThrottledError", err)
+	}
+}
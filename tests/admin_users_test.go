@@ -0,0 +1,325 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/repository"
+	"eskalate-movie-api/internal/revocation"
+	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/tokens"
+
+	"github.com/google/uuid"
+)
+
+var adminClient = services.ClientInfo{UserAgent: "admin console", IPAddress: "192.0.2.10"}
+
+func (f *authFixture) adminUsers() services.AdminUserService {
+	return services.NewAdminUserService(f.users, f.db, f.denylist)
+}
+
+// auditTrail returns the actions recorded on the user's account, oldest first.
+func (f *authFixture) auditTrail(t *testing.T, user *models.User) []models.AuditEvent {
+	t.Helper()
+	var events []models.AuditEvent
+	if err := f.db.Where("target_id = ?", user.ID).Order("created_at").Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestDebugEndpointRemoved(t *testing.T) {
+	r := newRouter(nil, loadConfig(t), newTestKeyRing(t), revocation.NewMemoryStore())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/auth/debug/someone@example.org", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /api/auth/debug/:email: status = %d, want 404", w.Code)
+	}
+}
+
+func TestAdminListUsers(t *testing.T) {
+	f := newAuthFixture(t)
+	for _, email := range []string{"ada@example.org", "grace@example.org", "alan@example.org"} {
+		f.createUser(t, email)
+	}
+	curator := f.createUser(t, "curator@example.org")
+	if err := f.db.Model(curator).Updates(map[string]interface{}{"role": models.RoleCurator, "display_name": "Ada's Curator"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	banned := f.createUser(t, "banned@example.org")
+	if err := f.db.Model(banned).Update("disabled", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	yes, no := true, false
+
+	cases := map[string]struct {
+		filter repository.UserFilter
+		want   []string
+	}{
+		"everyone":        {repository.UserFilter{}, []string{"ada", "alan", "banned", "curator", "grace"}},
+		"query":           {repository.UserFilter{Query: "ADA"}, []string{"ada", "curator"}},
+		"role":            {repository.UserFilter{Role: models.RoleCurator}, []string{"curator"}},
+		"disabled":        {repository.UserFilter{Disabled: &yes}, []string{"banned"}},
+		"enabled members": {repository.UserFilter{Role: models.RoleMember, Disabled: &no}, []string{"ada", "alan", "grace"}},
+		"no match":        {repository.UserFilter{Query: "nobody"}, nil},
+	}
+	for name, c := range cases {
+		users, total, err := f.adminUsers().List(c.filter, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, u := range users {
+			got = append(got, strings.TrimSuffix(u.Email, "@example.org"))
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(c.want, ",") || total != int64(len(c.want)) {
+			t.Errorf("%s: users = %v (total %d), want %v", name, got, total, c.want)
+		}
+	}
+
+	// Pages hold the newest users first
+	first, total, err := f.adminUsers().List(repository.UserFilter{}, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, _, err := f.adminUsers().List(repository.UserFilter{}, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 || len(first) != 2 || len(last) != 1 || first[0].ID != banned.ID || last[0].Email != "ada@example.org" {
+		t.Errorf("pages = %v / %v (total %d)", first, last, total)
+	}
+}
+
+func TestAdminGetUser(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "details@example.org")
+	f.login(t, user, testClient)
+	f.login(t, user, testClient)
+	if _, _, err := services.NewPersonalAccessTokenService(f.users, f.db, f.cfg).Create(user.ID, "sync", nil, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.db.Create(&models.UserPermission{UserID: user.ID, Permission: models.PermMoviesDeleteAny}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	details, err := f.adminUsers().Get(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Email != user.Email || details.ActiveSessions != 2 || details.PersonalAccessTokens != 1 ||
+		!contains(details.Permissions, models.PermMoviesDeleteAny) || !contains(details.Permissions, models.PermMoviesCreate) {
+		t.Errorf("details = %+v", details)
+	}
+	raw, err := json.Marshal(details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), user.Password) || strings.Contains(strings.ToLower(string(raw)), "password") {
+		t.Errorf("details expose the password: %s", raw)
+	}
+
+	if _, err := f.adminUsers().Get(uuid.New()); !errors.Is(err, services.ErrUserNotFound) {
+		t.Errorf("unknown user: err = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestAdminDisableUser(t *testing.T) {
+	f := newAuthFixture(t)
+	admin := f.createUser(t, "root@example.org")
+	user := f.createUser(t, "troublesome@example.org")
+	access, refresh := f.login(t, user, testClient)
+	pats := services.NewPersonalAccessTokenService(f.users, f.db, f.cfg)
+	_, pat, err := pats.Create(user.ID, "sync", nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.adminUsers().SetDisabled(adminActor(admin), adminClient, admin.ID, true); !errors.Is(err, services.ErrCannotModifySelf) {
+		t.Errorf("disabling oneself: err = %v, want ErrCannotModifySelf", err)
+	}
+	if _, err := f.adminUsers().SetDisabled(adminActor(admin), adminClient, uuid.New(), true); !errors.Is(err, services.ErrUserNotFound) {
+		t.Errorf("unknown user: err = %v, want ErrUserNotFound", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		disabled, err := f.adminUsers().SetDisabled(adminActor(admin), adminClient, user.ID, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !disabled.Disabled || disabled.DisabledAt == nil {
+			t.Errorf("returned user = %+v", disabled)
+		}
+	}
+	if _, err := f.auth.LoginWithRefresh(user.Email, testPassword, testClient); !errors.Is(err, services.ErrAccountDisabled) {
+		t.Errorf("login while disabled: err = %v, want ErrAccountDisabled", err)
+	}
+	if f.authorized(t, access) {
+		t.Error("access token accepted after disabling")
+	}
+	if _, _, err := f.auth.RefreshAccessToken(refresh, testClient); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("refresh while disabled: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := pats.Authenticate(pat); !errors.Is(err, services.ErrInvalidPersonalAccessToken) {
+		t.Errorf("personal access token while disabled: err = %v, want ErrInvalidPersonalAccessToken", err)
+	}
+
+	if _, err := f.adminUsers().SetDisabled(adminActor(admin), adminClient, user.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	f.login(t, user, testClient)
+
+	// Disabling twice is recorded once
+	var actions []string
+	for _, event := range f.auditTrail(t, user) {
+		if event.ActorID != nil && *event.ActorID == admin.ID {
+			actions = append(actions, event.Action)
+			if event.IPAddress != adminClient.IPAddress || event.UserAgent != adminClient.UserAgent {
+				t.Errorf("event %s client = %s %q", event.Action, event.IPAddress, event.UserAgent)
+			}
+		}
+	}
+	if strings.Join(actions, ",") != models.AuditUserDisable+","+models.AuditUserEnable {
+		t.Errorf("administrator actions = %v", actions)
+	}
+}
+
+func TestAdminSetRole(t *testing.T) {
+	f := newAuthFixture(t)
+	admin := f.createUser(t, "root@example.org")
+	user := f.createUser(t, "promoted@example.org")
+
+	cases := map[string]struct {
+		target uuid.UUID
+		role   string
+		want   error
+	}{
+		"unknown role": {user.ID, "owner", services.ErrInvalidRole},
+		"own role":     {admin.ID, models.RoleMember, services.ErrCannotModifySelf},
+		"unknown user": {uuid.New(), models.RoleCurator, services.ErrUserNotFound},
+	}
+	for name, c := range cases {
+		if _, err := f.adminUsers().SetRole(adminActor(admin), adminClient, c.target, c.role); !errors.Is(err, c.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, c.want)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := f.adminUsers().SetRole(adminActor(admin), adminClient, user.ID, models.RoleCurator); err != nil {
+			t.Fatal(err)
+		}
+	}
+	access, _ := f.login(t, user, testClient)
+	var claims tokens.AccessClaims
+	if _, err := f.keys.Parse(access, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(claims.Roles, ",") != models.RoleCurator {
+		t.Errorf("roles after promotion = %v", claims.Roles)
+	}
+	// Setting the same role again changes nothing and is not recorded
+	changes := f.auditEvents(t, models.AuditUserRoleChange, user)
+	if len(changes) != 1 || changes[0].Details != "member -> curator" || *changes[0].ActorID != admin.ID {
+		t.Errorf("role changes audited = %+v", changes)
+	}
+}
+
+func TestAdminDemotionEndsSessions(t *testing.T) {
+	f := newAuthFixture(t)
+	admin := f.createUser(t, "root@example.org")
+	user := f.createUser(t, "demoted@example.org")
+	access, refresh := f.login(t, user, testClient)
+
+	// A promotion leaves the sessions alone
+	if _, err := f.adminUsers().SetRole(adminActor(admin), adminClient, user.ID, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if !f.authorized(t, access) {
+		t.Fatal("access token refused after a promotion")
+	}
+	access, refresh, err := f.auth.RefreshAccessToken(refresh, testClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.adminUsers().SetRole(adminActor(admin), adminClient, user.ID, models.RoleCurator); err != nil {
+		t.Fatal(err)
+	}
+	if f.authorized(t, access) {
+		t.Error("access token of the admin role accepted after the demotion")
+	}
+	if _, _, err := f.auth.RefreshAccessToken(refresh, testClient); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("refresh token after the demotion: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	f.login(t, user, testClient)
+}
+
+func TestAdminResetMFA(t *testing.T) {
+	f := newAuthFixture(t)
+	admin := f.createUser(t, "root@example.org")
+	user := f.createUser(t, "lost-phone@example.org")
+
+	if err := f.adminUsers().ResetMFA(adminActor(admin), adminClient, user.ID); !errors.Is(err, services.ErrMFANotEnabled) {
+		t.Errorf("without MFA: err = %v, want ErrMFANotEnabled", err)
+	}
+	f.enableTOTP(t, user)
+	f.startMFALogin(t, user, testClient)
+
+	if err := f.adminUsers().ResetMFA(adminActor(admin), adminClient, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	f.login(t, user, testClient)
+	if n := len(f.auditEvents(t, models.AuditUserMFAReset, user)); n != 1 {
+		t.Errorf("%d MFA resets audited, want 1", n)
+	}
+}
+
+func TestAdminAuditTrail(t *testing.T) {
+	f := newAuthFixture(t)
+	admin := f.createUser(t, "root@example.org")
+	user := f.createUser(t, "watched@example.org")
+	bystander := f.createUser(t, "bystander@example.org")
+	f.login(t, user, testClient)
+	f.login(t, bystander, testClient)
+	if _, err := f.adminUsers().SetRole(adminActor(admin), adminClient, user.ID, models.RoleCurator); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.adminUsers().ForceLogout(adminActor(admin), adminClient, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	events, total, err := f.adminUsers().AuditTrail(user.ID, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(events) != 2 || events[0].Action != models.AuditUserLogout || events[1].Action != models.AuditUserRoleChange {
+		t.Errorf("first page = %+v (total %d)", events, total)
+	}
+	events, _, err = f.adminUsers().AuditTrail(user.ID, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Action != models.AuditLogin {
+		t.Errorf("second page = %+v", events)
+	}
+}
+
+// auditEvents returns the events of the action recorded on the user's account.
+func (f *authFixture) auditEvents(t *testing.T, action string, user *models.User) []models.AuditEvent {
+	t.Helper()
+	var events []models.AuditEvent
+	for _, event := range f.auditTrail(t, user) {
+		if event.Action == action {
+			events = append(events, event)
+		}
+	}
+	return events
+}
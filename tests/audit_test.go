@@ -0,0 +1,256 @@
+package tests
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+
+	"gorm.io/gorm"
+)
+
+func TestAuditRecordsAuthenticationEvents(t *testing.T) {
+	f := newAuthFixture(t)
+	user, err := f.signup("audited", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const password = "Newcomer-Pass-1"
+	if _, err := f.auth.LoginWithRefresh(user.Email, "Wrong-Password-1", testClient); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Fatal(err)
+	}
+	result, err := f.auth.LoginWithRefresh(user.Email, password, testClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, refresh, err := f.auth.RefreshAccessToken(result.RefreshToken, testClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.auth.ChangePassword(user.ID, password, "Brand-New-Pass-9", f.sessionID(t, result.AccessToken), testClient); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.auth.RevokeRefreshToken(refresh, testClient); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, event := range f.auditTrail(t, user) {
+		got = append(got, event.Action+":"+event.Outcome)
+		if event.ActorID == nil || *event.ActorID != user.ID {
+			t.Errorf("%s actor = %v, want the user", event.Action, event.ActorID)
+		}
+		if event.IPAddress != testClient.IPAddress || event.UserAgent != testClient.UserAgent {
+			t.Errorf("%s client = %s %q", event.Action, event.IPAddress, event.UserAgent)
+		}
+		for _, secret := range []string{password, "Wrong-Password-1", "Brand-New-Pass-9", user.Email} {
+			if strings.Contains(event.Details, secret) {
+				t.Errorf("%s details %q contain %q", event.Action, event.Details, secret)
+			}
+		}
+	}
+	want := []string{
+		models.AuditSignup + ":" + models.AuditSuccess,
+		models.AuditLogin + ":" + models.AuditFailure,
+		models.AuditLogin + ":" + models.AuditSuccess,
+		models.AuditRefresh + ":" + models.AuditSuccess,
+		models.AuditPasswordChange + ":" + models.AuditSuccess,
+		models.AuditLogout + ":" + models.AuditSuccess,
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestAuditRecordsFailedLoginForUnknownEmail(t *testing.T) {
+	f := newAuthFixture(t)
+	if _, err := f.auth.LoginWithRefresh("ghost@example.org", "Wrong-Password-1", testClient); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Fatal(err)
+	}
+	var events []models.AuditEvent
+	if err := f.db.Where("action = ?", models.AuditLogin).Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Outcome != models.AuditFailure || events[0].ActorID != nil || events[0].TargetID != nil ||
+		events[0].IPAddress != testClient.IPAddress || strings.Contains(events[0].Details, "ghost") {
+		t.Errorf("events = %+v, want one anonymous failure", events)
+	}
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "immutable@example.org")
+	f.login(t, user, testClient)
+	events := f.auditEvents(t, models.AuditLogin, user)
+	if len(events) != 1 {
+		t.Fatalf("%d logins audited, want 1", len(events))
+	}
+	event := events[0]
+
+	attempts := map[string]error{
+		"update":      f.db.Model(&models.AuditEvent{}).Where("id = ?", event.ID).Update("outcome", models.AuditFailure).Error,
+		"delete":      f.db.Delete(&models.AuditEvent{}, "id = ?", event.ID).Error,
+		"raw update":  f.db.Exec("UPDATE audit_events SET details = 'tampered'").Error,
+		"raw delete":  f.db.Exec("DELETE FROM audit_events").Error,
+		"transaction": f.db.Transaction(func(tx *gorm.DB) error { return tx.Exec("DELETE FROM audit_events WHERE id = ?", event.ID).Error }),
+	}
+	for name, err := range attempts {
+		if err == nil || !strings.Contains(err.Error(), "append-only") {
+			t.Errorf("%s: err = %v, want the append-only error", name, err)
+		}
+	}
+	stored := f.auditEvents(t, models.AuditLogin, user)
+	if len(stored) != 1 || stored[0].Outcome != event.Outcome || stored[0].Details != event.Details {
+		t.Errorf("stored events = %+v, want %+v", stored, event)
+	}
+
+	// Installing the trigger again, as every start does, is harmless
+	if err := services.EnforceAppendOnlyAudit(f.db); err != nil {
+		t.Fatal(err)
+	}
+	f.login(t, user, testClient)
+	if err := f.db.Exec("DELETE FROM audit_events").Error; err == nil {
+		t.Error("delete allowed after reinstalling the trigger")
+	}
+}
+
+func TestAuditLogQuery(t *testing.T) {
+	f := newAuthFixture(t)
+	admin := f.createUser(t, "root@example.org")
+	user := f.createUser(t, "queried@example.org")
+	start := time.Now()
+	f.login(t, user, testClient)
+	if _, err := f.auth.LoginWithRefresh(user.Email, "Wrong-Password-1", laptop); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Fatal(err)
+	}
+	if _, err := f.adminUsers().SetRole(adminActor(admin), adminClient, user.ID, models.RoleCurator); err != nil {
+		t.Fatal(err)
+	}
+	auditLog := services.NewAuditLogService(f.db)
+	later := time.Now().Add(time.Hour)
+
+	cases := map[string]struct {
+		filter services.AuditFilter
+		want   int64
+	}{
+		"everything":     {services.AuditFilter{}, 3},
+		"target":         {services.AuditFilter{TargetID: &user.ID}, 3},
+		"actor":          {services.AuditFilter{ActorID: &admin.ID}, 1},
+		"action":         {services.AuditFilter{Action: models.AuditLogin}, 2},
+		"failures":       {services.AuditFilter{Outcome: models.AuditFailure}, 1},
+		"address":        {services.AuditFilter{IPAddress: laptop.IPAddress}, 1},
+		"since":          {services.AuditFilter{Since: &start}, 3},
+		"until":          {services.AuditFilter{Until: &start}, 0},
+		"within a range": {services.AuditFilter{Since: &start, Until: &later}, 3},
+	}
+	for name, c := range cases {
+		events, total, err := auditLog.Query(c.filter, 1, 10)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if total != c.want || int64(len(events)) != c.want {
+			t.Errorf("%s: %d events (total %d), want %d", name, len(events), total, c.want)
+		}
+	}
+
+	events, total, err := auditLog.Query(services.AuditFilter{}, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(events) != 2 || events[0].Action != models.AuditUserRoleChange {
+		t.Errorf("first page = %+v (total %d), want the newest two", events, total)
+	}
+
+	for name, filter := range map[string]services.AuditFilter{
+		"unknown outcome":        {Outcome: "maybe"},
+		"range ending too early": {Since: &later, Until: &start},
+	} {
+		if _, _, err := auditLog.Query(filter, 1, 10); !errors.Is(err, services.ErrInvalidAuditFilter) {
+			t.Errorf("%s: err = %v, want ErrInvalidAuditFilter", name, err)
+		}
+	}
+}
+
+func TestAuditLogExport(t *testing.T) {
+	f := newAuthFixture(t)
+	admin := f.createUser(t, "root@example.org")
+	user := f.createUser(t, "exported@example.org")
+	f.login(t, user, testClient)
+	if _, err := f.adminUsers().SetRole(adminActor(admin), adminClient, user.ID, models.RoleCurator); err != nil {
+		t.Fatal(err)
+	}
+	auditLog := services.NewAuditLogService(f.db)
+
+	var exported []string
+	err := auditLog.Export(adminActor(admin), adminClient, services.AuditFilter{}, "ndjson", func(event *models.AuditEvent) error {
+		exported = append(exported, event.Action)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Oldest first, ending with the export itself
+	want := []string{models.AuditLogin, models.AuditUserRoleChange, models.AuditLogExport}
+	if strings.Join(exported, ",") != strings.Join(want, ",") {
+		t.Errorf("exported = %v, want %v", exported, want)
+	}
+	exports, _, err := auditLog.Query(services.AuditFilter{Action: models.AuditLogExport}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exports) != 1 || *exports[0].ActorID != admin.ID || exports[0].Details != "ndjson" {
+		t.Errorf("exports audited = %+v", exports)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = auditLog.Export(adminActor(admin), adminClient, services.AuditFilter{}, "csv", func(*models.AuditEvent) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("export stopped by its callback: err = %v after %d calls", err, calls)
+	}
+}
+
+func TestAuditLogExportEndpoint(t *testing.T) {
+	f := newAuthFixture(t)
+	admin := f.createUser(t, "root@example.org")
+	if err := f.db.Model(admin).Update("role", models.RoleAdmin).Error; err != nil {
+		t.Fatal(err)
+	}
+	access, _ := f.login(t, admin, testClient)
+	r := newRouter(f.db, f.cfg, f.keys, f.denylist)
+	export := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/audit/export"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+access)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := export("?format=csv&action=" + models.AuditLogin)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("csv export: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0][0] != "id" || records[1][2] != models.AuditLogin || records[1][3] != models.AuditSuccess {
+		t.Errorf("csv records = %v, want the header and the admin's login", records)
+	}
+
+	if w := export("?format=xml"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: status = %d, want 400", w.Code)
+	}
+	if w := export("?format=ndjson&outcome=maybe"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid filter: status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,349 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/tokens"
+
+	"github.com/google/uuid"
+)
+
+func TestCleanupConfig(t *testing.T) {
+	t.Setenv("CLEANUP_INTERVAL", "30m")
+	t.Setenv("CLEANUP_JOB_INTERVALS", "refresh_tokens=6h, action_tokens=0,login_throttles=soon,=1h")
+	cleanup := loadConfig(t).Cleanup
+	if !cleanup.Enabled || cleanup.Interval != 30*time.Minute {
+		t.Errorf("cleanup = %+v, want enabled every 30m", cleanup)
+	}
+	if cleanup.RefreshTokenRetention != 30*24*time.Hour {
+		t.Errorf("RefreshTokenRetention = %s, want 720h", cleanup.RefreshTokenRetention)
+	}
+	// An interval of 0 leaves a job to be run by hand; invalid entries are skipped
+	want := map[string]time.Duration{"refresh_tokens": 6 * time.Hour, "action_tokens": 0}
+	if len(cleanup.JobIntervals) != len(want) {
+		t.Fatalf("JobIntervals = %v, want %v", cleanup.JobIntervals, want)
+	}
+	for name, d := range want {
+		if got, ok := cleanup.JobIntervals[name]; !ok || got != d {
+			t.Errorf("JobIntervals[%q] = %s, want %s", name, got, d)
+		}
+	}
+}
+
+// newJobScheduler returns the scheduler on the fixture's database. Jobs run
+// only when triggered unless intervals names them.
+func (f *authFixture) newJobScheduler(intervals map[string]time.Duration) services.JobScheduler {
+	cfg := *f.cfg
+	cfg.Cleanup.Interval = 0
+	cfg.Cleanup.JobIntervals = intervals
+	return services.NewJobScheduler(f.db, &cfg)
+}
+
+// runJob runs the named job by hand and returns the rows it affected.
+func (f *authFixture) runJob(t *testing.T, scheduler services.JobScheduler, name string) int64 {
+	t.Helper()
+	run, err := scheduler.Run(name)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if run.Status != models.JobSucceeded {
+		t.Fatalf("%s: status = %s", name, run.Status)
+	}
+	return run.Affected
+}
+
+// exists reports whether a row of model matches the query.
+func (f *authFixture) exists(t *testing.T, model interface{}, query string, args ...interface{}) bool {
+	t.Helper()
+	var count int64
+	if err := f.db.Model(model).Where(query, args...).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func TestCleanupRefreshTokens(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "cleanup@example.org")
+	retention := f.cfg.Cleanup.RefreshTokenRetention
+	now := time.Now()
+	beyond := now.Add(-retention - time.Hour)
+	within := now.Add(-retention + time.Hour)
+
+	cases := map[string]struct {
+		expiresAt, lastUsedAt time.Time
+		revoked, kept         bool
+	}{
+		"expired beyond retention": {beyond, beyond, false, false},
+		"expired within retention": {within, within, false, true},
+		"revoked beyond retention": {now.Add(time.Hour), beyond, true, false},
+		"revoked within retention": {now.Add(time.Hour), within, true, true},
+		"active":                   {now.Add(time.Hour), now, false, true},
+	}
+	ids := map[string]uuid.UUID{}
+	for name, tok := range cases {
+		row := models.RefreshToken{
+			ID:          uuid.New(),
+			UserID:      user.ID,
+			TokenPrefix: uuid.NewString(),
+			FamilyID:    uuid.New(),
+			ExpiresAt:   tok.expiresAt,
+			Revoked:     tok.revoked,
+			StartedAt:   tok.lastUsedAt,
+			LastUsedAt:  tok.lastUsedAt,
+		}
+		if err := f.db.Create(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = row.ID
+	}
+
+	if affected := f.runJob(t, f.newJobScheduler(nil), services.JobRefreshTokens); affected != 2 {
+		t.Errorf("affected = %d, want 2", affected)
+	}
+	for name, tok := range cases {
+		if kept := f.exists(t, &models.RefreshToken{}, "id = ?", ids[name]); kept != tok.kept {
+			t.Errorf("%s: kept = %v, want %v", name, kept, tok.kept)
+		}
+	}
+}
+
+func TestCleanupActionTokensKeepsMagicLinkWindow(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "actions@example.org")
+	now := time.Now()
+
+	// Magic links sent within the last hour still count toward the limit
+	// on new ones, even once they have expired
+	cases := map[string]struct {
+		createdAt, expiresAt time.Time
+		kept                 bool
+	}{
+		"expired, sent long ago":   {now.Add(-2 * time.Hour), now.Add(-90 * time.Minute), false},
+		"expired, sent recently":   {now.Add(-30 * time.Minute), now.Add(-15 * time.Minute), true},
+		"unexpired, sent long ago": {now.Add(-2 * time.Hour), now.Add(time.Hour), true},
+		"unexpired, sent just now": {now, now.Add(15 * time.Minute), true},
+	}
+	ids := map[string]uuid.UUID{}
+	for name, tok := range cases {
+		row := models.ActionToken{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			Purpose:   tokens.UseMagicLink,
+			ExpiresAt: tok.expiresAt,
+			CreatedAt: tok.createdAt,
+		}
+		if err := f.db.Create(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = row.ID
+	}
+
+	if affected := f.runJob(t, f.newJobScheduler(nil), services.JobActionTokens); affected != 1 {
+		t.Errorf("affected = %d, want 1", affected)
+	}
+	for name, tok := range cases {
+		if kept := f.exists(t, &models.ActionToken{}, "id = ?", ids[name]); kept != tok.kept {
+			t.Errorf("%s: kept = %v, want %v", name, kept, tok.kept)
+		}
+	}
+}
+
+func TestCleanupLoginThrottlesKeepsLockouts(t *testing.T) {
+	f := newAuthFixture(t)
+	lockout := f.cfg.LoginLockoutDuration
+	now := time.Now()
+	old := now.Add(-lockout - time.Minute)
+	lockedUntil := now.Add(time.Minute)
+	unlockedAt := now.Add(-time.Minute)
+
+	throttles := map[string]struct {
+		lastFailureAt time.Time
+		lockedUntil   *time.Time
+		kept          bool
+	}{
+		"old failure":                  {old, nil, false},
+		"old failure, lockout over":    {old, &unlockedAt, false},
+		"old failure, still locked":    {old, &lockedUntil, true},
+		"recent failure":               {now.Add(-time.Minute), nil, true},
+		"recent failure, lockout over": {now.Add(-time.Minute), &unlockedAt, true},
+	}
+	for name, th := range throttles {
+		row := models.LoginThrottle{
+			Kind:          models.ThrottleAccount,
+			Subject:       name,
+			Failures:      1,
+			LastFailureAt: th.lastFailureAt,
+			LockedUntil:   th.lockedUntil,
+		}
+		if err := f.db.Create(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if affected := f.runJob(t, f.newJobScheduler(nil), services.JobLoginThrottles); affected != 2 {
+		t.Errorf("affected = %d, want 2", affected)
+	}
+	for name, th := range throttles {
+		if kept := f.exists(t, &models.LoginThrottle{}, "subject = ?", name); kept != th.kept {
+			t.Errorf("%s: kept = %v, want %v", name, kept, th.kept)
+		}
+	}
+}
+
+func TestCleanupRevokedAccessTokensAndCeremonies(t *testing.T) {
+	f := newAuthFixture(t)
+	now := time.Now()
+	rows := []interface{}{
+		&models.RevokedAccessToken{JTI: "expired", ExpiresAt: now.Add(-time.Minute)},
+		&models.RevokedAccessToken{JTI: "live", ExpiresAt: now.Add(time.Minute)},
+		&models.OIDCLoginState{State: "expired", Provider: "mock", Nonce: "n", CodeVerifier: "v", BindingHash: "b", ExpiresAt: now.Add(-time.Minute)},
+		&models.OIDCLoginState{State: "live", Provider: "mock", Nonce: "n", CodeVerifier: "v", BindingHash: "b", ExpiresAt: now.Add(time.Minute)},
+		&models.WebAuthnCeremony{ID: uuid.New(), Purpose: "login", Challenge: []byte("c"), ExpiresAt: now.Add(-time.Minute)},
+	}
+	for _, row := range rows {
+		if err := f.db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scheduler := f.newJobScheduler(nil)
+	if affected := f.runJob(t, scheduler, services.JobRevokedAccessTokens); affected != 1 {
+		t.Errorf("%s: affected = %d, want 1", services.JobRevokedAccessTokens, affected)
+	}
+	if !f.exists(t, &models.RevokedAccessToken{}, "jti = ?", "live") {
+		t.Error("unexpired denylist entry deleted")
+	}
+	if affected := f.runJob(t, scheduler, services.JobLoginCeremonies); affected != 2 {
+		t.Errorf("%s: affected = %d, want 2", services.JobLoginCeremonies, affected)
+	}
+	if !f.exists(t, &models.OIDCLoginState{}, "state = ?", "live") {
+		t.Error("unexpired login state deleted")
+	}
+}
+
+// jobLockKey is the advisory lock key the scheduler takes for a job.
+func jobLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("eskalate-movie-api/job/" + name))
+	return int64(h.Sum64())
+}
+
+func TestJobSkippedWhileRunningElsewhere(t *testing.T) {
+	f := newAuthFixture(t)
+	scheduler := f.newJobScheduler(nil)
+
+	// Another instance running the job holds its lock
+	other := f.db.Begin()
+	if err := other.Exec("SELECT pg_advisory_xact_lock(?)", jobLockKey(services.JobRefreshTokens)).Error; err != nil {
+		other.Rollback()
+		t.Fatal(err)
+	}
+	_, err := scheduler.Run(services.JobRefreshTokens)
+	other.Rollback()
+	if !errors.Is(err, services.ErrJobRunning) {
+		t.Fatalf("err = %v, want ErrJobRunning", err)
+	}
+	if f.exists(t, &models.JobRun{}, "name = ?", services.JobRefreshTokens) {
+		t.Error("skipped run was recorded")
+	}
+
+	// Other jobs have their own lock
+	f.runJob(t, scheduler, services.JobActionTokens)
+	// Once the lock is released the job runs
+	f.runJob(t, scheduler, services.JobRefreshTokens)
+}
+
+func TestScheduledJobSkippedWhenNotDue(t *testing.T) {
+	f := newAuthFixture(t)
+	now := time.Now()
+	// With an hourly interval a job is due once it last ran over 30m ago
+	lastRuns := map[string]time.Time{
+		services.JobRefreshTokens:  now.Add(-10 * time.Minute),
+		services.JobActionTokens:   now.Add(-40 * time.Minute),
+		services.JobLoginThrottles: now.Add(-2 * time.Hour),
+	}
+	intervals := map[string]time.Duration{}
+	for name, startedAt := range lastRuns {
+		intervals[name] = time.Hour
+		run := models.JobRun{Name: name, Status: models.JobSucceeded, Trigger: models.JobTriggerSchedule, Instance: "other", StartedAt: startedAt, FinishedAt: startedAt}
+		if err := f.db.Create(&run).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.newJobScheduler(intervals).Start(ctx)
+
+	lastRun := func(name string) models.JobRun {
+		var run models.JobRun
+		if err := f.db.First(&run, "name = ?", name).Error; err != nil {
+			t.Fatal(err)
+		}
+		return run
+	}
+	due := []string{services.JobActionTokens, services.JobLoginThrottles}
+	deadline := time.Now().Add(10 * time.Second)
+	for _, name := range due {
+		for lastRun(name).StartedAt.Before(now) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s was not run", name)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	// The jobs were started together; give the skipped one time to show
+	time.Sleep(500 * time.Millisecond)
+	if !lastRun(services.JobRefreshTokens).StartedAt.Before(now) {
+		t.Error("job ran again before it was due")
+	}
+
+	// Running by hand ignores the interval
+	if _, err := f.newJobScheduler(intervals).Run(services.JobRefreshTokens); err != nil {
+		t.Fatal(err)
+	}
+	if run := lastRun(services.JobRefreshTokens); run.Trigger != models.JobTriggerManual {
+		t.Errorf("manual run not recorded: %+v", run)
+	}
+}
+
+func TestFailedJobRunIsRecorded(t *testing.T) {
+	f := newAuthFixture(t)
+	scheduler := f.newJobScheduler(nil)
+	f.runJob(t, scheduler, services.JobLoginCeremonies)
+
+	expired := models.OIDCLoginState{State: "expired", Provider: "mock", Nonce: "n", CodeVerifier: "v", BindingHash: "b", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := f.db.Create(&expired).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Login states are deleted first; the ceremonies then fail
+	if err := f.db.Migrator().DropTable(&models.WebAuthnCeremony{}); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := scheduler.Run(services.JobLoginCeremonies)
+	if err == nil {
+		t.Fatal("job succeeded without its table")
+	}
+	if run == nil || run.Status != models.JobFailed || run.Error == "" || run.Affected != 0 {
+		t.Fatalf("run = %+v, want a failed run with its error", run)
+	}
+	var stored models.JobRun
+	if err := f.db.First(&stored, "name = ?", services.JobLoginCeremonies).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.JobFailed || stored.Error != run.Error || stored.Trigger != models.JobTriggerManual {
+		t.Errorf("stored run = %+v, want the failure to replace the earlier success", stored)
+	}
+	// The part of the job that succeeded was rolled back
+	if !f.exists(t, &models.OIDCLoginState{}, "state = ?", "expired") {
+		t.Error("login state deleted by a failed run")
+	}
+}
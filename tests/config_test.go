@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"eskalate-movie-api/internal/config"
+)
+
+// loadConfig loads the configuration with the required keys set.
+func loadConfig(t *testing.T) *config.Config {
+	t.Helper()
+	t.Setenv("REFRESH_TOKEN_HASH_KEY", strings.Repeat("r", 32))
+	t.Setenv("MFA_ENCRYPTION_KEY", strings.Repeat("m", 32))
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestConfigRequiresSecretKeys(t *testing.T) {
+	long, short := strings.Repeat("k", 32), strings.Repeat("k", 31)
+	cases := []struct{ refresh, mfa, missing string }{
+		{"", long, "REFRESH_TOKEN_HASH_KEY"},
+		{short, long, "REFRESH_TOKEN_HASH_KEY"},
+		{long, "", "MFA_ENCRYPTION_KEY"},
+		{long, short, "MFA_ENCRYPTION_KEY"},
+	}
+	for _, c := range cases {
+		t.Setenv("REFRESH_TOKEN_HASH_KEY", c.refresh)
+		t.Setenv("MFA_ENCRYPTION_KEY", c.mfa)
+		// JWT_SECRET is no longer a fallback for either key
+		t.Setenv("JWT_SECRET", long)
+		if _, err := config.LoadConfig(); err == nil || !strings.Contains(err.Error(), c.missing) {
+			t.Errorf("refresh=%d mfa=%d bytes: err = %v, want %s rejected", len(c.refresh), len(c.mfa), err, c.missing)
+		}
+	}
+
+	t.Setenv("REFRESH_TOKEN_HASH_KEY", long)
+	t.Setenv("MFA_ENCRYPTION_KEY", long)
+	if _, err := config.LoadConfig(); err != nil {
+		t.Fatalf("32 byte keys rejected: %v", err)
+	}
+}
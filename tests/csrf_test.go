@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"eskalate-movie-api/internal/middleware"
+	"eskalate-movie-api/internal/revocation"
+)
+
+// cookieRequest returns a request authenticated by the access token cookie,
+// with the CSRF cookie and header set unless empty.
+func cookieRequest(method, access, csrfCookie, csrfHeader string) *http.Request {
+	req := newRequest(method, "")
+	req.AddCookie(&http.Cookie{Name: middleware.AccessTokenCookie, Value: access})
+	if csrfCookie != "" {
+		req.AddCookie(&http.Cookie{Name: middleware.CSRFCookie, Value: csrfCookie})
+	}
+	if csrfHeader != "" {
+		req.Header.Set(middleware.CSRFHeader, csrfHeader)
+	}
+	return req
+}
+
+func TestCookieAuthRequiresCSRFTokenForUnsafeMethods(t *testing.T) {
+	ring := newTestKeyRing(t)
+	mw := newAuthMiddleware(ring, revocation.NewMemoryStore())
+	access, _ := signAccessToken(t, ring)
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		cases := map[string]struct {
+			cookie, header string
+			want           int
+		}{
+			"no token":        {"", "", http.StatusForbidden},
+			"cookie only":     {"csrf-123", "", http.StatusForbidden},
+			"header only":     {"", "csrf-123", http.StatusForbidden},
+			"mismatch":        {"csrf-123", "csrf-456", http.StatusForbidden},
+			"matching header": {"csrf-123", "csrf-123", http.StatusOK},
+		}
+		for name, c := range cases {
+			if w := serve(mw, cookieRequest(method, access, c.cookie, c.header)); w.Code != c.want {
+				t.Errorf("%s with %s: status = %d, want %d", method, name, w.Code, c.want)
+			}
+		}
+	}
+}
+
+func TestCookieAuthSafeMethodsNeedNoCSRFToken(t *testing.T) {
+	ring := newTestKeyRing(t)
+	mw := newAuthMiddleware(ring, revocation.NewMemoryStore())
+	access, _ := signAccessToken(t, ring)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions} {
+		if w := serve(mw, cookieRequest(method, access, "", "")); w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", method, w.Code)
+		}
+	}
+}
+
+func TestBearerAuthIsExemptFromCSRF(t *testing.T) {
+	ring := newTestKeyRing(t)
+	mw := newAuthMiddleware(ring, revocation.NewMemoryStore())
+	access, _ := signAccessToken(t, ring)
+
+	if w := serve(mw, newRequest(http.MethodPost, "Bearer "+access)); w.Code != http.StatusOK {
+		t.Errorf("POST with a bearer token: status = %d, want 200", w.Code)
+	}
+	// The header wins over a cookie the browser happens to send along
+	req := cookieRequest(http.MethodDelete, access, "csrf-123", "")
+	req.Header.Set("Authorization", "Bearer "+access)
+	if w := serve(mw, req); w.Code != http.StatusOK {
+		t.Errorf("DELETE with a bearer token and cookies: status = %d, want 200", w.Code)
+	}
+}
+
+func TestCookieAuthRejectsInvalidToken(t *testing.T) {
+	ring := newTestKeyRing(t)
+	mw := newAuthMiddleware(ring, revocation.NewMemoryStore())
+	if w := serve(mw, cookieRequest(http.MethodPost, "not-a-token", "csrf-123", "csrf-123")); w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/mailer"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/repository"
+	"eskalate-movie-api/internal/revocation"
+	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/tokens"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB returns a connection to the PostgreSQL database named by
+// TEST_DATABASE_URL, confined to a new schema that is migrated as at startup
+// and dropped when the test ends. Without TEST_DATABASE_URL the test is
+// skipped.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	quiet := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	admin, err := gorm.Open(postgres.Open(dsn), quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	for _, stmt := range []string{`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`, "CREATE SCHEMA " + schema} {
+		if err := admin.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Extensions stay in public, so it is searched after the test's schema
+	db, err := gorm.Open(postgres.Open(withSearchPath(dsn, schema+",public")), quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		t.Fatal(err)
+	}
+	if err := services.EnforceAppendOnlyAudit(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// withSearchPath sets the search_path connection parameter of a URL or
+// key=value connection string.
+func withSearchPath(dsn, path string) string {
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("search_path", path)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " search_path=" + path
+}
+
+// testMailer keeps the messages sent through it.
+type testMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *testMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// sentTo returns the messages sent to the address so far.
+func (m *testMailer) sentTo(to string) []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []mailer.Message
+	for _, msg := range m.messages {
+		if msg.To == to {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+const testPassword = "Correct-Horse-42"
+
+// testHasher hashes cheaply, so tests do not spend their time in Argon2id.
+func testHasher() services.PasswordHasher {
+	return services.NewArgon2idHasher(services.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1})
+}
+
+// authFixture is the auth service wired to a test database as in
+// routes.RegisterRoutes, with its dependencies at hand.
+type authFixture struct {
+	db       *gorm.DB
+	cfg      *config.Config
+	keys     *tokens.KeyRing
+	mail     *testMailer
+	throttle services.LoginThrottle
+	hasher   services.PasswordHasher
+	denylist revocation.Store
+	users    repository.UserRepository
+	auth     services.AuthService
+}
+
+// newAuthFixture builds the auth service on a fresh test database. configure
+// functions may change the configuration first.
+func newAuthFixture(t *testing.T, configure ...func(*config.Config)) *authFixture {
+	t.Helper()
+	db := testDB(t)
+	cfg := loadConfig(t)
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	f := &authFixture{
+		db:       db,
+		cfg:      cfg,
+		keys:     newTestKeyRing(t),
+		mail:     &testMailer{},
+		throttle: services.NewLoginThrottle(db, cfg),
+		hasher:   testHasher(),
+		denylist: revocation.NewPostgresStore(db),
+		users:    repository.NewUserRepository(db),
+	}
+	f.auth = services.NewAuthService(f.users, db, f.keys, f.mail, f.throttle, f.hasher, f.denylist, cfg)
+	return f
+}
+
+// createUser stores a verified member with testPassword.
+func (f *authFixture) createUser(t *testing.T, email string) *models.User {
+	t.Helper()
+	hash, err := f.hasher.Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{
+		Username:      strings.NewReplacer("@", "", ".", "").Replace(email),
+		Email:         email,
+		Password:      hash,
+		Role:          models.RoleMember,
+		EmailVerified: true,
+	}
+	if err := f.db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// login signs user in with testPassword and returns the token pair.
+func (f *authFixture) login(t *testing.T, user *models.User, client services.ClientInfo) (string, string) {
+	t.Helper()
+	result, err := f.auth.LoginWithRefresh(user.Email, testPassword, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MFARequired() {
+		t.Fatal("login asked for a second factor")
+	}
+	return result.AccessToken, result.RefreshToken
+}
@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/mailer"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/tokens"
+)
+
+var linkToken = regexp.MustCompile(`[?&]token=([^\s&]+)`)
+
+// linkedToken returns the token carried by the link in msg.
+func linkedToken(t *testing.T, msg mailer.Message) string {
+	t.Helper()
+	match := linkToken.FindStringSubmatch(msg.Body)
+	if match == nil {
+		t.Fatalf("no link with a token in %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// lastTokenSentTo returns the token linked from the latest message sent to
+// the address.
+func (f *authFixture) lastTokenSentTo(t *testing.T, to string) string {
+	t.Helper()
+	sent := f.mail.sentTo(to)
+	if len(sent) == 0 {
+		t.Fatalf("nothing sent to %s", to)
+	}
+	return linkedToken(t, sent[len(sent)-1])
+}
+
+func (f *authFixture) emailVerified(t *testing.T, user *models.User) bool {
+	t.Helper()
+	var stored models.User
+	if err := f.db.First(&stored, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return stored.EmailVerified
+}
+
+func TestOutboxMailerWritesMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	m, err := mailer.NewOutboxMailer(dir, "movies@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := mailer.Message{To: "reader@example.org", Subject: "Confirm your email address", Body: "line one\nline two\n"}
+	if err := m.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent := m.Sent(); len(sent) != 1 || sent[0] != msg {
+		t.Errorf("sent = %+v, want [%+v]", sent, msg)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("outbox files = %v, %v", files, err)
+	}
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"From: movies@example.org\r\n", "To: reader@example.org\r\n", "Subject: Confirm your email address\r\n", "\r\n\r\nline one\r\nline two\r\n"} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("message file lacks %q:\n%s", want, raw)
+		}
+	}
+}
+
+func TestMailerDriver(t *testing.T) {
+	for _, driver := range []string{"", "outbox"} {
+		if m, err := mailer.New(driver, "movies@example.org", "", "", "", "", ""); err != nil {
+			t.Error(err)
+		} else if _, ok := m.(*mailer.OutboxMailer); !ok {
+			t.Errorf("driver %q returned %T", driver, m)
+		}
+	}
+	if m, err := mailer.New("smtp", "movies@example.org", "localhost", "25", "", "", ""); err != nil {
+		t.Error(err)
+	} else if _, ok := m.(*mailer.SMTPMailer); !ok {
+		t.Errorf("smtp driver returned %T", m)
+	}
+	if _, err := mailer.New("carrier-pigeon", "", "", "", "", "", ""); err == nil {
+		t.Error("unknown driver accepted")
+	}
+}
+
+func TestSignupSendsVerificationEmail(t *testing.T) {
+	f := newAuthFixture(t)
+	user, err := f.signup("newcomer", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.emailVerified(t, user) {
+		t.Fatal("new account is verified before confirming its address")
+	}
+
+	token := f.lastTokenSentTo(t, user.Email)
+	if err := f.auth.VerifyEmail(token); err != nil {
+		t.Fatal(err)
+	}
+	if !f.emailVerified(t, user) {
+		t.Error("account not verified by the emailed token")
+	}
+	if err := f.auth.VerifyEmail(token); !errors.Is(err, services.ErrInvalidActionToken) {
+		t.Errorf("token used twice: err = %v, want ErrInvalidActionToken", err)
+	}
+}
+
+func TestVerifyEmailRejectsInvalidTokens(t *testing.T) {
+	f := newAuthFixture(t)
+	user, err := f.signup("pending", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := f.lastTokenSentTo(t, user.Email)
+	var row models.ActionToken
+	if err := f.db.First(&row, "user_id = ? AND purpose = ?", user.ID, tokens.UseEmailVerification).Error; err != nil {
+		t.Fatal(err)
+	}
+	sign := func(ring *tokens.KeyRing, use, email string) string {
+		signed, err := ring.Sign(ring.NewActionClaims(use, row.ID, user.ID, email, time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	for name, forged := range map[string]string{
+		"issued for another use": sign(f.keys, tokens.UsePasswordReset, user.Email),
+		"for another address":    sign(f.keys, tokens.UseEmailVerification, "someone@example.org"),
+		"signed by another key":  sign(newTestKeyRing(t), tokens.UseEmailVerification, user.Email),
+		"tampered":               token + "x",
+		"garbage":                "not-a-token",
+		"empty":                  "",
+	} {
+		if err := f.auth.VerifyEmail(forged); !errors.Is(err, services.ErrInvalidActionToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidActionToken", name, err)
+		}
+	}
+
+	if err := f.db.Model(&row).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := f.auth.VerifyEmail(token); !errors.Is(err, services.ErrInvalidActionToken) {
+		t.Errorf("expired: err = %v, want ErrInvalidActionToken", err)
+	}
+	if f.emailVerified(t, user) {
+		t.Error("account verified by an invalid token")
+	}
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	f := newAuthFixture(t)
+	user, err := f.signup("resender", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := f.lastTokenSentTo(t, user.Email)
+
+	if err := f.auth.ResendVerificationEmail(user.Email); err != nil {
+		t.Fatal(err)
+	}
+	if sent := f.mail.sentTo(user.Email); len(sent) != 2 {
+		t.Fatalf("%d messages sent, want 2", len(sent))
+	}
+	if err := f.auth.VerifyEmail(first); !errors.Is(err, services.ErrInvalidActionToken) {
+		t.Errorf("token replaced by a resend: err = %v, want ErrInvalidActionToken", err)
+	}
+	if err := f.auth.VerifyEmail(f.lastTokenSentTo(t, user.Email)); err != nil {
+		t.Errorf("resent token: %v", err)
+	}
+
+	// Verified and unknown addresses are answered alike, without mail
+	verified := f.createUser(t, "verified@example.org")
+	for _, email := range []string{user.Email, verified.Email, "nobody@example.org"} {
+		before := len(f.mail.sentTo(email))
+		if err := f.auth.ResendVerificationEmail(email); err != nil {
+			t.Errorf("%s: %v", email, err)
+		}
+		if len(f.mail.sentTo(email)) != before {
+			t.Errorf("verification email resent to %s", email)
+		}
+	}
+}
+
+func TestUnverifiedUserCannotCreateMovies(t *testing.T) {
+	f := newAuthFixture(t)
+	user, err := f.signup("unverified", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRouter(f.db, f.cfg, f.keys, f.denylist)
+	createMovie := func() int {
+		result, err := f.auth.LoginWithRefresh(user.Email, "Newcomer-Pass-1", testClient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, _ := call(t, r, http.MethodPost, "/api/movies/", result.AccessToken)
+		return status
+	}
+
+	if status := createMovie(); status != http.StatusForbidden {
+		t.Errorf("before verification: status = %d, want 403", status)
+	}
+	if err := f.auth.VerifyEmail(f.lastTokenSentTo(t, user.Email)); err != nil {
+		t.Fatal(err)
+	}
+	// The empty body is refused by the handler, past the middleware
+	if status := createMovie(); status != http.StatusBadRequest {
+		t.Errorf("after verification: status = %d, want 400", status)
+	}
+}
@@ -0,0 +1,254 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+)
+
+func TestInvitationActive(t *testing.T) {
+	now := time.Now()
+	revoked := now.Add(-time.Minute)
+	cases := map[string]struct {
+		invitation models.Invitation
+		want       bool
+	}{
+		"unused":  {models.Invitation{MaxUses: 2, Uses: 1, ExpiresAt: now.Add(time.Hour)}, true},
+		"used up": {models.Invitation{MaxUses: 2, Uses: 2, ExpiresAt: now.Add(time.Hour)}, false},
+		"expired": {models.Invitation{MaxUses: 2, ExpiresAt: now}, false},
+		"revoked": {models.Invitation{MaxUses: 2, ExpiresAt: now.Add(time.Hour), RevokedAt: &revoked}, false},
+		"no uses": {models.Invitation{MaxUses: 0, ExpiresAt: now.Add(time.Hour)}, false},
+	}
+	for name, c := range cases {
+		if got := c.invitation.Active(now); got != c.want {
+			t.Errorf("%s: Active = %v, want %v", name, got, c.want)
+		}
+	}
+}
+
+func TestRegistrationModeConfig(t *testing.T) {
+	cases := map[string]string{
+		"":            config.RegistrationOpen,
+		"Invite-Only": config.RegistrationInviteOnly,
+		"closed":      config.RegistrationClosed,
+		// A typo must not open registration to everyone
+		"invite_only": config.RegistrationClosed,
+	}
+	for value, want := range cases {
+		t.Setenv("REGISTRATION_MODE", value)
+		if got := loadConfig(t).RegistrationMode; got != want {
+			t.Errorf("REGISTRATION_MODE=%q: mode = %q, want %q", value, got, want)
+		}
+	}
+}
+
+// inviteOnly configures invite-only registration.
+func inviteOnly(cfg *config.Config) { cfg.RegistrationMode = config.RegistrationInviteOnly }
+
+func adminActor(user *models.User) services.Actor {
+	return services.Actor{UserID: user.ID, Permissions: models.RolePermissions[models.RoleAdmin]}
+}
+
+func memberActor(user *models.User) services.Actor {
+	return services.Actor{UserID: user.ID, Permissions: models.RolePermissions[models.RoleMember]}
+}
+
+// signup signs a new user up with the invitation code, which may be empty.
+func (f *authFixture) signup(name, code string) (*models.User, error) {
+	user := &models.User{Username: name, Email: name + "@example.org", Password: "Newcomer-Pass-1"}
+	return user, f.auth.Signup(user, code, testClient)
+}
+
+// invite creates an invitation as actor and returns its code.
+func (f *authFixture) invite(t *testing.T, actor services.Actor, role string, maxUses int) (*models.Invitation, string) {
+	t.Helper()
+	invitation, code, err := services.NewInvitationService(f.db, f.cfg).Create(actor, role, "", maxUses, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return invitation, code
+}
+
+func TestSignupRegistrationModes(t *testing.T) {
+	open := newAuthFixture(t)
+	if _, err := open.signup("openuser", ""); err != nil {
+		t.Errorf("open registration: %v", err)
+	}
+
+	invited := newAuthFixture(t, inviteOnly)
+	admin := invited.createUser(t, "inviter@example.org")
+	_, code := invited.invite(t, adminActor(admin), "", 5)
+	if _, err := invited.signup("nocode", ""); !errors.Is(err, services.ErrInvitationRequired) {
+		t.Errorf("invite-only without a code: err = %v, want ErrInvitationRequired", err)
+	}
+	for _, bad := range []string{"inv_unknown.code", "not-an-invitation", code + "x"} {
+		if _, err := invited.signup("badcode", bad); !errors.Is(err, services.ErrInvalidInvitation) {
+			t.Errorf("invite-only with code %q: err = %v, want ErrInvalidInvitation", bad, err)
+		}
+	}
+	if _, err := invited.signup("goodcode", code); err != nil {
+		t.Errorf("invite-only with a valid code: %v", err)
+	}
+
+	closed := newAuthFixture(t, func(cfg *config.Config) { cfg.RegistrationMode = config.RegistrationClosed })
+	admin = closed.createUser(t, "inviter@example.org")
+	_, code = closed.invite(t, adminActor(admin), "", 5)
+	if _, err := closed.signup("closeduser", code); !errors.Is(err, services.ErrRegistrationClosed) {
+		t.Errorf("closed registration with a valid code: err = %v, want ErrRegistrationClosed", err)
+	}
+	for name, f := range map[string]*authFixture{"invite-only": invited, "closed": closed} {
+		var count int64
+		if err := f.db.Model(&models.User{}).Where("username IN ?", []string{"nocode", "badcode", "closeduser"}).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("%s: %d refused signups created users", name, count)
+		}
+	}
+}
+
+func TestInvitationUseLimit(t *testing.T) {
+	f := newAuthFixture(t, inviteOnly)
+	admin := f.createUser(t, "inviter@example.org")
+	invitation, code := f.invite(t, adminActor(admin), "", 2)
+
+	for _, name := range []string{"first", "second"} {
+		if _, err := f.signup(name, code); err != nil {
+			t.Fatalf("%s signup: %v", name, err)
+		}
+	}
+	if _, err := f.signup("third", code); !errors.Is(err, services.ErrInvalidInvitation) {
+		t.Fatalf("signup past the limit: err = %v, want ErrInvalidInvitation", err)
+	}
+	var stored models.Invitation
+	if err := f.db.First(&stored, "id = ?", invitation.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Uses != 2 {
+		t.Errorf("uses = %d, want 2", stored.Uses)
+	}
+}
+
+func TestInvitationExpiry(t *testing.T) {
+	f := newAuthFixture(t, inviteOnly)
+	admin := f.createUser(t, "inviter@example.org")
+	invitation, code := f.invite(t, adminActor(admin), "", 5)
+	if err := f.db.Model(invitation).Update("expires_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.signup("late", code); !errors.Is(err, services.ErrInvalidInvitation) {
+		t.Fatalf("err = %v, want ErrInvalidInvitation", err)
+	}
+}
+
+func TestInvitationRevocation(t *testing.T) {
+	f := newAuthFixture(t, inviteOnly)
+	inviter := f.createUser(t, "inviter@example.org")
+	other := f.createUser(t, "other@example.org")
+	invitations := services.NewInvitationService(f.db, f.cfg)
+	invitation, code := f.invite(t, memberActor(inviter), "", 5)
+
+	if err := invitations.Revoke(memberActor(other), invitation.ID); !errors.Is(err, services.ErrInvitationNotFound) {
+		t.Fatalf("revoked by another member: err = %v, want ErrInvitationNotFound", err)
+	}
+	if err := invitations.Revoke(memberActor(inviter), invitation.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := invitations.Revoke(memberActor(inviter), invitation.ID); !errors.Is(err, services.ErrInvitationNotFound) {
+		t.Errorf("revoked twice: err = %v, want ErrInvitationNotFound", err)
+	}
+	if _, err := f.signup("revoked", code); !errors.Is(err, services.ErrInvalidInvitation) {
+		t.Fatalf("signup with a revoked code: err = %v, want ErrInvalidInvitation", err)
+	}
+
+	// Administrators can revoke anyone's invitation
+	invitation, _ = f.invite(t, memberActor(inviter), "", 5)
+	if err := invitations.Revoke(adminActor(other), invitation.ID); err != nil {
+		t.Errorf("revoked by an administrator: %v", err)
+	}
+}
+
+func TestInvitationFromDisabledInviter(t *testing.T) {
+	f := newAuthFixture(t, inviteOnly)
+	inviter := f.createUser(t, "inviter@example.org")
+	_, code := f.invite(t, memberActor(inviter), "", 5)
+	if err := f.db.Model(inviter).Update("disabled", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.signup("orphan", code); !errors.Is(err, services.ErrInvalidInvitation) {
+		t.Fatalf("err = %v, want ErrInvalidInvitation", err)
+	}
+}
+
+func TestInvitationAssignsRole(t *testing.T) {
+	f := newAuthFixture(t, inviteOnly)
+	admin := f.createUser(t, "inviter@example.org")
+	invitation, code := f.invite(t, adminActor(admin), models.RoleCurator, 1)
+
+	user, err := f.signup("curator", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored models.User
+	if err := f.db.First(&stored, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Role != models.RoleCurator {
+		t.Errorf("role = %q, want %q", stored.Role, models.RoleCurator)
+	}
+	if stored.InvitationID == nil || *stored.InvitationID != invitation.ID {
+		t.Errorf("InvitationID = %v, want %s", stored.InvitationID, invitation.ID)
+	}
+	if stored.EmailVerified {
+		t.Error("invited user's email counted as verified")
+	}
+}
+
+func TestInvitationCreateLimits(t *testing.T) {
+	f := newAuthFixture(t, inviteOnly, func(cfg *config.Config) { cfg.InvitationsPerUser = 2 })
+	member := f.createUser(t, "member@example.org")
+	admin := f.createUser(t, "admin@example.org")
+	invitations := services.NewInvitationService(f.db, f.cfg)
+
+	for _, role := range []string{models.RoleCurator, models.RoleAdmin} {
+		if _, _, err := invitations.Create(memberActor(member), role, "", 1, time.Hour); !errors.Is(err, services.ErrInvitationRole) {
+			t.Errorf("member inviting to %s: err = %v, want ErrInvitationRole", role, err)
+		}
+		if _, _, err := invitations.Create(adminActor(admin), role, "", 1, time.Hour); err != nil {
+			t.Errorf("administrator inviting to %s: %v", role, err)
+		}
+	}
+	if _, _, err := invitations.Create(memberActor(member), "owner", "", 1, time.Hour); !errors.Is(err, services.ErrInvalidRole) {
+		t.Errorf("unknown role: err = %v, want ErrInvalidRole", err)
+	}
+	for _, settings := range []struct {
+		uses int
+		ttl  time.Duration
+	}{{0, time.Hour}, {11, time.Hour}, {1, 0}, {1, 31 * 24 * time.Hour}} {
+		if _, _, err := invitations.Create(memberActor(member), "", "", settings.uses, settings.ttl); !errors.Is(err, services.ErrInvitationSettings) {
+			t.Errorf("member inviting %d uses for %s: err = %v, want ErrInvitationSettings", settings.uses, settings.ttl, err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := invitations.Create(memberActor(member), "", "", 1, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := invitations.Create(memberActor(member), "", "", 1, time.Hour); !errors.Is(err, services.ErrInvitationLimit) {
+		t.Errorf("past the limit: err = %v, want ErrInvitationLimit", err)
+	}
+
+	// Outside invite-only registration only administrators invite
+	f.cfg.RegistrationMode = config.RegistrationOpen
+	if _, _, err := invitations.Create(memberActor(member), "", "", 1, time.Hour); !errors.Is(err, services.ErrInvitationsDisabled) {
+		t.Errorf("member in open registration: err = %v, want ErrInvitationsDisabled", err)
+	}
+	if _, _, err := invitations.Create(adminActor(admin), "", "", 1, time.Hour); err != nil {
+		t.Errorf("administrator in open registration: %v", err)
+	}
+}
@@ -0,0 +1,382 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/middleware"
+	"eskalate-movie-api/internal/revocation"
+	"eskalate-movie-api/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testIssuer = "eskalate-movie-api"
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestKeyRing(t *testing.T) *tokens.KeyRing {
+	t.Helper()
+	ring, err := tokens.NewKeyRing(testIssuer, "", newEd25519Key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+// signAccessToken signs an access token for a new user with ring.
+func signAccessToken(t *testing.T, ring *tokens.KeyRing) (string, *tokens.AccessClaims) {
+	t.Helper()
+	claims := ring.NewAccessClaims(uuid.New(), time.Minute)
+	token, err := ring.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token, claims
+}
+
+func TestKeyRingSignAndParse(t *testing.T) {
+	key := newEd25519Key(t)
+	ring, err := tokens.NewKeyRing(testIssuer, "", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, claims := signAccessToken(t, ring)
+
+	var parsed tokens.AccessClaims
+	got, err := ring.Parse(token, &parsed)
+	if err != nil || !got.Valid {
+		t.Fatalf("Parse = %v", err)
+	}
+	// Without a configured kid the key's thumbprint is used
+	thumbprint, err := tokens.Thumbprint(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Header["kid"] != thumbprint || got.Method.Alg() != tokens.AlgEdDSA {
+		t.Errorf("header = %v, want kid %s and EdDSA", got.Header, thumbprint)
+	}
+	if parsed.UserID != claims.UserID || parsed.ID == "" || parsed.Use != tokens.UseAccess {
+		t.Errorf("claims = %+v", parsed)
+	}
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	oldKey := newRSAKey(t, 2048)
+	oldRing, err := tokens.NewKeyRing(testIssuer, "2024-01", oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, _ := signAccessToken(t, oldRing)
+
+	ring, err := tokens.NewKeyRing(testIssuer, "2024-06", newEd25519Key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.Parse(oldToken, &tokens.AccessClaims{}); !errors.Is(err, tokens.ErrUnknownKey) {
+		t.Fatalf("token of a key not in the ring: err = %v, want ErrUnknownKey", err)
+	}
+
+	// During rotation the previous key is still accepted, but new tokens use the new one
+	if err := ring.AddVerificationKey("2024-01", &oldKey.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.Parse(oldToken, &tokens.AccessClaims{}); err != nil {
+		t.Fatalf("token of the previous key: %v", err)
+	}
+	newToken, _ := signAccessToken(t, ring)
+	parsed, err := ring.Parse(newToken, &tokens.AccessClaims{})
+	if err != nil || parsed.Header["kid"] != "2024-06" {
+		t.Fatalf("new token kid = %v, err = %v", parsed.Header["kid"], err)
+	}
+}
+
+func TestKeyRingRejectsAlgorithmMismatch(t *testing.T) {
+	edKey := newEd25519Key(t)
+	rsaKey := newRSAKey(t, 2048)
+	ring, err := tokens.NewKeyRing(testIssuer, "ed", edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.AddVerificationKey("rsa", &rsaKey.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	claims := ring.NewAccessClaims(uuid.New(), time.Minute)
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	edPublic := []byte(edKey.Public().(ed25519.PublicKey))
+	forged := map[string]string{
+		// Each key is pinned to its own algorithm, whatever the header claims
+		"RS256 under the EdDSA kid": sign(jwt.SigningMethodRS256, "ed", rsaKey),
+		"EdDSA under the RS256 kid": sign(jwt.SigningMethodEdDSA, "rsa", edKey),
+		// The public key must never be usable as an HMAC secret
+		"HS256 keyed with the public key": sign(jwt.SigningMethodHS256, "ed", edPublic),
+		"unsigned":                        sign(jwt.SigningMethodNone, "ed", jwt.UnsafeAllowNoneSignatureType),
+		"no kid":                          sign(jwt.SigningMethodEdDSA, "", edKey),
+	}
+	for name, token := range forged {
+		if _, err := ring.Parse(token, &tokens.AccessClaims{}); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+
+	// The kid and alg that belong together are accepted
+	for name, token := range map[string]string{
+		"EdDSA": sign(jwt.SigningMethodEdDSA, "ed", edKey),
+		"RS256": sign(jwt.SigningMethodRS256, "rsa", rsaKey),
+	} {
+		if _, err := ring.Parse(token, &tokens.AccessClaims{}); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestKeyRingRequiresIssuerAndExpiry(t *testing.T) {
+	ring := newTestKeyRing(t)
+
+	other := ring.NewAccessClaims(uuid.New(), time.Minute)
+	other.Issuer = "someone-else"
+	noExpiry := ring.NewAccessClaims(uuid.New(), time.Minute)
+	noExpiry.ExpiresAt = nil
+	expired := ring.NewAccessClaims(uuid.New(), -time.Minute)
+
+	for name, claims := range map[string]*tokens.AccessClaims{"other issuer": other, "no expiry": noExpiry, "expired": expired} {
+		token, err := ring.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ring.Parse(token, &tokens.AccessClaims{}); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+}
+
+func TestKeyRingRejectsWeakKeys(t *testing.T) {
+	if _, err := tokens.NewKeyRing(testIssuer, "", newRSAKey(t, 1024)); err == nil {
+		t.Error("1024 bit RSA signing key accepted")
+	}
+	ring := newTestKeyRing(t)
+	if err := ring.AddVerificationKey("weak", &newRSAKey(t, 1024).PublicKey); err == nil {
+		t.Error("1024 bit RSA verification key accepted")
+	}
+}
+
+func TestKeyRingJWKS(t *testing.T) {
+	edKey := newEd25519Key(t)
+	rsaKey := newRSAKey(t, 2048)
+	ring, err := tokens.NewKeyRing(testIssuer, "active", edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.AddVerificationKey("previous", &rsaKey.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	// Adding a kid twice keeps the first key
+	if err := ring.AddVerificationKey("previous", &newRSAKey(t, 2048).PublicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	set := ring.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("keys = %+v, want the active and previous key", set.Keys)
+	}
+	active, previous := set.Keys[0], set.Keys[1]
+	if active.Kid != "active" || active.Kty != "OKP" || active.Crv != "Ed25519" || active.Alg != tokens.AlgEdDSA || active.Use != "sig" {
+		t.Errorf("active key = %+v", active)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(active.X)
+	if err != nil || !ed25519.PublicKey(x).Equal(edKey.Public()) {
+		t.Errorf("active x = %q does not encode the signing key", active.X)
+	}
+
+	if previous.Kid != "previous" || previous.Kty != "RSA" || previous.Alg != tokens.AlgRS256 || previous.Use != "sig" {
+		t.Errorf("previous key = %+v", previous)
+	}
+	n, errN := base64.RawURLEncoding.DecodeString(previous.N)
+	e, errE := base64.RawURLEncoding.DecodeString(previous.E)
+	if errN != nil || errE != nil || new(big.Int).SetBytes(n).Cmp(rsaKey.N) != 0 || new(big.Int).SetBytes(e).Int64() != int64(rsaKey.E) {
+		t.Errorf("previous n, e = %q, %q do not encode the RSA key", previous.N, previous.E)
+	}
+	// A verifier holding only the JWKS accepts the ring's tokens
+	token, _ := signAccessToken(t, ring)
+	_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return ed25519.PublicKey(x), nil
+	}, jwt.WithValidMethods([]string{active.Alg}))
+	if err != nil {
+		t.Fatalf("token does not verify against the published key: %v", err)
+	}
+}
+
+func TestThumbprintRFC7638(t *testing.T) {
+	// The example key of RFC 7638, section 3.1
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+	got, err := tokens.Thumbprint(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Errorf("Thumbprint = %s, want %s", got, want)
+	}
+}
+
+// TestAccessTokenUse checks that only tokens issued for access are accepted
+// by AuthMiddleware, although every kind is signed by the same key ring.
+func TestAccessTokenUse(t *testing.T) {
+	ring := newTestKeyRing(t)
+	mw := newAuthMiddleware(ring, revocation.NewMemoryStore())
+
+	access, _ := signAccessToken(t, ring)
+	if w := serve(mw, newRequest(http.MethodGet, "Bearer "+access)); w.Code != http.StatusOK {
+		t.Fatalf("access token: status = %d", w.Code)
+	}
+
+	for _, use := range []string{tokens.UseEmailVerification, tokens.UsePasswordReset, tokens.UseMFAChallenge, tokens.UseEmailChange, tokens.UseMagicLink} {
+		claims := ring.NewActionClaims(use, uuid.New(), uuid.New(), "jane@example.org", time.Minute)
+		token, err := ring.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := serve(mw, newRequest(http.MethodGet, "Bearer "+token)); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s token: status = %d, want 401", use, w.Code)
+		}
+	}
+
+	// Access claims without a use, e.g. forged from another service's token
+	claims := ring.NewAccessClaims(uuid.New(), time.Minute)
+	claims.Use = ""
+	token, err := ring.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(mw, newRequest(http.MethodGet, "Bearer "+token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("token without token_use: status = %d, want 401", w.Code)
+	}
+}
+
+// noPersonalAccessTokens rejects every personal access token.
+type noPersonalAccessTokens struct{}
+
+func (noPersonalAccessTokens) Authenticate(string) (*tokens.Identity, error) {
+	return nil, errors.New("invalid personal access token")
+}
+
+func newAuthMiddleware(ring *tokens.KeyRing, denylist revocation.Store) gin.HandlerFunc {
+	return middleware.AuthMiddleware(ring, noPersonalAccessTokens{}, denylist)
+}
+
+// newRequest returns a request to the test route, with an Authorization
+// header unless authorization is empty.
+func newRequest(method, authorization string) *http.Request {
+	req := httptest.NewRequest(method, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return req
+}
+
+// serve passes req through handlers to a route that responds 200 OK.
+func serve(handlers gin.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/", handlers, func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	if _, err := tokens.LoadKeyRing(testIssuer, "", "", "", false); !errors.Is(err, tokens.ErrNoSigningKey) {
+		t.Fatalf("without a signing key: err = %v, want ErrNoSigningKey", err)
+	}
+	if _, err := tokens.LoadKeyRing(testIssuer, "", "", "", true); err != nil {
+		t.Fatalf("ephemeral key allowed: %v", err)
+	}
+
+	dir := t.TempDir()
+	edKey := newEd25519Key(t)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signing := writePEM(t, dir, "signing.pem", "PRIVATE KEY", der)
+
+	rsaKey := newRSAKey(t, 2048)
+	pub, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := writePEM(t, dir, "previous.pub.pem", "PUBLIC KEY", pub)
+	legacy := writePEM(t, dir, "legacy.pub.pem", "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&newRSAKey(t, 2048).PublicKey))
+
+	// A configured signing key is used even when an ephemeral one is allowed
+	ring, err := tokens.LoadKeyRing(testIssuer, signing, "2024-06", "2024-01="+previous+", "+legacy, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := ring.JWKS()
+	if len(set.Keys) != 3 || set.Keys[0].Kid != "2024-06" || set.Keys[1].Kid != "2024-01" || set.Keys[2].Kty != "RSA" {
+		t.Fatalf("keys = %+v", set.Keys)
+	}
+	x, _ := base64.RawURLEncoding.DecodeString(set.Keys[0].X)
+	if !ed25519.PublicKey(x).Equal(edKey.Public()) {
+		t.Error("ring does not sign with the configured key")
+	}
+
+	if _, err := tokens.LoadKeyRing(testIssuer, filepath.Join(dir, "missing.pem"), "", "", true); err == nil {
+		t.Error("missing signing key file accepted")
+	}
+	if _, err := tokens.LoadKeyRing(testIssuer, signing, "", filepath.Join(dir, "missing.pem"), false); err == nil {
+		t.Error("missing verification key file accepted")
+	}
+}
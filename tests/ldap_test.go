@@ -0,0 +1,207 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/ldap"
+	"eskalate-movie-api/internal/ldap/ldaptest"
+)
+
+const (
+	ldapBaseDN    = "dc=example,dc=org"
+	ldapServiceDN = "cn=svc,ou=services,dc=example,dc=org"
+	ldapAdmins    = "cn=admins,ou=groups,dc=example,dc=org"
+	ldapEditors   = "cn=editors,ou=groups,dc=example,dc=org"
+)
+
+func newLDAPServer(t *testing.T) *ldaptest.Server {
+	server, err := ldaptest.NewServer(
+		ldaptest.Entry{DN: ldapServiceDN, Password: "svc-secret"},
+		ldaptest.Entry{
+			DN:       "uid=jane,ou=people,dc=example,dc=org",
+			Password: "jane-secret",
+			Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"jane"},
+				"mail":        {"jane@example.org"},
+				"displayName": {"Jane Doe"},
+				"memberOf":    {"CN=Editors, OU=Groups, DC=example, DC=org"},
+			},
+		},
+		ldaptest.Entry{
+			DN:       "uid=root,ou=people,dc=example,dc=org",
+			Password: "root-secret",
+			Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"root"},
+				"mail":        {"root@example.org"},
+				"memberOf":    {ldapAdmins, ldapEditors},
+			},
+		},
+		// Entries sharing an address cannot be told apart
+		ldaptest.Entry{DN: "uid=a,ou=people,dc=example,dc=org", Password: "x", Attributes: map[string][]string{"objectClass": {"person"}, "mail": {"shared@example.org"}}},
+		ldaptest.Entry{DN: "uid=b,ou=people,dc=example,dc=org", Password: "x", Attributes: map[string][]string{"objectClass": {"person"}, "mail": {"shared@example.org"}}},
+		ldaptest.Entry{DN: "uid=c,ou=people,dc=example,dc=org", Password: "x", Attributes: map[string][]string{"objectClass": {"person"}, "mail": {"shared@example.org"}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestDirectory(server *ldaptest.Server) *ldap.Directory {
+	return ldap.NewDirectory(ldap.Config{
+		URL:                  server.URL,
+		Timeout:              5 * time.Second,
+		BindDN:               ldapServiceDN,
+		BindPassword:         "svc-secret",
+		BaseDN:               ldapBaseDN,
+		UserFilter:           "(&(objectClass=person)(mail={login}))",
+		EmailAttribute:       "mail",
+		UsernameAttribute:    "uid",
+		DisplayNameAttribute: "displayName",
+		GroupAttribute:       "memberOf",
+	})
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	directory := newTestDirectory(newLDAPServer(t))
+	identity, err := directory.Authenticate("jane@example.org", "jane-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if identity.DN != "uid=jane,ou=people,dc=example,dc=org" || identity.Email != "jane@example.org" ||
+		identity.Username != "jane" || identity.DisplayName != "Jane Doe" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+	if !identity.InGroup(ldapEditors) || identity.InGroup(ldapAdmins) {
+		t.Fatalf("groups = %v", identity.Groups)
+	}
+}
+
+func TestLDAPRejectsBadCredentials(t *testing.T) {
+	server := newLDAPServer(t)
+	directory := newTestDirectory(server)
+	cases := map[string][2]string{
+		"wrong password": {"jane@example.org", "nope"},
+		"unknown login":  {"nobody@example.org", "jane-secret"},
+		// An empty password would be an anonymous bind, which servers accept
+		"empty password": {"jane@example.org", ""},
+		// The login is escaped, so it cannot widen the filter
+		"filter injection": {"*", "jane-secret"},
+		"filter breakout":  {"x)(mail=jane@example.org", "jane-secret"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := directory.Authenticate(c[0], c[1]); !errors.Is(err, ldap.ErrInvalidCredentials) {
+				t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+			}
+		})
+	}
+
+	server.SetPassword("uid=jane,ou=people,dc=example,dc=org", "rotated")
+	if _, err := directory.Authenticate("jane@example.org", "jane-secret"); !errors.Is(err, ldap.ErrInvalidCredentials) {
+		t.Fatalf("old password after rotation: err = %v", err)
+	}
+}
+
+func TestLDAPAmbiguousLogin(t *testing.T) {
+	directory := newTestDirectory(newLDAPServer(t))
+	if _, err := directory.Authenticate("shared@example.org", "x"); !errors.Is(err, ldap.ErrAmbiguousLogin) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLDAPServiceAccountRequired(t *testing.T) {
+	server := newLDAPServer(t)
+	directory := ldap.NewDirectory(ldap.Config{
+		URL:          server.URL,
+		Timeout:      5 * time.Second,
+		BindDN:       ldapServiceDN,
+		BindPassword: "wrong",
+		BaseDN:       ldapBaseDN,
+		UserFilter:   "(mail={login})",
+	})
+	// A misconfigured service account is not the user's fault and must not
+	// look like a wrong password
+	_, err := directory.Authenticate("jane@example.org", "jane-secret")
+	if err == nil || errors.Is(err, ldap.ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want a service account error", err)
+	}
+
+	server.AllowAnonymousSearch = true
+	anonymous := ldap.NewDirectory(ldap.Config{
+		URL:            server.URL,
+		Timeout:        5 * time.Second,
+		BaseDN:         ldapBaseDN,
+		UserFilter:     "(mail={login})",
+		EmailAttribute: "mail",
+	})
+	if _, err := anonymous.Authenticate("jane@example.org", "jane-secret"); err != nil {
+		t.Fatalf("anonymous search: %v", err)
+	}
+}
+
+func TestLDAPUnreachable(t *testing.T) {
+	server := newLDAPServer(t)
+	directory := newTestDirectory(server)
+	server.Close()
+	_, err := directory.Authenticate("jane@example.org", "jane-secret")
+	if err == nil || errors.Is(err, ldap.ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want a connection error", err)
+	}
+}
+
+func TestLDAPGroupRoles(t *testing.T) {
+	directory := newTestDirectory(newLDAPServer(t))
+	mapping := ldap.ParseGroupRoles("admin=" + ldapAdmins + "; curator=" + ldapEditors + ";bogus")
+	if len(mapping) != 2 {
+		t.Fatalf("mapping = %+v", mapping)
+	}
+
+	root, err := directory.Authenticate("root@example.org", "root-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role := root.Role(mapping, "member"); role != "admin" {
+		t.Fatalf("root role = %q, want the first matching mapping", role)
+	}
+	jane, err := directory.Authenticate("jane@example.org", "jane-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// memberOf differs in case and spacing from the configured DN
+	if role := jane.Role(mapping, "member"); role != "curator" {
+		t.Fatalf("jane role = %q", role)
+	}
+	if role := jane.Role(mapping[:1], "member"); role != "member" {
+		t.Fatalf("unmapped role = %q", role)
+	}
+}
+
+func TestLDAPFilters(t *testing.T) {
+	valid := []string{
+		"(mail=a@b)",
+		"(&(objectClass=person)(|(uid=jane)(mail=*@example.org)))",
+		"(!(uid=root))",
+		"(cn=J*n*e)",
+		"(description=a\\2ab)",
+	}
+	for _, f := range valid {
+		if _, err := ldap.CompileFilter(f); err != nil {
+			t.Errorf("CompileFilter(%q): %v", f, err)
+		}
+	}
+	invalid := []string{"", "mail=a", "(mail=a", "(&)", "(=a)", "(mail=a)(uid=b)", "(mail=\\2)"}
+	for _, f := range invalid {
+		if _, err := ldap.CompileFilter(f); err == nil {
+			t.Errorf("CompileFilter(%q) succeeded", f)
+		}
+	}
+	if got := ldap.EscapeFilter("a*(b)\\"); got != "a\\2a\\28b\\29\\5c" {
+		t.Errorf("EscapeFilter = %q", got)
+	}
+}
@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+)
+
+func throttleLimits(account, ip int) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.LoginLockoutThreshold = account
+		cfg.LoginIPLockoutThreshold = ip
+		cfg.LoginLockoutDuration = 15 * time.Minute
+	}
+}
+
+// retryAfter returns how long Check makes a login for email from ip wait.
+func (f *authFixture) retryAfter(t *testing.T, email, ip string) time.Duration {
+	t.Helper()
+	err := f.throttle.Check(email, ip)
+	if err == nil {
+		return 0
+	}
+	var throttled *services.ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatal(err)
+	}
+	return throttled.RetryAfter
+}
+
+func (f *authFixture) recordFailures(t *testing.T, email, ip string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := f.throttle.RecordFailure(email, ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoginBackoffGrowsUntilLockout(t *testing.T) {
+	f := newAuthFixture(t, throttleLimits(7, 100))
+	email := "guessed@example.org"
+
+	f.recordFailures(t, email, "", 3)
+	if wait := f.retryAfter(t, email, testClient.IPAddress); wait != 0 {
+		t.Fatalf("throttled after 3 failures for %s", wait)
+	}
+	// Each failure past the third doubles the wait, from a second
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		f.recordFailures(t, email, "", 1)
+		if wait := f.retryAfter(t, email, testClient.IPAddress); wait <= want/2 || wait > want {
+			t.Errorf("after %d failures: wait = %s, want %s", f.accountFailures(t, email), wait, want)
+		}
+	}
+
+	// The threshold locks the account for the lockout duration
+	f.recordFailures(t, email, "", 1)
+	if wait := f.retryAfter(t, email, testClient.IPAddress); wait < 14*time.Minute || wait > 15*time.Minute {
+		t.Errorf("after reaching the threshold: wait = %s, want 15m", wait)
+	}
+	locked, err := f.throttle.List(models.ThrottleAccount, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locked) != 1 || locked[0].Subject != email || locked[0].Failures != 7 {
+		t.Errorf("locked accounts = %+v", locked)
+	}
+}
+
+func TestLockedAccountRefusesCorrectPassword(t *testing.T) {
+	f := newAuthFixture(t, throttleLimits(4, 100))
+	user := f.createUser(t, "locked@example.org")
+
+	for i := 0; i < 4; i++ {
+		_, err := f.auth.LoginWithRefresh(user.Email, "Wrong-Password-1", testClient)
+		if !errors.Is(err, services.ErrInvalidCredentials) {
+			t.Fatalf("failure %d: err = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+	var throttled *services.ThrottledError
+	if _, err := f.auth.LoginWithRefresh(user.Email, testPassword, testClient); !errors.As(err, &throttled) {
+		t.Fatalf("correct password while locked: err = %v, want ThrottledError", err)
+	}
+	// Other accounts from other addresses are unaffected
+	other := f.createUser(t, "bystander@example.org")
+	f.login(t, other, services.ClientInfo{UserAgent: "tests", IPAddress: "192.0.2.200"})
+
+	if err := f.throttle.Clear(models.ThrottleAccount, " Locked@Example.org "); err != nil {
+		t.Fatal(err)
+	}
+	f.login(t, user, testClient)
+	if err := f.throttle.Clear(models.ThrottleAccount, user.Email); !errors.Is(err, services.ErrThrottleNotFound) {
+		t.Errorf("clearing twice: err = %v, want ErrThrottleNotFound", err)
+	}
+}
+
+func TestSuccessfulLoginClearsAccountFailures(t *testing.T) {
+	f := newAuthFixture(t, throttleLimits(10, 100))
+	user := f.createUser(t, "forgiven@example.org")
+	for i := 0; i < 3; i++ {
+		if _, err := f.auth.LoginWithRefresh(user.Email, "Wrong-Password-1", testClient); !errors.Is(err, services.ErrInvalidCredentials) {
+			t.Fatal(err)
+		}
+	}
+	if got := f.accountFailures(t, user.Email); got != 3 {
+		t.Fatalf("failures = %d, want 3", got)
+	}
+	f.login(t, user, testClient)
+	if got := f.accountFailures(t, user.Email); got != 0 {
+		t.Errorf("failures after a successful login = %d, want 0", got)
+	}
+}
+
+func TestOldFailuresAreForgotten(t *testing.T) {
+	f := newAuthFixture(t, throttleLimits(5, 100))
+	email := "patient@example.org"
+	f.recordFailures(t, email, "", 4)
+	if err := f.db.Model(&models.LoginThrottle{}).Where("subject = ?", email).Updates(map[string]interface{}{
+		"last_failure_at": time.Now().Add(-time.Hour),
+		"locked_until":    nil,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	f.recordFailures(t, email, "", 1)
+	if got := f.accountFailures(t, email); got != 1 {
+		t.Errorf("failures after an hour = %d, want 1", got)
+	}
+	if wait := f.retryAfter(t, email, ""); wait != 0 {
+		t.Errorf("throttled for %s by forgotten failures", wait)
+	}
+}
+
+func TestLoginThrottledPerIPAddress(t *testing.T) {
+	f := newAuthFixture(t, throttleLimits(100, 12))
+	attacker := "192.0.2.66"
+
+	// Failures spread over many accounts add up for the address
+	for i := 0; i < 10; i++ {
+		f.recordFailures(t, fmt.Sprintf("victim%d@example.org", i), attacker, 1)
+	}
+	if wait := f.retryAfter(t, "fresh@example.org", attacker); wait != 0 {
+		t.Fatalf("throttled after 10 failures for %s", wait)
+	}
+	f.recordFailures(t, "victim10@example.org", attacker, 1)
+	if wait := f.retryAfter(t, "fresh@example.org", attacker); wait == 0 || wait > time.Second {
+		t.Errorf("after 11 failures: wait = %s, want 1s", wait)
+	}
+	f.recordFailures(t, "victim11@example.org", attacker, 1)
+	if wait := f.retryAfter(t, "fresh@example.org", attacker); wait < 14*time.Minute {
+		t.Errorf("after reaching the threshold: wait = %s, want 15m", wait)
+	}
+	if wait := f.retryAfter(t, "fresh@example.org", "192.0.2.67"); wait != 0 {
+		t.Errorf("another address throttled for %s", wait)
+	}
+
+	ips, err := f.throttle.List(models.ThrottleIP, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 1 || ips[0].Subject != attacker || ips[0].Failures != 12 {
+		t.Errorf("throttled addresses = %+v", ips)
+	}
+	if err := f.throttle.Clear(models.ThrottleIP, attacker); err != nil {
+		t.Fatal(err)
+	}
+	if wait := f.retryAfter(t, "fresh@example.org", attacker); wait != 0 {
+		t.Errorf("cleared address throttled for %s", wait)
+	}
+}
+
+// verifyRecorder records the hashes passwords are verified against.
+type verifyRecorder struct {
+	services.PasswordHasher
+	mu       sync.Mutex
+	verified []string
+}
+
+func (r *verifyRecorder) Verify(hash, password string) bool {
+	r.mu.Lock()
+	r.verified = append(r.verified, hash)
+	r.mu.Unlock()
+	return r.PasswordHasher.Verify(hash, password)
+}
+
+func TestUnknownEmailCostsAPasswordHash(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "timed@example.org")
+	hasher := &verifyRecorder{PasswordHasher: f.hasher}
+	auth := services.NewAuthService(f.users, f.db, f.keys, f.mail, f.throttle, hasher, f.denylist, f.cfg)
+
+	for _, email := range []string{user.Email, "unknown@example.org"} {
+		if _, err := auth.LoginWithRefresh(email, "Wrong-Password-1", testClient); !errors.Is(err, services.ErrInvalidCredentials) {
+			t.Fatalf("%s: err = %v, want ErrInvalidCredentials", email, err)
+		}
+	}
+	if len(hasher.verified) != 2 {
+		t.Fatalf("%d passwords verified, want one per attempt", len(hasher.verified))
+	}
+	known, unknown := hasher.verified[0], hasher.verified[1]
+	if unknown == known || !strings.HasPrefix(unknown, "$argon2id$") || f.hasher.NeedsRehash(unknown) {
+		t.Errorf("unknown email verified against %q, want a hash like %q", unknown, known)
+	}
+}
+
+func TestUnknownEmailFailsLikeWrongPassword(t *testing.T) {
+	f := newAuthFixture(t, throttleLimits(10, 100))
+	user := f.createUser(t, "exists@example.org")
+
+	for _, email := range []string{user.Email, "missing@example.org"} {
+		for i := 0; i < 4; i++ {
+			_, err := f.auth.LoginWithRefresh(email, "Wrong-Password-1", testClient)
+			if !errors.Is(err, services.ErrInvalidCredentials) || err.Error() != services.ErrInvalidCredentials.Error() {
+				t.Fatalf("%s failure %d: err = %v, want ErrInvalidCredentials", email, i+1, err)
+			}
+		}
+		var throttled *services.ThrottledError
+		if _, err := f.auth.LoginWithRefresh(email, "Wrong-Password-1", testClient); !errors.As(err, &throttled) {
+			t.Errorf("%s after 4 failures: err = %v, want ThrottledError", email, err)
+		}
+	}
+
+	rows, err := f.throttle.List("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]int{}
+	for _, row := range rows {
+		kinds[row.Kind]++
+	}
+	if kinds[models.ThrottleAccount] != 2 || kinds[models.ThrottleIP] != 1 {
+		t.Errorf("throttled subjects = %+v, want both accounts and the address", rows)
+	}
+}
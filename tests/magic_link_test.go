@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/tokens"
+)
+
+// requestMagicLink asks for a sign-in link and returns the binding of the
+// requesting browser and the token of the message, which is sent in the
+// background.
+func (f *authFixture) requestMagicLink(t *testing.T, email string) (string, string) {
+	t.Helper()
+	before := len(f.mail.sentTo(email))
+	binding, err := f.auth.RequestMagicLink(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if len(f.mail.sentTo(email)) > before {
+			return binding, f.lastTokenSentTo(t, email)
+		}
+	}
+	t.Fatalf("no sign-in link sent to %s", email)
+	return "", ""
+}
+
+// magicLinks counts the sign-in links issued to user.
+func (f *authFixture) magicLinks(t *testing.T, user *models.User) int64 {
+	t.Helper()
+	var n int64
+	if err := f.db.Model(&models.ActionToken{}).Where("user_id = ? AND purpose = ?", user.ID, tokens.UseMagicLink).
+		Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestMagicLinkRequiresBinding(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "linked@example.org")
+	binding, token := f.requestMagicLink(t, user.Email)
+
+	other, err := f.auth.RequestMagicLink("nobody@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, wrong := range map[string]string{"missing": "", "another browser": other, "altered": binding + "x"} {
+		if _, err := f.auth.LoginWithMagicLink(token, wrong, testClient); !errors.Is(err, services.ErrInvalidActionToken) {
+			t.Errorf("%s binding: err = %v, want ErrInvalidActionToken", name, err)
+		}
+	}
+
+	// A rejected binding leaves the link usable from the right browser
+	result, err := f.auth.LoginWithMagicLink(token, binding, testClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MFARequired() || result.AccessToken == "" || result.RefreshToken == "" {
+		t.Errorf("login result = %+v, want a token pair", result)
+	}
+}
+
+func TestMagicLinkIsSingleUse(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "once@example.org")
+	if err := f.db.Model(user).Update("email_verified", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	binding, token := f.requestMagicLink(t, user.Email)
+
+	if _, err := f.auth.LoginWithMagicLink(token, binding, testClient); err != nil {
+		t.Fatal(err)
+	}
+	if !f.emailVerified(t, user) {
+		t.Error("address not verified by the link")
+	}
+	if _, err := f.auth.LoginWithMagicLink(token, binding, testClient); !errors.Is(err, services.ErrInvalidActionToken) {
+		t.Errorf("second exchange: err = %v, want ErrInvalidActionToken", err)
+	}
+}
+
+func TestMagicLinkRequestInvalidatesEarlierLinks(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "again@example.org")
+	firstBinding, first := f.requestMagicLink(t, user.Email)
+	secondBinding, second := f.requestMagicLink(t, user.Email)
+
+	if _, err := f.auth.LoginWithMagicLink(first, firstBinding, testClient); !errors.Is(err, services.ErrInvalidActionToken) {
+		t.Errorf("earlier link: err = %v, want ErrInvalidActionToken", err)
+	}
+	if _, err := f.auth.LoginWithMagicLink(second, secondBinding, testClient); err != nil {
+		t.Errorf("latest link: %v", err)
+	}
+}
+
+func TestMagicLinkHourlyLimit(t *testing.T) {
+	f := newAuthFixture(t, func(cfg *config.Config) { cfg.MagicLinkHourlyLimit = 2 })
+	user := f.createUser(t, "eager@example.org")
+	f.requestMagicLink(t, user.Email)
+	binding, token := f.requestMagicLink(t, user.Email)
+
+	// Past the limit the request is answered as usual but nothing is sent
+	limited, err := f.auth.RequestMagicLink(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limited == "" {
+		t.Error("no binding returned past the limit")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if sent := len(f.mail.sentTo(user.Email)); sent != 2 {
+		t.Errorf("%d links sent, want 2", sent)
+	}
+	if issued := f.magicLinks(t, user); issued != 2 {
+		t.Errorf("%d links issued, want 2", issued)
+	}
+
+	// The last link sent still works
+	if _, err := f.auth.LoginWithMagicLink(token, binding, testClient); err != nil {
+		t.Errorf("link sent before the limit: %v", err)
+	}
+
+	// Unknown addresses get a binding too
+	if unknown, err := f.auth.RequestMagicLink("stranger@example.org"); err != nil || unknown == "" {
+		t.Errorf("unknown address: binding %q, err = %v", unknown, err)
+	}
+}
+
+func TestMagicLinkExpired(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "late@example.org")
+	binding, token := f.requestMagicLink(t, user.Email)
+
+	if err := f.db.Model(&models.ActionToken{}).Where("user_id = ? AND purpose = ?", user.ID, tokens.UseMagicLink).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.auth.LoginWithMagicLink(token, binding, testClient); !errors.Is(err, services.ErrInvalidActionToken) {
+		t.Errorf("expired link: err = %v, want ErrInvalidActionToken", err)
+	}
+}
+
+func TestMagicLinkAsksForSecondFactor(t *testing.T) {
+	f := newAuthFixture(t)
+	withTOTP := f.createUser(t, "totp-link@example.org")
+	f.enableTOTP(t, withTOTP)
+	withPasskey := f.createUser(t, "passkey-link@example.org")
+	passkey := &models.Passkey{
+		UserID:       withPasskey.ID,
+		Name:         "Laptop",
+		CredentialID: []byte("magic-link-credential"),
+		PublicKey:    []byte("public-key"),
+		Algorithm:    -7,
+	}
+	if err := f.db.Create(passkey).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for user, method := range map[*models.User]string{withTOTP: services.MFAMethodTOTP, withPasskey: services.MFAMethodPasskey} {
+		binding, token := f.requestMagicLink(t, user.Email)
+		result, err := f.auth.LoginWithMagicLink(token, binding, testClient)
+		if err != nil {
+			t.Fatalf("%s: %v", user.Email, err)
+		}
+		if !result.MFARequired() || result.AccessToken != "" || result.RefreshToken != "" {
+			t.Errorf("%s: login result = %+v, want an MFA challenge", user.Email, result)
+		}
+		if !contains(result.MFAMethods, method) {
+			t.Errorf("%s: MFA methods = %v, want %s", user.Email, result.MFAMethods, method)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/totp"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238 appendix B,
+// "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	// The SHA-1 vectors of RFC 6238 appendix B, truncated to six digits
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range vectors {
+		at := time.Unix(v.unix, 0)
+		code, err := totp.CodeAt(rfc6238Secret, totp.Step(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != v.code {
+			t.Errorf("code at %d = %s, want %s", v.unix, code, v.code)
+		}
+		step, ok := totp.Validate(rfc6238Secret, v.code, at)
+		if !ok || step != totp.Step(at) {
+			t.Errorf("Validate(%s) at %d = %d, %v", v.code, v.unix, step, ok)
+		}
+	}
+}
+
+func TestTOTPSkewWindow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	current := totp.Step(now)
+	for offset := int64(-2); offset <= 2; offset++ {
+		code, err := totp.CodeAt(rfc6238Secret, current+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := totp.Validate(rfc6238Secret, code, now)
+		want := offset >= -totp.Skew && offset <= totp.Skew
+		if ok != want {
+			t.Errorf("code %d steps away accepted = %v, want %v", offset, ok, want)
+		}
+		if ok && step != current+offset {
+			t.Errorf("code %d steps away matched step %d, want %d", offset, step, current+offset)
+		}
+	}
+
+	code, _ := totp.CodeAt(rfc6238Secret, current)
+	if _, ok := totp.Validate(rfc6238Secret, " "+code+" ", now); !ok {
+		t.Error("code with surrounding spaces rejected")
+	}
+	for _, bad := range []string{code[:5], code + "0", "", "abcdef"} {
+		if _, ok := totp.Validate(rfc6238Secret, bad, now); ok {
+			t.Errorf("code %q accepted", bad)
+		}
+	}
+}
+
+func TestTOTPSealRoundTrip(t *testing.T) {
+	key := totp.SealKey(strings.Repeat("m", 32))
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := totp.Seal(key, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, secret) {
+		t.Fatal("sealed secret contains the plaintext")
+	}
+	opened, err := totp.Open(key, sealed)
+	if err != nil || opened != secret {
+		t.Fatalf("Open = %q, %v; want %q", opened, err, secret)
+	}
+
+	again, err := totp.Seal(key, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == sealed {
+		t.Error("sealing twice gave the same ciphertext; the nonce is not random")
+	}
+
+	if _, err := totp.Open(totp.SealKey(strings.Repeat("x", 32)), sealed); err == nil {
+		t.Error("Open with another key succeeded")
+	}
+	tampered := []byte(sealed)
+	i := len(tampered) / 2
+	if tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+	if _, err := totp.Open(key, string(tampered)); err == nil {
+		t.Error("Open of a tampered secret succeeded")
+	}
+	for _, bad := range []string{"", "AAAA", "not base64!"} {
+		if _, err := totp.Open(key, bad); err == nil {
+			t.Errorf("Open(%q) succeeded", bad)
+		}
+	}
+}
+
+// enableTOTP enrolls user in TOTP and returns the secret and the step of the
+// code used to confirm it.
+func (f *authFixture) enableTOTP(t *testing.T, user *models.User) (string, int64) {
+	t.Helper()
+	setup, err := f.auth.SetupTOTP(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := totp.Step(time.Now())
+	if _, err := f.auth.EnableTOTP(user.ID, totpCode(t, setup.Secret, step)); err != nil {
+		t.Fatal(err)
+	}
+	return setup.Secret, step
+}
+
+// startMFALogin signs user in with testPassword and returns the MFA challenge.
+func (f *authFixture) startMFALogin(t *testing.T, user *models.User, client services.ClientInfo) string {
+	t.Helper()
+	result, err := f.auth.LoginWithRefresh(user.Email, testPassword, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.MFARequired() {
+		t.Fatal("login did not ask for a second factor")
+	}
+	return result.MFAToken
+}
+
+// accountFailures returns the failed logins counted against email.
+func (f *authFixture) accountFailures(t *testing.T, email string) int {
+	t.Helper()
+	rows, err := f.throttle.List(models.ThrottleAccount, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if row.Subject == email {
+			return row.Failures
+		}
+	}
+	return 0
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := totp.CodeAt(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// wrongTOTPCode returns a six digit code that is not valid for secret now.
+func wrongTOTPCode(secret string) string {
+	for n := 0; ; n++ {
+		code := fmt.Sprintf("%06d", n)
+		if _, ok := totp.Validate(secret, code, time.Now()); !ok {
+			return code
+		}
+	}
+}
+
+var testClient = services.ClientInfo{UserAgent: "tests", IPAddress: "192.0.2.1"}
+
+func TestMFALoginRejectsReplayedCode(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "replay@example.org")
+	secret, step := f.enableTOTP(t, user)
+
+	// The code that confirmed enrollment cannot be used again
+	challenge := f.startMFALogin(t, user, testClient)
+	if _, _, err := f.auth.CompleteMFALogin(challenge, totpCode(t, secret, step), testClient); !errors.Is(err, services.ErrInvalidMFACode) {
+		t.Fatalf("enrollment code reused: err = %v", err)
+	}
+
+	next := totpCode(t, secret, step+1)
+	if _, _, err := f.auth.CompleteMFALogin(challenge, next, testClient); err != nil {
+		t.Fatalf("fresh code rejected: %v", err)
+	}
+	var stored models.User
+	if err := f.db.First(&stored, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.TOTPLastStep != step+1 {
+		t.Errorf("TOTPLastStep = %d, want %d", stored.TOTPLastStep, step+1)
+	}
+
+	challenge = f.startMFALogin(t, user, testClient)
+	if _, _, err := f.auth.CompleteMFALogin(challenge, next, testClient); !errors.Is(err, services.ErrInvalidMFACode) {
+		t.Fatalf("code replayed in a new login: err = %v", err)
+	}
+}
+
+func TestMFALoginRecoveryCodeIsSingleUse(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "recovery@example.org")
+	setup, err := f.auth.SetupTOTP(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := f.auth.EnableTOTP(user.ID, totpCode(t, setup.Secret, totp.Step(time.Now())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge := f.startMFALogin(t, user, testClient)
+	if _, _, err := f.auth.CompleteMFALogin(challenge, strings.ToUpper(codes[0]), testClient); err != nil {
+		t.Fatalf("recovery code rejected: %v", err)
+	}
+	challenge = f.startMFALogin(t, user, testClient)
+	if _, _, err := f.auth.CompleteMFALogin(challenge, codes[0], testClient); !errors.Is(err, services.ErrInvalidMFACode) {
+		t.Fatalf("recovery code used twice: err = %v", err)
+	}
+}
+
+func TestMFALoginWrongCodesCountAsFailedLogins(t *testing.T) {
+	f := newAuthFixture(t, func(cfg *config.Config) { cfg.LoginLockoutThreshold = 4 })
+	user := f.createUser(t, "guess@example.org")
+	secret, step := f.enableTOTP(t, user)
+	wrong := wrongTOTPCode(secret)
+
+	challenge := f.startMFALogin(t, user, testClient)
+	for i := 0; i < 3; i++ {
+		if _, _, err := f.auth.CompleteMFALogin(challenge, wrong, testClient); !errors.Is(err, services.ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: err = %v", i+1, err)
+		}
+	}
+
+	// A correct password alone does not clear the failures
+	challenge = f.startMFALogin(t, user, testClient)
+	if got := f.accountFailures(t, user.Email); got != 3 {
+		t.Fatalf("failures after a new challenge = %d, want 3", got)
+	}
+	if _, _, err := f.auth.CompleteMFALogin(challenge, wrong, testClient); !errors.Is(err, services.ErrInvalidMFACode) {
+		t.Fatalf("fourth attempt: err = %v", err)
+	}
+
+	// Locked out: even the right code and password are refused
+	var throttled *services.ThrottledError
+	if _, _, err := f.auth.CompleteMFALogin(challenge, totpCode(t, secret, step+1), testClient); !errors.As(err, &throttled) {
+		t.Fatalf("right code while locked out: err = %v", err)
+	}
+	if _, err := f.auth.LoginWithRefresh(user.Email, testPassword, testClient); !errors.As(err, &throttled) {
+		t.Fatalf("password login while locked out: err = %v", err)
+	}
+}
+
+func TestMFALoginSuccessClearsFailures(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "success@example.org")
+	secret, step := f.enableTOTP(t, user)
+
+	challenge := f.startMFALogin(t, user, testClient)
+	if _, _, err := f.auth.CompleteMFALogin(challenge, wrongTOTPCode(secret), testClient); !errors.Is(err, services.ErrInvalidMFACode) {
+		t.Fatalf("err = %v", err)
+	}
+	if got := f.accountFailures(t, user.Email); got != 1 {
+		t.Fatalf("failures = %d, want 1", got)
+	}
+	access, refresh, err := f.auth.CompleteMFALogin(challenge, totpCode(t, secret, step+1), testClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if access == "" || refresh == "" {
+		t.Fatal("no session issued")
+	}
+	if got := f.accountFailures(t, user.Email); got != 0 {
+		t.Errorf("failures after success = %d, want 0", got)
+	}
+}
+
+func TestDisableTOTPWrongCodesAreThrottled(t *testing.T) {
+	f := newAuthFixture(t, func(cfg *config.Config) { cfg.LoginLockoutThreshold = 4 })
+	user := f.createUser(t, "disable@example.org")
+	secret, step := f.enableTOTP(t, user)
+	wrong := wrongTOTPCode(secret)
+
+	for i := 0; i < 4; i++ {
+		if err := f.auth.DisableTOTP(user.ID, wrong, testClient); !errors.Is(err, services.ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: err = %v", i+1, err)
+		}
+	}
+	var throttled *services.ThrottledError
+	if err := f.auth.DisableTOTP(user.ID, totpCode(t, secret, step+1), testClient); !errors.As(err, &throttled) {
+		t.Fatalf("right code while locked out: err = %v", err)
+	}
+	var stored models.User
+	if err := f.db.First(&stored, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !stored.TOTPEnabled {
+		t.Error("TOTP was disabled while locked out")
+	}
+}
@@ -0,0 +1,327 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/oidc"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIssuer is a minimal OpenID provider: it serves discovery and JWKS,
+// issues codes from /authorize and redeems them at /token, checking PKCE.
+type mockIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+
+	mu    sync.Mutex
+	codes map[string]pendingCode
+
+	// claims are added to every ID token; tests override them
+	claims jwt.MapClaims
+	// signWith replaces the signing key when set
+	signWith *rsa.PrivateKey
+	// jwksHeld, when set, holds JWKS requests until it is closed, after
+	// signalling jwksRequested
+	jwksHeld      chan struct{}
+	jwksRequested chan struct{}
+}
+
+type pendingCode struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{t: t, key: key, kid: "mock-1", codes: map[string]pendingCode{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	m.claims = jwt.MapClaims{"sub": "user-123", "email": "jane@example.com", "email_verified": true}
+	return m
+}
+
+func (m *mockIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 m.server.URL,
+		"authorization_endpoint": m.server.URL + "/authorize",
+		"token_endpoint":         m.server.URL + "/token",
+		"jwks_uri":               m.server.URL + "/jwks",
+	})
+}
+
+func (m *mockIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	if m.jwksHeld != nil {
+		m.jwksRequested <- struct{}{}
+		<-m.jwksHeld
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": m.kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+	}}})
+}
+
+func (m *mockIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+	code, _ := oidc.RandomString(16)
+	m.mu.Lock()
+	m.codes[code] = pendingCode{
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+	}
+	m.mu.Unlock()
+	back, _ := url.Parse(q.Get("redirect_uri"))
+	params := back.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	back.RawQuery = params.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	m.mu.Lock()
+	pending, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	clientID, secret, _ := r.BasicAuth()
+	if !ok || clientID != pending.clientID || secret != "s3cret" ||
+		r.PostForm.Get("redirect_uri") != pending.redirectURI ||
+		oidc.S256Challenge(r.PostForm.Get("code_verifier")) != pending.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   m.server.URL,
+		"aud":   pending.clientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": pending.nonce,
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	key := m.key
+	if m.signWith != nil {
+		key = m.signWith
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = m.kid
+	idToken, err := token.SignedString(key)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": idToken})
+}
+
+func (m *mockIssuer) provider() *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		Name:         "mock",
+		Issuer:       m.server.URL,
+		ClientID:     "movie-api",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://localhost:8080/api/auth/oidc/mock/callback",
+	}, m.server.Client())
+}
+
+// login runs the browser leg of the flow: it follows the authorization URL
+// and returns the code and state the provider redirects back with.
+func (m *mockIssuer) login(t *testing.T, p *oidc.Provider, state, nonce, challenge string) (code, returnedState string) {
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestOIDCAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	m := newMockIssuer(t)
+	p := m.provider()
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state := m.login(t, p, "state-1", "nonce-1", challenge)
+	if state != "state-1" {
+		t.Fatalf("state = %q, want state-1", state)
+	}
+	claims, err := p.Exchange(context.Background(), code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if claims.Subject != "user-123" || claims.Email != "jane@example.com" || !claims.EmailVerified {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestOIDCRejectsWrongCodeVerifier(t *testing.T) {
+	m := newMockIssuer(t)
+	p := m.provider()
+	_, challenge, _ := oidc.NewPKCE()
+	otherVerifier, _, _ := oidc.NewPKCE()
+
+	code, _ := m.login(t, p, "state", "nonce", challenge)
+	if _, err := p.Exchange(context.Background(), code, otherVerifier, "nonce"); err == nil {
+		t.Fatal("exchange with the wrong code verifier succeeded")
+	}
+}
+
+func TestOIDCRejectsNonceMismatch(t *testing.T) {
+	m := newMockIssuer(t)
+	p := m.provider()
+	verifier, challenge, _ := oidc.NewPKCE()
+
+	code, _ := m.login(t, p, "state", "nonce-sent", challenge)
+	_, err := p.Exchange(context.Background(), code, verifier, "nonce-expected")
+	if !errors.Is(err, oidc.ErrNonceMismatch) {
+		t.Fatalf("err = %v, want ErrNonceMismatch", err)
+	}
+}
+
+func TestOIDCRejectsForgedIDToken(t *testing.T) {
+	m := newMockIssuer(t)
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.signWith = forger
+	p := m.provider()
+	verifier, challenge, _ := oidc.NewPKCE()
+
+	code, _ := m.login(t, p, "state", "nonce", challenge)
+	_, err = p.Exchange(context.Background(), code, verifier, "nonce")
+	if !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestOIDCRejectsTokenForAnotherClient(t *testing.T) {
+	m := newMockIssuer(t)
+	m.claims["aud"] = "another-client"
+	p := m.provider()
+	verifier, challenge, _ := oidc.NewPKCE()
+
+	code, _ := m.login(t, p, "state", "nonce", challenge)
+	_, err := p.Exchange(context.Background(), code, verifier, "nonce")
+	if !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestOIDCEmailVerifiedAsString(t *testing.T) {
+	m := newMockIssuer(t)
+	m.claims["email_verified"] = "false"
+	p := m.provider()
+	verifier, challenge, _ := oidc.NewPKCE()
+
+	code, _ := m.login(t, p, "state", "nonce", challenge)
+	claims, err := p.Exchange(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.EmailVerified {
+		t.Fatal("email_verified \"false\" decoded as true")
+	}
+}
+
+func TestOIDCKeyFetchDoesNotBlockDiscover(t *testing.T) {
+	m := newMockIssuer(t)
+	p := m.provider()
+	ctx := context.Background()
+	if _, err := p.Discover(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	m.jwksHeld = make(chan struct{})
+	m.jwksRequested = make(chan struct{}, 1)
+	// Release the held request before the server is closed, even on failure
+	release := sync.OnceFunc(func() { close(m.jwksHeld) })
+	t.Cleanup(release)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": m.server.URL,
+		"aud": "movie-api",
+		"sub": "user-123",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = m.kid
+	raw, err := token.SignedString(m.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified := make(chan error, 1)
+	go func() {
+		_, err := p.VerifyIDToken(ctx, raw, "")
+		verified <- err
+	}()
+	<-m.jwksRequested
+
+	discovered := make(chan error, 1)
+	go func() {
+		_, err := p.AuthCodeURL(ctx, "state", "nonce", "challenge")
+		discovered <- err
+	}()
+	select {
+	case err := <-discovered:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("AuthCodeURL waited for the signing key fetch")
+	}
+
+	release()
+	if err := <-verified; err != nil {
+		t.Fatal(err)
+	}
+}
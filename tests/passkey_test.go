@@ -0,0 +1,336 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+
+	"eskalate-movie-api/internal/webauthn"
+)
+
+// softAuthenticator is a software passkey authenticator: it creates
+// credentials and signs assertions the way a browser and platform
+// authenticator would, so ceremonies can be driven end to end in tests.
+type softAuthenticator struct {
+	t          *testing.T
+	origin     string
+	rpID       string
+	ecKey      *ecdsa.PrivateKey
+	edKey      ed25519.PrivateKey
+	credID     []byte
+	userHandle []byte
+	signCount  uint32
+	// verified controls the user verified flag
+	verified bool
+}
+
+func newSoftAuthenticator(t *testing.T, origin, rpID string) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credID := make([]byte, 16)
+	rand.Read(credID)
+	return &softAuthenticator{t: t, origin: origin, rpID: rpID, ecKey: key, credID: credID, verified: true}
+}
+
+func (a *softAuthenticator) clientData(typ string, challenge []byte) []byte {
+	data, _ := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    a.origin,
+	})
+	return data
+}
+
+func (a *softAuthenticator) authData(attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	flags := byte(0x01)
+	if a.verified {
+		flags |= 0x04
+	}
+	if attested != nil {
+		flags |= 0x40
+	}
+	data := append([]byte(nil), rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	if a.edKey != nil {
+		return cborEncode(map[interface{}]interface{}{1: 1, 3: -8, -1: 6, -2: []byte(a.edKey.Public().(ed25519.PublicKey))})
+	}
+	x := a.ecKey.X.FillBytes(make([]byte, 32))
+	y := a.ecKey.Y.FillBytes(make([]byte, 32))
+	return cborEncode(map[interface{}]interface{}{1: 2, 3: -7, -1: 1, -2: x, -3: y})
+}
+
+// create answers CreationOptions with a new credential and "none" attestation.
+func (a *softAuthenticator) create(opts webauthn.CreationOptions) *webauthn.AttestationResponse {
+	a.userHandle = opts.User.ID
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credID)))
+	attested = append(attested, a.credID...)
+	attested = append(attested, a.coseKey()...)
+	attestation := cborEncode(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": a.authData(attested),
+	})
+
+	// Round trip through JSON as a browser client would send it
+	var resp webauthn.AttestationResponse
+	a.roundTrip(map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(a.credID),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.credID),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(a.clientData("webauthn.create", opts.Challenge)),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attestation),
+			"transports":        []string{"internal"},
+		},
+	}, &resp)
+	return &resp
+}
+
+// get answers RequestOptions with an assertion, bumping the signature counter.
+func (a *softAuthenticator) get(opts webauthn.RequestOptions) *webauthn.AssertionResponse {
+	a.signCount++
+	authData := a.authData(nil)
+	clientData := a.clientData("webauthn.get", opts.Challenge)
+	hash := sha256.Sum256(clientData)
+	signed := append(append([]byte(nil), authData...), hash[:]...)
+	var sig []byte
+	if a.edKey != nil {
+		sig = ed25519.Sign(a.edKey, signed)
+	} else {
+		digest := sha256.Sum256(signed)
+		var err error
+		if sig, err = ecdsa.SignASN1(rand.Reader, a.ecKey, digest[:]); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+	var resp webauthn.AssertionResponse
+	a.roundTrip(map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(a.credID),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.credID),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(sig),
+			"userHandle":        base64.RawURLEncoding.EncodeToString(a.userHandle),
+		},
+	}, &resp)
+	return &resp
+}
+
+func (a *softAuthenticator) roundTrip(in, out interface{}) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		a.t.Fatal(err)
+	}
+}
+
+// cborEncode encodes the subset of CBOR authenticators use: integers, byte
+// and text strings, and maps, with map keys in canonical order.
+func cborEncode(v interface{}) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n <= 0xff:
+			return []byte{major<<5 | 24, byte(n)}
+		case n <= 0xffff:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		default:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		}
+	}
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case map[interface{}]interface{}:
+		entries := make([][2][]byte, 0, len(v))
+		for k, val := range v {
+			entries = append(entries, [2][]byte{cborEncode(k), cborEncode(val)})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			a, b := entries[i][0], entries[j][0]
+			if len(a) != len(b) {
+				return len(a) < len(b)
+			}
+			return bytes.Compare(a, b) < 0
+		})
+		out := head(5, uint64(len(v)))
+		for _, e := range entries {
+			out = append(append(out, e[0]...), e[1]...)
+		}
+		return out
+	}
+	panic("cborEncode: unsupported type")
+}
+
+func testRelyingParty() *webauthn.RelyingParty {
+	return &webauthn.RelyingParty{ID: "localhost", Name: "Test", Origins: []string{"http://localhost:8080"}}
+}
+
+// register runs a registration ceremony for a and returns the credential.
+func register(t *testing.T, rp *webauthn.RelyingParty, a *softAuthenticator) *webauthn.Credential {
+	t.Helper()
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := rp.CreationOptions(challenge, webauthn.UserEntity{ID: []byte("user-handle-1234"), Name: "jane@example.com", DisplayName: "jane"}, nil)
+	cred, err := rp.VerifyRegistration(a.create(opts), challenge, false)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	return cred
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	rp := testRelyingParty()
+	a := newSoftAuthenticator(t, "http://localhost:8080", "localhost")
+	cred := register(t, rp, a)
+	if !bytes.Equal(cred.ID, a.credID) || cred.Algorithm != webauthn.AlgES256 {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+	if len(cred.Transports) != 1 || cred.Transports[0] != "internal" {
+		t.Fatalf("transports = %v", cred.Transports)
+	}
+
+	for i := 0; i < 2; i++ {
+		challenge, _ := webauthn.NewChallenge()
+		opts := rp.RequestOptions(challenge, nil, webauthn.VerificationRequired)
+		signCount, err := rp.VerifyAssertion(a.get(opts), challenge, cred, a.userHandle, true)
+		if err != nil {
+			t.Fatalf("VerifyAssertion: %v", err)
+		}
+		if signCount != a.signCount {
+			t.Fatalf("sign count = %d, want %d", signCount, a.signCount)
+		}
+		cred.SignCount = signCount
+	}
+}
+
+func TestPasskeyEd25519(t *testing.T) {
+	rp := testRelyingParty()
+	a := newSoftAuthenticator(t, "http://localhost:8080", "localhost")
+	_, a.edKey, _ = ed25519.GenerateKey(rand.Reader)
+	cred := register(t, rp, a)
+	if cred.Algorithm != webauthn.AlgEdDSA {
+		t.Fatalf("algorithm = %d", cred.Algorithm)
+	}
+	challenge, _ := webauthn.NewChallenge()
+	if _, err := rp.VerifyAssertion(a.get(rp.RequestOptions(challenge, nil, "")), challenge, cred, nil, false); err != nil {
+		t.Fatalf("VerifyAssertion: %v", err)
+	}
+}
+
+func TestPasskeyRejectsWrongOriginAndRPID(t *testing.T) {
+	rp := testRelyingParty()
+	challenge, _ := webauthn.NewChallenge()
+	opts := rp.CreationOptions(challenge, webauthn.UserEntity{ID: []byte("u")}, nil)
+
+	phishing := newSoftAuthenticator(t, "https://evil.example", "localhost")
+	if _, err := rp.VerifyRegistration(phishing.create(opts), challenge, false); !errors.Is(err, webauthn.ErrOriginMismatch) {
+		t.Fatalf("wrong origin: err = %v", err)
+	}
+	otherRP := newSoftAuthenticator(t, "http://localhost:8080", "evil.example")
+	if _, err := rp.VerifyRegistration(otherRP.create(opts), challenge, false); !errors.Is(err, webauthn.ErrRPIDMismatch) {
+		t.Fatalf("wrong RP ID: err = %v", err)
+	}
+}
+
+func TestPasskeyRejectsWrongChallenge(t *testing.T) {
+	rp := testRelyingParty()
+	a := newSoftAuthenticator(t, "http://localhost:8080", "localhost")
+	cred := register(t, rp, a)
+
+	issued, _ := webauthn.NewChallenge()
+	other, _ := webauthn.NewChallenge()
+	resp := a.get(rp.RequestOptions(other, nil, ""))
+	if _, err := rp.VerifyAssertion(resp, issued, cred, nil, false); !errors.Is(err, webauthn.ErrChallengeMismatch) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestPasskeyRejectsBadSignature(t *testing.T) {
+	rp := testRelyingParty()
+	a := newSoftAuthenticator(t, "http://localhost:8080", "localhost")
+	cred := register(t, rp, a)
+
+	// Another authenticator claiming the same credential ID
+	impostor := newSoftAuthenticator(t, "http://localhost:8080", "localhost")
+	impostor.credID = a.credID
+	challenge, _ := webauthn.NewChallenge()
+	resp := impostor.get(rp.RequestOptions(challenge, nil, ""))
+	if _, err := rp.VerifyAssertion(resp, challenge, cred, nil, false); !errors.Is(err, webauthn.ErrBadSignature) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestPasskeyRejectsSignCountRegression(t *testing.T) {
+	rp := testRelyingParty()
+	a := newSoftAuthenticator(t, "http://localhost:8080", "localhost")
+	cred := register(t, rp, a)
+	cred.SignCount = 10
+
+	challenge, _ := webauthn.NewChallenge()
+	resp := a.get(rp.RequestOptions(challenge, nil, ""))
+	if _, err := rp.VerifyAssertion(resp, challenge, cred, nil, false); !errors.Is(err, webauthn.ErrSignCountRegression) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestPasskeyRequiresUserVerification(t *testing.T) {
+	rp := testRelyingParty()
+	a := newSoftAuthenticator(t, "http://localhost:8080", "localhost")
+	cred := register(t, rp, a)
+	a.verified = false
+
+	challenge, _ := webauthn.NewChallenge()
+	resp := a.get(rp.RequestOptions(challenge, nil, webauthn.VerificationRequired))
+	if _, err := rp.VerifyAssertion(resp, challenge, cred, nil, true); !errors.Is(err, webauthn.ErrUserNotVerified) {
+		t.Fatalf("err = %v", err)
+	}
+	// The same assertion is enough as a second factor
+	if _, err := rp.VerifyAssertion(resp, challenge, cred, nil, false); err != nil {
+		t.Fatalf("without UV required: %v", err)
+	}
+}
+
+func TestPasskeyRejectsOtherUsersHandle(t *testing.T) {
+	rp := testRelyingParty()
+	a := newSoftAuthenticator(t, "http://localhost:8080", "localhost")
+	cred := register(t, rp, a)
+
+	challenge, _ := webauthn.NewChallenge()
+	resp := a.get(rp.RequestOptions(challenge, nil, ""))
+	if _, err := rp.VerifyAssertion(resp, challenge, cred, []byte("someone-else"), false); !errors.Is(err, webauthn.ErrUserHandleMismatch) {
+		t.Fatalf("err = %v", err)
+	}
+}
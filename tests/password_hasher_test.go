@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestArgon2idHashFormat(t *testing.T) {
+	hasher := services.NewArgon2idHasher(services.Argon2Params{Memory: 1024, Iterations: 2, Parallelism: 1})
+	hash, err := hasher.Hash("s3cret-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=2,p=1$") {
+		t.Fatalf("hash = %q, want PHC format with the configured parameters", hash)
+	}
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		t.Fatalf("hash has %d fields, want 6", len(parts))
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) != 16 {
+		t.Errorf("salt = %d bytes, %v; want 16 by default", len(salt), err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) != 32 {
+		t.Errorf("key = %d bytes, %v; want 32 by default", len(key), err)
+	}
+
+	again, err := hasher.Hash("s3cret-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == hash {
+		t.Error("two hashes of the same password are equal; the salt is not random")
+	}
+	if !hasher.Verify(hash, "s3cret-pass") || !hasher.Verify(again, "s3cret-pass") {
+		t.Error("Verify rejected the right password")
+	}
+	if hasher.Verify(hash, "s3cret-Pass") {
+		t.Error("Verify accepted a wrong password")
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Error("NeedsRehash is true for a hash with the current parameters")
+	}
+}
+
+func TestArgon2idVerifiesWithEncodedParameters(t *testing.T) {
+	old := services.NewArgon2idHasher(services.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 16})
+	current := services.NewArgon2idHasher(services.Argon2Params{Memory: 2048, Iterations: 2, Parallelism: 2})
+	hash, err := old.Hash("s3cret-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !current.Verify(hash, "s3cret-pass") {
+		t.Fatal("hash made with other parameters not verified")
+	}
+	if !current.NeedsRehash(hash) {
+		t.Error("NeedsRehash is false for a hash made with other parameters")
+	}
+
+	// Each parameter on its own calls for a new hash
+	for name, params := range map[string]services.Argon2Params{
+		"memory":      {Memory: 2048, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 16},
+		"iterations":  {Memory: 1024, Iterations: 2, Parallelism: 1, SaltLength: 8, KeyLength: 16},
+		"parallelism": {Memory: 1024, Iterations: 1, Parallelism: 2, SaltLength: 8, KeyLength: 16},
+		"salt length": {Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 16},
+		"key length":  {Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 32},
+	} {
+		if !services.NewArgon2idHasher(params).NeedsRehash(hash) {
+			t.Errorf("changed %s: NeedsRehash is false", name)
+		}
+	}
+}
+
+func TestArgon2idRejectsMalformedHashes(t *testing.T) {
+	hasher := testHasher()
+	hash, err := hasher.Hash("s3cret-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(hash, "$")
+	malformed := map[string]string{
+		"empty":          "",
+		"argon2i":        strings.Replace(hash, "$argon2id$", "$argon2i$", 1),
+		"other version":  strings.Replace(hash, "$v=19$", "$v=16$", 1),
+		"zero memory":    strings.Replace(hash, "m=1024", "m=0", 1),
+		"bad parameters": strings.Replace(hash, parts[3], "m=1024", 1),
+		"bad salt":       strings.Replace(hash, parts[4], "not base64!", 1),
+		"no key":         strings.TrimSuffix(hash, parts[5]),
+		"extra field":    hash + "$x",
+	}
+	for name, bad := range malformed {
+		if hasher.Verify(bad, "s3cret-pass") {
+			t.Errorf("%s: Verify accepted %q", name, bad)
+		}
+		if !hasher.NeedsRehash(bad) {
+			t.Errorf("%s: NeedsRehash is false", name)
+		}
+	}
+}
+
+func TestArgon2idVerifiesLegacyBcrypt(t *testing.T) {
+	hasher := testHasher()
+	legacy, err := bcrypt.GenerateFromPassword([]byte("s3cret-pass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasher.Verify(string(legacy), "s3cret-pass") {
+		t.Fatal("bcrypt hash not verified")
+	}
+	if hasher.Verify(string(legacy), "wrong-pass") {
+		t.Error("bcrypt hash verified with a wrong password")
+	}
+	if !hasher.NeedsRehash(string(legacy)) {
+		t.Error("NeedsRehash is false for a bcrypt hash")
+	}
+}
+
+// storedPassword returns the password hash stored for user.
+func (f *authFixture) storedPassword(t *testing.T, user *models.User) string {
+	t.Helper()
+	var stored models.User
+	if err := f.db.First(&stored, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return stored.Password
+}
+
+func TestLoginUpgradesOutdatedHashes(t *testing.T) {
+	f := newAuthFixture(t)
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weaker, err := services.NewArgon2idHasher(services.Argon2Params{Memory: 512, Iterations: 1, Parallelism: 1}).Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, hash := range map[string]string{"bcrypt": string(bcryptHash), "weaker argon2id": weaker} {
+		user := f.createUser(t, strings.ReplaceAll(name, " ", "-")+"@example.org")
+		if err := f.db.Model(user).Update("password", hash).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		f.login(t, user, testClient)
+		upgraded := f.storedPassword(t, user)
+		if upgraded == hash || f.hasher.NeedsRehash(upgraded) {
+			t.Errorf("%s: hash not upgraded after login: %q", name, upgraded)
+		}
+		if !f.hasher.Verify(upgraded, testPassword) {
+			t.Errorf("%s: upgraded hash does not verify the password", name)
+		}
+		// The upgraded hash keeps working and is left alone
+		f.login(t, user, testClient)
+		if got := f.storedPassword(t, user); got != upgraded {
+			t.Errorf("%s: current hash replaced on the next login", name)
+		}
+	}
+}
+
+func TestFailedLoginKeepsOutdatedHash(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "legacy@example.org")
+	legacy, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.db.Model(user).Update("password", string(legacy)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.auth.LoginWithRefresh(user.Email, "not-the-password", testClient); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+	}
+	if got := f.storedPassword(t, user); got != string(legacy) {
+		t.Error("hash replaced after a failed login")
+	}
+}
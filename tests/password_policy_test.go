@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"eskalate-movie-api/internal/passwordpolicy"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := passwordpolicy.Policy{
+		MinLength:        10,
+		MaxLength:        20,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		DisallowIdentity: true,
+	}
+	id := passwordpolicy.Identity{Username: "moviebuff", Email: "jane.doe@example.org"}
+
+	if v := policy.Check("Correct-Horse-42", id); v.Err() != nil {
+		t.Fatalf("valid password rejected: %v", v)
+	}
+	// Letters outside ASCII count, and length is in characters
+	if v := policy.Check("Ünïcødé-pässwörd1", id); v.Err() != nil {
+		t.Fatalf("unicode password rejected: %v", v)
+	}
+
+	cases := map[string]struct {
+		password string
+		want     []error
+	}{
+		"short":         {"Ab1!", []error{passwordpolicy.ErrTooShort}},
+		"long":          {"Abcdefghij1!abcdefghij", []error{passwordpolicy.ErrTooLong}},
+		"no uppercase":  {"correct-horse-42", []error{passwordpolicy.ErrNoUppercase}},
+		"no lowercase":  {"CORRECT-HORSE-42", []error{passwordpolicy.ErrNoLowercase}},
+		"no digit":      {"Correct-Horse-xx", []error{passwordpolicy.ErrNoDigit}},
+		"no symbol":     {"CorrectHorse42", []error{passwordpolicy.ErrNoSymbol}},
+		"username":      {"I-am-a-MovieBuff-1", []error{passwordpolicy.ErrContainsUsername}},
+		"email":         {"Jane.Doe-rules-1", []error{passwordpolicy.ErrContainsEmail}},
+		"several rules": {"moviebuff", []error{passwordpolicy.ErrTooShort, passwordpolicy.ErrNoUppercase, passwordpolicy.ErrNoDigit, passwordpolicy.ErrNoSymbol, passwordpolicy.ErrContainsUsername}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			v := policy.Check(c.password, id)
+			if len(v) != len(c.want) {
+				t.Fatalf("violations = %v, want %v", v, c.want)
+			}
+			for _, want := range c.want {
+				if !errors.Is(v, want) {
+					t.Errorf("violations %v do not include %v", v, want)
+				}
+			}
+		})
+	}
+
+	// Short usernames are not looked for, and the rule can be turned off
+	if v := policy.Check("Correct-Joe-42", passwordpolicy.Identity{Username: "jo", Email: "jo@example.org"}); v.Err() != nil {
+		t.Fatalf("short identity rejected: %v", v)
+	}
+	policy.DisallowIdentity = false
+	if v := policy.Check("I-am-a-MovieBuff-1", id); v.Err() != nil {
+		t.Fatalf("identity checked while disabled: %v", v)
+	}
+}
+
+// Hashes of "password" and "P@ssw0rd" in the Pwned Passwords format
+const (
+	passwordSHA1 = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"
+	pAssw0rdSHA1 = "21BD12DC183F740EE76F27B78EB39C8AD972A757"
+)
+
+func TestBreachListRangeDirectory(t *testing.T) {
+	dir := t.TempDir()
+	ranges := map[string]string{
+		// Padding entries have a count of 0 and are not breached passwords
+		passwordSHA1[:5] + ".txt": "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n" + passwordSHA1[5:] + ":9545824\r\n",
+		pAssw0rdSHA1[:5]:          pAssw0rdSHA1[5:] + ":0\n",
+	}
+	for name, content := range ranges {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := passwordpolicy.OpenBreachList(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for password, want := range map[string]bool{"password": true, "P@ssw0rd": false, "Correct-Horse-42": false} {
+		if got, err := list.Contains(password); err != nil || got != want {
+			t.Errorf("Contains(%q) = %v, %v, want %v", password, got, err, want)
+		}
+	}
+}
+
+func TestBreachListSortedFile(t *testing.T) {
+	lines := []string{
+		"000000005AD76BD555C1D6D771DE417A4B87E4B4:10",
+		"00000000A8DAE4228F821FB418F59826079BF368:4",
+		pAssw0rdSHA1 + ":52579",
+		"3D4F2BF07DC1BE38B20CD6E46949A1071F9D0E3D:1",
+		passwordSHA1 + ":9545824",
+		"FFFFFFFEE791CBAC0F6305CAF0CEE06BBE131160:2",
+	}
+	var content string
+	for _, line := range lines {
+		content += line + "\n"
+	}
+	path := filepath.Join(t.TempDir(), "pwned-passwords-sha1-ordered-by-hash.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	list, err := passwordpolicy.OpenBreachList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for password, want := range map[string]bool{"password": true, "P@ssw0rd": true, "Correct-Horse-42": false, "": false} {
+		if got, err := list.Contains(password); err != nil || got != want {
+			t.Errorf("Contains(%q) = %v, %v, want %v", password, got, err, want)
+		}
+	}
+
+	if _, err := passwordpolicy.OpenBreachList(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("missing breach list opened")
+	}
+}
+
+func TestPasswordPolicyConfig(t *testing.T) {
+	t.Setenv("PASSWORD_HISTORY", "0")
+	t.Setenv("PASSWORD_MIN_LENGTH", "16")
+	t.Setenv("PASSWORD_MAX_LENGTH", "12")
+	policy := loadConfig(t).PasswordPolicy
+	if policy.History != 0 {
+		t.Errorf("History = %d, want 0", policy.History)
+	}
+	// A maximum below the minimum would reject every password
+	if policy.MinLength != 16 || policy.MaxLength != 16 {
+		t.Errorf("lengths = %d-%d, want 16-16", policy.MinLength, policy.MaxLength)
+	}
+	if !policy.RequireUppercase || !policy.RequireLowercase || policy.RequireDigit || !policy.RequireSymbol || !policy.DisallowIdentity {
+		t.Errorf("default character rules = %+v", policy)
+	}
+}
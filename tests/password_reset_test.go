@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/passwordpolicy"
+	"eskalate-movie-api/internal/services"
+)
+
+// requestReset asks for a password reset and returns the token of the
+// message, which is sent in the background.
+func (f *authFixture) requestReset(t *testing.T, email string) string {
+	t.Helper()
+	before := len(f.mail.sentTo(email))
+	if err := f.auth.RequestPasswordReset(email); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if len(f.mail.sentTo(email)) > before {
+			return f.lastTokenSentTo(t, email)
+		}
+	}
+	t.Fatalf("no reset email sent to %s", email)
+	return ""
+}
+
+func TestResetPassword(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "forgetful@example.org")
+	access, refresh := f.login(t, user, testClient)
+	token := f.requestReset(t, user.Email)
+
+	if err := f.auth.ResetPassword(token, "Brand-New-Pass-9", testClient); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.auth.LoginWithRefresh(user.Email, testPassword, testClient); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Errorf("old password: err = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := f.auth.LoginWithRefresh(user.Email, "Brand-New-Pass-9", testClient); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+
+	// Every session is signed out
+	if _, _, err := f.auth.RefreshAccessToken(refresh, testClient); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("refresh token after reset: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if f.authorized(t, access) {
+		t.Error("access token accepted after reset")
+	}
+
+	if err := f.auth.ResetPassword(token, "Another-New-Pass-8", testClient); !errors.Is(err, services.ErrInvalidActionToken) {
+		t.Errorf("token used twice: err = %v, want ErrInvalidActionToken", err)
+	}
+	var events int64
+	if err := f.db.Model(&models.AuditEvent{}).Where("action = ? AND target_id = ?", models.AuditPasswordReset, user.ID).
+		Count(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if events != 1 {
+		t.Errorf("%d resets audited, want 1", events)
+	}
+}
+
+func TestResetPasswordEnforcesPolicy(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "weak@example.org")
+	token := f.requestReset(t, user.Email)
+
+	if err := f.auth.ResetPassword(token, "Short-1", testClient); !errors.Is(err, passwordpolicy.ErrTooShort) {
+		t.Fatalf("short password: err = %v, want ErrTooShort", err)
+	}
+	f.login(t, user, testClient)
+
+	// The rejected attempt leaves the token usable
+	if err := f.auth.ResetPassword(token, "Brand-New-Pass-9", testClient); err != nil {
+		t.Errorf("token after a rejected password: %v", err)
+	}
+}
+
+func TestPasswordResetRequestInvalidatesEarlierTokens(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "twice@example.org")
+	first := f.requestReset(t, user.Email)
+	second := f.requestReset(t, user.Email)
+
+	if err := f.auth.ResetPassword(first, "Brand-New-Pass-9", testClient); !errors.Is(err, services.ErrInvalidActionToken) {
+		t.Errorf("earlier token: err = %v, want ErrInvalidActionToken", err)
+	}
+	if err := f.auth.ResetPassword(second, "Brand-New-Pass-9", testClient); err != nil {
+		t.Errorf("latest token: %v", err)
+	}
+}
+
+func TestPasswordResetRejectsInvalidTokens(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "expired-reset@example.org")
+	expired := f.requestReset(t, user.Email)
+	if err := f.db.Model(&models.ActionToken{}).Where("user_id = ?", user.ID).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	pending, err := f.signup("pending-reset", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{
+		"expired":            expired,
+		"email verification": f.lastTokenSentTo(t, pending.Email),
+		"garbage":            "not-a-token",
+		"empty":              "",
+	} {
+		if err := f.auth.ResetPassword(token, "Brand-New-Pass-9", testClient); !errors.Is(err, services.ErrInvalidActionToken) {
+			t.Errorf("%s token: err = %v, want ErrInvalidActionToken", name, err)
+		}
+	}
+	f.login(t, user, testClient)
+}
+
+func TestPasswordResetForUnknownEmail(t *testing.T) {
+	f := newAuthFixture(t)
+	if err := f.auth.RequestPasswordReset("nobody@example.org"); err != nil {
+		t.Errorf("unknown address: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if sent := f.mail.sentTo("nobody@example.org"); len(sent) != 0 {
+		t.Errorf("%d messages sent to an unknown address", len(sent))
+	}
+}
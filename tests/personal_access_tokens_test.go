@@ -0,0 +1,265 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/middleware"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/revocation"
+	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// staticTokens accepts the personal access tokens it maps to identities.
+type staticTokens map[string]*tokens.Identity
+
+func (s staticTokens) Authenticate(token string) (*tokens.Identity, error) {
+	if identity, ok := s[token]; ok {
+		return identity, nil
+	}
+	return nil, services.ErrInvalidPersonalAccessToken
+}
+
+func TestAuthMiddlewareAcceptsPersonalAccessTokens(t *testing.T) {
+	identity := &tokens.Identity{TokenID: uuid.New(), UserID: uuid.New(), Scopes: []string{models.ScopeMoviesWrite}}
+	mw := middleware.AuthMiddleware(newTestKeyRing(t), staticTokens{"pat_known.secret": identity}, revocation.NewMemoryStore())
+
+	var principal *middleware.Principal
+	handler := func(c *gin.Context) {
+		mw(c)
+		principal, _ = middleware.CurrentPrincipal(c)
+	}
+	if w := serve(handler, newRequest(http.MethodGet, "Bearer pat_known.secret")); w.Code != http.StatusOK {
+		t.Fatalf("known token: status = %d", w.Code)
+	}
+	if principal == nil || principal.UserID != identity.UserID || principal.TokenID != identity.TokenID ||
+		!principal.ViaPersonalAccessToken() || !principal.HasScope(models.ScopeMoviesWrite) {
+		t.Errorf("principal = %+v, want the token's identity", principal)
+	}
+	if w := serve(mw, newRequest(http.MethodGet, "Bearer pat_unknown.secret")); w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown token: status = %d, want 401", w.Code)
+	}
+}
+
+func TestRequireScopeAndSession(t *testing.T) {
+	with := func(p *middleware.Principal, check gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			middleware.SetPrincipal(c, p)
+			check(c)
+		}
+	}
+	session := &middleware.Principal{UserID: uuid.New(), SessionID: uuid.New()}
+	scoped := &middleware.Principal{UserID: uuid.New(), TokenID: uuid.New(), Scopes: []string{models.ScopeMoviesWrite}}
+	unscoped := &middleware.Principal{UserID: uuid.New(), TokenID: uuid.New()}
+
+	cases := map[string]struct {
+		principal *middleware.Principal
+		check     gin.HandlerFunc
+		want      int
+	}{
+		"session within scope check": {session, middleware.RequireScope(models.ScopeMoviesWrite), http.StatusOK},
+		"token with the scope":       {scoped, middleware.RequireScope(models.ScopeMoviesWrite), http.StatusOK},
+		"token with another scope":   {scoped, middleware.RequireScope(models.ScopeSCIM), http.StatusForbidden},
+		"token without scopes":       {unscoped, middleware.RequireScope(models.ScopeMoviesWrite), http.StatusForbidden},
+		"session on session route":   {session, middleware.RequireSession(), http.StatusOK},
+		"token on session route":     {scoped, middleware.RequireSession(), http.StatusForbidden},
+	}
+	for name, c := range cases {
+		if w := serve(with(c.principal, c.check), newRequest(http.MethodPost, "")); w.Code != c.want {
+			t.Errorf("%s: status = %d, want %d", name, w.Code, c.want)
+		}
+	}
+	if w := serve(middleware.RequireScope(models.ScopeMoviesWrite), newRequest(http.MethodPost, "")); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous: status = %d, want 401", w.Code)
+	}
+}
+
+func TestCreatePersonalAccessToken(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "automation@example.org")
+	pats := services.NewPersonalAccessTokenService(f.users, f.db, f.cfg)
+
+	record, token, err := pats.Create(user.ID, "  movie sync  ", []string{models.ScopeMoviesWrite, models.ScopeMoviesWrite}, 30*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, tokens.PersonalAccessTokenScheme) {
+		t.Errorf("token %q lacks the scheme prefix", token)
+	}
+	if record.Name != "movie sync" || strings.Join(record.Scopes, " ") != models.ScopeMoviesWrite {
+		t.Errorf("record = %+v", record)
+	}
+
+	var stored models.PersonalAccessToken
+	if err := f.db.First(&stored, "id = ?", record.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, secret, _ := strings.Cut(strings.TrimPrefix(token, tokens.PersonalAccessTokenScheme), ".")
+	if stored.TokenHash == "" || strings.Contains(stored.TokenHash, secret) {
+		t.Errorf("stored hash %q exposes the token", stored.TokenHash)
+	}
+
+	cases := map[string]struct {
+		scopes []string
+		ttl    time.Duration
+		want   error
+	}{
+		"unknown scope":   {[]string{"movies:delete"}, time.Hour, services.ErrInvalidScope},
+		"no expiry":       {nil, 0, services.ErrInvalidTokenLifetime},
+		"past expiry":     {nil, -time.Hour, services.ErrInvalidTokenLifetime},
+		"over a year":     {nil, 366 * 24 * time.Hour, services.ErrInvalidTokenLifetime},
+		"without scopes":  {nil, time.Hour, nil},
+		"every scope":     {models.ValidScopes, time.Hour, nil},
+		"exactly a year":  {nil, 365 * 24 * time.Hour, nil},
+		"scim and movies": {[]string{models.ScopeSCIM, models.ScopeMoviesWrite}, time.Hour, nil},
+	}
+	for name, c := range cases {
+		if _, _, err := pats.Create(user.ID, name, c.scopes, c.ttl); !errors.Is(err, c.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, c.want)
+		}
+	}
+}
+
+func TestAuthenticatePersonalAccessToken(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "script@example.org")
+	if err := f.db.Model(user).Update("role", models.RoleCurator).Error; err != nil {
+		t.Fatal(err)
+	}
+	pats := services.NewPersonalAccessTokenService(f.users, f.db, f.cfg)
+	record, token, err := pats.Create(user.ID, "sync", []string{models.ScopeMoviesWrite}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.LastUsedAt != nil {
+		t.Error("new token already marked as used")
+	}
+
+	identity, err := pats.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.UserID != user.ID || identity.TokenID != record.ID || !identity.EmailVerified ||
+		strings.Join(identity.Roles, ",") != models.RoleCurator || !contains(identity.Permissions, models.PermMoviesUpdateAny) ||
+		strings.Join(identity.Scopes, ",") != models.ScopeMoviesWrite {
+		t.Errorf("identity = %+v", identity)
+	}
+	listed, err := pats.List(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].LastUsedAt == nil {
+		t.Errorf("listed = %+v, want the token with its last use", listed)
+	}
+
+	for name, presented := range map[string]string{
+		"tampered":    token + "x",
+		"unknown":     tokens.PersonalAccessTokenScheme + "0123456789abcdef.c2VjcmV0",
+		"stored hash": listed[0].TokenHash,
+		"empty":       "",
+	} {
+		if _, err := pats.Authenticate(presented); !errors.Is(err, services.ErrInvalidPersonalAccessToken) {
+			t.Errorf("%s token: err = %v, want ErrInvalidPersonalAccessToken", name, err)
+		}
+	}
+}
+
+func TestPersonalAccessTokensStopWorking(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "stopped@example.org")
+	other := f.createUser(t, "other-owner@example.org")
+	pats := services.NewPersonalAccessTokenService(f.users, f.db, f.cfg)
+	create := func(owner *models.User) (*models.PersonalAccessToken, string) {
+		record, token, err := pats.Create(owner.ID, "sync", nil, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record, token
+	}
+
+	revoked, revokedToken := create(user)
+	if err := pats.Revoke(other.ID, revoked.ID); !errors.Is(err, services.ErrPersonalAccessTokenNotFound) {
+		t.Errorf("revoking another user's token: err = %v, want ErrPersonalAccessTokenNotFound", err)
+	}
+	if err := pats.Revoke(user.ID, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := pats.Revoke(user.ID, revoked.ID); !errors.Is(err, services.ErrPersonalAccessTokenNotFound) {
+		t.Errorf("revoking twice: err = %v, want ErrPersonalAccessTokenNotFound", err)
+	}
+
+	expired, expiredToken := create(user)
+	if err := f.db.Model(expired).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, disabledToken := create(other)
+	if err := f.db.Model(other).Update("disabled", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, validToken := create(user)
+
+	for name, token := range map[string]string{"revoked": revokedToken, "expired": expiredToken, "disabled owner": disabledToken} {
+		if _, err := pats.Authenticate(token); !errors.Is(err, services.ErrInvalidPersonalAccessToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidPersonalAccessToken", name, err)
+		}
+	}
+	if _, err := pats.Authenticate(validToken); err != nil {
+		t.Errorf("valid token: %v", err)
+	}
+
+	// Revoked and expired tokens stay listed, newest first
+	listed, err := pats.List(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 3 || listed[2].ID != revoked.ID || listed[2].RevokedAt == nil {
+		t.Errorf("listed = %+v", listed)
+	}
+}
+
+func TestPersonalAccessTokenRoutes(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "routes@example.org")
+	pats := services.NewPersonalAccessTokenService(f.users, f.db, f.cfg)
+	_, writer, err := pats.Create(user.ID, "writer", []string{models.ScopeMoviesWrite}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, reader, err := pats.Create(user.ID, "reader", nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRouter(f.db, f.cfg, f.keys, f.denylist)
+
+	cases := []struct {
+		name, method, path, token string
+		want                      int
+	}{
+		// The empty body is refused by the handler, past the middleware
+		{"scoped token creating a movie", http.MethodPost, "/api/movies/", writer, http.StatusBadRequest},
+		{"unscoped token creating a movie", http.MethodPost, "/api/movies/", reader, http.StatusForbidden},
+		{"token on the profile", http.MethodGet, "/api/users/me", writer, http.StatusForbidden},
+		{"token listing tokens", http.MethodGet, "/api/auth/tokens", writer, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		if status, body := call(t, r, c.method, c.path, c.token); status != c.want {
+			t.Errorf("%s: %d %+v, want %d", c.name, status, body, c.want)
+		}
+	}
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
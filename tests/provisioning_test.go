@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/services"
+)
+
+func TestProvisioningRoleRemovalEndsSessions(t *testing.T) {
+	f := newAuthFixture(t)
+	admin := f.createUser(t, "directory@example.org")
+	user := f.createUser(t, "grouped@example.org")
+	provisioning := services.NewProvisioningService(f.db, f.denylist)
+	access, refresh := f.login(t, user, testClient)
+
+	// Joining a group is a promotion and leaves the sessions alone
+	if err := provisioning.ReplaceGroup(adminActor(admin), adminClient, models.RoleCurator, []string{user.ID.String()}); err != nil {
+		t.Fatal(err)
+	}
+	if !f.authorized(t, access) {
+		t.Fatal("access token refused after a promotion")
+	}
+	access, refresh, err := f.auth.RefreshAccessToken(refresh, testClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := provisioning.ReplaceGroup(adminActor(admin), adminClient, models.RoleCurator, nil); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := f.users.FindByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Role != models.RoleMember {
+		t.Errorf("role after leaving the group = %s, want member", stored.Role)
+	}
+	if f.authorized(t, access) {
+		t.Error("access token of the curator role accepted after leaving the group")
+	}
+	if _, _, err := f.auth.RefreshAccessToken(refresh, testClient); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("refresh token after leaving the group: err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
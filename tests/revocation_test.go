@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/revocation"
+	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/tokens"
+
+	"github.com/google/uuid"
+)
+
+// testRevocationStore checks the behaviour every revocation.Store shares.
+func testRevocationStore(t *testing.T, store revocation.Store) {
+	t.Helper()
+	jti := uuid.NewString()
+	if revoked, err := store.IsRevoked(jti); err != nil || revoked {
+		t.Fatalf("unknown jti: revoked = %v, %v", revoked, err)
+	}
+	if err := store.Revoke(jti, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := store.IsRevoked(jti); err != nil || !revoked {
+		t.Fatalf("revoked jti: revoked = %v, %v", revoked, err)
+	}
+	if revoked, _ := store.IsRevoked(uuid.NewString()); revoked {
+		t.Error("another jti is revoked too")
+	}
+
+	// Revoking again with an earlier expiry must not shorten the entry
+	if err := store.Revoke(jti, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := store.IsRevoked(jti); err != nil || !revoked {
+		t.Errorf("after revoking again with an earlier expiry: revoked = %v, %v", revoked, err)
+	}
+
+	// Entries only matter until the token would have expired anyway
+	expired := uuid.NewString()
+	if err := store.Revoke(expired, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := store.IsRevoked(expired); err != nil || revoked {
+		t.Errorf("expired entry: revoked = %v, %v", revoked, err)
+	}
+}
+
+func TestMemoryRevocationStore(t *testing.T) {
+	testRevocationStore(t, revocation.NewMemoryStore())
+}
+
+func TestPostgresRevocationStore(t *testing.T) {
+	db := testDB(t)
+	testRevocationStore(t, revocation.NewPostgresStore(db))
+
+	// Instances sharing the database share the denylist
+	jti := uuid.NewString()
+	if err := revocation.NewPostgresStore(db).Revoke(jti, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := revocation.NewPostgresStore(db).IsRevoked(jti); err != nil || !revoked {
+		t.Errorf("jti revoked by another instance: revoked = %v, %v", revoked, err)
+	}
+}
+
+func TestRevocationStoreDriver(t *testing.T) {
+	if store, err := revocation.New("memory", nil); err != nil {
+		t.Error(err)
+	} else if _, ok := store.(*revocation.MemoryStore); !ok {
+		t.Errorf("memory driver returned %T", store)
+	}
+	for _, driver := range []string{"", "postgres"} {
+		if store, err := revocation.New(driver, nil); err != nil {
+			t.Error(err)
+		} else if _, ok := store.(*revocation.PostgresStore); !ok {
+			t.Errorf("driver %q returned %T", driver, store)
+		}
+	}
+	if _, err := revocation.New("redis", nil); err == nil {
+		t.Error("unknown driver accepted")
+	}
+}
+
+func TestAuthMiddlewareRejectsRevokedToken(t *testing.T) {
+	ring := newTestKeyRing(t)
+	denylist := revocation.NewMemoryStore()
+	mw := newAuthMiddleware(ring, denylist)
+
+	token, claims := signAccessToken(t, ring)
+	if w := serve(mw, newRequest(http.MethodGet, "Bearer "+token)); w.Code != http.StatusOK {
+		t.Fatalf("before revocation: status = %d", w.Code)
+	}
+	if err := denylist.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(mw, newRequest(http.MethodGet, "Bearer "+token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("after revocation: status = %d, want 401", w.Code)
+	}
+	other, _ := signAccessToken(t, ring)
+	if w := serve(mw, newRequest(http.MethodGet, "Bearer "+other)); w.Code != http.StatusOK {
+		t.Errorf("another token: status = %d", w.Code)
+	}
+}
+
+// authorized reports whether AuthMiddleware, checking the fixture's denylist,
+// accepts the access token.
+func (f *authFixture) authorized(t *testing.T, access string) bool {
+	t.Helper()
+	w := serve(newAuthMiddleware(f.keys, f.denylist), newRequest(http.MethodGet, "Bearer "+access))
+	switch w.Code {
+	case http.StatusOK:
+		return true
+	case http.StatusUnauthorized:
+		return false
+	}
+	t.Fatalf("status = %d", w.Code)
+	return false
+}
+
+// sessionID returns the session an access token was issued for.
+func (f *authFixture) sessionID(t *testing.T, access string) uuid.UUID {
+	t.Helper()
+	var claims tokens.AccessClaims
+	if _, err := f.keys.Parse(access, &claims); err != nil {
+		t.Fatal(err)
+	}
+	id, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "logout@example.org")
+	access, refresh := f.login(t, user, testClient)
+	other, _ := f.login(t, user, testClient)
+	if !f.authorized(t, access) {
+		t.Fatal("access token rejected before logout")
+	}
+
+	if err := f.auth.RevokeRefreshToken(refresh, testClient); err != nil {
+		t.Fatal(err)
+	}
+	if f.authorized(t, access) {
+		t.Error("access token accepted after logout")
+	}
+	if !f.authorized(t, other) {
+		t.Error("logout ended another session")
+	}
+}
+
+func TestRefreshedAccessTokensAreRevokedAtLogout(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "rotated@example.org")
+	first, refresh := f.login(t, user, testClient)
+	second, refresh, err := f.auth.RefreshAccessToken(refresh, testClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.auth.RevokeRefreshToken(refresh, testClient); err != nil {
+		t.Fatal(err)
+	}
+	for name, access := range map[string]string{"first": first, "refreshed": second} {
+		if f.authorized(t, access) {
+			t.Errorf("%s access token accepted after logout", name)
+		}
+	}
+}
+
+func TestPasswordChangeRevokesOtherSessions(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "change@example.org")
+	current, _ := f.login(t, user, testClient)
+	other, _ := f.login(t, user, testClient)
+
+	if err := f.auth.ChangePassword(user.ID, testPassword, "Another-Horse-77", f.sessionID(t, current), testClient); err != nil {
+		t.Fatal(err)
+	}
+	if f.authorized(t, other) {
+		t.Error("other session's access token accepted after a password change")
+	}
+	if !f.authorized(t, current) {
+		t.Error("the session that changed the password was signed out")
+	}
+}
+
+func TestForceLogoutRevokesAccessTokens(t *testing.T) {
+	f := newAuthFixture(t)
+	admin := f.createUser(t, "admin@example.org")
+	user := f.createUser(t, "forced@example.org")
+	first, _ := f.login(t, user, testClient)
+	second, _ := f.login(t, user, testClient)
+	own, _ := f.login(t, admin, testClient)
+
+	actor := services.Actor{UserID: admin.ID, Permissions: []string{models.PermUsersManage}}
+	ended, err := services.NewAdminUserService(f.users, f.db, f.denylist).ForceLogout(actor, testClient, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ended != 2 {
+		t.Errorf("sessions ended = %d, want 2", ended)
+	}
+	for name, access := range map[string]string{"first": first, "second": second} {
+		if f.authorized(t, access) {
+			t.Errorf("%s session's access token accepted after force logout", name)
+		}
+	}
+	if !f.authorized(t, own) {
+		t.Error("force logout ended the administrator's session")
+	}
+}
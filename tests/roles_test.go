@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"eskalate-movie-api/internal/middleware"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/repository"
+	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestEffectivePermissions(t *testing.T) {
+	cases := []struct {
+		role   string
+		grants []string
+		want   []string
+	}{
+		{models.RoleMember, nil, []string{models.PermMoviesCreate}},
+		{models.RoleCurator, nil, []string{models.PermMoviesCreate, models.PermMoviesDeleteAny, models.PermMoviesUpdateAny}},
+		{models.RoleAdmin, nil, []string{models.PermMoviesCreate, models.PermMoviesDeleteAny, models.PermMoviesUpdateAny, models.PermUsersManage}},
+		{models.RoleMember, []string{models.PermMoviesDeleteAny, models.PermMoviesCreate}, []string{models.PermMoviesCreate, models.PermMoviesDeleteAny}},
+		{"unknown", []string{models.PermMoviesCreate}, []string{models.PermMoviesCreate}},
+	}
+	for _, c := range cases {
+		got := models.EffectivePermissions(c.role, c.grants)
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("EffectivePermissions(%s, %v) = %v, want %v", c.role, c.grants, got, c.want)
+		}
+	}
+
+	// Grants must not leak into the role's shared permission list
+	models.EffectivePermissions(models.RoleMember, []string{models.PermUsersManage})
+	if len(models.RolePermissions[models.RoleMember]) != 1 {
+		t.Errorf("member permissions changed to %v", models.RolePermissions[models.RoleMember])
+	}
+}
+
+func TestIsDemotion(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.RoleAdmin, models.RoleCurator, true},
+		{models.RoleAdmin, models.RoleMember, true},
+		{models.RoleCurator, models.RoleMember, true},
+		{models.RoleMember, models.RoleCurator, false},
+		{models.RoleCurator, models.RoleAdmin, false},
+		{models.RoleCurator, models.RoleCurator, false},
+	}
+	for _, c := range cases {
+		if got := models.IsDemotion(c.from, c.to); got != c.want {
+			t.Errorf("IsDemotion(%s, %s) = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestActorCanModify(t *testing.T) {
+	owner := uuid.New()
+	member := services.Actor{UserID: uuid.New(), Permissions: models.RolePermissions[models.RoleMember]}
+	curator := services.Actor{UserID: uuid.New(), Permissions: models.RolePermissions[models.RoleCurator]}
+
+	if !(services.Actor{UserID: owner}).CanModify(owner, models.PermMoviesUpdateAny) {
+		t.Error("owner without permissions cannot modify their own resource")
+	}
+	if member.CanModify(owner, models.PermMoviesUpdateAny) {
+		t.Error("member can modify someone else's resource")
+	}
+	if !curator.CanModify(owner, models.PermMoviesUpdateAny) || !curator.CanModify(owner, models.PermMoviesDeleteAny) {
+		t.Error("curator cannot modify someone else's resource")
+	}
+	if curator.CanModify(owner, models.PermUsersManage) {
+		t.Error("curator holds users:manage")
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	withPrincipal := func(p *middleware.Principal) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if p != nil {
+				middleware.SetPrincipal(c, p)
+			}
+			middleware.RequirePermission(models.PermMoviesCreate, models.PermMoviesUpdateAny)(c)
+		}
+	}
+	cases := map[string]struct {
+		principal *middleware.Principal
+		want      int
+	}{
+		"anonymous":    {nil, http.StatusUnauthorized},
+		"member":       {&middleware.Principal{Permissions: models.RolePermissions[models.RoleMember]}, http.StatusForbidden},
+		"curator":      {&middleware.Principal{Permissions: models.RolePermissions[models.RoleCurator]}, http.StatusOK},
+		"member grant": {&middleware.Principal{Permissions: models.EffectivePermissions(models.RoleMember, []string{models.PermMoviesUpdateAny})}, http.StatusOK},
+	}
+	for name, c := range cases {
+		if w := serve(withPrincipal(c.principal), newRequest(http.MethodPost, "")); w.Code != c.want {
+			t.Errorf("%s: status = %d, want %d", name, w.Code, c.want)
+		}
+	}
+}
+
+func TestLoginEmbedsRoleAndPermissions(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "claims@example.org")
+	if err := f.db.Model(user).Update("role", models.RoleCurator).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := f.db.Create(&models.UserPermission{UserID: user.ID, Permission: models.PermUsersManage}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	access, _ := f.login(t, user, testClient)
+	var claims tokens.AccessClaims
+	if _, err := f.keys.Parse(access, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != models.RoleCurator {
+		t.Errorf("roles = %v, want [curator]", claims.Roles)
+	}
+	got := append([]string{}, claims.Permissions...)
+	sort.Strings(got)
+	want := []string{models.PermMoviesCreate, models.PermMoviesDeleteAny, models.PermMoviesUpdateAny, models.PermUsersManage}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("permissions = %v, want %v", got, want)
+	}
+}
+
+func TestMovieOwnershipAndRoles(t *testing.T) {
+	f := newAuthFixture(t)
+	owner := f.createUser(t, "owner@example.org")
+	movies := services.NewMovieService(repository.NewMovieRepository(f.db))
+	newMovie := func() *models.Movie {
+		movie := &models.Movie{
+			Title:       "The Third Man",
+			Description: "A writer looks into the death of a friend in Vienna.",
+			Trailer:     "https://www.youtube.com/watch?v=AAAAAAAAAAA",
+			Actors:      []string{"Joseph Cotten"},
+			Genres:      []string{"Noir"},
+			UserID:      owner.ID,
+		}
+		if err := movies.Create(movie); err != nil {
+			t.Fatal(err)
+		}
+		return movie
+	}
+	actorFor := func(role string, id uuid.UUID) services.Actor {
+		return services.Actor{UserID: id, Permissions: models.RolePermissions[role]}
+	}
+
+	cases := map[string]struct {
+		actor   services.Actor
+		allowed bool
+	}{
+		"owner":          {actorFor(models.RoleMember, owner.ID), true},
+		"another member": {actorFor(models.RoleMember, uuid.New()), false},
+		"curator":        {actorFor(models.RoleCurator, uuid.New()), true},
+		"admin":          {actorFor(models.RoleAdmin, uuid.New()), true},
+	}
+	for name, c := range cases {
+		movie := newMovie()
+		update := *movie
+		update.Title = "Edited by " + name
+		update.UserID = c.actor.UserID
+		err := movies.Update(&update, c.actor)
+		if c.allowed != (err == nil) || (!c.allowed && !errors.Is(err, services.ErrForbidden)) {
+			t.Errorf("%s updating: err = %v, allowed %v", name, err, c.allowed)
+		}
+		stored, err := movies.GetByID(movie.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.UserID != owner.ID {
+			t.Errorf("%s updating: owner changed to %s", name, stored.UserID)
+		}
+
+		err = movies.Delete(movie.ID, c.actor)
+		if c.allowed != (err == nil) || (!c.allowed && !errors.Is(err, services.ErrForbidden)) {
+			t.Errorf("%s deleting: err = %v, allowed %v", name, err, c.allowed)
+		}
+		if _, err := movies.GetByID(movie.ID); (err == nil) == c.allowed {
+			t.Errorf("%s deleting: movie still there = %v", name, err == nil)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"eskalate-movie-api/internal/config"
+	"eskalate-movie-api/internal/handlers"
+	"eskalate-movie-api/internal/models"
+	"eskalate-movie-api/internal/revocation"
+	"eskalate-movie-api/internal/routes"
+	"eskalate-movie-api/internal/services"
+	"eskalate-movie-api/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// newRouter wires every route as main does. Requests refused by the
+// authentication and authorization middleware never reach db, so tests of
+// those may pass nil.
+func newRouter(db *gorm.DB, cfg *config.Config, ring *tokens.KeyRing, denylist revocation.Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	routes.RegisterRoutes(r, db, cfg, ring, &testMailer{}, denylist, services.NewJobScheduler(db, cfg))
+	return r
+}
+
+// signRoleToken signs an access token carrying the role's permissions.
+func signRoleToken(t *testing.T, ring *tokens.KeyRing, role string, emailVerified bool) string {
+	t.Helper()
+	claims := ring.NewAccessClaims(uuid.New(), time.Minute)
+	claims.Roles = []string{role}
+	claims.Permissions = models.RolePermissions[role]
+	claims.EmailVerified = emailVerified
+	claims.SessionID = uuid.NewString()
+	token, err := ring.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// call sends a request through r and decodes the response envelope.
+func call(t *testing.T, r http.Handler, method, path, token string) (int, handlers.BaseResponse) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var body handlers.BaseResponse
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s %s: response is not JSON: %s", method, path, w.Body.String())
+		}
+	}
+	return w.Code, body
+}
+
+// protectedRoutes need an authenticated caller.
+var protectedRoutes = []struct{ method, path string }{
+	{http.MethodPost, "/api/movies/"},
+	{http.MethodPut, "/api/movies/" + uuid.NewString()},
+	{http.MethodDelete, "/api/movies/" + uuid.NewString()},
+	{http.MethodGet, "/api/users/me"},
+	{http.MethodGet, "/api/auth/sessions"},
+	{http.MethodGet, "/api/auth/tokens"},
+	{http.MethodGet, "/api/admin/users"},
+	{http.MethodGet, "/api/admin/audit"},
+}
+
+func TestProtectedRoutesRequireAuthentication(t *testing.T) {
+	ring := newTestKeyRing(t)
+	r := newRouter(nil, loadConfig(t), ring, revocation.NewMemoryStore())
+	other := newTestKeyRing(t)
+	forged := signRoleToken(t, other, models.RoleAdmin, true)
+
+	for _, route := range protectedRoutes {
+		for name, token := range map[string]string{"no token": "", "malformed token": "not-a-jwt", "foreign key": forged} {
+			status, body := call(t, r, route.method, route.path, token)
+			if status != http.StatusUnauthorized || body.Success || body.Message != "Unauthorized" || len(body.Errors) == 0 {
+				t.Errorf("%s %s with %s: %d %+v, want a 401 envelope", route.method, route.path, name, status, body)
+			}
+		}
+	}
+}
+
+func TestRoutesEnforcePermissions(t *testing.T) {
+	ring := newTestKeyRing(t)
+	r := newRouter(nil, loadConfig(t), ring, revocation.NewMemoryStore())
+
+	cases := []struct {
+		name, method, path, token string
+	}{
+		{"member on admin routes", http.MethodGet, "/api/admin/users", signRoleToken(t, ring, models.RoleMember, true)},
+		{"curator on admin routes", http.MethodGet, "/api/admin/audit", signRoleToken(t, ring, models.RoleCurator, true)},
+		{"unverified member creating a movie", http.MethodPost, "/api/movies/", signRoleToken(t, ring, models.RoleMember, false)},
+		{"token without permissions creating a movie", http.MethodPost, "/api/movies/", signRoleToken(t, ring, "none", true)},
+	}
+	for _, c := range cases {
+		status, body := call(t, r, c.method, c.path, c.token)
+		if status != http.StatusForbidden || body.Success || body.Message != "Forbidden" || len(body.Errors) == 0 {
+			t.Errorf("%s: %d %+v, want a 403 envelope", c.name, status, body)
+		}
+	}
+}
+
+func TestAuthenticatedRequestReachesHandlerAsCaller(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "caller@example.org")
+	access, _ := f.login(t, user, testClient)
+	r := newRouter(f.db, f.cfg, f.keys, f.denylist)
+
+	status, body := call(t, r, http.MethodGet, "/api/users/me", access)
+	if status != http.StatusOK || !body.Success {
+		t.Fatalf("GET /api/users/me: %d %+v", status, body)
+	}
+	profile, _ := body.Object.(map[string]interface{})
+	if profile["id"] != user.ID.String() {
+		t.Errorf("profile id = %v, want %s", profile["id"], user.ID)
+	}
+	// Public routes need no token
+	if status, body := call(t, r, http.MethodGet, "/api/movies/", ""); status != http.StatusOK || !body.Success {
+		t.Errorf("GET /api/movies/: %d %+v", status, body)
+	}
+}
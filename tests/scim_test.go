@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"eskalate-movie-api/internal/scim"
+)
+
+func TestSCIMParseFilter(t *testing.T) {
+	f, err := scim.ParseFilter(`userName eq "jane@example.org" and (active eq true or not (displayName sw "J"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	and, ok := f.(*scim.Logical)
+	if !ok || and.Operator != "and" {
+		t.Fatalf("top level = %#v", f)
+	}
+	if c, ok := and.Left.(*scim.Comparison); !ok || c.Attribute != "userName" || c.Operator != "eq" || c.Value != "jane@example.org" {
+		t.Fatalf("left = %#v", and.Left)
+	}
+	or, ok := and.Right.(*scim.Logical)
+	if !ok || or.Operator != "or" {
+		t.Fatalf("right = %#v", and.Right)
+	}
+	if c, ok := or.Left.(*scim.Comparison); !ok || c.Value != true {
+		t.Fatalf("active = %#v", or.Left)
+	}
+	if _, ok := or.Right.(*scim.Not); !ok {
+		t.Fatalf("not = %#v", or.Right)
+	}
+
+	// Operators and keywords are case-insensitive, schema prefixes are dropped
+	f, err = scim.ParseFilter(`urn:ietf:params:scim:schemas:core:2.0:User:userName EQ "a" OR externalId Pr`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := f.(*scim.Logical).Left.(*scim.Comparison); c.Attribute != "userName" || c.Operator != "eq" {
+		t.Fatalf("prefixed = %#v", c)
+	}
+
+	f, err = scim.ParseFilter(`emails[type eq "work" and value co "@example.org"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vp, ok := f.(*scim.ValuePath); !ok || vp.Attribute != "emails" {
+		t.Fatalf("value path = %#v", f)
+	}
+
+	invalid := []string{
+		"", `userName`, `userName eq`, `userName xx "a"`, `userName eq "a" and`,
+		`(userName eq "a"`, `userName eq "unterminated`, `emails[type eq "work"`, `userName eq bare`,
+	}
+	for _, s := range invalid {
+		_, err := scim.ParseFilter(s)
+		var scimErr *scim.Error
+		if !errors.As(err, &scimErr) || scimErr.ScimType != scim.ErrorInvalidFilter || scimErr.StatusCode() != http.StatusBadRequest {
+			t.Errorf("ParseFilter(%q) err = %v", s, err)
+		}
+	}
+}
+
+func TestSCIMMatches(t *testing.T) {
+	attrs := map[string]interface{}{"id": "curator", "displayName": "Curator", "active": true}
+	cases := map[string]bool{
+		`displayName eq "curator"`:                  true,
+		`DISPLAYNAME ne "admin"`:                    true,
+		`displayName sw "cur" and id ew "tor"`:      true,
+		`displayName eq "admin" or id co "ura"`:     true,
+		`not (id eq "curator")`:                     false,
+		`active eq true`:                            true,
+		`active eq "true"`:                          false,
+		`externalId pr`:                             false,
+		`members[value eq "x"]`:                     false,
+		`displayName gt "a" and displayName lt "d"`: true,
+		`id eq "curator" and not (displayName pr)`:  false,
+	}
+	for s, want := range cases {
+		f, err := scim.ParseFilter(s)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q): %v", s, err)
+		}
+		if got := scim.Matches(f, attrs); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestSCIMParsePath(t *testing.T) {
+	path, err := scim.ParsePath(`emails[type eq "work"].value`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Attribute != "emails" || path.SubAttribute != "value" || path.Filter == nil {
+		t.Fatalf("path = %#v", path)
+	}
+	path, err = scim.ParsePath("urn:ietf:params:scim:schemas:core:2.0:User:name.givenName")
+	if err != nil || path.Attribute != "name" || path.SubAttribute != "givenName" {
+		t.Fatalf("path = %#v, err = %v", path, err)
+	}
+	// Extension attributes are kept whole, dots and all
+	ext := "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department"
+	if path, err = scim.ParsePath(ext); err != nil || path.Attribute != ext {
+		t.Fatalf("path = %#v, err = %v", path, err)
+	}
+	for _, s := range []string{"", "1name", "name.", "emails[type eq", "emails[type eq \"work\"]value"} {
+		if _, err := scim.ParsePath(s); err == nil {
+			t.Errorf("ParsePath(%q) succeeded", s)
+		}
+	}
+}
+
+func TestSCIMApplyUserPatch(t *testing.T) {
+	active := true
+	user := &scim.User{UserName: "jane@example.org", DisplayName: "Jane", ExternalID: "42", Active: &active}
+	var req scim.PatchRequest
+	// Operations as sent by common identity providers: capitalised ops,
+	// string booleans and path-less values
+	body := `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[
+		{"op":"Replace","path":"userName","value":"jane.doe@example.org"},
+		{"op":"replace","value":{"displayName":"Jane Doe","name.givenName":"Jane"}},
+		{"op":"add","path":"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department","value":"IT"},
+		{"op":"replace","path":"emails[type eq \"work\"].value","value":"ignored@example.org"},
+		{"op":"remove","path":"externalId"},
+		{"op":"Replace","path":"active","value":"False"}
+	]}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	if err := scim.ApplyUserPatch(user, req.Operations); err != nil {
+		t.Fatalf("ApplyUserPatch: %v", err)
+	}
+	if user.UserName != "jane.doe@example.org" || user.DisplayName != "Jane Doe" || user.ExternalID != "" {
+		t.Fatalf("user = %+v", user)
+	}
+	if user.Name == nil || user.Name.GivenName != "Jane" {
+		t.Fatalf("name = %+v", user.Name)
+	}
+	if user.Active == nil || *user.Active {
+		t.Fatal("active was not cleared")
+	}
+
+	invalid := map[string]string{
+		"unknown op":       `[{"op":"move","path":"userName","value":"x"}]`,
+		"remove userName":  `[{"op":"remove","path":"userName"}]`,
+		"remove no path":   `[{"op":"remove"}]`,
+		"bad active":       `[{"op":"replace","path":"active","value":"maybe"}]`,
+		"wrong value type": `[{"op":"replace","path":"displayName","value":7}]`,
+		"no path, scalar":  `[{"op":"replace","value":"x"}]`,
+	}
+	for name, ops := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var operations []scim.PatchOperation
+			if err := json.Unmarshal([]byte(ops), &operations); err != nil {
+				t.Fatal(err)
+			}
+			var scimErr *scim.Error
+			if err := scim.ApplyUserPatch(&scim.User{UserName: "a@b.c"}, operations); !errors.As(err, &scimErr) || scimErr.StatusCode() != http.StatusBadRequest {
+				t.Fatalf("err = %v", err)
+			}
+		})
+	}
+}
+
+func TestSCIMMemberValues(t *testing.T) {
+	values, err := scim.MemberValues(json.RawMessage(`[{"value":"a"},{"value":"b","display":"B"}]`))
+	if err != nil || len(values) != 2 || values[1] != "b" {
+		t.Fatalf("values = %v, err = %v", values, err)
+	}
+	if values, err = scim.MemberValues(json.RawMessage(`{"value":"a"}`)); err != nil || len(values) != 1 {
+		t.Fatalf("single member: values = %v, err = %v", values, err)
+	}
+	if _, err := scim.MemberValues(json.RawMessage(`[{"display":"no value"}]`)); err == nil {
+		t.Fatal("member without a value accepted")
+	}
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"eskalate-movie-api/internal/services"
+
+	"github.com/google/uuid"
+)
+
+var (
+	laptop = services.ClientInfo{UserAgent: "Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0", IPAddress: "198.51.100.7"}
+	phone  = services.ClientInfo{UserAgent: "Mozilla/5.0 (iPhone) Mobile Safari/604.1", IPAddress: "203.0.113.9"}
+)
+
+func TestListSessions(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "devices@example.org")
+	laptopAccess, laptopRefresh := f.login(t, user, laptop)
+	phoneAccess, _ := f.login(t, user, phone)
+	laptopSession, phoneSession := f.sessionID(t, laptopAccess), f.sessionID(t, phoneAccess)
+
+	// Refreshing from another network keeps the session and records the client
+	moved := services.ClientInfo{UserAgent: laptop.UserAgent, IPAddress: "192.0.2.44"}
+	if _, _, err := f.auth.RefreshAccessToken(laptopRefresh, moved); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := f.auth.ListSessions(user.ID, phoneSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("%d sessions listed, want 2: %+v", len(sessions), sessions)
+	}
+	first, second := sessions[0], sessions[1]
+	if first.ID != laptopSession || second.ID != phoneSession {
+		t.Fatalf("sessions = %+v, want the laptop's, most recently used, first", sessions)
+	}
+	if first.UserAgent != moved.UserAgent || first.IPAddress != moved.IPAddress || first.Current {
+		t.Errorf("laptop session = %+v", first)
+	}
+	if !first.StartedAt.Before(first.LastUsedAt) {
+		t.Errorf("laptop session started %s, last used %s: refresh restarted it", first.StartedAt, first.LastUsedAt)
+	}
+	if second.UserAgent != phone.UserAgent || second.IPAddress != phone.IPAddress || !second.Current {
+		t.Errorf("phone session = %+v", second)
+	}
+
+	// Sessions of other users are not listed
+	other := f.createUser(t, "someone-else@example.org")
+	if sessions, err := f.auth.ListSessions(other.ID, uuid.Nil); err != nil || len(sessions) != 0 {
+		t.Errorf("other user's sessions = %+v, %v", sessions, err)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "revoke-one@example.org")
+	other := f.createUser(t, "not-mine@example.org")
+	keptAccess, keptRefresh := f.login(t, user, laptop)
+	endedAccess, endedRefresh := f.login(t, user, phone)
+	ended := f.sessionID(t, endedAccess)
+
+	if err := f.auth.RevokeSession(other.ID, ended); !errors.Is(err, services.ErrSessionNotFound) {
+		t.Errorf("another user's session: err = %v, want ErrSessionNotFound", err)
+	}
+	if err := f.auth.RevokeSession(user.ID, ended); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.auth.RevokeSession(user.ID, ended); !errors.Is(err, services.ErrSessionNotFound) {
+		t.Errorf("revoking twice: err = %v, want ErrSessionNotFound", err)
+	}
+	if err := f.auth.RevokeSession(user.ID, uuid.New()); !errors.Is(err, services.ErrSessionNotFound) {
+		t.Errorf("unknown session: err = %v, want ErrSessionNotFound", err)
+	}
+
+	if f.authorized(t, endedAccess) {
+		t.Error("revoked session's access token accepted")
+	}
+	if _, _, err := f.auth.RefreshAccessToken(endedRefresh, phone); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("revoked session's refresh token: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if !f.authorized(t, keptAccess) {
+		t.Error("other session's access token rejected")
+	}
+	if _, _, err := f.auth.RefreshAccessToken(keptRefresh, laptop); err != nil {
+		t.Errorf("other session's refresh token: %v", err)
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "everywhere@example.org")
+	current, currentRefresh := f.login(t, user, laptop)
+	var others []string
+	for i := 0; i < 2; i++ {
+		access, refresh := f.login(t, user, phone)
+		// A rotated session still counts once
+		if _, _, err := f.auth.RefreshAccessToken(refresh, phone); err != nil {
+			t.Fatal(err)
+		}
+		others = append(others, access)
+	}
+
+	ended, err := f.auth.RevokeAllSessions(user.ID, f.sessionID(t, current))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ended != 2 {
+		t.Errorf("sessions ended = %d, want 2", ended)
+	}
+	for i, access := range others {
+		if f.authorized(t, access) {
+			t.Errorf("session %d's access token accepted after logging out everywhere else", i)
+		}
+	}
+	if !f.authorized(t, current) {
+		t.Error("the current session was signed out")
+	}
+
+	// Without a current session, every session ends
+	if ended, err := f.auth.RevokeAllSessions(user.ID, uuid.Nil); err != nil || ended != 1 {
+		t.Errorf("logging out everywhere: ended = %d, %v, want 1", ended, err)
+	}
+	if _, _, err := f.auth.RefreshAccessToken(currentRefresh, laptop); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("current refresh token after logging out everywhere: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if sessions, err := f.auth.ListSessions(user.ID, uuid.Nil); err != nil || len(sessions) != 0 {
+		t.Errorf("sessions left = %+v, %v", sessions, err)
+	}
+}
+
+func TestNewDeviceLoginNotification(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.createUser(t, "notify@example.org")
+
+	f.login(t, user, laptop)
+	if sent := f.mail.sentTo(user.Email); len(sent) != 0 {
+		t.Fatalf("first login notified: %+v", sent)
+	}
+	f.login(t, user, services.ClientInfo{UserAgent: laptop.UserAgent, IPAddress: "192.0.2.80"})
+	if sent := f.mail.sentTo(user.Email); len(sent) != 0 {
+		t.Fatalf("login from a known device notified: %+v", sent)
+	}
+
+	f.login(t, user, phone)
+	sent := f.mail.sentTo(user.Email)
+	if len(sent) != 1 {
+		t.Fatalf("%d notifications for a new device, want 1", len(sent))
+	}
+	for _, want := range []string{phone.UserAgent, phone.IPAddress} {
+		if !strings.Contains(sent[0].Body, want) {
+			t.Errorf("notification does not mention %q:\n%s", want, sent[0].Body)
+		}
+	}
+
+	f.login(t, user, phone)
+	if sent := f.mail.sentTo(user.Email); len(sent) != 1 {
+		t.Errorf("second login from the new device notified again")
+	}
+}